@@ -0,0 +1,554 @@
+package statepro
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rendis/statepro/v3/internal/util"
+	"github.com/rendis/statepro/v3/theoretical"
+)
+
+const (
+	IssueUnreachableReality            ValidationIssueCode = "unreachable_reality"
+	IssueDeadEndReality                ValidationIssueCode = "dead_end_reality"
+	IssueAlwaysCycle                   ValidationIssueCode = "always_cycle"
+	IssueSuperpositionWithoutObservers ValidationIssueCode = "superposition_without_observers"
+	IssueUntargetedUniverse            ValidationIssueCode = "untargeted_universe"
+)
+
+// AnalysisReport is the result of AnalyzeQuantumMachine.
+type AnalysisReport struct {
+	// Findings lists every finding in a deterministic order.
+	Findings []ValidationIssue `json:"findings"`
+}
+
+// Err returns a *ValidationError with the findings whose severity is at least minSeverity,
+// or nil if there are none. Use it to gate merges or deployments:
+//
+//	if err := report.Err(statepro.ValidationSeverityWarning); err != nil { ... }
+func (r *AnalysisReport) Err(minSeverity ValidationSeverity) error {
+	if r == nil {
+		return nil
+	}
+
+	collector := &validationIssueCollector{}
+	for _, finding := range r.Findings {
+		if severityRank(finding.Severity) >= severityRank(minSeverity) {
+			collector.issues = append(collector.issues, finding)
+		}
+	}
+	return collector.err()
+}
+
+func severityRank(severity ValidationSeverity) int {
+	switch severity {
+	case ValidationSeverityError:
+		return 2
+	case ValidationSeverityWarning:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// AnalyzeQuantumMachine runs a static analysis over a definition that goes beyond validation.
+// It reports:
+//   - realities unreachable from the machine Initials and universe Initial realities;
+//   - non-final realities with no path to any final reality of their universe;
+//   - Always chains that can cycle (error when every transition in the cycle is unconditional);
+//   - universe targets that start a superposition that can never collapse because no reality defines observers;
+//   - universes that are neither initial nor targeted by any transition.
+//
+// The model is expected to be valid (see ValidateQuantumMachineDefinition); unresolved references are ignored.
+// The analysis over-approximates runtime behaviour: conditions and observers are assumed to be able to
+// return both true and false.
+func AnalyzeQuantumMachine(model *theoretical.QuantumMachineModel) (*AnalysisReport, error) {
+	if model == nil {
+		return nil, fmt.Errorf("source model cannot be nil")
+	}
+
+	a := &machineAnalysis{
+		model:     model,
+		collector: &validationIssueCollector{},
+		graph:     buildRealityGraph(model),
+	}
+
+	a.checkUntargetedUniverses()
+	a.checkReachability()
+	a.checkDeadEnds()
+	a.checkAlwaysCycles()
+	a.checkSuperpositionObservers()
+
+	return &AnalysisReport{Findings: a.collector.issues}, nil
+}
+
+type machineAnalysis struct {
+	model     *theoretical.QuantumMachineModel
+	collector *validationIssueCollector
+	graph     *realityGraph
+}
+
+// realityNode identifies a reality of a universe.
+type realityNode struct {
+	universe string
+	reality  string
+}
+
+// realityGraph is the over-approximated transition graph of a machine.
+type realityGraph struct {
+	// entries are the realities a universe can be established on when started or targeted.
+	entries map[realityNode]bool
+
+	// entered are the universes that are started or targeted.
+	entered map[string]bool
+
+	// edges links a reality to every reality it can lead to.
+	edges map[realityNode]map[realityNode]bool
+
+	// exits links a reality to the realities of its own universe it can move to: internal targets and, for
+	// superposition transitions, the observed realities the universe can collapse on. Notify transitions and
+	// targets in other universes do not move the universe out of the reality.
+	exits map[realityNode]map[realityNode]bool
+
+	// superpositionSources are the universes that can fall into superposition after one of their transitions.
+	superpositionSources map[string]bool
+
+	// targetedUniverses are the universes referenced by the machine Initials or by a transition of another universe.
+	targetedUniverses map[string]bool
+}
+
+func buildRealityGraph(model *theoretical.QuantumMachineModel) *realityGraph {
+	g := &realityGraph{
+		entries:              map[realityNode]bool{},
+		entered:              map[string]bool{},
+		edges:                map[realityNode]map[realityNode]bool{},
+		exits:                map[realityNode]map[realityNode]bool{},
+		superpositionSources: map[string]bool{},
+		targetedUniverses:    map[string]bool{},
+	}
+
+	for _, initial := range model.Initials {
+		kind, universeID, realityID, ok := parseStateReference(initial)
+		if !ok || kind == referenceReality {
+			continue
+		}
+		g.targetedUniverses[universeID] = true
+		for _, node := range externalTargetNodes(model, kind, universeID, realityID) {
+			g.entries[node] = true
+			g.entered[node.universe] = true
+		}
+	}
+
+	forEachTransition(model, func(universeID string, realityID string, _ string, transition *theoretical.TransitionModel) {
+		from := realityNode{universe: universeID, reality: realityID}
+		notifies := transition.IsNotification()
+		fansOut := !notifies && isSuperpositionTransition(transition)
+
+		for _, target := range transition.Targets {
+			kind, targetUniverseID, targetRealityID, ok := parseStateReference(target)
+			if !ok {
+				continue
+			}
+
+			if kind == referenceReality {
+				to := realityNode{universe: universeID, reality: targetRealityID}
+				addEdge(g.edges, from, to)
+				if !notifies {
+					addEdge(g.exits, from, to)
+				}
+				continue
+			}
+
+			if targetUniverseID != universeID {
+				g.targetedUniverses[targetUniverseID] = true
+			}
+			for _, node := range externalTargetNodes(model, kind, targetUniverseID, targetRealityID) {
+				addEdge(g.edges, from, node)
+				g.entered[node.universe] = true
+			}
+		}
+
+		if fansOut {
+			g.superpositionSources[universeID] = true
+			// the source universe collapses on any reality whose observers approve
+			for _, node := range observedRealities(model, universeID) {
+				addEdge(g.edges, from, node)
+				addEdge(g.exits, from, node)
+			}
+		}
+	})
+
+	return g
+}
+
+func addEdge(edges map[realityNode]map[realityNode]bool, from, to realityNode) {
+	if edges[from] == nil {
+		edges[from] = map[realityNode]bool{}
+	}
+	edges[from][to] = true
+}
+
+// externalTargetNodes returns the realities a universe can be established on when referenced from outside.
+func externalTargetNodes(model *theoretical.QuantumMachineModel, kind stateReferenceType, universeID, realityID string) []realityNode {
+	universe := model.Universes[universeID]
+	if universe == nil {
+		return nil
+	}
+
+	if kind == referenceUniverseReality {
+		if _, ok := universe.Realities[realityID]; !ok {
+			return nil
+		}
+		return []realityNode{{universe: universeID, reality: realityID}}
+	}
+
+	// U:<universe>: started on its initial reality, or in superposition collapsing on observed realities
+	nodes := observedRealities(model, universeID)
+	if universe.Initial != nil {
+		if _, ok := universe.Realities[*universe.Initial]; ok {
+			nodes = append(nodes, realityNode{universe: universeID, reality: *universe.Initial})
+		}
+	}
+	return nodes
+}
+
+// observedRealities returns the realities of a universe that define observers, sorted by id.
+func observedRealities(model *theoretical.QuantumMachineModel, universeID string) []realityNode {
+	universe := model.Universes[universeID]
+	if universe == nil {
+		return nil
+	}
+
+	var nodes []realityNode
+	for _, realityID := range util.SortedKeys(universe.Realities) {
+		if reality := universe.Realities[realityID]; reality != nil && len(reality.Observers) > 0 {
+			nodes = append(nodes, realityNode{universe: universeID, reality: realityID})
+		}
+	}
+	return nodes
+}
+
+// isSuperpositionTransition returns true when executing the transition puts its universe in superposition:
+// more than one target, or a single target outside the universe.
+func isSuperpositionTransition(transition *theoretical.TransitionModel) bool {
+	if len(transition.Targets) > 1 {
+		return true
+	}
+	if len(transition.Targets) == 1 {
+		kind, _, _, ok := parseStateReference(transition.Targets[0])
+		return ok && kind != referenceReality
+	}
+	return false
+}
+
+// forEachTransition calls fn for every non-nil transition, in a deterministic order.
+// transitionPointer is the JSON Pointer of the transition.
+func forEachTransition(model *theoretical.QuantumMachineModel, fn func(universeID, realityID, transitionPointer string, transition *theoretical.TransitionModel)) {
+	for _, universeID := range util.SortedKeys(model.Universes) {
+		universe := model.Universes[universeID]
+		if universe == nil {
+			continue
+		}
+		for _, realityID := range util.SortedKeys(universe.Realities) {
+			reality := universe.Realities[realityID]
+			if reality == nil {
+				continue
+			}
+			realityPath := util.JSONPointer("universes", universeID, "realities", realityID)
+
+			for i, transition := range reality.Always {
+				if transition != nil {
+					fn(universeID, realityID, realityPath+util.JSONPointer("always", strconv.Itoa(i)), transition)
+				}
+			}
+
+			// final realities ignore On handlers at runtime
+			if theoretical.IsFinalState(reality.Type) {
+				continue
+			}
+
+			for _, eventName := range util.SortedKeys(reality.On) {
+				for i, transition := range reality.On[eventName] {
+					if transition != nil {
+						fn(universeID, realityID, realityPath+util.JSONPointer("on", eventName, strconv.Itoa(i)), transition)
+					}
+				}
+			}
+		}
+	}
+}
+
+func (a *machineAnalysis) forEachReality(fn func(node realityNode, reality *theoretical.RealityModel)) {
+	for _, universeID := range util.SortedKeys(a.model.Universes) {
+		universe := a.model.Universes[universeID]
+		if universe == nil {
+			continue
+		}
+		for _, realityID := range util.SortedKeys(universe.Realities) {
+			if reality := universe.Realities[realityID]; reality != nil {
+				fn(realityNode{universe: universeID, reality: realityID}, reality)
+			}
+		}
+	}
+}
+
+func (a *machineAnalysis) checkUntargetedUniverses() {
+	for _, universeID := range util.SortedKeys(a.model.Universes) {
+		if a.model.Universes[universeID] == nil || a.graph.targetedUniverses[universeID] {
+			continue
+		}
+		a.collector.addWithSeverity(
+			ValidationSeverityWarning, IssueUntargetedUniverse, util.JSONPointer("universes", universeID),
+			"universe '%s' is not referenced by the machine initials nor targeted by any other universe", universeID,
+		)
+	}
+}
+
+func (a *machineAnalysis) checkReachability() {
+	reachable := map[realityNode]bool{}
+	var queue []realityNode
+	for _, node := range sortedNodes(a.graph.entries) {
+		reachable[node] = true
+		queue = append(queue, node)
+	}
+
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		for _, next := range sortedNodes(a.graph.edges[node]) {
+			if !reachable[next] {
+				reachable[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+
+	a.forEachReality(func(node realityNode, _ *theoretical.RealityModel) {
+		if reachable[node] {
+			return
+		}
+		a.collector.addWithSeverity(
+			ValidationSeverityWarning, IssueUnreachableReality, util.JSONPointer("universes", node.universe, "realities", node.reality),
+			"reality '%s' in universe '%s' is unreachable from the machine initials", node.reality, node.universe,
+		)
+	})
+}
+
+func (a *machineAnalysis) checkDeadEnds() {
+	// reverse reachability from final realities
+	reverse := map[realityNode][]realityNode{}
+	for from, targets := range a.graph.exits {
+		for to := range targets {
+			reverse[to] = append(reverse[to], from)
+		}
+	}
+
+	canFinish := map[realityNode]bool{}
+	var queue []realityNode
+	a.forEachReality(func(node realityNode, reality *theoretical.RealityModel) {
+		if theoretical.IsFinalState(reality.Type) {
+			canFinish[node] = true
+			queue = append(queue, node)
+		}
+	})
+
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		for _, prev := range reverse[node] {
+			if !canFinish[prev] {
+				canFinish[prev] = true
+				queue = append(queue, prev)
+			}
+		}
+	}
+
+	a.forEachReality(func(node realityNode, reality *theoretical.RealityModel) {
+		if canFinish[node] {
+			return
+		}
+		a.collector.addWithSeverity(
+			ValidationSeverityWarning, IssueDeadEndReality, util.JSONPointer("universes", node.universe, "realities", node.reality),
+			"reality '%s' in universe '%s' has no path to any final reality", node.reality, node.universe,
+		)
+	})
+}
+
+// checkAlwaysCycles reports cycles formed by Always transitions with a single internal target.
+// At runtime such a cascade fails with "cyclic transition detected" once a reality is visited twice.
+func (a *machineAnalysis) checkAlwaysCycles() {
+	for _, universeID := range util.SortedKeys(a.model.Universes) {
+		universe := a.model.Universes[universeID]
+		if universe == nil {
+			continue
+		}
+
+		edges := map[string][]string{}
+		// firstTargets holds the target of the first always transition of a reality when that transition is
+		// unconditional with a single internal target: the runtime always takes it
+		firstTargets := map[string]string{}
+		for _, realityID := range util.SortedKeys(universe.Realities) {
+			reality := universe.Realities[realityID]
+			if reality == nil {
+				continue
+			}
+			for i, transition := range reality.Always {
+				if transition == nil || transition.IsNotification() || len(transition.Targets) != 1 {
+					continue
+				}
+				kind, _, target, ok := parseStateReference(transition.Targets[0])
+				if !ok || kind != referenceReality {
+					continue
+				}
+				if _, exists := universe.Realities[target]; !exists {
+					continue
+				}
+				edges[realityID] = append(edges[realityID], target)
+				if i == 0 && transition.Condition == nil && len(transition.Conditions) == 0 {
+					firstTargets[realityID] = target
+				}
+			}
+		}
+
+		for _, component := range stronglyConnectedComponents(util.SortedKeys(universe.Realities), edges) {
+			if len(component) == 1 && !slices.Contains(edges[component[0]], component[0]) {
+				continue
+			}
+
+			severity := ValidationSeverityWarning
+			qualifier := "can cycle"
+			if isUnconditionalCycle(component, firstTargets) {
+				severity = ValidationSeverityError
+				qualifier = "always cycles"
+			}
+
+			a.collector.addWithSeverity(
+				severity, IssueAlwaysCycle, util.JSONPointer("universes", universeID, "realities", component[0], "always"),
+				"always transitions in universe '%s' %s between realities [%s]", universeID, qualifier, strings.Join(component, ", "),
+			)
+		}
+	}
+}
+
+// isUnconditionalCycle returns true when the first always transition of every reality of the component is
+// unconditional and stays inside the component, so the cascade is guaranteed to loop.
+func isUnconditionalCycle(component []string, firstTargets map[string]string) bool {
+	inComponent := map[string]bool{}
+	for _, realityID := range component {
+		inComponent[realityID] = true
+	}
+
+	for _, realityID := range component {
+		// the runtime takes the first approved transition, so only the first always entry matters
+		target, ok := firstTargets[realityID]
+		if !ok || !inComponent[target] {
+			return false
+		}
+	}
+	return true
+}
+
+// checkSuperpositionObservers reports the transitions that put a universe in superposition when none of its
+// realities define observers, so it can never collapse:
+//   - targeting a universe (U:<universe>) that has no initial reality, which is started in superposition;
+//   - with several targets or an external target, which leave their own universe in superposition.
+func (a *machineAnalysis) checkSuperpositionObservers() {
+	forEachTransition(a.model, func(universeID, _, transitionPointer string, transition *theoretical.TransitionModel) {
+		if transition.IsNotification() {
+			return
+		}
+
+		if isSuperpositionTransition(transition) && len(observedRealities(a.model, universeID)) == 0 {
+			a.collector.addWithSeverity(
+				ValidationSeverityWarning, IssueSuperpositionWithoutObservers, transitionPointer,
+				"transition to [%s] puts universe '%s' in superposition but none of its realities define observers, so it can never collapse",
+				strings.Join(transition.Targets, ", "), universeID,
+			)
+		}
+
+		for i, target := range transition.Targets {
+			kind, targetUniverseID, _, ok := parseStateReference(target)
+			if !ok || kind != referenceUniverse {
+				continue
+			}
+			targetUniverse := a.model.Universes[targetUniverseID]
+			if targetUniverse == nil || targetUniverse.Initial != nil || len(observedRealities(a.model, targetUniverseID)) > 0 {
+				continue
+			}
+			a.collector.addWithSeverity(
+				ValidationSeverityWarning, IssueSuperpositionWithoutObservers, transitionPointer+util.JSONPointer("targets", strconv.Itoa(i)),
+				"target '%s' starts universe '%s' in superposition but none of its realities define observers, so it can never collapse",
+				target, targetUniverseID,
+			)
+		}
+	})
+}
+
+// stronglyConnectedComponents returns the SCCs of the graph (Tarjan), each sorted, in a deterministic order.
+func stronglyConnectedComponents(nodes []string, edges map[string][]string) [][]string {
+	index := 0
+	indices := map[string]int{}
+	lowLink := map[string]int{}
+	onStack := map[string]bool{}
+	var stack []string
+	var components [][]string
+
+	var strongConnect func(v string)
+	strongConnect = func(v string) {
+		indices[v] = index
+		lowLink[v] = index
+		index++
+		stack = append(stack, v)
+		onStack[v] = true
+
+		for _, w := range edges[v] {
+			if _, visited := indices[w]; !visited {
+				strongConnect(w)
+				lowLink[v] = min(lowLink[v], lowLink[w])
+			} else if onStack[w] {
+				lowLink[v] = min(lowLink[v], indices[w])
+			}
+		}
+
+		if lowLink[v] == indices[v] {
+			var component []string
+			for {
+				w := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[w] = false
+				component = append(component, w)
+				if w == v {
+					break
+				}
+			}
+			sort.Strings(component)
+			components = append(components, component)
+		}
+	}
+
+	for _, v := range nodes {
+		if _, visited := indices[v]; !visited {
+			strongConnect(v)
+		}
+	}
+
+	sort.Slice(components, func(i, j int) bool { return components[i][0] < components[j][0] })
+	return components
+}
+
+func sortedNodes(set map[realityNode]bool) []realityNode {
+	nodes := make([]realityNode, 0, len(set))
+	for node := range set {
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].universe != nodes[j].universe {
+			return nodes[i].universe < nodes[j].universe
+		}
+		return nodes[i].reality < nodes[j].reality
+	})
+	return nodes
+}
@@ -0,0 +1,280 @@
+package statepro
+
+import (
+	"os"
+	"testing"
+)
+
+func analyzeFromJSON(t *testing.T, payload string) *AnalysisReport {
+	t.Helper()
+
+	model, err := DeserializeQuantumMachineFromBinary([]byte(payload))
+	if err != nil {
+		t.Fatalf("unexpected deserialize error: %v", err)
+	}
+
+	report, err := AnalyzeQuantumMachine(model)
+	if err != nil {
+		t.Fatalf("unexpected analysis error: %v", err)
+	}
+	return report
+}
+
+func findingsByCodeAndPath(report *AnalysisReport) map[string]ValidationIssue {
+	findings := map[string]ValidationIssue{}
+	for _, finding := range report.Findings {
+		findings[string(finding.Code)+" "+finding.Path] = finding
+	}
+	return findings
+}
+
+func TestAnalyzeQuantumMachine_Findings(t *testing.T) {
+	report := analyzeFromJSON(t, `{
+		"id":"machine",
+		"canonicalName":"machine",
+		"version":"1.0.0",
+		"initials":["U:main"],
+		"universes":{
+			"main":{
+				"id":"main",
+				"canonicalName":"main",
+				"version":"1.0.0",
+				"initial":"A",
+				"realities":{
+					"A":{"id":"A","type":"transition","on":{"go":[{"targets":["B"]}],"stop":[{"targets":["END"]}],"park":[{"targets":["U:parked"]}]}},
+					"B":{"id":"B","type":"transition","always":[{"targets":["C"]}]},
+					"C":{"id":"C","type":"transition","always":[{"targets":["B"]}]},
+					"ORPHAN":{"id":"ORPHAN","type":"transition","on":{"go":[{"targets":["END"]}]}},
+					"END":{"id":"END","type":"final"}
+				}
+			},
+			"parked":{
+				"id":"parked",
+				"canonicalName":"parked",
+				"version":"1.0.0",
+				"realities":{"P":{"id":"P","type":"final"}}
+			},
+			"lonely":{
+				"id":"lonely",
+				"canonicalName":"lonely",
+				"version":"1.0.0",
+				"initial":"L",
+				"realities":{"L":{"id":"L","type":"final"}}
+			}
+		}
+	}`)
+
+	findings := findingsByCodeAndPath(report)
+	expected := map[string]ValidationSeverity{
+		"unreachable_reality /universes/main/realities/ORPHAN":                            ValidationSeverityWarning,
+		"unreachable_reality /universes/parked/realities/P":                               ValidationSeverityWarning,
+		"unreachable_reality /universes/lonely/realities/L":                               ValidationSeverityWarning,
+		"dead_end_reality /universes/main/realities/B":                                    ValidationSeverityWarning,
+		"dead_end_reality /universes/main/realities/C":                                    ValidationSeverityWarning,
+		"always_cycle /universes/main/realities/B/always":                                 ValidationSeverityError,
+		"superposition_without_observers /universes/main/realities/A/on/park/0/targets/0": ValidationSeverityWarning,
+		"superposition_without_observers /universes/main/realities/A/on/park/0":           ValidationSeverityWarning,
+		"untargeted_universe /universes/lonely":                                           ValidationSeverityWarning,
+	}
+
+	if len(findings) != len(expected) {
+		t.Fatalf("expected %d findings, got %+v", len(expected), report.Findings)
+	}
+	for key, severity := range expected {
+		finding, ok := findings[key]
+		if !ok {
+			t.Fatalf("missing finding %q, got %+v", key, report.Findings)
+		}
+		if finding.Severity != severity {
+			t.Fatalf("finding %q: expected severity %q, got %q", key, severity, finding.Severity)
+		}
+	}
+
+	if err := report.Err(ValidationSeverityError); err == nil {
+		t.Fatal("expected error-level findings")
+	} else if vErr, _ := AsValidationError(err); len(vErr.Issues) != 1 || vErr.Issues[0].Code != IssueAlwaysCycle {
+		t.Fatalf("expected only the always cycle at error level, got %v", err)
+	}
+}
+
+func TestAnalyzeQuantumMachine_ConditionalAlwaysCycle(t *testing.T) {
+	report := analyzeFromJSON(t, `{
+		"id":"machine",
+		"canonicalName":"machine",
+		"version":"1.0.0",
+		"initials":["U:main"],
+		"universes":{
+			"main":{
+				"id":"main",
+				"canonicalName":"main",
+				"version":"1.0.0",
+				"initial":"A",
+				"realities":{
+					"A":{"id":"A","type":"transition","always":[{"targets":["A"],"condition":{"src":"test:condition:retry"}},{"targets":["END"]}]},
+					"END":{"id":"END","type":"final"}
+				}
+			}
+		}
+	}`)
+
+	if len(report.Findings) != 1 {
+		t.Fatalf("expected a single finding, got %+v", report.Findings)
+	}
+	finding := report.Findings[0]
+	if finding.Code != IssueAlwaysCycle || finding.Severity != ValidationSeverityWarning {
+		t.Fatalf("expected a conditional always cycle warning, got %+v", finding)
+	}
+	if report.Err(ValidationSeverityError) != nil {
+		t.Fatal("warnings must not fail an error-level gate")
+	}
+	if report.Err(ValidationSeverityWarning) == nil {
+		t.Fatal("warnings must fail a warning-level gate")
+	}
+}
+
+func TestAnalyzeQuantumMachine_AlwaysCycleLeftExternally(t *testing.T) {
+	// the first always transition of B leaves the universe, so the B <-> C cascade never loops
+	report := analyzeFromJSON(t, `{
+		"id":"machine",
+		"canonicalName":"machine",
+		"version":"1.0.0",
+		"initials":["U:main"],
+		"universes":{
+			"main":{
+				"id":"main",
+				"canonicalName":"main",
+				"version":"1.0.0",
+				"initial":"B",
+				"realities":{
+					"B":{"id":"B","type":"transition","observers":[{"src":"builtin:observer:alwaysTrue"}],
+						"always":[{"targets":["U:side:S"]},{"targets":["C"]}]},
+					"C":{"id":"C","type":"transition","always":[{"targets":["B"]}]}
+				}
+			},
+			"side":{
+				"id":"side",
+				"canonicalName":"side",
+				"version":"1.0.0",
+				"initial":"S",
+				"realities":{"S":{"id":"S","type":"final"}}
+			}
+		}
+	}`)
+
+	findings := findingsByCodeAndPath(report)
+	finding, ok := findings["always_cycle /universes/main/realities/B/always"]
+	if !ok || finding.Severity != ValidationSeverityWarning {
+		t.Fatalf("expected a possible always cycle warning, got %+v", report.Findings)
+	}
+}
+
+func TestAnalyzeQuantumMachine_SourceSuperpositionWithoutObservers(t *testing.T) {
+	report := analyzeFromJSON(t, `{
+		"id":"machine",
+		"canonicalName":"machine",
+		"version":"1.0.0",
+		"initials":["U:main"],
+		"universes":{
+			"main":{
+				"id":"main",
+				"canonicalName":"main",
+				"version":"1.0.0",
+				"initial":"A",
+				"realities":{
+					"A":{"id":"A","type":"transition","on":{"split":[{"targets":["B","END"]}],"notify":[{"targets":["B","END"],"type":"notify"}]}},
+					"B":{"id":"B","type":"transition","on":{"done":[{"targets":["END"]}]}},
+					"END":{"id":"END","type":"final"}
+				}
+			}
+		}
+	}`)
+
+	findings := findingsByCodeAndPath(report)
+	if _, ok := findings["superposition_without_observers /universes/main/realities/A/on/split/0"]; !ok || len(findings) != 1 {
+		t.Fatalf("expected the multi-target transition to be reported, got %+v", report.Findings)
+	}
+}
+
+func TestAnalyzeQuantumMachine_SuperpositionCollapse(t *testing.T) {
+	// realities with observers are reachable once the universe is in superposition
+	report := analyzeFromJSON(t, `{
+		"id":"machine",
+		"canonicalName":"machine",
+		"version":"1.0.0",
+		"initials":["U:main"],
+		"universes":{
+			"main":{
+				"id":"main",
+				"canonicalName":"main",
+				"version":"1.0.0",
+				"realities":{
+					"A":{"id":"A","type":"transition","observers":[{"src":"builtin:observer:alwaysTrue"}],"on":{"go":[{"targets":["END"]}]}},
+					"END":{"id":"END","type":"final"}
+				}
+			}
+		}
+	}`)
+
+	if len(report.Findings) != 0 {
+		t.Fatalf("expected no findings, got %+v", report.Findings)
+	}
+}
+
+func TestAnalyzeQuantumMachine_NotifyIsNotAnExit(t *testing.T) {
+	// notifying another universe does not move the universe out of x
+	report := analyzeFromJSON(t, `{
+		"id":"machine",
+		"canonicalName":"machine",
+		"version":"1.0.0",
+		"initials":["U:a","U:b"],
+		"universes":{
+			"a":{
+				"id":"a",
+				"canonicalName":"a",
+				"version":"1.0.0",
+				"initial":"x",
+				"realities":{
+					"x":{"id":"x","type":"transition","on":{"ping":[{"targets":["U:b:done"],"type":"notify"}]}}
+				}
+			},
+			"b":{
+				"id":"b",
+				"canonicalName":"b",
+				"version":"1.0.0",
+				"initial":"done",
+				"realities":{"done":{"id":"done","type":"final"}}
+			}
+		}
+	}`)
+
+	findings := findingsByCodeAndPath(report)
+	if _, ok := findings["dead_end_reality /universes/a/realities/x"]; !ok {
+		t.Fatalf("expected x to be reported as a dead end, got %+v", report.Findings)
+	}
+}
+
+func TestAnalyzeQuantumMachine_Fixtures(t *testing.T) {
+	for _, file := range []string{"example/sm/state_machine.json", "example/bot/state_machine.json", "example/cli/state_machine.json"} {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("error reading fixture %s: %v", file, err)
+		}
+		model, err := DeserializeQuantumMachineFromBinary(b)
+		if err != nil {
+			t.Fatalf("error deserializing fixture %s: %v", file, err)
+		}
+		report, err := AnalyzeQuantumMachine(model)
+		if err != nil {
+			t.Fatalf("unexpected analysis error for %s: %v", file, err)
+		}
+		if err = report.Err(ValidationSeverityError); err != nil {
+			t.Fatalf("expected no error-level findings for %s, got: %v", file, err)
+		}
+	}
+}
+
+func TestAnalyzeQuantumMachine_NilModel(t *testing.T) {
+	if _, err := AnalyzeQuantumMachine(nil); err == nil {
+		t.Fatal("expected error for nil model")
+	}
+}
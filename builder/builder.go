@@ -0,0 +1,235 @@
+// Package builder builds quantum machine definitions in Go with a fluent API.
+//
+// Builders fill in ids, map keys, canonical names and versions, and Build validates the definition with
+// statepro.ValidateQuantumMachineDefinition:
+//
+//	model, err := builder.New("order").
+//		Initials(builder.Universe("payment")).
+//		Universe("payment", func(u *builder.UniverseBuilder) {
+//			u.Initial("PENDING")
+//			u.Reality("PENDING", func(r *builder.RealityBuilder) {
+//				r.On("pay", "PAID").Action("action:charge", nil)
+//				r.On("ship", builder.UniverseReality("shipping", "WAITING"))
+//			})
+//			u.Final("PAID", nil)
+//		}).
+//		Build()
+package builder
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/rendis/statepro/v3"
+	"github.com/rendis/statepro/v3/theoretical"
+)
+
+// DefaultVersion is the version of machines and universes that do not set one.
+const DefaultVersion = "1.0.0"
+
+// Universe returns the reference to a universe (U:<universe>), entering it through its initial reality or in superposition.
+func Universe(universeID string) string {
+	return "U:" + universeID
+}
+
+// UniverseReality returns the reference to a reality of another universe (U:<universe>:<reality>).
+func UniverseReality(universeID, realityID string) string {
+	return "U:" + universeID + ":" + realityID
+}
+
+// MachineBuilder builds a theoretical.QuantumMachineModel.
+type MachineBuilder struct {
+	model     *theoretical.QuantumMachineModel
+	universes []*UniverseBuilder
+	errs      []error
+}
+
+// New returns a builder for the machine id. The canonical name defaults to the id and the version to DefaultVersion.
+func New(id string) *MachineBuilder {
+	return &MachineBuilder{model: &theoretical.QuantumMachineModel{
+		ID:            id,
+		CanonicalName: id,
+		Version:       DefaultVersion,
+		Universes:     map[string]*theoretical.UniverseModel{},
+	}}
+}
+
+// CanonicalName sets the canonical name of the machine.
+func (b *MachineBuilder) CanonicalName(name string) *MachineBuilder {
+	b.model.CanonicalName = name
+	return b
+}
+
+// Version sets the version of the machine, inherited by the universes that do not set one.
+func (b *MachineBuilder) Version(version string) *MachineBuilder {
+	b.model.Version = version
+	return b
+}
+
+// Description sets the description of the machine.
+func (b *MachineBuilder) Description(description string) *MachineBuilder {
+	b.model.Description = &description
+	return b
+}
+
+// Metadata sets a metadata entry of the machine.
+func (b *MachineBuilder) Metadata(key string, value any) *MachineBuilder {
+	b.model.Metadata = setMetadata(b.model.Metadata, key, value)
+	return b
+}
+
+// Initials appends initial references (see Universe and UniverseReality).
+func (b *MachineBuilder) Initials(refs ...string) *MachineBuilder {
+	b.model.Initials = append(b.model.Initials, refs...)
+	return b
+}
+
+// Universe adds the universe id, configured by fn (which may be nil).
+func (b *MachineBuilder) Universe(id string, fn func(u *UniverseBuilder)) *MachineBuilder {
+	if _, exists := b.model.Universes[id]; exists {
+		b.errs = append(b.errs, fmt.Errorf("universe '%s' is defined more than once", id))
+		return b
+	}
+
+	u := &UniverseBuilder{model: &theoretical.UniverseModel{
+		ID:            id,
+		CanonicalName: id,
+		Realities:     map[string]*theoretical.RealityModel{},
+	}}
+	b.model.Universes[id] = u.model
+	b.universes = append(b.universes, u)
+	if fn != nil {
+		fn(u)
+	}
+	return b
+}
+
+// Constants configures the machine universal constants.
+func (b *MachineBuilder) Constants(fn func(c *ConstantsBuilder)) *MachineBuilder {
+	if b.model.UniversalConstants == nil {
+		b.model.UniversalConstants = &theoretical.UniversalConstantsModel{}
+	}
+	fn(&ConstantsBuilder{model: b.model.UniversalConstants})
+	return b
+}
+
+// Build returns a copy of the definition once it passes statepro.ValidateQuantumMachineDefinition.
+// The builder is not modified, so it can keep being configured and built again.
+func (b *MachineBuilder) Build() (*theoretical.QuantumMachineModel, error) {
+	var errs []error
+	errs = append(errs, b.errs...)
+	for _, u := range b.universes {
+		errs = append(errs, u.errs...)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	model := copyMachine(b.model)
+	for _, universe := range model.Universes {
+		if universe.Version == "" {
+			universe.Version = model.Version
+		}
+	}
+	if err := statepro.ValidateQuantumMachineDefinition(model); err != nil {
+		return nil, err
+	}
+	return model, nil
+}
+
+// MustBuild is like Build but panics on error. Intended for tests and package-level definitions.
+func (b *MachineBuilder) MustBuild() *theoretical.QuantumMachineModel {
+	model, err := b.Build()
+	if err != nil {
+		panic(err)
+	}
+	return model
+}
+
+// UniverseBuilder builds a theoretical.UniverseModel. The canonical name defaults to the id and the version to the machine version.
+type UniverseBuilder struct {
+	model *theoretical.UniverseModel
+	errs  []error
+}
+
+// CanonicalName sets the canonical name of the universe.
+func (u *UniverseBuilder) CanonicalName(name string) *UniverseBuilder {
+	u.model.CanonicalName = name
+	return u
+}
+
+// Version sets the version of the universe.
+func (u *UniverseBuilder) Version(version string) *UniverseBuilder {
+	u.model.Version = version
+	return u
+}
+
+// Description sets the description of the universe.
+func (u *UniverseBuilder) Description(description string) *UniverseBuilder {
+	u.model.Description = &description
+	return u
+}
+
+// Metadata sets a metadata entry of the universe.
+func (u *UniverseBuilder) Metadata(key string, value any) *UniverseBuilder {
+	u.model.Metadata = setMetadata(u.model.Metadata, key, value)
+	return u
+}
+
+// Tags appends tags to the universe.
+func (u *UniverseBuilder) Tags(tags ...string) *UniverseBuilder {
+	u.model.Tags = append(u.model.Tags, tags...)
+	return u
+}
+
+// Initial sets the initial reality. Universes without initial reality start in superposition.
+func (u *UniverseBuilder) Initial(realityID string) *UniverseBuilder {
+	u.model.Initial = &realityID
+	return u
+}
+
+// Constants configures the universe universal constants.
+func (u *UniverseBuilder) Constants(fn func(c *ConstantsBuilder)) *UniverseBuilder {
+	if u.model.UniversalConstants == nil {
+		u.model.UniversalConstants = &theoretical.UniversalConstantsModel{}
+	}
+	fn(&ConstantsBuilder{model: u.model.UniversalConstants})
+	return u
+}
+
+// Reality adds a transition reality, configured by fn (which may be nil).
+func (u *UniverseBuilder) Reality(id string, fn func(r *RealityBuilder)) *UniverseBuilder {
+	return u.reality(id, theoretical.RealityTypeTransition, fn)
+}
+
+// Final adds a final reality, configured by fn (which may be nil).
+func (u *UniverseBuilder) Final(id string, fn func(r *RealityBuilder)) *UniverseBuilder {
+	return u.reality(id, theoretical.RealityTypeFinal, fn)
+}
+
+// UnsuccessfulFinal adds an unsuccessful final reality, configured by fn (which may be nil).
+func (u *UniverseBuilder) UnsuccessfulFinal(id string, fn func(r *RealityBuilder)) *UniverseBuilder {
+	return u.reality(id, theoretical.RealityTypeUnsuccessfulFinal, fn)
+}
+
+func (u *UniverseBuilder) reality(id string, realityType theoretical.RealityType, fn func(r *RealityBuilder)) *UniverseBuilder {
+	if _, exists := u.model.Realities[id]; exists {
+		u.errs = append(u.errs, fmt.Errorf("reality '%s' is defined more than once in universe '%s'", id, u.model.ID))
+		return u
+	}
+
+	r := &RealityBuilder{model: &theoretical.RealityModel{ID: id, Type: realityType}}
+	u.model.Realities[id] = r.model
+	if fn != nil {
+		fn(r)
+	}
+	return u
+}
+
+func setMetadata(metadata map[string]any, key string, value any) map[string]any {
+	if metadata == nil {
+		metadata = map[string]any{}
+	}
+	metadata[key] = value
+	return metadata
+}
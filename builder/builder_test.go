@@ -0,0 +1,162 @@
+package builder
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rendis/statepro/v3"
+)
+
+const orderJSON = `{
+	"id": "order",
+	"canonicalName": "order",
+	"version": "2.0.0",
+	"initials": ["U:payment"],
+	"universalConstants": {"entryActions": [{"src": "action:log"}]},
+	"universes": {
+		"payment": {
+			"id": "payment",
+			"canonicalName": "payment",
+			"version": "2.0.0",
+			"initial": "PENDING",
+			"realities": {
+				"PENDING": {
+					"id": "PENDING",
+					"type": "transition",
+					"description": "waiting for payment",
+					"on": {
+						"pay": [{
+							"condition": {"src": "condition:funded"},
+							"conditions": [{"src": "condition:verified", "args": {"level": 2}}],
+							"targets": ["PAID", "U:shipping:WAITING"],
+							"actions": [{"src": "action:charge", "args": {"retries": 3}}]
+						}],
+						"cancel": [{"targets": ["CANCELLED"]}]
+					},
+					"entryActions": [{"src": "action:remind"}]
+				},
+				"PAID": {"id": "PAID", "type": "final"},
+				"CANCELLED": {"id": "CANCELLED", "type": "unsuccessfulFinal"}
+			}
+		},
+		"shipping": {
+			"id": "shipping",
+			"canonicalName": "shipping",
+			"version": "2.0.0",
+			"tags": ["logistics"],
+			"realities": {
+				"WAITING": {"id": "WAITING", "type": "transition", "always": [{"targets": ["SENT"]}]},
+				"SENT": {"id": "SENT", "type": "final"}
+			}
+		}
+	}
+}`
+
+func TestBuild(t *testing.T) {
+	model, err := New("order").
+		Version("2.0.0").
+		Initials(Universe("payment")).
+		Constants(func(c *ConstantsBuilder) {
+			c.EntryAction("action:log", nil)
+		}).
+		Universe("payment", func(u *UniverseBuilder) {
+			u.Initial("PENDING")
+			u.Reality("PENDING", func(r *RealityBuilder) {
+				r.Description("waiting for payment").EntryAction("action:remind", nil)
+				r.On("pay", "PAID", UniverseReality("shipping", "WAITING")).
+					Condition("condition:funded", nil).
+					Condition("condition:verified", map[string]any{"level": float64(2)}).
+					Action("action:charge", map[string]any{"retries": float64(3)})
+				r.On("cancel", "CANCELLED")
+			})
+			u.Final("PAID", nil)
+			u.UnsuccessfulFinal("CANCELLED", nil)
+		}).
+		Universe("shipping", func(u *UniverseBuilder) {
+			u.Tags("logistics")
+			u.Reality("WAITING", func(r *RealityBuilder) {
+				r.Always("SENT")
+			})
+			u.Final("SENT", nil)
+		}).
+		Build()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected, err := statepro.DeserializeQuantumMachineFromBinary([]byte(orderJSON))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(expected, model) {
+		t.Fatalf("built model differs from JSON definition:\nexpected %+v\ngot      %+v", expected, model)
+	}
+}
+
+func TestBuildReturnsCopies(t *testing.T) {
+	b := New("m").
+		Initials(Universe("a")).
+		Universe("a", func(u *UniverseBuilder) {
+			u.Reality("A", func(r *RealityBuilder) {
+				r.On("go", "END").Action("action:charge", map[string]any{"retries": 3})
+			})
+			u.Final("END", nil)
+		})
+
+	first, err := b.Build()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	first.Universes["a"].Realities["A"].On["go"][0].Targets[0] = "A"
+	first.Universes["a"].Realities["A"].On["go"][0].Actions[0].Args["retries"] = 0
+
+	// the universe version still follows the machine version, and the first model is not shared
+	second, err := b.Version("2.0.0").Build()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	transition := second.Universes["a"].Realities["A"].On["go"][0]
+	if second.Universes["a"].Version != "2.0.0" || transition.Targets[0] != "END" || transition.Actions[0].Args["retries"] != 3 {
+		t.Fatalf("expected an independent model, got version %s and transition %+v",
+			second.Universes["a"].Version, transition)
+	}
+	if first.Universes["a"].Version != DefaultVersion {
+		t.Fatalf("expected the first model to keep its version, got %s", first.Universes["a"].Version)
+	}
+}
+
+func TestBuildErrors(t *testing.T) {
+	_, err := New("m").
+		Initials(Universe("a")).
+		Universe("a", func(u *UniverseBuilder) {
+			u.Final("DONE", nil).Final("DONE", nil)
+		}).
+		Universe("a", nil).
+		Build()
+	if err == nil || !strings.Contains(err.Error(), "universe 'a' is defined more than once") ||
+		!strings.Contains(err.Error(), "reality 'DONE' is defined more than once in universe 'a'") {
+		t.Fatalf("expected duplicate errors, got %v", err)
+	}
+
+	_, err = New("m").
+		Initials(Universe("a")).
+		Universe("a", func(u *UniverseBuilder) {
+			u.Initial("START").Reality("START", func(r *RealityBuilder) {
+				r.On("go", "MISSING")
+			})
+		}).
+		Build()
+	if _, ok := statepro.AsValidationError(err); !ok {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+}
+
+func TestMustBuildPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic")
+		}
+	}()
+	New("m").MustBuild()
+}
@@ -0,0 +1,119 @@
+package builder
+
+import (
+	"slices"
+
+	"github.com/rendis/statepro/v3/internal/util"
+	"github.com/rendis/statepro/v3/theoretical"
+)
+
+// The copies below are deep: Build hands out a definition that shares nothing with the builder.
+
+func copyMachine(m *theoretical.QuantumMachineModel) *theoretical.QuantumMachineModel {
+	c := *m
+	c.Universes = make(map[string]*theoretical.UniverseModel, len(m.Universes))
+	for id, universe := range m.Universes {
+		c.Universes[id] = copyUniverse(universe)
+	}
+	c.Initials = slices.Clone(m.Initials)
+	c.UniversalConstants = copyConstants(m.UniversalConstants)
+	c.Description = util.CloneString(m.Description)
+	c.Metadata = util.CloneMap(m.Metadata)
+	return &c
+}
+
+func copyUniverse(u *theoretical.UniverseModel) *theoretical.UniverseModel {
+	c := *u
+	c.Initial = util.CloneString(u.Initial)
+	c.Realities = make(map[string]*theoretical.RealityModel, len(u.Realities))
+	for id, reality := range u.Realities {
+		c.Realities[id] = copyReality(reality)
+	}
+	c.UniversalConstants = copyConstants(u.UniversalConstants)
+	c.Description = util.CloneString(u.Description)
+	c.Metadata = util.CloneMap(u.Metadata)
+	c.Tags = slices.Clone(u.Tags)
+	return &c
+}
+
+func copyConstants(constants *theoretical.UniversalConstantsModel) *theoretical.UniversalConstantsModel {
+	if constants == nil {
+		return nil
+	}
+	return &theoretical.UniversalConstantsModel{
+		EntryInvokes:        copyAll(constants.EntryInvokes, copyInvoke),
+		ExitInvokes:         copyAll(constants.ExitInvokes, copyInvoke),
+		EntryActions:        copyAll(constants.EntryActions, copyAction),
+		ExitActions:         copyAll(constants.ExitActions, copyAction),
+		InvokesOnTransition: copyAll(constants.InvokesOnTransition, copyInvoke),
+		ActionsOnTransition: copyAll(constants.ActionsOnTransition, copyAction),
+	}
+}
+
+func copyReality(r *theoretical.RealityModel) *theoretical.RealityModel {
+	c := *r
+	c.Observers = copyAll(r.Observers, copyObserver)
+	c.Always = copyAll(r.Always, copyTransition)
+	if r.On != nil {
+		c.On = make(map[string][]*theoretical.TransitionModel, len(r.On))
+		for event, transitions := range r.On {
+			c.On[event] = copyAll(transitions, copyTransition)
+		}
+	}
+	c.EntryInvokes = copyAll(r.EntryInvokes, copyInvoke)
+	c.ExitInvokes = copyAll(r.ExitInvokes, copyInvoke)
+	c.EntryActions = copyAll(r.EntryActions, copyAction)
+	c.ExitActions = copyAll(r.ExitActions, copyAction)
+	c.Description = util.CloneString(r.Description)
+	c.Metadata = util.CloneMap(r.Metadata)
+	return &c
+}
+
+func copyTransition(t *theoretical.TransitionModel) *theoretical.TransitionModel {
+	c := *t
+	c.Condition = copyCondition(t.Condition)
+	c.Conditions = copyAll(t.Conditions, copyCondition)
+	if t.Type != nil {
+		transitionType := *t.Type
+		c.Type = &transitionType
+	}
+	c.Targets = slices.Clone(t.Targets)
+	c.Actions = copyAll(t.Actions, copyAction)
+	c.Invokes = copyAll(t.Invokes, copyInvoke)
+	c.Description = util.CloneString(t.Description)
+	c.Metadata = util.CloneMap(t.Metadata)
+	return &c
+}
+
+func copyCondition(m *theoretical.ConditionModel) *theoretical.ConditionModel {
+	if m == nil {
+		return nil
+	}
+	return &theoretical.ConditionModel{Src: m.Src, Args: util.CloneMap(m.Args), Description: util.CloneString(m.Description), Metadata: util.CloneMap(m.Metadata)}
+}
+
+func copyObserver(m *theoretical.ObserverModel) *theoretical.ObserverModel {
+	return &theoretical.ObserverModel{Src: m.Src, Args: util.CloneMap(m.Args), Description: util.CloneString(m.Description), Metadata: util.CloneMap(m.Metadata)}
+}
+
+func copyAction(m *theoretical.ActionModel) *theoretical.ActionModel {
+	return &theoretical.ActionModel{Src: m.Src, Args: util.CloneMap(m.Args), Description: util.CloneString(m.Description), Metadata: util.CloneMap(m.Metadata)}
+}
+
+func copyInvoke(m *theoretical.InvokeModel) *theoretical.InvokeModel {
+	return &theoretical.InvokeModel{Src: m.Src, Args: util.CloneMap(m.Args), Description: util.CloneString(m.Description), Metadata: util.CloneMap(m.Metadata)}
+}
+
+// copyAll copies the elements of items with copyFn, keeping nil elements (validation reports them).
+func copyAll[T any](items []*T, copyFn func(*T) *T) []*T {
+	if items == nil {
+		return nil
+	}
+	c := make([]*T, len(items))
+	for i, item := range items {
+		if item != nil {
+			c[i] = copyFn(item)
+		}
+	}
+	return c
+}
@@ -0,0 +1,160 @@
+package builder
+
+import "github.com/rendis/statepro/v3/theoretical"
+
+// RealityBuilder builds a theoretical.RealityModel.
+type RealityBuilder struct {
+	model *theoretical.RealityModel
+}
+
+// Description sets the description of the reality.
+func (r *RealityBuilder) Description(description string) *RealityBuilder {
+	r.model.Description = &description
+	return r
+}
+
+// Metadata sets a metadata entry of the reality.
+func (r *RealityBuilder) Metadata(key string, value any) *RealityBuilder {
+	r.model.Metadata = setMetadata(r.model.Metadata, key, value)
+	return r
+}
+
+// On appends a transition to targets for the event and returns its builder.
+// Targets are reality ids of the same universe or references built with Universe and UniverseReality.
+func (r *RealityBuilder) On(event string, targets ...string) *TransitionBuilder {
+	if r.model.On == nil {
+		r.model.On = map[string][]*theoretical.TransitionModel{}
+	}
+	t := newTransition(targets)
+	r.model.On[event] = append(r.model.On[event], t.model)
+	return t
+}
+
+// Always appends an always transition to targets and returns its builder.
+func (r *RealityBuilder) Always(targets ...string) *TransitionBuilder {
+	t := newTransition(targets)
+	r.model.Always = append(r.model.Always, t.model)
+	return t
+}
+
+// EntryAction appends an action executed when entering the reality.
+func (r *RealityBuilder) EntryAction(src string, args map[string]any) *RealityBuilder {
+	r.model.EntryActions = append(r.model.EntryActions, &theoretical.ActionModel{Src: src, Args: args})
+	return r
+}
+
+// ExitAction appends an action executed when leaving the reality.
+func (r *RealityBuilder) ExitAction(src string, args map[string]any) *RealityBuilder {
+	r.model.ExitActions = append(r.model.ExitActions, &theoretical.ActionModel{Src: src, Args: args})
+	return r
+}
+
+// EntryInvoke appends an invoke started when entering the reality.
+func (r *RealityBuilder) EntryInvoke(src string, args map[string]any) *RealityBuilder {
+	r.model.EntryInvokes = append(r.model.EntryInvokes, &theoretical.InvokeModel{Src: src, Args: args})
+	return r
+}
+
+// ExitInvoke appends an invoke started when leaving the reality.
+func (r *RealityBuilder) ExitInvoke(src string, args map[string]any) *RealityBuilder {
+	r.model.ExitInvokes = append(r.model.ExitInvokes, &theoretical.InvokeModel{Src: src, Args: args})
+	return r
+}
+
+// Observer appends an observer of the reality.
+func (r *RealityBuilder) Observer(src string, args map[string]any) *RealityBuilder {
+	r.model.Observers = append(r.model.Observers, &theoretical.ObserverModel{Src: src, Args: args})
+	return r
+}
+
+// TransitionBuilder builds a theoretical.TransitionModel.
+type TransitionBuilder struct {
+	model *theoretical.TransitionModel
+}
+
+func newTransition(targets []string) *TransitionBuilder {
+	return &TransitionBuilder{model: &theoretical.TransitionModel{Targets: targets}}
+}
+
+// Condition adds a condition. The first one is set as the transition condition, the next ones are appended to its conditions.
+func (t *TransitionBuilder) Condition(src string, args map[string]any) *TransitionBuilder {
+	condition := &theoretical.ConditionModel{Src: src, Args: args}
+	if t.model.Condition == nil {
+		t.model.Condition = condition
+	} else {
+		t.model.Conditions = append(t.model.Conditions, condition)
+	}
+	return t
+}
+
+// Action appends an action executed during the transition.
+func (t *TransitionBuilder) Action(src string, args map[string]any) *TransitionBuilder {
+	t.model.Actions = append(t.model.Actions, &theoretical.ActionModel{Src: src, Args: args})
+	return t
+}
+
+// Invoke appends an invoke started during the transition.
+func (t *TransitionBuilder) Invoke(src string, args map[string]any) *TransitionBuilder {
+	t.model.Invokes = append(t.model.Invokes, &theoretical.InvokeModel{Src: src, Args: args})
+	return t
+}
+
+// Notify makes the transition a notify transition: the event is sent to the targets instead of changing reality.
+func (t *TransitionBuilder) Notify() *TransitionBuilder {
+	notify := theoretical.TransitionTypeNotify
+	t.model.Type = &notify
+	return t
+}
+
+// Description sets the description of the transition.
+func (t *TransitionBuilder) Description(description string) *TransitionBuilder {
+	t.model.Description = &description
+	return t
+}
+
+// Metadata sets a metadata entry of the transition.
+func (t *TransitionBuilder) Metadata(key string, value any) *TransitionBuilder {
+	t.model.Metadata = setMetadata(t.model.Metadata, key, value)
+	return t
+}
+
+// ConstantsBuilder builds a theoretical.UniversalConstantsModel.
+type ConstantsBuilder struct {
+	model *theoretical.UniversalConstantsModel
+}
+
+// EntryAction appends an action executed when entering any reality.
+func (c *ConstantsBuilder) EntryAction(src string, args map[string]any) *ConstantsBuilder {
+	c.model.EntryActions = append(c.model.EntryActions, &theoretical.ActionModel{Src: src, Args: args})
+	return c
+}
+
+// ExitAction appends an action executed when leaving any reality.
+func (c *ConstantsBuilder) ExitAction(src string, args map[string]any) *ConstantsBuilder {
+	c.model.ExitActions = append(c.model.ExitActions, &theoretical.ActionModel{Src: src, Args: args})
+	return c
+}
+
+// EntryInvoke appends an invoke started when entering any reality.
+func (c *ConstantsBuilder) EntryInvoke(src string, args map[string]any) *ConstantsBuilder {
+	c.model.EntryInvokes = append(c.model.EntryInvokes, &theoretical.InvokeModel{Src: src, Args: args})
+	return c
+}
+
+// ExitInvoke appends an invoke started when leaving any reality.
+func (c *ConstantsBuilder) ExitInvoke(src string, args map[string]any) *ConstantsBuilder {
+	c.model.ExitInvokes = append(c.model.ExitInvokes, &theoretical.InvokeModel{Src: src, Args: args})
+	return c
+}
+
+// TransitionAction appends an action executed on every transition.
+func (c *ConstantsBuilder) TransitionAction(src string, args map[string]any) *ConstantsBuilder {
+	c.model.ActionsOnTransition = append(c.model.ActionsOnTransition, &theoretical.ActionModel{Src: src, Args: args})
+	return c
+}
+
+// TransitionInvoke appends an invoke started on every transition.
+func (c *ConstantsBuilder) TransitionInvoke(src string, args map[string]any) *ConstantsBuilder {
+	c.model.InvokesOnTransition = append(c.model.InvokesOnTransition, &theoretical.InvokeModel{Src: src, Args: args})
+	return c
+}
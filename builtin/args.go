@@ -0,0 +1,301 @@
+package builtin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/rendis/statepro/v3/instrumentation"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// ExecutorKind identifies the registry an executor src is resolved from.
+type ExecutorKind string
+
+const (
+	ExecutorKindObserver  ExecutorKind = "observer"
+	ExecutorKindAction    ExecutorKind = "action"
+	ExecutorKindInvoke    ExecutorKind = "invoke"
+	ExecutorKindCondition ExecutorKind = "condition"
+)
+
+const argsSchemaResource = "statepro://executor-args.schema.json"
+
+// ArgsSchema validates the Args map declared for an executor in a machine definition.
+type ArgsSchema interface {
+	// ValidateArgs returns an error describing every problem found in args.
+	ValidateArgs(args map[string]any) error
+}
+
+// RegisterOption configures an executor registration.
+type RegisterOption func(*registration)
+
+type registration struct {
+	argsSchema ArgsSchema
+}
+
+// WithArgsSchema attaches an args schema to the executor being registered.
+// Definitions can then be checked against it with statepro.ValidateQuantumMachineExecutorArgs.
+func WithArgsSchema(schema ArgsSchema) RegisterOption {
+	return func(r *registration) {
+		r.argsSchema = schema
+	}
+}
+
+var argsSchemaRegistry = map[ExecutorKind]map[string]ArgsSchema{}
+
+func applyRegisterOptions(kind ExecutorKind, src string, opts []RegisterOption) {
+	r := &registration{}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(r)
+		}
+	}
+
+	if r.argsSchema == nil {
+		delete(argsSchemaRegistry[kind], src)
+		return
+	}
+
+	if argsSchemaRegistry[kind] == nil {
+		argsSchemaRegistry[kind] = map[string]ArgsSchema{}
+	}
+	argsSchemaRegistry[kind][src] = r.argsSchema
+}
+
+// GetArgsSchema returns the args schema registered for the given executor, or nil if none was registered.
+// Builtin executors with documented args come with a schema.
+func GetArgsSchema(kind ExecutorKind, src string) ArgsSchema {
+	src = strings.TrimSpace(src)
+	if schema := builtinArgsSchemaRegistry[kind][src]; schema != nil {
+		return schema
+	}
+	return argsSchemaRegistry[kind][src]
+}
+
+// ValidateArgs validates args against the schema registered for the given executor.
+// Executors registered without a schema accept any args.
+func ValidateArgs(kind ExecutorKind, src string, args map[string]any) error {
+	schema := GetArgsSchema(kind, src)
+	if schema == nil {
+		return nil
+	}
+	return schema.ValidateArgs(args)
+}
+
+// ----- JSON Schema -----
+
+type jsonSchemaArgs struct {
+	schema *jsonschema.Schema
+}
+
+// NewJSONSchemaArgs compiles a JSON Schema document used to validate executor args.
+func NewJSONSchemaArgs(schema []byte) (ArgsSchema, error) {
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(schema))
+	if err != nil {
+		return nil, fmt.Errorf("invalid args schema: %w", err)
+	}
+
+	compiler := jsonschema.NewCompiler()
+	if err = compiler.AddResource(argsSchemaResource, doc); err != nil {
+		return nil, fmt.Errorf("invalid args schema: %w", err)
+	}
+
+	compiled, err := compiler.Compile(argsSchemaResource)
+	if err != nil {
+		return nil, fmt.Errorf("invalid args schema: %w", err)
+	}
+
+	return &jsonSchemaArgs{schema: compiled}, nil
+}
+
+// MustJSONSchemaArgs is like NewJSONSchemaArgs but panics if the schema cannot be compiled.
+func MustJSONSchemaArgs(schema []byte) ArgsSchema {
+	s, err := NewJSONSchemaArgs(schema)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+func (s *jsonSchemaArgs) ValidateArgs(args map[string]any) error {
+	if args == nil {
+		args = map[string]any{}
+	}
+
+	// normalize Go values (ints, typed maps...) into the JSON data model the validator expects
+	b, err := json.Marshal(args)
+	if err != nil {
+		return fmt.Errorf("args are not JSON serializable: %w", err)
+	}
+
+	instance, err := jsonschema.UnmarshalJSON(bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+
+	return s.schema.Validate(instance)
+}
+
+// ----- Go struct -----
+
+type structArgs struct {
+	typ      reflect.Type
+	required []string
+}
+
+// NewStructArgs derives an args schema from the Go struct T.
+// Keys are matched against the struct json tags: unknown keys and values that cannot be
+// decoded into the field type are rejected. Fields tagged `statepro:"required"` must be present.
+func NewStructArgs[T any]() ArgsSchema {
+	typ := reflect.TypeFor[T]()
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+
+	s := &structArgs{typ: typ}
+	if typ.Kind() != reflect.Struct {
+		return s
+	}
+
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() || field.Tag.Get("statepro") != "required" {
+			continue
+		}
+		s.required = append(s.required, jsonFieldName(field))
+	}
+	sort.Strings(s.required)
+
+	return s
+}
+
+func (s *structArgs) ValidateArgs(args map[string]any) error {
+	var problems []string
+	for _, key := range s.required {
+		if _, ok := args[key]; !ok {
+			problems = append(problems, fmt.Sprintf("missing required arg '%s'", key))
+		}
+	}
+
+	if err := decodeArgsInto(args, reflect.New(s.typ).Interface()); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func jsonFieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+// DecodeArgs decodes executor args into T, rejecting unknown keys.
+func DecodeArgs[T any](args map[string]any) (T, error) {
+	var out T
+	if err := decodeArgsInto(args, &out); err != nil {
+		return out, err
+	}
+	return out, nil
+}
+
+func decodeArgsInto(args map[string]any, target any) error {
+	if args == nil {
+		args = map[string]any{}
+	}
+
+	b, err := json.Marshal(args)
+	if err != nil {
+		return fmt.Errorf("args are not JSON serializable: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.DisallowUnknownFields()
+	if err = decoder.Decode(target); err != nil {
+		return fmt.Errorf("invalid args: %w", err)
+	}
+	return nil
+}
+
+// ----- typed executors -----
+
+// TypedObserverFn is an observer that receives its args decoded into T.
+type TypedObserverFn[T any] func(ctx context.Context, args instrumentation.ObserverExecutorArgs, typedArgs T) (bool, error)
+
+// TypedActionFn is an action that receives its args decoded into T.
+type TypedActionFn[T any] func(ctx context.Context, args instrumentation.ActionExecutorArgs, typedArgs T) error
+
+// TypedInvokeFn is an invoke that receives its args decoded into T.
+type TypedInvokeFn[T any] func(ctx context.Context, args instrumentation.InvokeExecutorArgs, typedArgs T)
+
+// TypedConditionFn is a condition that receives its args decoded into T.
+type TypedConditionFn[T any] func(ctx context.Context, args instrumentation.ConditionExecutorArgs, typedArgs T) (bool, error)
+
+// RegisterTypedObserver registers an observer whose args are decoded into T.
+// The args schema is derived from T (see NewStructArgs) unless overridden with WithArgsSchema.
+func RegisterTypedObserver[T any](src string, fn TypedObserverFn[T], opts ...RegisterOption) error {
+	wrapped := func(ctx context.Context, args instrumentation.ObserverExecutorArgs) (bool, error) {
+		typed, err := DecodeArgs[T](args.GetObserver().Args)
+		if err != nil {
+			return false, err
+		}
+		return fn(ctx, args, typed)
+	}
+	return RegisterObserver(src, wrapped, withDefaultStructArgs[T](opts)...)
+}
+
+// RegisterTypedAction registers an action whose args are decoded into T.
+// The args schema is derived from T (see NewStructArgs) unless overridden with WithArgsSchema.
+func RegisterTypedAction[T any](src string, fn TypedActionFn[T], opts ...RegisterOption) error {
+	wrapped := func(ctx context.Context, args instrumentation.ActionExecutorArgs) error {
+		typed, err := DecodeArgs[T](args.GetAction().Args)
+		if err != nil {
+			return err
+		}
+		return fn(ctx, args, typed)
+	}
+	return RegisterAction(src, wrapped, withDefaultStructArgs[T](opts)...)
+}
+
+// RegisterTypedInvoke registers an invoke whose args are decoded into T.
+// Invokes cannot report errors, so args that fail to decode are logged and the invoke is skipped.
+func RegisterTypedInvoke[T any](src string, fn TypedInvokeFn[T], opts ...RegisterOption) error {
+	wrapped := func(ctx context.Context, args instrumentation.InvokeExecutorArgs) {
+		typed, err := DecodeArgs[T](args.GetInvoke().Args)
+		if err != nil {
+			slog.ErrorContext(ctx, "invoke args decoding failed", "src", args.GetInvoke().Src, "error", err)
+			return
+		}
+		fn(ctx, args, typed)
+	}
+	return RegisterInvoke(src, wrapped, withDefaultStructArgs[T](opts)...)
+}
+
+// RegisterTypedCondition registers a condition whose args are decoded into T.
+// The args schema is derived from T (see NewStructArgs) unless overridden with WithArgsSchema.
+func RegisterTypedCondition[T any](src string, fn TypedConditionFn[T], opts ...RegisterOption) error {
+	wrapped := func(ctx context.Context, args instrumentation.ConditionExecutorArgs) (bool, error) {
+		typed, err := DecodeArgs[T](args.GetCondition().Args)
+		if err != nil {
+			return false, err
+		}
+		return fn(ctx, args, typed)
+	}
+	return RegisterCondition(src, wrapped, withDefaultStructArgs[T](opts)...)
+}
+
+// withDefaultStructArgs prepends the schema derived from T so a caller supplied WithArgsSchema wins.
+func withDefaultStructArgs[T any](opts []RegisterOption) []RegisterOption {
+	return append([]RegisterOption{WithArgsSchema(NewStructArgs[T]())}, opts...)
+}
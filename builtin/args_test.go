@@ -0,0 +1,153 @@
+package builtin
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/rendis/statepro/v3/instrumentation"
+	"github.com/rendis/statepro/v3/theoretical"
+)
+
+type notifyArgs struct {
+	Channel string `json:"channel" statepro:"required"`
+	Retries int    `json:"retries,omitempty"`
+}
+
+type stubActionArgs struct {
+	instrumentation.ActionExecutorArgs
+	action theoretical.ActionModel
+}
+
+func (s *stubActionArgs) GetAction() theoretical.ActionModel { return s.action }
+
+func TestNewJSONSchemaArgs_ValidatesArgs(t *testing.T) {
+	schema, err := NewJSONSchemaArgs([]byte(`{
+		"type":"object",
+		"properties":{"templateId":{"type":"string"}},
+		"required":["templateId"],
+		"additionalProperties":false
+	}`))
+	if err != nil {
+		t.Fatalf("unexpected schema error: %v", err)
+	}
+
+	if err = schema.ValidateArgs(map[string]any{"templateId": "welcome"}); err != nil {
+		t.Fatalf("expected valid args, got %v", err)
+	}
+
+	if err = schema.ValidateArgs(map[string]any{"templateID": "welcome"}); err == nil {
+		t.Fatal("expected error for misspelled key")
+	}
+}
+
+func TestNewJSONSchemaArgs_InvalidSchema(t *testing.T) {
+	if _, err := NewJSONSchemaArgs([]byte(`{"type":`)); err == nil {
+		t.Fatal("expected error for malformed schema")
+	}
+}
+
+func TestNewStructArgs_RejectsUnknownMissingAndMistyped(t *testing.T) {
+	schema := NewStructArgs[notifyArgs]()
+
+	if err := schema.ValidateArgs(map[string]any{"channel": "email", "retries": 3}); err != nil {
+		t.Fatalf("expected valid args, got %v", err)
+	}
+
+	cases := map[string]struct {
+		args        map[string]any
+		mustContain string
+	}{
+		"unknown key":      {args: map[string]any{"channel": "email", "retry": 3}, mustContain: "unknown field"},
+		"missing required": {args: map[string]any{"retries": 3}, mustContain: "missing required arg 'channel'"},
+		"wrong type":       {args: map[string]any{"channel": 7}, mustContain: "cannot unmarshal"},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := schema.ValidateArgs(tc.args)
+			if err == nil || !strings.Contains(err.Error(), tc.mustContain) {
+				t.Fatalf("expected error containing %q, got %v", tc.mustContain, err)
+			}
+		})
+	}
+}
+
+func TestRegisterAction_WithArgsSchema(t *testing.T) {
+	fn := func(ctx context.Context, args instrumentation.ActionExecutorArgs) error { return nil }
+	if err := RegisterAction("custom:action:schema", fn, WithArgsSchema(NewStructArgs[notifyArgs]())); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if GetArgsSchema(ExecutorKindAction, "custom:action:schema") == nil {
+		t.Fatal("expected schema to be registered")
+	}
+	if err := ValidateArgs(ExecutorKindAction, "custom:action:schema", map[string]any{"chanel": "sms"}); err == nil {
+		t.Fatal("expected args validation error")
+	}
+
+	// re-registering without a schema drops the previous one
+	if err := RegisterAction("custom:action:schema", fn); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if GetArgsSchema(ExecutorKindAction, "custom:action:schema") != nil {
+		t.Fatal("expected schema to be removed")
+	}
+}
+
+func TestValidateArgs_BuiltinSchemas(t *testing.T) {
+	if err := ValidateArgs(ExecutorKindObserver, "builtin:observer:totalEventsBetweenLimits", map[string]any{"minimum": 4}); err != nil {
+		t.Fatalf("expected valid builtin args, got %v", err)
+	}
+	if err := ValidateArgs(ExecutorKindObserver, "builtin:observer:totalEventsBetweenLimits", map[string]any{"minimun": 4}); err == nil {
+		t.Fatal("expected error for misspelled builtin arg")
+	}
+	if err := ValidateArgs(ExecutorKindObserver, "builtin:observer:greaterThanEqualCounter", map[string]any{"sign": "two"}); err == nil {
+		t.Fatal("expected error for non-integer counter")
+	}
+	if err := ValidateArgs(ExecutorKindObserver, "builtin:observer:alwaysTrue", map[string]any{"anything": true}); err != nil {
+		t.Fatalf("expected executors without schema to accept any args, got %v", err)
+	}
+}
+
+func TestRegisterTypedAction_DecodesArgs(t *testing.T) {
+	var received notifyArgs
+	err := RegisterTypedAction("custom:action:typed", func(ctx context.Context, args instrumentation.ActionExecutorArgs, typed notifyArgs) error {
+		received = typed
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if GetArgsSchema(ExecutorKindAction, "custom:action:typed") == nil {
+		t.Fatal("expected schema derived from the args struct")
+	}
+
+	fn := GetAction("custom:action:typed")
+	args := &stubActionArgs{action: theoretical.ActionModel{
+		Src:  "custom:action:typed",
+		Args: map[string]any{"channel": "sms", "retries": float64(2)},
+	}}
+	if err = fn(context.Background(), args); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if received.Channel != "sms" || received.Retries != 2 {
+		t.Fatalf("unexpected decoded args: %+v", received)
+	}
+
+	args.action.Args = map[string]any{"channel": "sms", "unknown": 1}
+	if err = fn(context.Background(), args); err == nil {
+		t.Fatal("expected decoding error for unknown key")
+	}
+}
+
+func TestDecodeArgs_NilArgs(t *testing.T) {
+	out, err := DecodeArgs[notifyArgs](nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out.Channel != "" {
+		t.Fatalf("expected zero value, got %+v", out)
+	}
+}
@@ -1,9 +1,13 @@
 package builtin
 
 import (
+	"strings"
+
 	"github.com/rendis/statepro/v3/instrumentation"
 )
 
+const builtinPrefix = "builtin:"
+
 var builtinObserverRegistry = map[string]instrumentation.ObserverFn{
 	"builtin:observer:containsAllEvents":        ContainsAllEvents,
 	"builtin:observer:containsAtLeastOneEvent":  ContainsAtLeastOneEvent,
@@ -23,6 +27,25 @@ var builtinInvokeRegistry = map[string]instrumentation.InvokeFn{}
 
 var builtinConditionRegistry = map[string]instrumentation.ConditionFn{}
 
+var (
+	eventNamesArgsSchema   = MustJSONSchemaArgs([]byte(`{"type":"object","additionalProperties":{"type":"string","minLength":1}}`))
+	eventCounterArgsSchema = MustJSONSchemaArgs([]byte(`{"type":"object","additionalProperties":{"type":"integer","minimum":0}}`))
+	eventLimitsArgsSchema  = MustJSONSchemaArgs([]byte(`{
+		"type":"object",
+		"properties":{"minimum":{"type":"integer"},"maximum":{"type":"integer"}},
+		"additionalProperties":false
+	}`))
+)
+
+var builtinArgsSchemaRegistry = map[ExecutorKind]map[string]ArgsSchema{
+	ExecutorKindObserver: {
+		"builtin:observer:containsAllEvents":        eventNamesArgsSchema,
+		"builtin:observer:containsAtLeastOneEvent":  eventNamesArgsSchema,
+		"builtin:observer:greaterThanEqualCounter":  eventCounterArgsSchema,
+		"builtin:observer:totalEventsBetweenLimits": eventLimitsArgsSchema,
+	},
+}
+
 func GetObserver(src string) instrumentation.ObserverFn {
 	if fn := builtinObserverRegistry[src]; fn != nil {
 		return fn
@@ -50,3 +73,30 @@ func GetCondition(src string) instrumentation.ConditionFn {
 	}
 	return getCondition(src)
 }
+
+// IsRegistered reports whether src resolves to a builtin or registered executor of the given kind.
+func IsRegistered(kind ExecutorKind, src string) bool {
+	switch kind {
+	case ExecutorKindObserver:
+		return GetObserver(src) != nil
+	case ExecutorKindAction:
+		return GetAction(src) != nil
+	case ExecutorKindInvoke:
+		return GetInvoke(src) != nil
+	case ExecutorKindCondition:
+		return GetCondition(src) != nil
+	default:
+		return false
+	}
+}
+
+// BuiltinKind returns the executor kind encoded in a builtin src (builtin:<kind>:<name>).
+// The second value is false when src does not use the builtin prefix.
+func BuiltinKind(src string) (ExecutorKind, bool) {
+	rest, ok := strings.CutPrefix(src, builtinPrefix)
+	if !ok {
+		return "", false
+	}
+	kind, _, _ := strings.Cut(rest, ":")
+	return ExecutorKind(kind), true
+}
@@ -150,3 +150,24 @@ func TestGetCondition_BuiltinRegistry(t *testing.T) {
 	// Restore original registry
 	builtinConditionRegistry = originalRegistry
 }
+
+func TestIsRegistered(t *testing.T) {
+	if !IsRegistered(ExecutorKindObserver, "builtin:observer:alwaysTrue") {
+		t.Fatal("expected builtin observer to be registered")
+	}
+	if IsRegistered(ExecutorKindCondition, "builtin:observer:alwaysTrue") {
+		t.Fatal("builtin observer must not resolve as a condition")
+	}
+	if IsRegistered(ExecutorKind("unknown"), "builtin:observer:alwaysTrue") {
+		t.Fatal("unknown kind must not resolve")
+	}
+}
+
+func TestBuiltinKind(t *testing.T) {
+	if kind, ok := BuiltinKind("builtin:action:logArgs"); !ok || kind != ExecutorKindAction {
+		t.Fatalf("unexpected kind %q (%t)", kind, ok)
+	}
+	if _, ok := BuiltinKind("custom:action:logArgs"); ok {
+		t.Fatal("custom src must not be reported as builtin")
+	}
+}
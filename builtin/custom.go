@@ -18,39 +18,51 @@ var actionRegistry = map[string]instrumentation.ActionFn{}
 var invokeRegistry = map[string]instrumentation.InvokeFn{}
 var conditionRegistry = map[string]instrumentation.ConditionFn{}
 
-func RegisterObserver(src string, fn instrumentation.ObserverFn) error {
+// RegisterObserver registers a custom observer under src.
+// Options can attach an args schema used by definition validation (see WithArgsSchema).
+func RegisterObserver(src string, fn instrumentation.ObserverFn, opts ...RegisterOption) error {
 	src, err := normalizeSrc(src)
 	if err != nil {
 		return err
 	}
 	observerRegistry[src] = fn
+	applyRegisterOptions(ExecutorKindObserver, src, opts)
 	return nil
 }
 
-func RegisterAction(src string, fn instrumentation.ActionFn) error {
+// RegisterAction registers a custom action under src.
+// Options can attach an args schema used by definition validation (see WithArgsSchema).
+func RegisterAction(src string, fn instrumentation.ActionFn, opts ...RegisterOption) error {
 	src, err := normalizeSrc(src)
 	if err != nil {
 		return err
 	}
 	actionRegistry[src] = fn
+	applyRegisterOptions(ExecutorKindAction, src, opts)
 	return nil
 }
 
-func RegisterInvoke(src string, fn instrumentation.InvokeFn) error {
+// RegisterInvoke registers a custom invoke under src.
+// Options can attach an args schema used by definition validation (see WithArgsSchema).
+func RegisterInvoke(src string, fn instrumentation.InvokeFn, opts ...RegisterOption) error {
 	src, err := normalizeSrc(src)
 	if err != nil {
 		return err
 	}
 	invokeRegistry[src] = fn
+	applyRegisterOptions(ExecutorKindInvoke, src, opts)
 	return nil
 }
 
-func RegisterCondition(src string, fn instrumentation.ConditionFn) error {
+// RegisterCondition registers a custom condition under src.
+// Options can attach an args schema used by definition validation (see WithArgsSchema).
+func RegisterCondition(src string, fn instrumentation.ConditionFn, opts ...RegisterOption) error {
 	src, err := normalizeSrc(src)
 	if err != nil {
 		return err
 	}
 	conditionRegistry[src] = fn
+	applyRegisterOptions(ExecutorKindCondition, src, opts)
 	return nil
 }
 
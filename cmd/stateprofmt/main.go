@@ -0,0 +1,103 @@
+// Command stateprofmt formats quantum machine definition files canonically (see statepro.FormatQuantumMachineJSON).
+//
+// Usage:
+//
+//	stateprofmt [-l] [-w] [file ...]
+//
+// Without files it formats standard input (JSON) to standard output. Files ending in .yaml or .yml are
+// formatted as YAML, other files as JSON.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rendis/statepro/v3"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("stateprofmt", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	list := flags.Bool("l", false, "list files whose formatting differs from stateprofmt's")
+	write := flags.Bool("w", false, "write result to (source) file instead of stdout")
+	flags.Usage = func() {
+		_, _ = fmt.Fprintln(stderr, "usage: stateprofmt [-l] [-w] [file ...]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() == 0 {
+		if *write {
+			_, _ = fmt.Fprintln(stderr, "stateprofmt: cannot use -w with standard input")
+			return 2
+		}
+		source, err := io.ReadAll(stdin)
+		if err == nil {
+			err = process("<standard input>", source, false, *list, false, stdout)
+		}
+		if err != nil {
+			_, _ = fmt.Fprintln(stderr, err)
+			return 2
+		}
+		return 0
+	}
+
+	status := 0
+	for _, path := range flags.Args() {
+		source, err := os.ReadFile(path)
+		if err == nil {
+			err = process(path, source, isYAMLFile(path), *list, *write, stdout)
+		}
+		if err != nil {
+			_, _ = fmt.Fprintln(stderr, err)
+			status = 2
+		}
+	}
+	return status
+}
+
+func process(name string, source []byte, asYAML, list, write bool, stdout io.Writer) error {
+	format := statepro.FormatQuantumMachineJSON
+	if asYAML {
+		format = statepro.FormatQuantumMachineYAML
+	}
+	formatted, err := format(source)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	changed := !bytes.Equal(source, formatted)
+	if list && changed {
+		_, _ = fmt.Fprintln(stdout, name)
+	}
+	if write && changed {
+		info, err := os.Stat(name)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(name, formatted, info.Mode().Perm())
+	}
+	if !list && !write {
+		_, err = stdout.Write(formatted)
+	}
+	return err
+}
+
+func isYAMLFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return true
+	}
+	return false
+}
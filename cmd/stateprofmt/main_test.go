@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const source = `{"id":"m","canonicalName":"m","version":"1.0.0","initials":["U:a"],"universes":{"a":{
+	"id":"a","canonicalName":"a","version":"1.0.0","initial":"DONE","realities":{"DONE":{"id":"DONE","type":"final"}}}}}`
+
+func TestRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "machine.json")
+	if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if status := run([]string{"-l", path}, nil, &stdout, &stderr); status != 0 || stdout.String() != path+"\n" {
+		t.Fatalf("expected file to be listed, got status %d, stdout %q, stderr %q", status, stdout.String(), stderr.String())
+	}
+
+	stdout.Reset()
+	if status := run([]string{"-w", path}, nil, &stdout, &stderr); status != 0 || stdout.Len() != 0 {
+		t.Fatalf("expected silent write, got status %d, stdout %q, stderr %q", status, stdout.String(), stderr.String())
+	}
+	if status := run([]string{"-l", path}, nil, &stdout, &stderr); status != 0 || stdout.Len() != 0 {
+		t.Fatalf("expected formatted file, got status %d, stdout %q", status, stdout.String())
+	}
+}
+
+func TestRunStdin(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if status := run(nil, strings.NewReader(source), &stdout, &stderr); status != 0 || !strings.HasPrefix(stdout.String(), "{\n  \"id\": \"m\",") {
+		t.Fatalf("expected formatted output, got status %d, stdout %q, stderr %q", status, stdout.String(), stderr.String())
+	}
+
+	stdout.Reset()
+	if status := run(nil, strings.NewReader(`{"id": 1}`), &stdout, &stderr); status != 2 || !strings.Contains(stderr.String(), "<standard input>") {
+		t.Fatalf("expected error, got status %d, stderr %q", status, stderr.String())
+	}
+}
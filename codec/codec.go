@@ -0,0 +1,220 @@
+// Package codec encodes machine snapshots in compact, versioned binary envelopes.
+//
+// An encoded snapshot starts with a 7 byte header: the "SPSN" magic, the envelope format version, the id of
+// the Codec that serialized the snapshot and the Compression applied to the payload. Decode reads the header to
+// pick the codec and decompression, so snapshots written with different settings can be mixed in a store.
+// JSON and CBOR codecs are built in; other codecs can be added with Register.
+package codec
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/rendis/statepro/v3/instrumentation"
+)
+
+// FormatVersion is the version of the envelope written by Encode.
+const FormatVersion byte = 1
+
+const headerSize = 7
+
+var magic = [4]byte{'S', 'P', 'S', 'N'}
+
+var (
+	// ErrInvalidHeader is returned when decoding data that is neither an encoded snapshot nor a JSON snapshot.
+	ErrInvalidHeader = errors.New("invalid snapshot header")
+
+	// ErrUnsupportedVersion is returned when decoding an envelope written by a newer format version.
+	ErrUnsupportedVersion = errors.New("unsupported snapshot format version")
+
+	// ErrUnknownCodec is returned when decoding a snapshot written by a codec that is not registered.
+	ErrUnknownCodec = errors.New("unknown snapshot codec")
+
+	// ErrUnknownCompression is returned for a compression that is not supported.
+	ErrUnknownCompression = errors.New("unknown snapshot compression")
+)
+
+// Codec serializes machine snapshots. Implementations must be safe for concurrent use.
+type Codec interface {
+	// ID identifies the codec in the header of encoded snapshots. Ids below 128 are reserved for statepro.
+	ID() byte
+
+	// Name is a human-readable name of the codec.
+	Name() string
+
+	Marshal(snapshot *instrumentation.MachineSnapshot) ([]byte, error)
+	Unmarshal(data []byte, snapshot *instrumentation.MachineSnapshot) error
+}
+
+// Compression is the compression applied to the serialized snapshot.
+type Compression byte
+
+const (
+	NoCompression Compression = 0
+	Gzip          Compression = 1
+)
+
+func (c Compression) String() string {
+	switch c {
+	case NoCompression:
+		return "none"
+	case Gzip:
+		return "gzip"
+	default:
+		return fmt.Sprintf("compression(%d)", byte(c))
+	}
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[byte]Codec{}
+)
+
+func init() {
+	registry[JSON.ID()] = JSON
+	registry[CBOR.ID()] = CBOR
+}
+
+// Register makes a codec available to Decode. It fails when the id is 0 or already registered.
+func Register(c Codec) error {
+	if c == nil || c.ID() == 0 {
+		return fmt.Errorf("codec id 0 is reserved")
+	}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if existing, ok := registry[c.ID()]; ok {
+		return fmt.Errorf("codec id %d is already registered by '%s'", c.ID(), existing.Name())
+	}
+	registry[c.ID()] = c
+	return nil
+}
+
+// Lookup returns the registered codec with the given id.
+func Lookup(id byte) (Codec, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	c, ok := registry[id]
+	return c, ok
+}
+
+// Option configures Encode.
+type Option func(*options)
+
+type options struct {
+	codec       Codec
+	compression Compression
+	level       int
+}
+
+// WithCodec sets the codec used to serialize the snapshot (default CBOR).
+func WithCodec(c Codec) Option {
+	return func(o *options) {
+		o.codec = c
+	}
+}
+
+// WithCompression sets the compression applied to the serialized snapshot (default NoCompression).
+func WithCompression(c Compression) Option {
+	return func(o *options) {
+		o.compression = c
+	}
+}
+
+// WithCompressionLevel sets the gzip compression level (default gzip.DefaultCompression).
+func WithCompressionLevel(level int) Option {
+	return func(o *options) {
+		o.level = level
+	}
+}
+
+// Encode serializes the snapshot in a versioned envelope.
+func Encode(snapshot *instrumentation.MachineSnapshot, opts ...Option) ([]byte, error) {
+	if snapshot == nil {
+		return nil, fmt.Errorf("snapshot cannot be nil")
+	}
+	o := &options{codec: CBOR, level: gzip.DefaultCompression}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.codec == nil {
+		return nil, fmt.Errorf("codec cannot be nil")
+	}
+
+	payload, err := o.codec.Marshal(snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding snapshot with codec '%s': %w", o.codec.Name(), err)
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(headerSize + len(payload))
+	buf.Write(magic[:])
+	buf.Write([]byte{FormatVersion, o.codec.ID(), byte(o.compression)})
+
+	switch o.compression {
+	case NoCompression:
+		buf.Write(payload)
+	case Gzip:
+		w, err := gzip.NewWriterLevel(&buf, o.level)
+		if err != nil {
+			return nil, fmt.Errorf("error compressing snapshot: %w", err)
+		}
+		if _, err = w.Write(payload); err != nil {
+			return nil, fmt.Errorf("error compressing snapshot: %w", err)
+		}
+		if err = w.Close(); err != nil {
+			return nil, fmt.Errorf("error compressing snapshot: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownCompression, o.compression)
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode deserializes a snapshot written by Encode. Plain JSON snapshots (MachineSnapshot.ToJson) are
+// accepted too, so stores can move to the binary format without rewriting existing records.
+func Decode(data []byte) (*instrumentation.MachineSnapshot, error) {
+	if trimmed := bytes.TrimLeft(data, " \t\r\n"); len(trimmed) > 0 && trimmed[0] == '{' {
+		snapshot := &instrumentation.MachineSnapshot{}
+		if err := JSON.Unmarshal(trimmed, snapshot); err != nil {
+			return nil, fmt.Errorf("error decoding JSON snapshot: %w", err)
+		}
+		return snapshot, nil
+	}
+
+	if len(data) < headerSize || !bytes.Equal(data[:len(magic)], magic[:]) {
+		return nil, ErrInvalidHeader
+	}
+	version, codecID, compression := data[4], data[5], Compression(data[6])
+	if version == 0 || version > FormatVersion {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, version)
+	}
+	c, ok := Lookup(codecID)
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownCodec, codecID)
+	}
+
+	payload := data[headerSize:]
+	switch compression {
+	case NoCompression:
+	case Gzip:
+		r, err := gzip.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return nil, fmt.Errorf("error decompressing snapshot: %w", err)
+		}
+		if payload, err = io.ReadAll(r); err != nil {
+			return nil, fmt.Errorf("error decompressing snapshot: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownCompression, compression)
+	}
+
+	snapshot := &instrumentation.MachineSnapshot{}
+	if err := c.Unmarshal(payload, snapshot); err != nil {
+		return nil, fmt.Errorf("error decoding snapshot with codec '%s': %w", c.Name(), err)
+	}
+	return snapshot, nil
+}
@@ -0,0 +1,243 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/rendis/statepro/v3/instrumentation"
+)
+
+func strPtr(s string) *string { return &s }
+
+// testSnapshot covers every snapshot field and the JSON value kinds metadata and event data can hold
+// (integers within the float64 range the JSON form is limited to).
+func testSnapshot(universes int) *instrumentation.MachineSnapshot {
+	s := &instrumentation.MachineSnapshot{Fingerprint: "sha256:00ff"}
+	for i := 0; i < universes; i++ {
+		id := fmt.Sprintf("u%d", i)
+		u := &instrumentation.UniverseSnapshot{
+			ID: id, CanonicalName: "orders-" + id, Version: "1.2.0",
+			Initialized: true, RealityInitialized: true,
+			Metadata: map[string]any{
+				"count": 3, "ratio": 0.1, "half": 2.5, "large": int64(1) << 50, "negative": -7,
+				"ok": true, "none": nil, "name": "ünïcode",
+				"nested": map[string]any{"list": []any{1, "two", map[string]any{"three": 3.0}}},
+			},
+		}
+		if i%2 == 0 {
+			u.CurrentReality = strPtr("PAID")
+			s.AddActiveUniverse(u.CanonicalName, "PAID")
+		} else {
+			u.InSuperposition = true
+			u.RealityBeforeSuperposition = strPtr("PENDING")
+			u.Accumulator = &instrumentation.AccumulatorSnapshot{RealitiesEvents: map[string][]instrumentation.EventSnapshot{
+				"PAID": {{Name: "pay", Data: map[string]any{"amount": 10.5}, EvtType: instrumentation.EventTypeOn}},
+				"VOID": {{Name: "void", EvtType: instrumentation.EventTypeOn, Flags: instrumentation.EventFlags{ReplayOnEntry: true}}},
+			}}
+			s.AddSuperpositionUniverse(u.CanonicalName, "PENDING")
+		}
+		s.AddUniverseSnapshot(id, u)
+		s.AddTracking(id, []string{"NEW", "PENDING", "PAID"})
+		s.AddUniverseFingerprint(id, "sha256:"+id)
+	}
+	return s
+}
+
+func jsonForm(t testing.TB, s *instrumentation.MachineSnapshot) string {
+	t.Helper()
+	b, err := json.Marshal(s)
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	return string(b)
+}
+
+func TestEncodeDecode_EquivalentToJSON(t *testing.T) {
+	snapshots := map[string]*instrumentation.MachineSnapshot{
+		"empty": {},
+		"full":  testSnapshot(4),
+	}
+	for name, snapshot := range snapshots {
+		for _, c := range []Codec{JSON, CBOR} {
+			for _, compression := range []Compression{NoCompression, Gzip} {
+				t.Run(fmt.Sprintf("%s/%s/%s", name, c.Name(), compression), func(t *testing.T) {
+					data, err := Encode(snapshot, WithCodec(c), WithCompression(compression))
+					if err != nil {
+						t.Fatalf("Encode failed: %v", err)
+					}
+					if !bytes.HasPrefix(data, []byte{'S', 'P', 'S', 'N', FormatVersion, c.ID(), byte(compression)}) {
+						t.Fatalf("unexpected header % x", data[:headerSize])
+					}
+					decoded, err := Decode(data)
+					if err != nil {
+						t.Fatalf("Decode failed: %v", err)
+					}
+					if got, want := jsonForm(t, decoded), jsonForm(t, snapshot); got != want {
+						t.Fatalf("decoded snapshot differs from the JSON form\n got: %s\nwant: %s", got, want)
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestCBOR_BigIntegers(t *testing.T) {
+	snapshot := &instrumentation.MachineSnapshot{}
+	snapshot.AddUniverseSnapshot("u1", &instrumentation.UniverseSnapshot{ID: "u1", Metadata: map[string]any{
+		"max": uint64(math.MaxUint64), "count": 3,
+	}})
+	data, _ := Encode(snapshot)
+	decoded, err := Decode(data)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	metadata := decoded.Snapshots["u1"].Metadata
+	if max, ok := metadata["max"].(*big.Int); !ok || !max.IsUint64() || max.Uint64() != math.MaxUint64 {
+		t.Fatalf("expected max to decode exactly as *big.Int, got %T %v", metadata["max"], metadata["max"])
+	}
+	if count, ok := metadata["count"].(int64); !ok || count != 3 {
+		t.Fatalf("expected count to decode as int64, got %T", metadata["count"])
+	}
+}
+
+func TestEncode_Deterministic(t *testing.T) {
+	first, _ := Encode(testSnapshot(8))
+	for i := 0; i < 10; i++ {
+		if again, _ := Encode(testSnapshot(8)); !bytes.Equal(first, again) {
+			t.Fatal("expected identical snapshots to encode to identical bytes")
+		}
+	}
+}
+
+func TestEncode_Compact(t *testing.T) {
+	snapshot := testSnapshot(50)
+	jsonSize := len(jsonForm(t, snapshot))
+	cborData, _ := Encode(snapshot)
+	gzipData, _ := Encode(snapshot, WithCompression(Gzip))
+	if len(cborData) >= jsonSize || len(gzipData) >= len(cborData) {
+		t.Fatalf("expected json (%d) > cbor (%d) > cbor+gzip (%d)", jsonSize, len(cborData), len(gzipData))
+	}
+}
+
+func TestDecode_LegacyJSON(t *testing.T) {
+	snapshot := testSnapshot(2)
+	legacy, err := snapshot.ToJson()
+	if err != nil {
+		t.Fatalf("ToJson failed: %v", err)
+	}
+	decoded, err := Decode([]byte("\n" + legacy))
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if jsonForm(t, decoded) != legacy {
+		t.Fatal("expected plain JSON snapshots to decode")
+	}
+}
+
+func TestDecode_Errors(t *testing.T) {
+	valid, _ := Encode(testSnapshot(1))
+	withHeader := func(version, codecID, compression byte) []byte {
+		data := bytes.Clone(valid)
+		data[4], data[5], data[6] = version, codecID, compression
+		return data
+	}
+
+	cases := map[string]struct {
+		data []byte
+		err  error
+	}{
+		"garbage":             {[]byte("not a snapshot"), ErrInvalidHeader},
+		"short":               {[]byte("SPSN"), ErrInvalidHeader},
+		"future version":      {withHeader(FormatVersion+1, CBOR.ID(), 0), ErrUnsupportedVersion},
+		"unknown codec":       {withHeader(FormatVersion, 99, 0), ErrUnknownCodec},
+		"unknown compression": {withHeader(FormatVersion, CBOR.ID(), 9), ErrUnknownCompression},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Decode(tc.data); !errors.Is(err, tc.err) {
+				t.Fatalf("expected %v, got %v", tc.err, err)
+			}
+		})
+	}
+
+	if _, err := Decode(valid[:len(valid)-3]); err == nil {
+		t.Fatal("expected error for a truncated payload")
+	}
+	if _, err := Encode(testSnapshot(1), WithCompression(9)); !errors.Is(err, ErrUnknownCompression) {
+		t.Fatalf("expected ErrUnknownCompression, got %v", err)
+	}
+}
+
+// upperJSON is a custom codec storing JSON upper-cased, to check the registry.
+type upperJSON struct{}
+
+func (upperJSON) ID() byte     { return 200 }
+func (upperJSON) Name() string { return "upper-json" }
+
+func (upperJSON) Marshal(s *instrumentation.MachineSnapshot) ([]byte, error) {
+	b, err := json.Marshal(s)
+	return bytes.ToUpper(b), err
+}
+
+func (upperJSON) Unmarshal(data []byte, s *instrumentation.MachineSnapshot) error {
+	return json.Unmarshal([]byte(strings.ToLower(string(data))), s)
+}
+
+func TestRegister(t *testing.T) {
+	if err := Register(upperJSON{}); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if err := Register(upperJSON{}); err == nil {
+		t.Fatal("expected error registering the same id twice")
+	}
+	if err := Register(nil); err == nil {
+		t.Fatal("expected error registering a nil codec")
+	}
+
+	snapshot := &instrumentation.MachineSnapshot{}
+	snapshot.AddActiveUniverse("orders", "paid")
+	data, err := Encode(snapshot, WithCodec(upperJSON{}))
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	decoded, err := Decode(data)
+	if err != nil || decoded.Resume.ActiveUniverses["orders"] != "paid" {
+		t.Fatalf("unexpected decode %v (%v)", decoded, err)
+	}
+}
+
+func BenchmarkCodecs(b *testing.B) {
+	snapshot := testSnapshot(20)
+	formats := []struct {
+		name string
+		opts []Option
+	}{
+		{"json", []Option{WithCodec(JSON)}},
+		{"cbor", nil},
+		{"cbor+gzip", []Option{WithCompression(Gzip)}},
+	}
+	for _, f := range formats {
+		data, _ := Encode(snapshot, f.opts...)
+		b.Run(f.name+"/encode", func(b *testing.B) {
+			b.ReportMetric(float64(len(data)), "bytes")
+			for i := 0; i < b.N; i++ {
+				if _, err := Encode(snapshot, f.opts...); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(f.name+"/decode", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := Decode(data); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package codec
+
+import (
+	"encoding/json"
+	"reflect"
+
+	"github.com/fxamacker/cbor/v2"
+
+	"github.com/rendis/statepro/v3/instrumentation"
+)
+
+var (
+	// JSON serializes snapshots as MachineSnapshot.ToJson does.
+	JSON Codec = jsonCodec{}
+
+	// CBOR serializes snapshots as RFC 8949 CBOR with deterministic map ordering, using the JSON field names.
+	// Integer metadata and event data values decode as int64, or *big.Int beyond its range (float64 with JSON).
+	CBOR Codec = cborCodec{}
+)
+
+type jsonCodec struct{}
+
+func (jsonCodec) ID() byte     { return 1 }
+func (jsonCodec) Name() string { return "json" }
+
+func (jsonCodec) Marshal(snapshot *instrumentation.MachineSnapshot) ([]byte, error) {
+	return json.Marshal(snapshot)
+}
+
+func (jsonCodec) Unmarshal(data []byte, snapshot *instrumentation.MachineSnapshot) error {
+	return json.Unmarshal(data, snapshot)
+}
+
+var (
+	cborEnc cbor.EncMode
+	cborDec cbor.DecMode
+)
+
+func init() {
+	var err error
+	cborEnc, err = cbor.EncOptions{
+		Sort:          cbor.SortCoreDeterministic,
+		ShortestFloat: cbor.ShortestFloat16,
+	}.EncMode()
+	if err != nil {
+		panic(err)
+	}
+	cborDec, err = cbor.DecOptions{
+		DefaultMapType: reflect.TypeOf(map[string]any(nil)),
+		IntDec:         cbor.IntDecConvertSignedOrBigInt,
+		BigIntDec:      cbor.BigIntDecodePointer,
+	}.DecMode()
+	if err != nil {
+		panic(err)
+	}
+}
+
+type cborCodec struct{}
+
+func (cborCodec) ID() byte     { return 2 }
+func (cborCodec) Name() string { return "cbor" }
+
+func (cborCodec) Marshal(snapshot *instrumentation.MachineSnapshot) ([]byte, error) {
+	return cborEnc.Marshal(snapshot)
+}
+
+func (cborCodec) Unmarshal(data []byte, snapshot *instrumentation.MachineSnapshot) error {
+	return cborDec.Unmarshal(data, snapshot)
+}
@@ -0,0 +1,269 @@
+package statepro
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/rendis/statepro/v3/internal/util"
+	"github.com/rendis/statepro/v3/theoretical"
+)
+
+// refKey is the key of a JSON Reference object: {"$ref": "<file>#<pointer>"}.
+const refKey = "$ref"
+
+// LoadQuantumMachine loads a definition split across files and validates it. See LoadQuantumMachineFS.
+// Included files are resolved relative to the including file and must stay inside the directory of path.
+func LoadQuantumMachine(filePath string) (*theoretical.QuantumMachineModel, error) {
+	return LoadQuantumMachineFS(os.DirFS(filepath.Dir(filePath)), filepath.Base(filePath))
+}
+
+// LoadQuantumMachineFS loads the definition at name from fsys, resolving includes, and validates the result.
+//
+// Any object of the form {"$ref": "<file>#<JSON Pointer>"} is replaced by the referenced value: a whole file
+// ("universes/payment.json"), a fragment of a file ("shared/realities.yaml#/failed") or a fragment of the
+// same file ("#/templates/done"). Other keys of the referencing object override the keys of the referenced
+// object, which makes fragments usable as templates:
+//
+//	"FAILED": {"$ref": "shared/realities.json#/failed", "id": "FAILED"}
+//
+// Files ending in .yaml or .yml are read as YAML, any other file as JSON. Validation issues carry the File
+// and FilePointer that supplied the offending value, plus its Line and Column for YAML files.
+func LoadQuantumMachineFS(fsys fs.FS, name string) (*theoretical.QuantumMachineModel, error) {
+	c := &composer{fsys: fsys, files: map[string]*composedFile{}, sources: map[string]valueSource{}}
+
+	payload, err := c.resolveRef(name, "", name, "")
+	if err != nil {
+		return nil, err
+	}
+
+	source, ok := payload.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s: definition must be an object", name)
+	}
+
+	if err = validateQuantumMachineDefinitionFromMap(source, c.annotateIssue); err != nil {
+		return nil, err
+	}
+
+	return DeserializeQuantumMachineFromMap(source)
+}
+
+// composedFile is a parsed file of a composed definition.
+type composedFile struct {
+	payload any
+	// yaml holds the value positions of YAML files.
+	yaml *yamlDocument
+}
+
+// valueSource is the file and JSON Pointer that supplied a value of the composed definition.
+type valueSource struct {
+	file    string
+	pointer string
+}
+
+type composer struct {
+	fsys  fs.FS
+	files map[string]*composedFile
+	// sources maps the JSON Pointer of every composed value to its origin.
+	sources map[string]valueSource
+	// resolving holds the references being resolved, to detect cycles.
+	resolving []string
+}
+
+func (c *composer) load(name string) (*composedFile, error) {
+	if f, ok := c.files[name]; ok {
+		return f, nil
+	}
+
+	b, err := fs.ReadFile(c.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &composedFile{}
+	switch strings.ToLower(path.Ext(name)) {
+	case ".yaml", ".yml":
+		if f.yaml, err = parseYAMLDefinition(b); err != nil {
+			return nil, err
+		}
+		f.payload = f.yaml.payload
+	default:
+		if err = json.Unmarshal(b, &f.payload); err != nil {
+			return nil, fmt.Errorf("invalid json payload: %w", err)
+		}
+	}
+
+	c.files[name] = f
+	return f, nil
+}
+
+// resolveRef resolves the value at pointer in file and stores it at target in the composed definition.
+// from describes the referencing location for error messages.
+func (c *composer) resolveRef(file, pointer, from, target string) (any, error) {
+	ref := file + "#" + pointer
+	for _, inProgress := range c.resolving {
+		if inProgress == ref {
+			return nil, fmt.Errorf("%s: circular $ref '%s' (%s)", from, ref, strings.Join(append(c.resolving, ref), " -> "))
+		}
+	}
+	c.resolving = append(c.resolving, ref)
+	defer func() { c.resolving = c.resolving[:len(c.resolving)-1] }()
+
+	f, err := c.load(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: error loading '%s': %w", from, file, err)
+	}
+
+	value, err := lookupPointer(f.payload, pointer)
+	if err != nil {
+		return nil, fmt.Errorf("%s: $ref '%s': %w", from, ref, err)
+	}
+
+	return c.resolve(file, pointer, value, target)
+}
+
+// resolve copies value, found at pointer in file, to target in the composed definition, resolving nested references.
+func (c *composer) resolve(file, pointer string, value any, target string) (any, error) {
+	c.sources[target] = valueSource{file: file, pointer: pointer}
+
+	switch v := value.(type) {
+	case map[string]any:
+		if raw, ok := v[refKey]; ok {
+			return c.resolveReferencingObject(file, pointer, v, raw, target)
+		}
+		out := make(map[string]any, len(v))
+		for key, child := range v {
+			resolved, err := c.resolve(file, pointer+util.JSONPointer(key), child, target+util.JSONPointer(key))
+			if err != nil {
+				return nil, err
+			}
+			out[key] = resolved
+		}
+		return out, nil
+	case []any:
+		out := make([]any, len(v))
+		for i, child := range v {
+			index := "/" + strconv.Itoa(i)
+			resolved, err := c.resolve(file, pointer+index, child, target+index)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = resolved
+		}
+		return out, nil
+	default:
+		return value, nil
+	}
+}
+
+func (c *composer) resolveReferencingObject(file, pointer string, object map[string]any, raw any, target string) (any, error) {
+	from := file + "#" + pointer
+	ref, ok := raw.(string)
+	if !ok || ref == "" {
+		return nil, fmt.Errorf("%s: $ref must be a non-empty string", from)
+	}
+
+	refFile, refPointer, _ := strings.Cut(ref, "#")
+	if refFile == "" {
+		refFile = file
+	} else {
+		refFile = path.Join(path.Dir(file), refFile)
+		if !fs.ValidPath(refFile) {
+			return nil, fmt.Errorf("%s: $ref '%s' points outside of the definition directory", from, ref)
+		}
+	}
+
+	resolved, err := c.resolveRef(refFile, refPointer, from, target)
+	if err != nil {
+		return nil, err
+	}
+	if len(object) == 1 {
+		return resolved, nil
+	}
+
+	base, ok := resolved.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s: $ref '%s' with overrides must reference an object", from, ref)
+	}
+	out := make(map[string]any, len(base)+len(object)-1)
+	for key, value := range base {
+		out[key] = value
+	}
+	c.sources[target] = valueSource{file: file, pointer: pointer}
+	for key, value := range object {
+		if key == refKey {
+			continue
+		}
+		overridden, err := c.resolve(file, pointer+util.JSONPointer(key), value, target+util.JSONPointer(key))
+		if err != nil {
+			return nil, err
+		}
+		out[key] = overridden
+	}
+	return out, nil
+}
+
+// annotateIssue sets the file, and the position for YAML files, that supplied the value of the issue.
+func (c *composer) annotateIssue(issue *ValidationIssue) {
+	source, suffix, found := c.source(issue.Path)
+	if !found {
+		return
+	}
+	issue.File = source.file
+	issue.FilePointer = source.pointer + suffix
+	if f := c.files[source.file]; f != nil && f.yaml != nil {
+		pos := f.yaml.position(issue.FilePointer)
+		issue.Line, issue.Column = pos.line, pos.column
+	}
+}
+
+// source returns the origin of the value at pointer, or of its closest ancestor plus the remaining suffix.
+func (c *composer) source(pointer string) (valueSource, string, bool) {
+	suffix := ""
+	for {
+		if source, ok := c.sources[pointer]; ok {
+			return source, suffix, true
+		}
+		if pointer == "" {
+			return valueSource{}, "", false
+		}
+		cut := strings.LastIndex(pointer, "/")
+		suffix = pointer[cut:] + suffix
+		pointer = pointer[:cut]
+	}
+}
+
+// lookupPointer returns the value at the RFC 6901 JSON Pointer in document.
+func lookupPointer(document any, pointer string) (any, error) {
+	tokens, err := util.JSONPointerTokens(pointer)
+	if err != nil {
+		return nil, err
+	}
+
+	current := document
+	for _, token := range tokens {
+		switch v := current.(type) {
+		case map[string]any:
+			next, ok := v[token]
+			if !ok {
+				return nil, fmt.Errorf("'%s' not found", token)
+			}
+			current = next
+		case []any:
+			index, err := strconv.Atoi(token)
+			if err != nil || index < 0 || index >= len(v) {
+				return nil, fmt.Errorf("index '%s' out of range", token)
+			}
+			current = v[index]
+		default:
+			return nil, fmt.Errorf("'%s' not found", token)
+		}
+	}
+	return current, nil
+}
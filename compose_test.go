@@ -0,0 +1,180 @@
+package statepro
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func composedFS() fstest.MapFS {
+	return fstest.MapFS{
+		"machine.json": {Data: []byte(`{
+			"id": "order",
+			"canonicalName": "order",
+			"version": "1.0.0",
+			"initials": ["U:payment"],
+			"universalConstants": {"$ref": "shared/constants.yaml"},
+			"universes": {
+				"payment": {"$ref": "universes/payment/universe.yaml"},
+				"shipping": {"$ref": "universes/shipping/universe.json"}
+			}
+		}`)},
+		"universes/payment/universe.yaml": {Data: []byte(`# owned by the payments team
+id: payment
+canonicalName: payment
+version: 1.0.0
+initial: PENDING
+realities:
+  PENDING:
+    id: PENDING
+    type: transition
+    "on":
+      pay:
+        - targets: [U:shipping:WAITING]
+      reject:
+        - targets: [FAILED]
+  FAILED:
+    $ref: ../../shared/realities.json#/failed
+    id: FAILED
+`)},
+		"universes/shipping/universe.json": {Data: []byte(`{
+			"id": "shipping",
+			"canonicalName": "shipping",
+			"version": "1.0.0",
+			"realities": {
+				"WAITING": {"id": "WAITING", "type": "transition", "on": {"ship": [{"targets": ["SENT"]}]}},
+				"SENT": {"$ref": "../../shared/realities.json#/done", "id": "SENT"}
+			}
+		}`)},
+		"shared/realities.json": {Data: []byte(`{
+			"final": {"id": "TEMPLATE", "type": "final"},
+			"done": {"$ref": "#/final", "description": "delivered"},
+			"failed": {"id": "TEMPLATE", "type": "unsuccessfulFinal", "entryActions": [{"src": "action:alert"}]}
+		}`)},
+		"shared/constants.yaml": {Data: []byte("entryActions:\n  - src: action:log\n")},
+	}
+}
+
+func TestLoadQuantumMachineFS(t *testing.T) {
+	model, err := LoadQuantumMachineFS(composedFS(), "machine.json")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	failed := model.Universes["payment"].Realities["FAILED"]
+	if failed.ID != "FAILED" || failed.Type != "unsuccessfulFinal" || failed.EntryActions[0].Src != "action:alert" {
+		t.Fatalf("expected template with overridden id, got %+v", failed)
+	}
+	if sent := model.Universes["shipping"].Realities["SENT"]; sent.ID != "SENT" || sent.Type != "final" || *sent.Description != "delivered" {
+		t.Fatalf("expected template with overridden id, got %+v", sent)
+	}
+	if model.UniversalConstants == nil || model.UniversalConstants.EntryActions[0].Src != "action:log" {
+		t.Fatalf("expected shared constants, got %+v", model.UniversalConstants)
+	}
+}
+
+func TestLoadQuantumMachineFS_IssueOrigin(t *testing.T) {
+	fsys := composedFS()
+	fsys["universes/payment/universe.yaml"].Data = []byte(strings.Replace(string(fsys["universes/payment/universe.yaml"].Data),
+		"targets: [FAILED]", "targets: [MISSING]", 1))
+
+	_, err := LoadQuantumMachineFS(fsys, "machine.json")
+	validationErr, ok := AsValidationError(err)
+	if !ok {
+		t.Fatalf("expected ValidationError, got %v", err)
+	}
+
+	issue := validationErr.Issues[0]
+	if issue.Code != IssueUnknownReality || issue.File != "universes/payment/universe.yaml" ||
+		issue.FilePointer != "/realities/PENDING/on/reject/0/targets/0" || issue.Line != 14 || issue.Column != 21 {
+		t.Fatalf("expected issue located in the universe file, got %+v", issue)
+	}
+	if !strings.Contains(err.Error(), "universes/payment/universe.yaml:14:21: ") {
+		t.Fatalf("expected file position in error message, got %v", err)
+	}
+}
+
+func TestLoadQuantumMachineFS_SchemaIssueOrigin(t *testing.T) {
+	fsys := composedFS()
+	fsys["shared/realities.json"].Data = []byte(strings.Replace(string(fsys["shared/realities.json"].Data),
+		`"type": "unsuccessfulFinal"`, `"type": "failure"`, 1))
+
+	_, err := LoadQuantumMachineFS(fsys, "machine.json")
+	validationErr, ok := AsValidationError(err)
+	if !ok {
+		t.Fatalf("expected ValidationError, got %v", err)
+	}
+
+	for _, issue := range validationErr.Issues {
+		if issue.File == "shared/realities.json" && issue.FilePointer == "/failed/type" && issue.Line == 0 {
+			if !strings.Contains(err.Error(), "shared/realities.json#/failed/type: ") {
+				t.Fatalf("expected file pointer in error message, got %v", err)
+			}
+			return
+		}
+	}
+	t.Fatalf("expected issue located in the shared template, got %+v", validationErr.Issues)
+}
+
+func TestLoadQuantumMachineFS_Errors(t *testing.T) {
+	cases := map[string]struct {
+		files    fstest.MapFS
+		expected string
+	}{
+		"missing file": {
+			files:    fstest.MapFS{"machine.json": {Data: []byte(`{"universes": {"a": {"$ref": "a.json"}}}`)}},
+			expected: "error loading 'a.json'",
+		},
+		"missing fragment": {
+			files:    fstest.MapFS{"machine.json": {Data: []byte(`{"universes": {"a": {"$ref": "#/nope"}}}`)}},
+			expected: "'nope' not found",
+		},
+		"cycle": {
+			files: fstest.MapFS{
+				"machine.json": {Data: []byte(`{"universes": {"a": {"$ref": "a.json"}}}`)},
+				"a.json":       {Data: []byte(`{"next": {"$ref": "machine.json#/universes"}}`)},
+			},
+			expected: "circular $ref",
+		},
+		"outside of directory": {
+			files:    fstest.MapFS{"machine.json": {Data: []byte(`{"universes": {"a": {"$ref": "../a.json"}}}`)}},
+			expected: "outside of the definition directory",
+		},
+		"override of non object": {
+			files:    fstest.MapFS{"machine.json": {Data: []byte(`{"id": "m", "x": [1], "y": {"$ref": "#/x", "id": "y"}}`)}},
+			expected: "must reference an object",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := LoadQuantumMachineFS(tc.files, "machine.json")
+			if err == nil || !strings.Contains(err.Error(), tc.expected) {
+				t.Fatalf("expected error containing %q, got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestLoadQuantumMachine(t *testing.T) {
+	dir := t.TempDir()
+	for name, file := range composedFS() {
+		if name == "universes/shipping/universe.json" {
+			continue
+		}
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(target, file.Data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err := LoadQuantumMachine(filepath.Join(dir, "machine.json"))
+	if err == nil || !strings.Contains(err.Error(), "universes/shipping/universe.json") {
+		t.Fatalf("expected missing included file error, got %v", err)
+	}
+}
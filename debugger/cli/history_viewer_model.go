@@ -14,6 +14,10 @@ var historyViewerKeys = []key.Binding{
 		key.WithKeys("v", "m", "t", "s"),
 		key.WithHelp("v/m/t/s", "view snapshot"),
 	),
+	key.NewBinding(
+		key.WithKeys("d"),
+		key.WithHelp("d", "view changes"),
+	),
 	key.NewBinding(
 		key.WithKeys("esc"),
 		key.WithHelp("esc", "back"),
@@ -110,6 +114,14 @@ func historyViewerModelUpdate(m *model, teaMsg tea.Msg) (tea.Model, tea.Cmd) {
 			m.container.history = m.container.history[:h.pos+1]
 			markEventsFromHistory(m.container)
 			return m.prevModel, nil
+		case "d":
+			if isFiltering(hm) {
+				break
+			}
+			item, _ := hm.SelectedItem().(*choice)
+			h := item.obj.(*containerHistory)
+			v := buildChangesFromHistory(m.container, h)
+			return buildJsonViewerModel(m, v.title, v.content)
 		case "v", "m", "t", "s":
 			if isFiltering(hm) {
 				break
@@ -145,6 +157,20 @@ func buildSnapshotPartFromHistory(history *containerHistory, key string) *versio
 	}
 }
 
+// buildChangesFromHistory describes the changes of the snapshot since the previous history entry.
+func buildChangesFromHistory(container *smContainer, history *containerHistory) *version {
+	var previous *instrumentation.MachineSnapshot
+	if history.pos > 0 {
+		previous = container.history[history.pos-1].snapshot
+	}
+
+	title := fmt.Sprintf("Changes: %s (%s)", history.event.Name, *buildYellowTitle("since previous event"))
+	return &version{
+		title:   title,
+		content: instrumentation.DiffSnapshots(previous, history.snapshot),
+	}
+}
+
 func buildSnapshotPart(ds *debuggerSnapshot, key string) *version {
 
 	extractor, ok := historySnapshotKeys[key]
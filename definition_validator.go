@@ -3,8 +3,10 @@ package statepro
 import (
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
 
+	"github.com/rendis/statepro/v3/internal/util"
 	"github.com/rendis/statepro/v3/theoretical"
 )
 
@@ -28,11 +30,15 @@ func ValidateQuantumMachineDefinition(source *theoretical.QuantumMachineModel) e
 
 // ValidateQuantumMachineDefinitionFromMap validates both schema and semantic integrity from map payload.
 func ValidateQuantumMachineDefinitionFromMap(source map[string]any) error {
+	return validateQuantumMachineDefinitionFromMap(source, nil)
+}
+
+func validateQuantumMachineDefinitionFromMap(source map[string]any, annotate issueAnnotator) error {
 	if source == nil {
 		return fmt.Errorf("source map cannot be nil")
 	}
 
-	if err := ValidateQuantumMachineBySchemaFromMap(source); err != nil {
+	if err := validateQuantumMachineBySchema(source, annotate); err != nil {
 		return err
 	}
 
@@ -42,7 +48,7 @@ func ValidateQuantumMachineDefinitionFromMap(source map[string]any) error {
 	}
 
 	if err = validateQuantumMachineSemantics(model); err != nil {
-		return fmt.Errorf("semantic validation failed: %w", err)
+		return fmt.Errorf("semantic validation failed: %w", annotate.apply(err))
 	}
 
 	return nil
@@ -70,23 +76,21 @@ func ValidateQuantumMachineDefinitionFromBinary(source []byte) error {
 	return nil
 }
 
-type semanticValidationErrors struct {
-	errors []string
-}
-
-func (v *semanticValidationErrors) add(format string, args ...any) {
-	v.errors = append(v.errors, fmt.Sprintf(format, args...))
-}
-
-func (v *semanticValidationErrors) hasErrors() bool {
-	return len(v.errors) > 0
-}
+// ValidateQuantumMachineDefinitionFromYAML validates both schema and semantic integrity from YAML payload.
+// Issues report the line and column of the offending value.
+func ValidateQuantumMachineDefinitionFromYAML(source []byte) error {
+	doc, err := parseYAMLDefinition(source)
+	if err != nil {
+		return err
+	}
 
-func (v *semanticValidationErrors) Error() string {
-	if len(v.errors) == 0 {
-		return ""
+	payload, ok := doc.payload.(map[string]any)
+	if !ok {
+		// not an object: let the schema report it
+		return validateQuantumMachineBySchema(doc.payload, doc.annotateIssue)
 	}
-	return strings.Join(v.errors, "; ")
+
+	return validateQuantumMachineDefinitionFromMap(payload, doc.annotateIssue)
 }
 
 func validateQuantumMachineSemantics(model *theoretical.QuantumMachineModel) error {
@@ -94,100 +98,108 @@ func validateQuantumMachineSemantics(model *theoretical.QuantumMachineModel) err
 		return fmt.Errorf("model cannot be nil")
 	}
 
-	errCollector := &semanticValidationErrors{}
+	errCollector := &validationIssueCollector{}
 
 	if len(model.Universes) == 0 {
-		errCollector.add("machine must define at least one universe")
+		errCollector.add(IssueMissingUniverses, util.JSONPointer("universes"), "machine must define at least one universe")
 	}
 
-	for universeKey, universe := range model.Universes {
+	for _, universeKey := range util.SortedKeys(model.Universes) {
+		universe := model.Universes[universeKey]
+		universePath := util.JSONPointer("universes", universeKey)
+
 		if universe == nil {
-			errCollector.add("universe '%s' cannot be nil", universeKey)
+			errCollector.add(IssueNilUniverse, universePath, "universe '%s' cannot be nil", universeKey)
 			continue
 		}
 
 		if universe.ID != universeKey {
-			errCollector.add("universe key '%s' must match universe.id '%s'", universeKey, universe.ID)
+			errCollector.add(IssueUniverseIDMismatch, universePath+util.JSONPointer("id"), "universe key '%s' must match universe.id '%s'", universeKey, universe.ID)
 		}
 
 		if len(universe.Realities) == 0 {
-			errCollector.add("universe '%s' must define at least one reality", universeKey)
+			errCollector.add(IssueMissingRealities, universePath+util.JSONPointer("realities"), "universe '%s' must define at least one reality", universeKey)
 			continue
 		}
 
-		for realityKey, reality := range universe.Realities {
+		for _, realityKey := range util.SortedKeys(universe.Realities) {
+			reality := universe.Realities[realityKey]
+			realityPath := universePath + util.JSONPointer("realities", realityKey)
+
 			if reality == nil {
-				errCollector.add("reality '%s' in universe '%s' cannot be nil", realityKey, universeKey)
+				errCollector.add(IssueNilReality, realityPath, "reality '%s' in universe '%s' cannot be nil", realityKey, universeKey)
 				continue
 			}
 
 			if reality.ID != realityKey {
-				errCollector.add("reality key '%s' in universe '%s' must match reality.id '%s'", realityKey, universeKey, reality.ID)
+				errCollector.add(IssueRealityIDMismatch, realityPath+util.JSONPointer("id"), "reality key '%s' in universe '%s' must match reality.id '%s'", realityKey, universeKey, reality.ID)
 			}
 
 			if reality.Type == theoretical.RealityTypeTransition && !hasEffectiveTransitionFlow(reality) {
-				errCollector.add("transition reality '%s' in universe '%s' must define non-empty 'on' or non-empty 'always'", realityKey, universeKey)
+				errCollector.add(IssueTransitionRealityWithoutFlow, realityPath, "transition reality '%s' in universe '%s' must define non-empty 'on' or non-empty 'always'", realityKey, universeKey)
 			}
 		}
 
 		if universe.Initial != nil && *universe.Initial != "" {
 			if _, ok := universe.Realities[*universe.Initial]; !ok {
-				errCollector.add("universe '%s' initial '%s' does not reference an existing reality", universeKey, *universe.Initial)
+				errCollector.add(IssueUnknownInitialReality, universePath+util.JSONPointer("initial"), "universe '%s' initial '%s' does not reference an existing reality", universeKey, *universe.Initial)
 			}
 		}
 	}
 
 	for idx, initial := range model.Initials {
+		initialPath := util.JSONPointer("initials", strconv.Itoa(idx))
+
 		kind, universeID, realityID, ok := parseStateReference(initial)
 		if !ok {
-			errCollector.add("initials[%d] has invalid reference '%s'", idx, initial)
+			errCollector.add(IssueInvalidReference, initialPath, "initials[%d] has invalid reference '%s'", idx, initial)
 			continue
 		}
 
 		if kind == referenceReality {
-			errCollector.add("initials[%d] must be external reference (U:<universe> or U:<universe>:<reality>), got '%s'", idx, initial)
+			errCollector.add(IssueInternalInitialReference, initialPath, "initials[%d] must be external reference (U:<universe> or U:<universe>:<reality>), got '%s'", idx, initial)
 			continue
 		}
 
 		u, exists := model.Universes[universeID]
 		if !exists || u == nil {
-			errCollector.add("initials[%d] references unknown universe '%s'", idx, universeID)
+			errCollector.add(IssueUnknownUniverse, initialPath, "initials[%d] references unknown universe '%s'", idx, universeID)
 			continue
 		}
 
 		if kind == referenceUniverseReality {
 			if _, exists = u.Realities[realityID]; !exists {
-				errCollector.add("initials[%d] references unknown reality '%s' in universe '%s'", idx, realityID, universeID)
+				errCollector.add(IssueUnknownReality, initialPath, "initials[%d] references unknown reality '%s' in universe '%s'", idx, realityID, universeID)
 			}
 		}
 	}
 
-	for universeID, universe := range model.Universes {
+	for _, universeID := range util.SortedKeys(model.Universes) {
+		universe := model.Universes[universeID]
 		if universe == nil {
 			continue
 		}
 
-		for realityID, reality := range universe.Realities {
+		for _, realityID := range util.SortedKeys(universe.Realities) {
+			reality := universe.Realities[realityID]
 			if reality == nil {
 				continue
 			}
+			realityPath := util.JSONPointer("universes", universeID, "realities", realityID)
 
 			for tIdx, transition := range reality.Always {
-				validateTransitionSemantics(errCollector, model, universeID, realityID, "always", tIdx, transition)
+				validateTransitionSemantics(errCollector, model, universeID, realityID, "always", realityPath+util.JSONPointer("always"), tIdx, transition)
 			}
 
-			for eventName, transitions := range reality.On {
-				for tIdx, transition := range transitions {
-					validateTransitionSemantics(errCollector, model, universeID, realityID, "on."+eventName, tIdx, transition)
+			for _, eventName := range util.SortedKeys(reality.On) {
+				for tIdx, transition := range reality.On[eventName] {
+					validateTransitionSemantics(errCollector, model, universeID, realityID, "on."+eventName, realityPath+util.JSONPointer("on", eventName), tIdx, transition)
 				}
 			}
 		}
 	}
 
-	if errCollector.hasErrors() {
-		return errCollector
-	}
-	return nil
+	return errCollector.err()
 }
 
 func hasEffectiveTransitionFlow(reality *theoretical.RealityModel) bool {
@@ -213,30 +225,36 @@ func hasEffectiveTransitionFlow(reality *theoretical.RealityModel) bool {
 }
 
 func validateTransitionSemantics(
-	errCollector *semanticValidationErrors,
+	errCollector *validationIssueCollector,
 	model *theoretical.QuantumMachineModel,
 	universeID string,
 	realityID string,
 	transitionPath string,
+	transitionPointer string,
 	transitionIndex int,
 	transition *theoretical.TransitionModel,
 ) {
+	transitionPointer += util.JSONPointer(strconv.Itoa(transitionIndex))
+
 	if transition == nil {
-		errCollector.add("universe '%s' reality '%s' transition '%s[%d]' cannot be null", universeID, realityID, transitionPath, transitionIndex)
+		errCollector.add(IssueNilTransition, transitionPointer, "universe '%s' reality '%s' transition '%s[%d]' cannot be null", universeID, realityID, transitionPath, transitionIndex)
 		return
 	}
 
 	if len(transition.Targets) == 0 {
-		errCollector.add("universe '%s' reality '%s' transition '%s[%d]' must define at least one target", universeID, realityID, transitionPath, transitionIndex)
+		errCollector.add(IssueMissingTargets, transitionPointer+util.JSONPointer("targets"), "universe '%s' reality '%s' transition '%s[%d]' must define at least one target", universeID, realityID, transitionPath, transitionIndex)
 		return
 	}
 
 	isNotify := transition.Type != nil && *transition.Type == theoretical.TransitionTypeNotify
 
 	for targetIndex, target := range transition.Targets {
+		targetPointer := transitionPointer + util.JSONPointer("targets", strconv.Itoa(targetIndex))
+
 		kind, targetUniverseID, targetRealityID, ok := parseStateReference(target)
 		if !ok {
 			errCollector.add(
+				IssueInvalidReference, targetPointer,
 				"universe '%s' reality '%s' transition '%s[%d]' target[%d] has invalid reference '%s'",
 				universeID, realityID, transitionPath, transitionIndex, targetIndex, target,
 			)
@@ -245,6 +263,7 @@ func validateTransitionSemantics(
 
 		if isNotify && kind == referenceReality {
 			errCollector.add(
+				IssueNotifyInternalTarget, targetPointer,
 				"universe '%s' reality '%s' transition '%s[%d]' with type 'notify' cannot target internal reality '%s'",
 				universeID, realityID, transitionPath, transitionIndex, target,
 			)
@@ -254,11 +273,12 @@ func validateTransitionSemantics(
 		case referenceReality:
 			u := model.Universes[universeID]
 			if u == nil {
-				errCollector.add("unknown source universe '%s' while validating target '%s'", universeID, target)
+				errCollector.add(IssueUnknownUniverse, targetPointer, "unknown source universe '%s' while validating target '%s'", universeID, target)
 				continue
 			}
 			if _, exists := u.Realities[targetRealityID]; !exists {
 				errCollector.add(
+					IssueUnknownReality, targetPointer,
 					"universe '%s' reality '%s' transition '%s[%d]' target[%d] references unknown internal reality '%s'",
 					universeID, realityID, transitionPath, transitionIndex, targetIndex, targetRealityID,
 				)
@@ -266,6 +286,7 @@ func validateTransitionSemantics(
 		case referenceUniverse:
 			if tu := model.Universes[targetUniverseID]; tu == nil {
 				errCollector.add(
+					IssueUnknownUniverse, targetPointer,
 					"universe '%s' reality '%s' transition '%s[%d]' target[%d] references unknown universe '%s'",
 					universeID, realityID, transitionPath, transitionIndex, targetIndex, targetUniverseID,
 				)
@@ -274,6 +295,7 @@ func validateTransitionSemantics(
 			tu := model.Universes[targetUniverseID]
 			if tu == nil {
 				errCollector.add(
+					IssueUnknownUniverse, targetPointer,
 					"universe '%s' reality '%s' transition '%s[%d]' target[%d] references unknown universe '%s'",
 					universeID, realityID, transitionPath, transitionIndex, targetIndex, targetUniverseID,
 				)
@@ -281,6 +303,7 @@ func validateTransitionSemantics(
 			}
 			if _, exists := tu.Realities[targetRealityID]; !exists {
 				errCollector.add(
+					IssueUnknownReality, targetPointer,
 					"universe '%s' reality '%s' transition '%s[%d]' target[%d] references unknown reality '%s' in universe '%s'",
 					universeID, realityID, transitionPath, transitionIndex, targetIndex, targetRealityID, targetUniverseID,
 				)
@@ -0,0 +1,193 @@
+// Package diagram renders quantum machine definitions as Graphviz DOT and Mermaid stateDiagram-v2 diagrams.
+//
+// Universes are drawn as clusters (composite states in Mermaid) and realities are coloured by type.
+// Notify transitions are dashed, transitions with several targets go through a fork node (superposition
+// fan-out) and U:<universe> / U:<universe>:<reality> targets are drawn as cross-links between clusters.
+// A snapshot can be passed to highlight the current reality of every universe.
+package diagram
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rendis/statepro/v3/instrumentation"
+	"github.com/rendis/statepro/v3/internal/util"
+	"github.com/rendis/statepro/v3/theoretical"
+)
+
+const (
+	transitionColor        = "#E3F2FD"
+	finalColor             = "#C8E6C9"
+	unsuccessfulFinalColor = "#FFCDD2"
+	highlightColor         = "#FF6F00"
+	notifyColor            = "#1E88E5"
+)
+
+// Option configures the rendering.
+type Option func(*options)
+
+type options struct {
+	snapshot      *instrumentation.MachineSnapshot
+	showCondition bool
+}
+
+// WithSnapshot highlights the current reality of every universe in the snapshot and marks the universes in superposition.
+func WithSnapshot(snapshot *instrumentation.MachineSnapshot) Option {
+	return func(o *options) {
+		o.snapshot = snapshot
+	}
+}
+
+// WithConditions adds the condition srcs of each transition to its label.
+func WithConditions() Option {
+	return func(o *options) {
+		o.showCondition = true
+	}
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// machineState is the state of the machine extracted from a snapshot, keyed by universe id.
+type machineState struct {
+	current       map[string]string
+	superposition map[string]bool
+}
+
+func (o *options) machineState(model *theoretical.QuantumMachineModel) machineState {
+	state := machineState{current: map[string]string{}, superposition: map[string]bool{}}
+	if o.snapshot == nil {
+		return state
+	}
+
+	byCanonicalName := map[string]string{}
+	for id, universe := range model.Universes {
+		if universe != nil {
+			byCanonicalName[universe.CanonicalName] = id
+		}
+	}
+
+	resume := o.snapshot.Resume
+	for name, reality := range resume.ActiveUniverses {
+		state.current[byCanonicalName[name]] = reality
+	}
+	for name, reality := range resume.FinalizedUniverses {
+		state.current[byCanonicalName[name]] = reality
+	}
+	for name := range resume.SuperpositionUniverses {
+		state.superposition[byCanonicalName[name]] = true
+	}
+	for name := range resume.SuperpositionUniversesFinalized {
+		state.superposition[byCanonicalName[name]] = true
+	}
+	return state
+}
+
+// target is a resolved transition target.
+type target struct {
+	universe string
+	// reality is empty for a U:<universe> target.
+	reality string
+}
+
+// resolveTarget resolves a reference relative to the universe that owns the transition.
+func resolveTarget(universeID, ref string) target {
+	if !strings.HasPrefix(ref, "U:") {
+		return target{universe: universeID, reality: ref}
+	}
+	parts := strings.SplitN(strings.TrimPrefix(ref, "U:"), ":", 2)
+	if len(parts) == 1 {
+		return target{universe: parts[0]}
+	}
+	return target{universe: parts[0], reality: parts[1]}
+}
+
+// edge is a transition to draw.
+type edge struct {
+	universe string
+	reality  string
+	label    string
+	notify   bool
+	targets  []target
+	// forkSuffix names the fork node of a fan-out, unique within the reality.
+	forkSuffix string
+}
+
+// collectEdges returns the transitions of a reality in a deterministic order: always first, then On by event name.
+func collectEdges(universeID string, reality *theoretical.RealityModel, o *options) []edge {
+	var edges []edge
+	add := func(forkSuffix string, label string, transition *theoretical.TransitionModel) {
+		if transition == nil {
+			return
+		}
+		e := edge{
+			universe:   universeID,
+			reality:    reality.ID,
+			label:      label,
+			notify:     transition.IsNotification(),
+			forkSuffix: forkSuffix,
+		}
+		if o.showCondition {
+			if conditions := conditionSrcs(transition); len(conditions) > 0 {
+				e.label = fmt.Sprintf("%s [%s]", e.label, strings.Join(conditions, ", "))
+			}
+		}
+		if e.notify {
+			e.label += " (notify)"
+		}
+		for _, ref := range transition.Targets {
+			e.targets = append(e.targets, resolveTarget(universeID, ref))
+		}
+		edges = append(edges, e)
+	}
+
+	for i, transition := range reality.Always {
+		add(fmt.Sprintf("always_%d", i), "always", transition)
+	}
+	for _, eventName := range util.SortedKeys(reality.On) {
+		for i, transition := range reality.On[eventName] {
+			add(fmt.Sprintf("on_%s_%d", eventName, i), eventName, transition)
+		}
+	}
+	return edges
+}
+
+func conditionSrcs(transition *theoretical.TransitionModel) []string {
+	var srcs []string
+	if transition.Condition != nil {
+		srcs = append(srcs, transition.Condition.Src)
+	}
+	for _, condition := range transition.Conditions {
+		if condition != nil {
+			srcs = append(srcs, condition.Src)
+		}
+	}
+	return srcs
+}
+
+// observedRealities returns the realities of the universe with observers, the ones a superposition can collapse on.
+func observedRealities(universe *theoretical.UniverseModel) []string {
+	var realities []string
+	for _, realityID := range util.SortedKeys(universe.Realities) {
+		if reality := universe.Realities[realityID]; reality != nil && len(reality.Observers) > 0 {
+			realities = append(realities, realityID)
+		}
+	}
+	return realities
+}
+
+func realityColor(realityType theoretical.RealityType) string {
+	switch realityType {
+	case theoretical.RealityTypeFinal:
+		return finalColor
+	case theoretical.RealityTypeUnsuccessfulFinal:
+		return unsuccessfulFinalColor
+	default:
+		return transitionColor
+	}
+}
@@ -0,0 +1,142 @@
+package diagram
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/rendis/statepro/v3/instrumentation"
+	"github.com/rendis/statepro/v3/theoretical"
+)
+
+const diagramMachine = `{
+	"id":"machine",
+	"canonicalName":"machine",
+	"version":"1.0.0",
+	"initials":["U:main"],
+	"universes":{
+		"main":{
+			"id":"main",
+			"canonicalName":"main",
+			"version":"1.0.0",
+			"initial":"A",
+			"realities":{
+				"A":{"id":"A","type":"transition","on":{
+					"go":[{"targets":["DONE"],"condition":{"src":"condition:ready"}}],
+					"ping":[{"targets":["U:side"],"type":"notify"}],
+					"split":[{"targets":["U:side","U:other:X"]}]
+				}},
+				"DONE":{"id":"DONE","type":"final"}
+			}
+		},
+		"side":{
+			"id":"side",
+			"canonicalName":"side",
+			"version":"1.0.0",
+			"realities":{"S":{"id":"S","type":"transition","observers":[{"src":"builtin:observer:alwaysTrue"}],"on":{"fail":[{"targets":["F"]}]}},"F":{"id":"F","type":"unsuccessfulFinal"}}
+		},
+		"other":{
+			"id":"other",
+			"canonicalName":"other",
+			"version":"1.0.0",
+			"initial":"X",
+			"realities":{"X":{"id":"X","type":"final"}}
+		}
+	}
+}`
+
+func diagramModel(t *testing.T) *theoretical.QuantumMachineModel {
+	t.Helper()
+	model := &theoretical.QuantumMachineModel{}
+	if err := json.Unmarshal([]byte(diagramMachine), model); err != nil {
+		t.Fatalf("unexpected unmarshal error: %v", err)
+	}
+	return model
+}
+
+func assertContains(t *testing.T, output string, expected ...string) {
+	t.Helper()
+	for _, want := range expected {
+		if !strings.Contains(output, want) {
+			t.Fatalf("expected output to contain %q, got:\n%s", want, output)
+		}
+	}
+}
+
+func TestToDOT(t *testing.T) {
+	snapshot := &instrumentation.MachineSnapshot{}
+	snapshot.AddActiveUniverse("main", "A")
+	snapshot.AddSuperpositionUniverse("side", "S")
+
+	out, err := ToDOT(diagramModel(t), WithSnapshot(snapshot), WithConditions())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	assertContains(t, out,
+		`digraph "machine" {`,
+		`subgraph "cluster_main" {`,
+		`"main/A" [label="A", fillcolor="#E3F2FD", color="#FF6F00", penwidth=3];`,
+		`"main/DONE" [label="DONE", fillcolor="#C8E6C9", peripheries=2];`,
+		`"side/F" [label="F", fillcolor="#FFCDD2", peripheries=2];`,
+		`label="side — superposition";`,
+		`"main/A" -> "main/DONE" [label="go [condition:ready]"];`,
+		`"main/A" -> "side@entry" [label="ping (notify)", style=dashed, color="#1E88E5", fontcolor="#1E88E5"];`,
+		`"main/A@on_split_0" [shape=diamond`,
+		`"main/A@on_split_0" -> "other/X" [style=bold];`,
+		`"side@entry" -> "side/S" [style=dotted, label="observers"];`,
+		`"@start" -> "main@entry";`,
+	)
+
+	again, _ := ToDOT(diagramModel(t), WithSnapshot(snapshot), WithConditions())
+	if again != out {
+		t.Fatal("expected deterministic output")
+	}
+}
+
+func TestToMermaid(t *testing.T) {
+	snapshot := &instrumentation.MachineSnapshot{}
+	snapshot.AddFinalizedUniverse("other", "X")
+	snapshot.AddSuperpositionUniverse("side", "S")
+
+	out, err := ToMermaid(diagramModel(t), WithSnapshot(snapshot))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	assertContains(t, out,
+		"stateDiagram-v2",
+		`state "main" as universe_main {`,
+		"[*] --> main_A",
+		"main_A --> main_DONE : go",
+		"main_DONE --> [*]",
+		"[*] --> universe_main",
+		"main_A --> universe_side : ping (notify)",
+		"state main_A_on_split_0 <<fork>>",
+		"main_A_on_split_0 --> other_X",
+		"note right of universe_side : superposition",
+		"class side_F unsuccessfulFinal",
+		"class other_X current",
+	)
+}
+
+func TestMermaidIDs(t *testing.T) {
+	ids := &mermaidIDs{used: map[string]string{}, taken: map[string]bool{}}
+	first := ids.get("a-b/c")
+	second := ids.get("a_b/c")
+	if first != "a_b_c" || second != "a_b_c_2" {
+		t.Fatalf("expected unique sanitized ids, got %q and %q", first, second)
+	}
+	if ids.get("a-b/c") != first {
+		t.Fatal("expected stable ids")
+	}
+}
+
+func TestNilModel(t *testing.T) {
+	if _, err := ToDOT(nil); err == nil {
+		t.Fatal("expected error for nil model")
+	}
+	if _, err := ToMermaid(nil); err == nil {
+		t.Fatal("expected error for nil model")
+	}
+}
@@ -0,0 +1,167 @@
+package diagram
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rendis/statepro/v3/internal/util"
+	"github.com/rendis/statepro/v3/theoretical"
+)
+
+// ToDOT renders the model as a Graphviz DOT digraph.
+func ToDOT(model *theoretical.QuantumMachineModel, opts ...Option) (string, error) {
+	if model == nil {
+		return "", fmt.Errorf("source model cannot be nil")
+	}
+
+	o := newOptions(opts)
+	state := o.machineState(model)
+
+	var sb strings.Builder
+	w := func(format string, args ...any) {
+		_, _ = fmt.Fprintf(&sb, format, args...)
+		sb.WriteByte('\n')
+	}
+
+	w("digraph %s {", dotID(model.ID))
+	w("  label=%s;", dotID(fmt.Sprintf("%s (%s)", model.CanonicalName, model.Version)))
+	w("  labelloc=t;")
+	w("  compound=true;")
+	w("  rankdir=LR;")
+	w(`  node [shape=box, style="rounded,filled", fontname="Helvetica"];`)
+	w(`  edge [fontname="Helvetica", fontsize=10];`)
+	w(`  %s [shape=circle, label="", width=0.2, style=filled, fillcolor=black];`, dotID("@start"))
+
+	var crossLinks []string
+	for _, universeID := range util.SortedKeys(model.Universes) {
+		universe := model.Universes[universeID]
+		if universe == nil {
+			continue
+		}
+
+		label := universeID
+		if universe.CanonicalName != "" && universe.CanonicalName != universeID {
+			label = fmt.Sprintf("%s (%s)", universeID, universe.CanonicalName)
+		}
+		style := "rounded"
+		if state.superposition[universeID] {
+			label += " — superposition"
+			style = "rounded,dashed"
+		}
+
+		w("  subgraph %s {", dotID("cluster_"+universeID))
+		w("    label=%s;", dotID(label))
+		w("    style=%s;", dotID(style))
+		w(`    %s [shape=point, width=0.1];`, dotID(entryNode(universeID)))
+
+		for _, realityID := range util.SortedKeys(universe.Realities) {
+			reality := universe.Realities[realityID]
+			if reality == nil {
+				continue
+			}
+			attrs := []string{
+				"label=" + dotID(realityID),
+				"fillcolor=" + dotID(realityColor(reality.Type)),
+			}
+			if theoretical.IsFinalState(reality.Type) {
+				attrs = append(attrs, "peripheries=2")
+			}
+			if current, ok := state.current[universeID]; ok && current == realityID {
+				attrs = append(attrs, "color="+dotID(highlightColor), "penwidth=3")
+			}
+			w("    %s [%s];", dotID(realityNode(universeID, realityID)), strings.Join(attrs, ", "))
+		}
+
+		// the entry point leads to the initial reality, or to the realities a superposition can collapse on
+		if universe.Initial != nil {
+			w("    %s -> %s;", dotID(entryNode(universeID)), dotID(realityNode(universeID, *universe.Initial)))
+		} else {
+			for _, realityID := range observedRealities(universe) {
+				w(`    %s -> %s [style=dotted, label="observers"];`, dotID(entryNode(universeID)), dotID(realityNode(universeID, realityID)))
+			}
+		}
+
+		for _, realityID := range util.SortedKeys(universe.Realities) {
+			reality := universe.Realities[realityID]
+			if reality == nil {
+				continue
+			}
+			for _, e := range collectEdges(universeID, reality, o) {
+				for _, line := range dotEdge(e) {
+					if e.crossesUniverses() {
+						crossLinks = append(crossLinks, line)
+					} else {
+						w("    %s", line)
+					}
+				}
+			}
+		}
+		w("  }")
+	}
+
+	for _, ref := range model.Initials {
+		w("  %s -> %s;", dotID("@start"), dotID(targetNode(resolveTarget("", ref))))
+	}
+	for _, line := range crossLinks {
+		w("  %s", line)
+	}
+	w("}")
+
+	return sb.String(), nil
+}
+
+// crossesUniverses returns true when a target lives outside the universe that owns the transition.
+func (e edge) crossesUniverses() bool {
+	for _, t := range e.targets {
+		if t.universe != e.universe || t.reality == "" {
+			return true
+		}
+	}
+	return false
+}
+
+func dotEdge(e edge) []string {
+	from := dotID(realityNode(e.universe, e.reality))
+	attrs := []string{"label=" + dotID(e.label)}
+	if e.notify {
+		attrs = append(attrs, "style=dashed", "color="+dotID(notifyColor), "fontcolor="+dotID(notifyColor))
+	}
+
+	if len(e.targets) == 1 {
+		return []string{fmt.Sprintf("%s -> %s [%s];", from, dotID(targetNode(e.targets[0])), strings.Join(attrs, ", "))}
+	}
+
+	// superposition fan-out: source -> fork -> every target
+	fork := dotID(realityNode(e.universe, e.reality) + "@" + e.forkSuffix)
+	lines := []string{
+		fmt.Sprintf(`%s [shape=diamond, label="", width=0.2, height=0.2, style=filled, fillcolor=black];`, fork),
+		fmt.Sprintf("%s -> %s [%s];", from, fork, strings.Join(attrs, ", ")),
+	}
+	for _, t := range e.targets {
+		style := "bold"
+		if e.notify {
+			style = "dashed"
+		}
+		lines = append(lines, fmt.Sprintf("%s -> %s [style=%s];", fork, dotID(targetNode(t)), style))
+	}
+	return lines
+}
+
+func realityNode(universeID, realityID string) string {
+	return universeID + "/" + realityID
+}
+
+func entryNode(universeID string) string {
+	return universeID + "@entry"
+}
+
+func targetNode(t target) string {
+	if t.reality == "" {
+		return entryNode(t.universe)
+	}
+	return realityNode(t.universe, t.reality)
+}
+
+func dotID(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
@@ -0,0 +1,177 @@
+package diagram
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rendis/statepro/v3/internal/util"
+	"github.com/rendis/statepro/v3/theoretical"
+)
+
+// ToMermaid renders the model as a Mermaid stateDiagram-v2.
+// Mermaid cannot style individual transitions of a state diagram, so notify transitions are suffixed with
+// "(notify)" and fan-outs go through a <<fork>> state.
+func ToMermaid(model *theoretical.QuantumMachineModel, opts ...Option) (string, error) {
+	if model == nil {
+		return "", fmt.Errorf("source model cannot be nil")
+	}
+
+	o := newOptions(opts)
+	state := o.machineState(model)
+	ids := &mermaidIDs{used: map[string]string{}, taken: map[string]bool{}}
+
+	var sb strings.Builder
+	w := func(format string, args ...any) {
+		_, _ = fmt.Fprintf(&sb, format, args...)
+		sb.WriteByte('\n')
+	}
+
+	w("---")
+	w(`title: "%s (%s)"`, mermaidText(model.CanonicalName), mermaidText(model.Version))
+	w("---")
+	w("stateDiagram-v2")
+
+	var crossLinks, classes []string
+	for _, universeID := range util.SortedKeys(model.Universes) {
+		universe := model.Universes[universeID]
+		if universe == nil {
+			continue
+		}
+
+		universeState := ids.get("universe:" + universeID)
+		w(`  state "%s" as %s {`, mermaidText(universeID), universeState)
+
+		if universe.Initial != nil {
+			w("    [*] --> %s", ids.get(realityNode(universeID, *universe.Initial)))
+		}
+
+		for _, realityID := range util.SortedKeys(universe.Realities) {
+			reality := universe.Realities[realityID]
+			if reality == nil {
+				continue
+			}
+			id := ids.get(realityNode(universeID, realityID))
+			w(`    state "%s" as %s`, mermaidText(realityID), id)
+
+			classes = append(classes, fmt.Sprintf("class %s %s", id, mermaidClass(reality.Type)))
+			if current, ok := state.current[universeID]; ok && current == realityID {
+				classes = append(classes, fmt.Sprintf("class %s current", id))
+			}
+			if theoretical.IsFinalState(reality.Type) {
+				w("    %s --> [*]", id)
+			}
+		}
+
+		for _, realityID := range util.SortedKeys(universe.Realities) {
+			reality := universe.Realities[realityID]
+			if reality == nil {
+				continue
+			}
+			for _, e := range collectEdges(universeID, reality, o) {
+				lines := mermaidEdge(e, ids)
+				if e.crossesUniverses() {
+					crossLinks = append(crossLinks, lines...)
+					continue
+				}
+				for _, line := range lines {
+					w("    %s", line)
+				}
+			}
+		}
+		w("  }")
+
+		if state.superposition[universeID] {
+			w("  note right of %s : superposition", universeState)
+		}
+	}
+
+	for _, ref := range model.Initials {
+		w("  [*] --> %s", ids.get(mermaidTargetKey(resolveTarget("", ref))))
+	}
+	for _, line := range crossLinks {
+		w("  %s", line)
+	}
+
+	w("  classDef transition fill:%s", transitionColor)
+	w("  classDef final fill:%s", finalColor)
+	w("  classDef unsuccessfulFinal fill:%s", unsuccessfulFinalColor)
+	w("  classDef current stroke:%s,stroke-width:4px", highlightColor)
+	for _, class := range classes {
+		w("  %s", class)
+	}
+
+	return sb.String(), nil
+}
+
+func mermaidEdge(e edge, ids *mermaidIDs) []string {
+	from := ids.get(realityNode(e.universe, e.reality))
+	label := mermaidText(e.label)
+
+	if len(e.targets) == 1 {
+		return []string{fmt.Sprintf("%s --> %s : %s", from, ids.get(mermaidTargetKey(e.targets[0])), label)}
+	}
+
+	fork := ids.get(realityNode(e.universe, e.reality) + "@" + e.forkSuffix)
+	lines := []string{
+		fmt.Sprintf("state %s <<fork>>", fork),
+		fmt.Sprintf("%s --> %s : %s", from, fork, label),
+	}
+	for _, t := range e.targets {
+		lines = append(lines, fmt.Sprintf("%s --> %s", fork, ids.get(mermaidTargetKey(t))))
+	}
+	return lines
+}
+
+// mermaidTargetKey points U:<universe> targets to the composite state of the universe.
+func mermaidTargetKey(t target) string {
+	if t.reality == "" {
+		return "universe:" + t.universe
+	}
+	return realityNode(t.universe, t.reality)
+}
+
+func mermaidClass(realityType theoretical.RealityType) string {
+	switch realityType {
+	case theoretical.RealityTypeFinal:
+		return "final"
+	case theoretical.RealityTypeUnsuccessfulFinal:
+		return "unsuccessfulFinal"
+	default:
+		return "transition"
+	}
+}
+
+// mermaidIDs maps node keys to unique Mermaid identifiers (letters, digits and underscores).
+type mermaidIDs struct {
+	used  map[string]string
+	taken map[string]bool
+}
+
+func (m *mermaidIDs) get(key string) string {
+	if id, ok := m.used[key]; ok {
+		return id
+	}
+
+	var sb strings.Builder
+	for _, r := range key {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			sb.WriteRune(r)
+		default:
+			sb.WriteByte('_')
+		}
+	}
+	base := sb.String()
+
+	id := base
+	for i := 2; m.taken[id]; i++ {
+		id = fmt.Sprintf("%s_%d", base, i)
+	}
+	m.used[key] = id
+	m.taken[id] = true
+	return id
+}
+
+func mermaidText(s string) string {
+	return strings.NewReplacer(`"`, "#quot;", "\n", " ").Replace(s)
+}
@@ -0,0 +1,380 @@
+package statepro
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/rendis/statepro/v3/internal/util"
+	"github.com/rendis/statepro/v3/theoretical"
+)
+
+// ChangeKind identifies the kind of a definition change.
+type ChangeKind string
+
+const (
+	ChangeUniverseAdded         ChangeKind = "universe_added"
+	ChangeUniverseRemoved       ChangeKind = "universe_removed"
+	ChangeUniverseCanonicalName ChangeKind = "universe_canonical_name_changed"
+	ChangeUniverseInitial       ChangeKind = "universe_initial_changed"
+	ChangeInitials              ChangeKind = "initials_changed"
+	ChangeRealityAdded          ChangeKind = "reality_added"
+	ChangeRealityRemoved        ChangeKind = "reality_removed"
+	ChangeRealityType           ChangeKind = "reality_type_changed"
+	ChangeEventAdded            ChangeKind = "event_added"
+	ChangeEventRemoved          ChangeKind = "event_removed"
+	ChangeTransitionAdded       ChangeKind = "transition_added"
+	ChangeTransitionRemoved     ChangeKind = "transition_removed"
+	ChangeTransitionType        ChangeKind = "transition_type_changed"
+	ChangeTargets               ChangeKind = "targets_changed"
+	ChangeExecutorsChanged      ChangeKind = "executors_changed"
+	ChangeExecutorsReordered    ChangeKind = "executors_reordered"
+	ChangeExecutorArgs          ChangeKind = "executor_args_changed"
+)
+
+// ChangeCompatibility classifies a change for the snapshots taken with the previous definition.
+type ChangeCompatibility string
+
+const (
+	// ChangeCompatible changes keep every snapshot loadable with the same meaning.
+	ChangeCompatible ChangeCompatibility = "compatible"
+
+	// ChangeBreaking changes can make a snapshot fail to load, or resume a live machine in a different state:
+	// removed universes and realities, realities whose type changes, renamed universe canonical names
+	// (the keys of the snapshot resume) and events removed from a reality (a snapshot in it stops handling them).
+	ChangeBreaking ChangeCompatibility = "breaking"
+)
+
+// DefinitionChange is a change between two definitions.
+type DefinitionChange struct {
+	Kind          ChangeKind          `json:"kind"`
+	Compatibility ChangeCompatibility `json:"compatibility"`
+
+	// Path is the JSON Pointer of the changed value, in the new definition (in the old one for removals).
+	Path string `json:"path"`
+
+	// Before and After hold the changed values (ids, targets, executor srcs, args), when relevant.
+	Before any `json:"before,omitempty"`
+	After  any `json:"after,omitempty"`
+
+	Message string `json:"message"`
+}
+
+func (c DefinitionChange) String() string {
+	return fmt.Sprintf("%s %s: %s", c.Compatibility, c.Path, c.Message)
+}
+
+// DefinitionDiff is the result of DiffQuantumMachines.
+type DefinitionDiff struct {
+	FromVersion string `json:"fromVersion"`
+	ToVersion   string `json:"toVersion"`
+
+	// Changes lists every change in a deterministic order.
+	Changes []DefinitionChange `json:"changes"`
+}
+
+// HasBreakingChanges reports whether any change is ChangeBreaking.
+func (d *DefinitionDiff) HasBreakingChanges() bool {
+	return len(d.Breaking()) > 0
+}
+
+// Breaking returns the ChangeBreaking changes.
+func (d *DefinitionDiff) Breaking() []DefinitionChange {
+	if d == nil {
+		return nil
+	}
+	var breaking []DefinitionChange
+	for _, change := range d.Changes {
+		if change.Compatibility == ChangeBreaking {
+			breaking = append(breaking, change)
+		}
+	}
+	return breaking
+}
+
+// DiffQuantumMachines compares two versions of a definition: universes, realities, events and transitions
+// added or removed, targets, reality and transition types, initials, and the actions, invokes, conditions and
+// observers (added, removed, reordered or with different args). Transitions of an event are compared by
+// position, since the first one whose conditions pass is taken. Descriptions and metadata are ignored.
+//
+// Each change is classified as ChangeCompatible or ChangeBreaking for snapshots taken with the old definition.
+func DiffQuantumMachines(from, to *theoretical.QuantumMachineModel) (*DefinitionDiff, error) {
+	if from == nil || to == nil {
+		return nil, fmt.Errorf("source models cannot be nil")
+	}
+
+	d := &definitionDiffer{}
+	if !slices.Equal(from.Initials, to.Initials) {
+		d.add(ChangeInitials, ChangeCompatible, "/initials", from.Initials, to.Initials, "machine initials changed")
+	}
+	d.diffConstants("/universalConstants", "machine", from.UniversalConstants, to.UniversalConstants)
+
+	for _, id := range unionKeys(from.Universes, to.Universes) {
+		path := util.JSONPointer("universes", id)
+		oldUniverse, inOld := from.Universes[id]
+		newUniverse, inNew := to.Universes[id]
+		switch {
+		case !inNew:
+			d.add(ChangeUniverseRemoved, ChangeBreaking, path, id, nil,
+				fmt.Sprintf("universe '%s' removed, the state of snapshots in it is dropped", id))
+		case !inOld:
+			d.add(ChangeUniverseAdded, ChangeCompatible, path, nil, id, fmt.Sprintf("universe '%s' added", id))
+		default:
+			d.diffUniverse(path, oldUniverse, newUniverse)
+		}
+	}
+
+	return &DefinitionDiff{FromVersion: from.Version, ToVersion: to.Version, Changes: d.changes}, nil
+}
+
+type definitionDiffer struct {
+	changes []DefinitionChange
+}
+
+func (d *definitionDiffer) add(kind ChangeKind, compatibility ChangeCompatibility, path string, before, after any, message string) {
+	d.changes = append(d.changes, DefinitionChange{
+		Kind:          kind,
+		Compatibility: compatibility,
+		Path:          path,
+		Before:        before,
+		After:         after,
+		Message:       message,
+	})
+}
+
+func (d *definitionDiffer) diffUniverse(path string, from, to *theoretical.UniverseModel) {
+	if from.CanonicalName != to.CanonicalName {
+		d.add(ChangeUniverseCanonicalName, ChangeBreaking, path+"/canonicalName", from.CanonicalName, to.CanonicalName,
+			fmt.Sprintf("universe '%s' canonical name changed, snapshot resumes are keyed by it", to.ID))
+	}
+	if stringValue(from.Initial) != stringValue(to.Initial) {
+		d.add(ChangeUniverseInitial, ChangeCompatible, path+"/initial", stringValue(from.Initial), stringValue(to.Initial),
+			fmt.Sprintf("universe '%s' initial reality changed", to.ID))
+	}
+	d.diffConstants(path+"/universalConstants", fmt.Sprintf("universe '%s'", to.ID), from.UniversalConstants, to.UniversalConstants)
+
+	for _, id := range unionKeys(from.Realities, to.Realities) {
+		realityPath := path + util.JSONPointer("realities", id)
+		oldReality, inOld := from.Realities[id]
+		newReality, inNew := to.Realities[id]
+		name := to.ID + ":" + id
+		switch {
+		case !inNew:
+			d.add(ChangeRealityRemoved, ChangeBreaking, realityPath, id, nil,
+				fmt.Sprintf("reality '%s' removed, snapshots in it can no longer be loaded", name))
+		case !inOld:
+			d.add(ChangeRealityAdded, ChangeCompatible, realityPath, nil, id, fmt.Sprintf("reality '%s' added", name))
+		default:
+			d.diffReality(realityPath, name, oldReality, newReality)
+		}
+	}
+}
+
+func (d *definitionDiffer) diffReality(path, name string, from, to *theoretical.RealityModel) {
+	if from.Type != to.Type {
+		d.add(ChangeRealityType, ChangeBreaking, path+"/type", from.Type, to.Type,
+			fmt.Sprintf("reality '%s' type changed from '%s' to '%s', snapshots in it resume with the new type", name, from.Type, to.Type))
+	}
+
+	d.diffExecutors(path+"/observers", "observers of reality '"+name+"'", observerExecutors(from.Observers), observerExecutors(to.Observers))
+	d.diffExecutors(path+"/entryActions", "entry actions of reality '"+name+"'", actionExecutors(from.EntryActions), actionExecutors(to.EntryActions))
+	d.diffExecutors(path+"/exitActions", "exit actions of reality '"+name+"'", actionExecutors(from.ExitActions), actionExecutors(to.ExitActions))
+	d.diffExecutors(path+"/entryInvokes", "entry invokes of reality '"+name+"'", invokeExecutors(from.EntryInvokes), invokeExecutors(to.EntryInvokes))
+	d.diffExecutors(path+"/exitInvokes", "exit invokes of reality '"+name+"'", invokeExecutors(from.ExitInvokes), invokeExecutors(to.ExitInvokes))
+
+	d.diffTransitions(path+"/always", "always of reality '"+name+"'", from.Always, to.Always)
+	for _, event := range unionKeys(from.On, to.On) {
+		eventPath := path + util.JSONPointer("on", event)
+		oldTransitions, inOld := from.On[event]
+		newTransitions, inNew := to.On[event]
+		switch {
+		case !inNew:
+			d.add(ChangeEventRemoved, ChangeBreaking, eventPath, event, nil,
+				fmt.Sprintf("reality '%s' no longer handles event '%s', snapshots in it ignore the event", name, event))
+		case !inOld:
+			d.add(ChangeEventAdded, ChangeCompatible, eventPath, nil, event,
+				fmt.Sprintf("reality '%s' handles new event '%s'", name, event))
+		default:
+			d.diffTransitions(eventPath, fmt.Sprintf("event '%s' of reality '%s'", event, name), oldTransitions, newTransitions)
+		}
+	}
+}
+
+func (d *definitionDiffer) diffTransitions(path, owner string, from, to []*theoretical.TransitionModel) {
+	for i := 0; i < max(len(from), len(to)); i++ {
+		transitionPath := path + "/" + strconv.Itoa(i)
+		label := fmt.Sprintf("transition %d of %s", i, owner)
+		switch {
+		case i >= len(to):
+			d.add(ChangeTransitionRemoved, ChangeCompatible, transitionPath, from[i].Targets, nil, label+" removed")
+		case i >= len(from):
+			d.add(ChangeTransitionAdded, ChangeCompatible, transitionPath, nil, to[i].Targets, label+" added")
+		default:
+			d.diffTransition(transitionPath, label, from[i], to[i])
+		}
+	}
+}
+
+func (d *definitionDiffer) diffTransition(path, label string, from, to *theoretical.TransitionModel) {
+	if transitionTypeValue(from.Type) != transitionTypeValue(to.Type) {
+		d.add(ChangeTransitionType, ChangeCompatible, path+"/type", transitionTypeValue(from.Type), transitionTypeValue(to.Type),
+			label+" type changed")
+	}
+	if !slices.Equal(from.Targets, to.Targets) {
+		d.add(ChangeTargets, ChangeCompatible, path+"/targets", from.Targets, to.Targets, label+" targets changed")
+	}
+	d.diffExecutors(path+"/conditions", "conditions of "+label, conditionExecutors(from), conditionExecutors(to))
+	d.diffExecutors(path+"/actions", "actions of "+label, actionExecutors(from.Actions), actionExecutors(to.Actions))
+	d.diffExecutors(path+"/invokes", "invokes of "+label, invokeExecutors(from.Invokes), invokeExecutors(to.Invokes))
+}
+
+func (d *definitionDiffer) diffConstants(path, owner string, from, to *theoretical.UniversalConstantsModel) {
+	if from == nil {
+		from = &theoretical.UniversalConstantsModel{}
+	}
+	if to == nil {
+		to = &theoretical.UniversalConstantsModel{}
+	}
+	owner += " constant "
+	d.diffExecutors(path+"/entryActions", owner+"entry actions", actionExecutors(from.EntryActions), actionExecutors(to.EntryActions))
+	d.diffExecutors(path+"/exitActions", owner+"exit actions", actionExecutors(from.ExitActions), actionExecutors(to.ExitActions))
+	d.diffExecutors(path+"/actionsOnTransition", owner+"transition actions", actionExecutors(from.ActionsOnTransition), actionExecutors(to.ActionsOnTransition))
+	d.diffExecutors(path+"/entryInvokes", owner+"entry invokes", invokeExecutors(from.EntryInvokes), invokeExecutors(to.EntryInvokes))
+	d.diffExecutors(path+"/exitInvokes", owner+"exit invokes", invokeExecutors(from.ExitInvokes), invokeExecutors(to.ExitInvokes))
+	d.diffExecutors(path+"/invokesOnTransition", owner+"transition invokes", invokeExecutors(from.InvokesOnTransition), invokeExecutors(to.InvokesOnTransition))
+}
+
+// executorRef is the comparable part of an action, invoke, condition or observer.
+type executorRef struct {
+	src  string
+	args map[string]any
+}
+
+func (e executorRef) equal(other executorRef) bool {
+	return e.src == other.src && (len(e.args) == 0 && len(other.args) == 0 || reflect.DeepEqual(e.args, other.args))
+}
+
+// diffExecutors reports an executor list whose srcs are the same but with different args, the same executors
+// in another order, or else a change of the list.
+func (d *definitionDiffer) diffExecutors(path, owner string, from, to []executorRef) {
+	if slices.EqualFunc(from, to, executorRef.equal) {
+		return
+	}
+
+	fromSrcs, toSrcs := executorSrcs(from), executorSrcs(to)
+	if slices.Equal(fromSrcs, toSrcs) {
+		for i := range from {
+			if !from[i].equal(to[i]) {
+				d.add(ChangeExecutorArgs, ChangeCompatible, path+"/"+strconv.Itoa(i)+"/args", from[i].args, to[i].args,
+					fmt.Sprintf("args of '%s' in %s changed", to[i].src, owner))
+			}
+		}
+		return
+	}
+
+	if isPermutation(from, to) {
+		d.add(ChangeExecutorsReordered, ChangeCompatible, path, fromSrcs, toSrcs, owner+" reordered")
+		return
+	}
+	d.add(ChangeExecutorsChanged, ChangeCompatible, path, fromSrcs, toSrcs,
+		fmt.Sprintf("%s changed from [%s] to [%s]", owner, strings.Join(fromSrcs, ", "), strings.Join(toSrcs, ", ")))
+}
+
+func isPermutation(from, to []executorRef) bool {
+	if len(from) != len(to) {
+		return false
+	}
+	used := make([]bool, len(to))
+	for _, e := range from {
+		found := false
+		for j, other := range to {
+			if !used[j] && e.equal(other) {
+				used[j], found = true, true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func executorSrcs(executors []executorRef) []string {
+	srcs := make([]string, len(executors))
+	for i, e := range executors {
+		srcs[i] = e.src
+	}
+	return srcs
+}
+
+func actionExecutors(actions []*theoretical.ActionModel) []executorRef {
+	var refs []executorRef
+	for _, a := range actions {
+		if a != nil {
+			refs = append(refs, executorRef{src: a.Src, args: a.Args})
+		}
+	}
+	return refs
+}
+
+func invokeExecutors(invokes []*theoretical.InvokeModel) []executorRef {
+	var refs []executorRef
+	for _, i := range invokes {
+		if i != nil {
+			refs = append(refs, executorRef{src: i.Src, args: i.Args})
+		}
+	}
+	return refs
+}
+
+func observerExecutors(observers []*theoretical.ObserverModel) []executorRef {
+	var refs []executorRef
+	for _, o := range observers {
+		if o != nil {
+			refs = append(refs, executorRef{src: o.Src, args: o.Args})
+		}
+	}
+	return refs
+}
+
+// conditionExecutors returns the conditions of a transition in evaluation order (Condition, then Conditions).
+func conditionExecutors(transition *theoretical.TransitionModel) []executorRef {
+	var refs []executorRef
+	if transition.Condition != nil {
+		refs = append(refs, executorRef{src: transition.Condition.Src, args: transition.Condition.Args})
+	}
+	for _, c := range transition.Conditions {
+		if c != nil {
+			refs = append(refs, executorRef{src: c.Src, args: c.Args})
+		}
+	}
+	return refs
+}
+
+func unionKeys[V any](a, b map[string]V) []string {
+	union := make(map[string]struct{}, len(a)+len(b))
+	for k := range a {
+		union[k] = struct{}{}
+	}
+	for k := range b {
+		union[k] = struct{}{}
+	}
+	return util.SortedKeys(union)
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func transitionTypeValue(t *theoretical.TransitionType) theoretical.TransitionType {
+	if t == nil {
+		return theoretical.TransitionTypeDefault
+	}
+	return *t
+}
@@ -0,0 +1,141 @@
+package statepro
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rendis/statepro/v3/theoretical"
+)
+
+const diffBaseMachine = `{
+	"id": "order", "canonicalName": "order", "version": "1.0.0", "initials": ["U:main"],
+	"universes": {
+		"main": {
+			"id": "main", "canonicalName": "main", "version": "1.0.0", "initial": "CREATED",
+			"realities": {
+				"CREATED": {"id": "CREATED", "type": "transition", "on": {
+					"pay": [{
+						"condition": {"src": "condition:funded"},
+						"targets": ["PAID"],
+						"actions": [{"src": "action:charge", "args": {"retries": 3}}, {"src": "action:log"}]
+					}],
+					"cancel": [{"targets": ["CANCELLED"]}]
+				}},
+				"PAID": {"id": "PAID", "type": "final"},
+				"CANCELLED": {"id": "CANCELLED", "type": "final"}
+			}
+		},
+		"audit": {
+			"id": "audit", "canonicalName": "audit", "version": "1.0.0", "initial": "OPEN",
+			"realities": {"OPEN": {"id": "OPEN", "type": "final"}}
+		}
+	}
+}`
+
+func diffModels(t *testing.T, edit func(m *theoretical.QuantumMachineModel)) *DefinitionDiff {
+	t.Helper()
+	from, err := DeserializeQuantumMachineFromBinary([]byte(diffBaseMachine))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	to, _ := DeserializeQuantumMachineFromBinary([]byte(diffBaseMachine))
+	edit(to)
+
+	diff, err := DiffQuantumMachines(from, to)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return diff
+}
+
+func TestDiffQuantumMachines_NoChanges(t *testing.T) {
+	diff := diffModels(t, func(m *theoretical.QuantumMachineModel) {
+		// moving the condition to conditions and setting the default type keep the same behaviour
+		transition := m.Universes["main"].Realities["CREATED"].On["pay"][0]
+		transition.Conditions, transition.Condition = []*theoretical.ConditionModel{transition.Condition}, nil
+		defaultType := theoretical.TransitionTypeDefault
+		transition.Type = &defaultType
+		m.Version = "1.1.0"
+	})
+	if len(diff.Changes) != 0 || diff.FromVersion != "1.0.0" || diff.ToVersion != "1.1.0" {
+		t.Fatalf("expected no changes, got %+v", diff)
+	}
+}
+
+func TestDiffQuantumMachines(t *testing.T) {
+	diff := diffModels(t, func(m *theoretical.QuantumMachineModel) {
+		main := m.Universes["main"]
+		created := main.Realities["CREATED"]
+		pay := created.On["pay"][0]
+		pay.Targets = []string{"PAID", "U:audit"}
+		pay.Actions[0], pay.Actions[1] = pay.Actions[1], pay.Actions[0]
+		created.On["refund"] = []*theoretical.TransitionModel{{Targets: []string{"CANCELLED"}}}
+		delete(created.On, "cancel")
+		main.Realities["CANCELLED"].Type = theoretical.RealityTypeUnsuccessfulFinal
+		main.Realities["SHIPPED"] = &theoretical.RealityModel{ID: "SHIPPED", Type: theoretical.RealityTypeFinal}
+		delete(main.Realities, "PAID")
+		delete(m.Universes, "audit")
+	})
+
+	expected := []struct {
+		kind          ChangeKind
+		compatibility ChangeCompatibility
+		path          string
+	}{
+		{ChangeUniverseRemoved, ChangeBreaking, "/universes/audit"},
+		{ChangeRealityType, ChangeBreaking, "/universes/main/realities/CANCELLED/type"},
+		{ChangeEventRemoved, ChangeBreaking, "/universes/main/realities/CREATED/on/cancel"},
+		{ChangeTargets, ChangeCompatible, "/universes/main/realities/CREATED/on/pay/0/targets"},
+		{ChangeExecutorsReordered, ChangeCompatible, "/universes/main/realities/CREATED/on/pay/0/actions"},
+		{ChangeEventAdded, ChangeCompatible, "/universes/main/realities/CREATED/on/refund"},
+		{ChangeRealityRemoved, ChangeBreaking, "/universes/main/realities/PAID"},
+		{ChangeRealityAdded, ChangeCompatible, "/universes/main/realities/SHIPPED"},
+	}
+	if len(diff.Changes) != len(expected) {
+		t.Fatalf("expected %d changes, got %+v", len(expected), diff.Changes)
+	}
+	for i, e := range expected {
+		change := diff.Changes[i]
+		if change.Kind != e.kind || change.Compatibility != e.compatibility || change.Path != e.path {
+			t.Fatalf("change %d: expected %s %s %s, got %s", i, e.compatibility, e.kind, e.path, change)
+		}
+	}
+	if !diff.HasBreakingChanges() || len(diff.Breaking()) != 4 {
+		t.Fatalf("expected 4 breaking changes, got %+v", diff.Breaking())
+	}
+}
+
+func TestDiffQuantumMachines_Executors(t *testing.T) {
+	diff := diffModels(t, func(m *theoretical.QuantumMachineModel) {
+		pay := m.Universes["main"].Realities["CREATED"].On["pay"][0]
+		pay.Actions[0].Args = map[string]any{"retries": 5}
+		pay.Conditions = []*theoretical.ConditionModel{{Src: "condition:verified"}}
+		m.Universes["main"].Realities["CREATED"].On["pay"] = append(m.Universes["main"].Realities["CREATED"].On["pay"],
+			&theoretical.TransitionModel{Targets: []string{"CANCELLED"}})
+		m.Universes["main"].CanonicalName = "orders"
+	})
+
+	kinds := make([]string, len(diff.Changes))
+	for i, change := range diff.Changes {
+		kinds[i] = string(change.Kind)
+	}
+	expected := "universe_canonical_name_changed,executors_changed,executor_args_changed,transition_added"
+	if strings.Join(kinds, ",") != expected {
+		t.Fatalf("expected %s, got %v", expected, diff.Changes)
+	}
+	if diff.Changes[1].Message != "conditions of transition 0 of event 'pay' of reality 'main:CREATED' changed from [condition:funded] to [condition:funded, condition:verified]" {
+		t.Fatalf("unexpected message %q", diff.Changes[1].Message)
+	}
+	if diff.Changes[2].Path != "/universes/main/realities/CREATED/on/pay/0/actions/0/args" {
+		t.Fatalf("unexpected args path %s", diff.Changes[2].Path)
+	}
+	if len(diff.Breaking()) != 1 {
+		t.Fatalf("expected canonical name change to be breaking, got %+v", diff.Breaking())
+	}
+}
+
+func TestDiffQuantumMachines_Nil(t *testing.T) {
+	if _, err := DiffQuantumMachines(nil, &theoretical.QuantumMachineModel{}); err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -0,0 +1,206 @@
+package statepro
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/rendis/statepro/v3/builtin"
+	"github.com/rendis/statepro/v3/internal/util"
+	"github.com/rendis/statepro/v3/theoretical"
+)
+
+// ValidateQuantumMachineExecutorArgs checks the args of every action, condition, observer and invoke
+// declared in the model against the args schemas registered in the builtin registry.
+// Executors registered without a schema are not checked.
+func ValidateQuantumMachineExecutorArgs(source *theoretical.QuantumMachineModel) error {
+	if source == nil {
+		return fmt.Errorf("source model cannot be nil")
+	}
+
+	errCollector := &validationIssueCollector{}
+
+	walkExecutorReferences(source, func(ref executorReference) {
+		if err := builtin.ValidateArgs(ref.kind, ref.src, ref.args); err != nil {
+			errCollector.add(IssueInvalidExecutorArgs, ref.path, "%s '%s' at '%s' has invalid args: %s", ref.kind, ref.src, ref.path, err)
+		}
+	})
+
+	return errCollector.err()
+}
+
+// ValidateQuantumMachineExecutors checks that every executor src in the model resolves to a builtin or
+// registered executor of the right kind. It is meant to run at service boot, once all custom executors
+// have been registered, since at runtime a missing executor silently falls back to a default
+// (observers approve, conditions reject, actions and invokes do nothing).
+// Builtin executors used with the wrong kind (e.g. a builtin:observer:* used as a condition) and executors
+// without src are reported too.
+func ValidateQuantumMachineExecutors(source *theoretical.QuantumMachineModel) error {
+	if source == nil {
+		return fmt.Errorf("source model cannot be nil")
+	}
+
+	errCollector := &validationIssueCollector{}
+
+	walkExecutorReferences(source, func(ref executorReference) {
+		if ref.src == "" {
+			errCollector.add(IssueEmptyExecutorSrc, ref.path, "%s at '%s' has an empty src (runtime fallback: %s)", ref.kind, ref.path, emptySrcFallback[ref.kind])
+			return
+		}
+
+		if kind, isBuiltin := builtin.BuiltinKind(ref.src); isBuiltin && kind != ref.kind {
+			errCollector.add(IssueExecutorKindMismatch, ref.path, "%s at '%s' uses builtin '%s' which is not a %s executor", ref.kind, ref.path, ref.src, ref.kind)
+			return
+		}
+
+		if !builtin.IsRegistered(ref.kind, ref.src) {
+			errCollector.add(IssueUnregisteredExecutor, ref.path, "%s '%s' at '%s' is not registered (runtime fallback: %s)", ref.kind, ref.src, ref.path, missingExecutorFallback[ref.kind])
+		}
+	})
+
+	return errCollector.err()
+}
+
+// missingExecutorFallback describes what the runtime does when an executor src cannot be resolved.
+var missingExecutorFallback = map[builtin.ExecutorKind]string{
+	builtin.ExecutorKindObserver:  "approves",
+	builtin.ExecutorKindCondition: "rejects",
+	builtin.ExecutorKindAction:    "does nothing",
+	builtin.ExecutorKindInvoke:    "does nothing",
+}
+
+// emptySrcFallback describes what the runtime does with an executor without src.
+var emptySrcFallback = map[builtin.ExecutorKind]string{
+	builtin.ExecutorKindObserver:  "approves",
+	builtin.ExecutorKindCondition: "approves",
+	builtin.ExecutorKindAction:    "does nothing",
+	builtin.ExecutorKindInvoke:    "does nothing",
+}
+
+// executorReference is an executor model found while walking a definition.
+type executorReference struct {
+	kind builtin.ExecutorKind
+	src  string
+	args map[string]any
+	// path is the JSON Pointer of the executor model inside the definition.
+	path string
+}
+
+// walkExecutorReferences calls fn for every executor model in the definition, in a deterministic order.
+func walkExecutorReferences(model *theoretical.QuantumMachineModel, fn func(executorReference)) {
+	if model == nil {
+		return
+	}
+
+	walkConstantsExecutors(model.UniversalConstants, util.JSONPointer("universalConstants"), fn)
+
+	for _, universeID := range util.SortedKeys(model.Universes) {
+		universe := model.Universes[universeID]
+		if universe == nil {
+			continue
+		}
+		universePath := util.JSONPointer("universes", universeID)
+
+		walkConstantsExecutors(universe.UniversalConstants, universePath+util.JSONPointer("universalConstants"), fn)
+
+		for _, realityID := range util.SortedKeys(universe.Realities) {
+			reality := universe.Realities[realityID]
+			if reality == nil {
+				continue
+			}
+			realityPath := universePath + util.JSONPointer("realities", realityID)
+
+			for i, observer := range reality.Observers {
+				if observer != nil {
+					fn(executorReference{
+						kind: builtin.ExecutorKindObserver,
+						src:  observer.Src,
+						args: observer.Args,
+						path: realityPath + util.JSONPointer("observers", strconv.Itoa(i)),
+					})
+				}
+			}
+
+			walkActions(reality.EntryActions, realityPath+util.JSONPointer("entryActions"), fn)
+			walkActions(reality.ExitActions, realityPath+util.JSONPointer("exitActions"), fn)
+			walkInvokes(reality.EntryInvokes, realityPath+util.JSONPointer("entryInvokes"), fn)
+			walkInvokes(reality.ExitInvokes, realityPath+util.JSONPointer("exitInvokes"), fn)
+
+			for i, transition := range reality.Always {
+				walkTransitionExecutors(transition, realityPath+util.JSONPointer("always", strconv.Itoa(i)), fn)
+			}
+
+			for _, eventName := range util.SortedKeys(reality.On) {
+				for i, transition := range reality.On[eventName] {
+					walkTransitionExecutors(transition, realityPath+util.JSONPointer("on", eventName, strconv.Itoa(i)), fn)
+				}
+			}
+		}
+	}
+}
+
+func walkConstantsExecutors(constants *theoretical.UniversalConstantsModel, path string, fn func(executorReference)) {
+	if constants == nil {
+		return
+	}
+	walkActions(constants.EntryActions, path+util.JSONPointer("entryActions"), fn)
+	walkActions(constants.ExitActions, path+util.JSONPointer("exitActions"), fn)
+	walkActions(constants.ActionsOnTransition, path+util.JSONPointer("actionsOnTransition"), fn)
+	walkInvokes(constants.EntryInvokes, path+util.JSONPointer("entryInvokes"), fn)
+	walkInvokes(constants.ExitInvokes, path+util.JSONPointer("exitInvokes"), fn)
+	walkInvokes(constants.InvokesOnTransition, path+util.JSONPointer("invokesOnTransition"), fn)
+}
+
+func walkTransitionExecutors(transition *theoretical.TransitionModel, path string, fn func(executorReference)) {
+	if transition == nil {
+		return
+	}
+
+	if transition.Condition != nil {
+		fn(executorReference{
+			kind: builtin.ExecutorKindCondition,
+			src:  transition.Condition.Src,
+			args: transition.Condition.Args,
+			path: path + util.JSONPointer("condition"),
+		})
+	}
+
+	for i, condition := range transition.Conditions {
+		if condition != nil {
+			fn(executorReference{
+				kind: builtin.ExecutorKindCondition,
+				src:  condition.Src,
+				args: condition.Args,
+				path: path + util.JSONPointer("conditions", strconv.Itoa(i)),
+			})
+		}
+	}
+
+	walkActions(transition.Actions, path+util.JSONPointer("actions"), fn)
+	walkInvokes(transition.Invokes, path+util.JSONPointer("invokes"), fn)
+}
+
+func walkActions(actions []*theoretical.ActionModel, path string, fn func(executorReference)) {
+	for i, action := range actions {
+		if action != nil {
+			fn(executorReference{
+				kind: builtin.ExecutorKindAction,
+				src:  action.Src,
+				args: action.Args,
+				path: path + util.JSONPointer(strconv.Itoa(i)),
+			})
+		}
+	}
+}
+
+func walkInvokes(invokes []*theoretical.InvokeModel, path string, fn func(executorReference)) {
+	for i, invoke := range invokes {
+		if invoke != nil {
+			fn(executorReference{
+				kind: builtin.ExecutorKindInvoke,
+				src:  invoke.Src,
+				args: invoke.Args,
+				path: path + util.JSONPointer(strconv.Itoa(i)),
+			})
+		}
+	}
+}
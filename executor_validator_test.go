@@ -0,0 +1,168 @@
+package statepro
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/rendis/statepro/v3/builtin"
+	"github.com/rendis/statepro/v3/instrumentation"
+	"github.com/rendis/statepro/v3/internal/util"
+)
+
+const executorArgsMachine = `{
+	"id":"machine",
+	"canonicalName":"machine",
+	"version":"1.0.0",
+	"initials":["U:main"],
+	"universalConstants":{
+		"entryActions":[{"src":"test:action:sendEmail","args":{"template":"welcome"}}]
+	},
+	"universes":{
+		"main":{
+			"id":"main",
+			"canonicalName":"main",
+			"version":"1.0.0",
+			"initial":"A",
+			"realities":{
+				"A":{
+					"id":"A",
+					"type":"transition",
+					"on":{"go":[{"targets":["END"],"actions":[{"src":"test:action:sendEmail","args":{"tempalte":"bye"}}]}]}
+				},
+				"END":{
+					"id":"END",
+					"type":"final",
+					"observers":[{"src":"builtin:observer:totalEventsBetweenLimits","args":{"minimun":1}}]
+				}
+			}
+		}
+	}
+}`
+
+func TestValidateQuantumMachineExecutorArgs(t *testing.T) {
+	type emailArgs struct {
+		Template string `json:"template" statepro:"required"`
+	}
+	err := builtin.RegisterAction(
+		"test:action:sendEmail",
+		func(ctx context.Context, args instrumentation.ActionExecutorArgs) error { return nil },
+		builtin.WithArgsSchema(builtin.NewStructArgs[emailArgs]()),
+	)
+	if err != nil {
+		t.Fatalf("unexpected register error: %v", err)
+	}
+
+	model, err := DeserializeQuantumMachineFromBinary([]byte(executorArgsMachine))
+	if err != nil {
+		t.Fatalf("unexpected deserialize error: %v", err)
+	}
+
+	err = ValidateQuantumMachineExecutorArgs(model)
+	if err == nil {
+		t.Fatal("expected args validation error")
+	}
+
+	msg := err.Error()
+	for _, want := range []string{
+		"/universes/main/realities/A/on/go/0/actions/0",
+		"/universes/main/realities/END/observers/0",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Fatalf("expected error to mention %q, got: %s", want, msg)
+		}
+	}
+	if strings.Contains(msg, "/universalConstants/entryActions/0") {
+		t.Fatalf("valid constants action must not be reported, got: %s", msg)
+	}
+}
+
+func TestValidateQuantumMachineExecutorArgs_Fixtures(t *testing.T) {
+	for _, file := range []string{"example/sm/state_machine.json", "example/bot/state_machine.json"} {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("error reading fixture %s: %v", file, err)
+		}
+		model, err := DeserializeQuantumMachineFromBinary(b)
+		if err != nil {
+			t.Fatalf("error deserializing fixture %s: %v", file, err)
+		}
+		if err = ValidateQuantumMachineExecutorArgs(model); err != nil {
+			t.Fatalf("expected fixture %s args to be valid, got: %v", file, err)
+		}
+	}
+}
+
+func TestJSONPointerEscaping(t *testing.T) {
+	if got := util.JSONPointer("on", "a/b", "c~d"); got != "/on/a~1b/c~0d" {
+		t.Fatalf("unexpected pointer: %s", got)
+	}
+}
+
+func TestValidateQuantumMachineExecutors(t *testing.T) {
+	err := builtin.RegisterCondition(
+		"test:condition:isPremium",
+		func(ctx context.Context, args instrumentation.ConditionExecutorArgs) (bool, error) { return true, nil },
+	)
+	if err != nil {
+		t.Fatalf("unexpected register error: %v", err)
+	}
+
+	model, err := DeserializeQuantumMachineFromBinary([]byte(`{
+		"id":"machine",
+		"canonicalName":"machine",
+		"version":"1.0.0",
+		"initials":["U:main"],
+		"universes":{
+			"main":{
+				"id":"main",
+				"canonicalName":"main",
+				"version":"1.0.0",
+				"initial":"A",
+				"realities":{
+					"A":{
+						"id":"A",
+						"type":"transition",
+						"entryActions":[{"src":"builtin:action:logBasicInfo"},{"src":"test:action:missing"}],
+						"exitActions":[{"src":""}],
+						"on":{
+							"go":[
+								{"targets":["END"],"condition":{"src":"builtin:observer:alwaysTrue"}},
+								{"targets":["END"],"conditions":[{"src":"test:condition:isPremium"},{"src":"test:condition:missing"}]}
+							]
+						}
+					},
+					"END":{"id":"END","type":"final","observers":[{"src":"builtin:observer:doesNotExist"}]}
+				}
+			}
+		}
+	}`))
+	if err != nil {
+		t.Fatalf("unexpected deserialize error: %v", err)
+	}
+
+	err = ValidateQuantumMachineExecutors(model)
+	if err == nil {
+		t.Fatal("expected unresolved executors")
+	}
+
+	msg := err.Error()
+	for _, want := range []string{
+		"action 'test:action:missing' at '/universes/main/realities/A/entryActions/1' is not registered",
+		"condition at '/universes/main/realities/A/on/go/0/condition' uses builtin 'builtin:observer:alwaysTrue'",
+		"condition 'test:condition:missing' at '/universes/main/realities/A/on/go/1/conditions/1' is not registered",
+		"observer 'builtin:observer:doesNotExist' at '/universes/main/realities/END/observers/0' is not registered",
+		"action at '/universes/main/realities/A/exitActions/0' has an empty src (runtime fallback: does nothing)",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Fatalf("expected error to contain %q, got: %s", want, msg)
+		}
+	}
+
+	for _, unexpected := range []string{"entryActions/0", "conditions/0"} {
+		if strings.Contains(msg, unexpected) {
+			t.Fatalf("resolved executor %q must not be reported, got: %s", unexpected, msg)
+		}
+	}
+}
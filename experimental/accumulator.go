@@ -2,7 +2,9 @@ package experimental
 
 import (
 	"fmt"
+
 	"github.com/rendis/statepro/v3/instrumentation"
+	"github.com/rendis/statepro/v3/internal/util"
 )
 
 // newEventAccumulator returns a new Event accumulator
@@ -18,6 +20,47 @@ type eventAccumulator struct {
 	RealitiesEvents map[string][]*Event `json:"realitiesEvents,omitempty"`
 }
 
+// eventAccumulatorFromSnapshot returns the Event accumulator of a snapshot, see eventAccumulator.snapshot
+func eventAccumulatorFromSnapshot(snapshot *instrumentation.AccumulatorSnapshot) *eventAccumulator {
+	return &eventAccumulator{RealitiesEvents: eventsFromSnapshot(snapshot.RealitiesEvents)}
+}
+
+// snapshot returns a copy of the accumulated events
+func (ea *eventAccumulator) snapshot() *instrumentation.AccumulatorSnapshot {
+	return &instrumentation.AccumulatorSnapshot{RealitiesEvents: eventsToSnapshot(ea.RealitiesEvents)}
+}
+
+func eventsToSnapshot(realitiesEvents map[string][]*Event) map[string][]instrumentation.EventSnapshot {
+	if realitiesEvents == nil {
+		return nil
+	}
+	snapshot := make(map[string][]instrumentation.EventSnapshot, len(realitiesEvents))
+	for realityName, events := range realitiesEvents {
+		snapshotEvents := make([]instrumentation.EventSnapshot, len(events))
+		for i, evt := range events {
+			snapshotEvents[i] = instrumentation.EventSnapshot{Name: evt.Name, Data: cloneEventData(evt.Data), EvtType: evt.EvtType, Flags: evt.Flags}
+		}
+		snapshot[realityName] = snapshotEvents
+	}
+	return snapshot
+}
+
+func eventsFromSnapshot(snapshot map[string][]instrumentation.EventSnapshot) map[string][]*Event {
+	realitiesEvents := make(map[string][]*Event, len(snapshot))
+	for realityName, snapshotEvents := range snapshot {
+		events := make([]*Event, len(snapshotEvents))
+		for i, evt := range snapshotEvents {
+			events[i] = &Event{Name: evt.Name, Data: cloneEventData(evt.Data), EvtType: evt.EvtType, Flags: evt.Flags}
+		}
+		realitiesEvents[realityName] = events
+	}
+	return realitiesEvents
+}
+
+func cloneEventData(data map[string]any) map[string]any {
+	return util.CloneMap(data)
+}
+
 func (ea *eventAccumulator) String() string {
 	var msg string
 	for realityName, events := range ea.RealitiesEvents {
@@ -2,6 +2,7 @@ package experimental
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"sync/atomic"
@@ -306,8 +307,8 @@ func TestAdv_Condition_MutatesMetadataDuringGuard(t *testing.T) {
 	}
 	assertReality(t, u, "stateB")
 	snap := qm.GetSnapshot()
-	raw, ok := snap.Snapshots["u1"]["metadata"].(map[string]any)
-	if !ok {
+	raw := snap.Snapshots["u1"].Metadata
+	if raw == nil {
 		t.Fatalf("snapshot metadata missing: %#v", snap.Snapshots["u1"])
 	}
 	if raw["gate"] != "open" {
@@ -535,6 +536,9 @@ func TestAdv_NotifyCascade_DepthOverLimitErrors(t *testing.T) {
 	if err == nil {
 		t.Fatal("expected cascade depth error")
 	}
+	if !errors.Is(err, ErrExternalTargetDepth) {
+		t.Fatalf("expected ErrExternalTargetDepth, got: %v", err)
+	}
 }
 
 // ---------------------------------------------------------------------------
@@ -0,0 +1,50 @@
+package experimental
+
+import (
+	"fmt"
+
+	"github.com/rendis/statepro/v3/instrumentation"
+	"github.com/rendis/statepro/v3/internal/util"
+	"github.com/rendis/statepro/v3/theoretical"
+)
+
+// CompiledModel is a machine definition prepared once to build many machines, e.g. one per workflow instance.
+// The work that only depends on the definition (model fingerprints) is done by CompileModel instead of by every
+// machine. The model is shared by the machines and must not be modified after compiling.
+type CompiledModel struct {
+	model        *theoretical.QuantumMachineModel
+	fingerprints *modelFingerprints
+	universeIDs  []string
+}
+
+// CompileModel prepares the model to build machines with NewMachine.
+func CompileModel(model *theoretical.QuantumMachineModel) (*CompiledModel, error) {
+	if model == nil {
+		return nil, fmt.Errorf("model cannot be nil")
+	}
+	fingerprints, err := newModelFingerprints(model)
+	if err != nil {
+		return nil, err
+	}
+	return &CompiledModel{model: model, fingerprints: fingerprints, universeIDs: util.SortedKeys(model.Universes)}, nil
+}
+
+// Model returns the compiled definition.
+func (c *CompiledModel) Model() *theoretical.QuantumMachineModel {
+	return c.model
+}
+
+// NewMachine builds a machine, not initialized, with a universe per universe of the definition.
+func (c *CompiledModel) NewMachine(opts ...MachineOption) (instrumentation.QuantumMachine, error) {
+	universes := make([]*ExUniverse, 0, len(c.universeIDs))
+	for _, id := range c.universeIDs {
+		if model := c.model.Universes[id]; model != nil {
+			universes = append(universes, NewExUniverse(model))
+		}
+	}
+	qm, err := newExQuantumMachine(c.model, c.fingerprints, universes, opts)
+	if err != nil {
+		return nil, err
+	}
+	return qm, nil
+}
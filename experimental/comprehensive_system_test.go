@@ -188,13 +188,9 @@ func TestSnapshot_WithMetadata(t *testing.T) {
 	if uSnap == nil {
 		t.Fatal("expected universe snapshot for u1")
 	}
-	md, ok := uSnap["metadata"]
-	if !ok {
-		t.Fatal("expected metadata key in universe snapshot")
-	}
-	mdMap, ok := md.(map[string]any)
-	if !ok {
-		t.Fatalf("expected metadata to be map[string]any, got %T", md)
+	mdMap := uSnap.Metadata
+	if mdMap == nil {
+		t.Fatal("expected metadata in universe snapshot")
 	}
 	if mdMap["myKey"] != "myValue" {
 		t.Fatalf("expected metadata[myKey]=myValue, got %v", mdMap["myKey"])
@@ -248,10 +244,10 @@ func TestSnapshot_LoadInvalid(t *testing.T) {
 
 	// Create a snapshot with a universe that references a reality that does not exist
 	invalidSnap := &instrumentation.MachineSnapshot{
-		Snapshots: map[string]instrumentation.SerializedUniverseSnapshot{
+		Snapshots: map[string]*instrumentation.UniverseSnapshot{
 			"u1": {
-				"initialized":    true,
-				"currentReality": "nonExistentReality",
+				Initialized:    true,
+				CurrentReality: strPtr("nonExistentReality"),
 			},
 		},
 	}
@@ -528,12 +524,8 @@ func TestSnapshot_AccumulatorPreserved(t *testing.T) {
 		t.Fatal("expected u1 snapshot")
 	}
 	// Verify the snapshot captured the superposition state
-	inSup, ok := uSnap["inSuperposition"]
-	if !ok {
-		t.Fatal("expected inSuperposition key in snapshot")
-	}
-	if inSup != true {
-		t.Fatalf("expected inSuperposition=true, got %v", inSup)
+	if !uSnap.InSuperposition {
+		t.Fatal("expected inSuperposition=true")
 	}
 }
 
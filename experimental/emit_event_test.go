@@ -2,6 +2,7 @@ package experimental
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"testing"
@@ -494,6 +495,9 @@ func TestEmitEvent_InfiniteLoop(t *testing.T) {
 	if !strings.Contains(err.Error(), "depth exceeded") {
 		t.Fatalf("expected 'depth exceeded' in error, got: %v", err)
 	}
+	if !errors.Is(err, ErrEmitDepth) {
+		t.Fatalf("expected ErrEmitDepth, got: %v", err)
+	}
 }
 
 // 12. Chain within depth limit → works
@@ -0,0 +1,60 @@
+package experimental
+
+import (
+	"github.com/rendis/statepro/v3/builtin"
+	"github.com/rendis/statepro/v3/instrumentation"
+)
+
+// Executors are machine-scoped executors, keyed by src. A machine built with them (see WithExecutors) resolves
+// them before the builtin registry; other machines do not see them.
+type Executors struct {
+	Observers  map[string]instrumentation.ObserverFn
+	Actions    map[string]instrumentation.ActionFn
+	Invokes    map[string]instrumentation.InvokeFn
+	Conditions map[string]instrumentation.ConditionFn
+}
+
+// WithExecutors sets executors resolved by the machine before the builtin registry.
+func WithExecutors(executors Executors) MachineOption {
+	return func(qm *ExQuantumMachine) {
+		qm.executors = &executors
+	}
+}
+
+// The lookups below accept a nil receiver (no machine-scoped executors) and fall back to the builtin registry.
+
+func (e *Executors) observer(src string) instrumentation.ObserverFn {
+	if e != nil {
+		if fn := e.Observers[src]; fn != nil {
+			return fn
+		}
+	}
+	return builtin.GetObserver(src)
+}
+
+func (e *Executors) action(src string) instrumentation.ActionFn {
+	if e != nil {
+		if fn := e.Actions[src]; fn != nil {
+			return fn
+		}
+	}
+	return builtin.GetAction(src)
+}
+
+func (e *Executors) invoke(src string) instrumentation.InvokeFn {
+	if e != nil {
+		if fn := e.Invokes[src]; fn != nil {
+			return fn
+		}
+	}
+	return builtin.GetInvoke(src)
+}
+
+func (e *Executors) condition(src string) instrumentation.ConditionFn {
+	if e != nil {
+		if fn := e.Conditions[src]; fn != nil {
+			return fn
+		}
+	}
+	return builtin.GetCondition(src)
+}
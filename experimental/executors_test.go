@@ -0,0 +1,56 @@
+package experimental
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/rendis/statepro/v3/instrumentation"
+	"github.com/rendis/statepro/v3/theoretical"
+)
+
+func TestWithExecutors(t *testing.T) {
+	var calls []string
+	registerTestAction(t, "test:executors:entry", func(context.Context, instrumentation.ActionExecutorArgs) error {
+		calls = append(calls, "registry")
+		return nil
+	})
+	executors := Executors{
+		Actions: map[string]instrumentation.ActionFn{
+			"test:executors:entry": func(context.Context, instrumentation.ActionExecutorArgs) error {
+				calls = append(calls, "scoped")
+				return nil
+			},
+		},
+		Conditions: map[string]instrumentation.ConditionFn{
+			"test:executors:ready": func(context.Context, instrumentation.ConditionExecutorArgs) (bool, error) {
+				return true, nil
+			},
+		},
+	}
+	realities := func() map[string]*theoretical.RealityModel {
+		return map[string]*theoretical.RealityModel{
+			"PENDING": newTransitionReality("PENDING", withEntryAction("test:executors:entry"),
+				withOnTransition("go", []string{"DONE"}, &theoretical.ConditionModel{Src: "test:executors:ready"})),
+			"DONE": newFinalReality("DONE"),
+		}
+	}
+
+	scoped, scopedU := buildVersionedQM(t, "1.0.0", realities(), WithExecutors(executors))
+	other, otherU := buildVersionedQM(t, "1.0.0", realities())
+	for _, qm := range []*ExQuantumMachine{scoped, other} {
+		if err := qm.Init(context.Background(), nil); err != nil {
+			t.Fatalf("Init failed: %v", err)
+		}
+		if _, err := qm.SendEvent(context.Background(), NewEventBuilder("go").Build()); err != nil {
+			t.Fatalf("SendEvent failed: %v", err)
+		}
+	}
+
+	// the scoped executors win over the registry, and only in their machine
+	if strings.Join(calls, ",") != "scoped,registry" {
+		t.Fatalf("expected the scoped action then the registered one, got %v", calls)
+	}
+	assertReality(t, scopedU, "DONE")
+	assertReality(t, otherU, "PENDING")
+}
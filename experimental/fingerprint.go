@@ -0,0 +1,115 @@
+package experimental
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/rendis/statepro/v3/instrumentation"
+	"github.com/rendis/statepro/v3/internal/util"
+	"github.com/rendis/statepro/v3/theoretical"
+)
+
+// FingerprintPolicy is what LoadSnapshot does with a snapshot taken with a definition whose fingerprint
+// (see theoretical.QuantumMachineModel.Fingerprint) differs from the one of the running machine.
+type FingerprintPolicy int
+
+const (
+	// FingerprintIgnore loads the snapshot without comparing fingerprints (default).
+	FingerprintIgnore FingerprintPolicy = iota
+
+	// FingerprintWarn logs a warning for every mismatch and loads the snapshot.
+	FingerprintWarn
+
+	// FingerprintRefuse returns a *SnapshotValidationError listing the mismatches, without changing the machine.
+	FingerprintRefuse
+)
+
+// WithFingerprintPolicy sets what LoadSnapshot does on fingerprint mismatches. Snapshots without fingerprints
+// (taken before they were recorded) are never reported. In strict mode (see WithStrictSnapshotLoad) mismatches
+// are always refused.
+func WithFingerprintPolicy(policy FingerprintPolicy) MachineOption {
+	return func(qm *ExQuantumMachine) {
+		qm.fingerprintPolicy = policy
+	}
+}
+
+// modelFingerprints are the fingerprints of a machine definition and of its universes.
+type modelFingerprints struct {
+	machine string
+
+	// universes key: universe id
+	universes map[string]string
+}
+
+func newModelFingerprints(model *theoretical.QuantumMachineModel) (*modelFingerprints, error) {
+	machine, err := model.Fingerprint()
+	if err != nil {
+		return nil, fmt.Errorf("error computing fingerprint of machine '%s': %w", model.ID, err)
+	}
+	fingerprints := &modelFingerprints{machine: machine, universes: map[string]string{}}
+	for id, universe := range model.Universes {
+		if universe == nil {
+			continue
+		}
+		if fingerprints.universes[id], err = universe.Fingerprint(); err != nil {
+			return nil, fmt.Errorf("error computing fingerprint of universe '%s': %w", id, err)
+		}
+	}
+	return fingerprints, nil
+}
+
+func (f *modelFingerprints) universe(id string) (string, bool) {
+	if f == nil {
+		return "", false
+	}
+	fingerprint, ok := f.universes[id]
+	return fingerprint, ok
+}
+
+// issues compares the fingerprints recorded in the snapshot with the ones of the definition.
+func (f *modelFingerprints) issues(snapshot *instrumentation.MachineSnapshot) []SnapshotIssue {
+	var issues []SnapshotIssue
+	if snapshot.Fingerprint != "" && snapshot.Fingerprint != f.machine {
+		issues = append(issues, SnapshotIssue{
+			Code:    SnapshotIssueFingerprintMismatch,
+			Message: fmt.Sprintf("snapshot was taken with machine fingerprint '%s', the definition has '%s'", snapshot.Fingerprint, f.machine),
+		})
+	}
+	for _, id := range util.SortedKeys(snapshot.UniverseFingerprints) {
+		expected, ok := f.universes[id]
+		if recorded := snapshot.UniverseFingerprints[id]; ok && recorded != "" && recorded != expected {
+			issues = append(issues, SnapshotIssue{
+				Code:     SnapshotIssueFingerprintMismatch,
+				Universe: id,
+				Message:  fmt.Sprintf("snapshot of universe '%s' was taken with fingerprint '%s', the definition has '%s'", id, recorded, expected),
+			})
+		}
+	}
+	return issues
+}
+
+// validateSnapshot is ValidateSnapshot with the fingerprints computed when the machine was created.
+func (qm *ExQuantumMachine) validateSnapshot(snapshot *instrumentation.MachineSnapshot) error {
+	if qm.fingerprints == nil {
+		return ValidateSnapshot(qm.model, snapshot)
+	}
+	return validateSnapshot(qm.model, qm.fingerprints, snapshot)
+}
+
+// checkFingerprints applies the fingerprint policy of the machine to a snapshot about to be loaded.
+func (qm *ExQuantumMachine) checkFingerprints(snapshot *instrumentation.MachineSnapshot) error {
+	if qm.fingerprintPolicy == FingerprintIgnore || qm.fingerprints == nil {
+		return nil
+	}
+	issues := qm.fingerprints.issues(snapshot)
+	if len(issues) == 0 {
+		return nil
+	}
+	if qm.fingerprintPolicy == FingerprintRefuse {
+		return &SnapshotValidationError{Issues: issues}
+	}
+	for _, issue := range issues {
+		slog.Warn("loading snapshot taken with another definition", "machine", qm.model.ID, "universe", issue.Universe, "issue", issue.Message)
+	}
+	return nil
+}
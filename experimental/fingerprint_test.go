@@ -0,0 +1,133 @@
+package experimental
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/rendis/statepro/v3/instrumentation"
+	"github.com/rendis/statepro/v3/theoretical"
+)
+
+func TestGetSnapshot_RecordsFingerprints(t *testing.T) {
+	snapshot := validSnapshot(t)
+	model := snapshotValidationModel()
+
+	expected, _ := model.Fingerprint()
+	if snapshot.Fingerprint != expected {
+		t.Fatalf("expected machine fingerprint %q, got %q", expected, snapshot.Fingerprint)
+	}
+	for id, universe := range model.Universes {
+		expected, _ := universe.Fingerprint()
+		if snapshot.UniverseFingerprints[id] != expected {
+			t.Fatalf("expected fingerprint %q for universe '%s', got %q", expected, id, snapshot.UniverseFingerprints[id])
+		}
+	}
+}
+
+// changedSnapshot is a snapshot taken with a definition that differs from snapshotValidationModel under the same version.
+func changedSnapshot(t *testing.T) *instrumentation.MachineSnapshot {
+	t.Helper()
+	snapshot := validSnapshot(t)
+	model := snapshotValidationModel()
+	model.Universes["idle"].Realities["IDLE"].Description = strPtr("changed")
+	snapshot.Fingerprint, _ = model.Fingerprint()
+	snapshot.UniverseFingerprints["idle"], _ = model.Universes["idle"].Fingerprint()
+	return snapshot
+}
+
+func TestLoadSnapshot_FingerprintPolicy(t *testing.T) {
+	for _, policy := range []FingerprintPolicy{FingerprintIgnore, FingerprintWarn} {
+		qm, _ := buildSnapshotValidationQM(t, WithFingerprintPolicy(policy))
+		if err := qm.LoadSnapshot(changedSnapshot(t), nil); err != nil {
+			t.Fatalf("policy %d: expected snapshot to load, got %v", policy, err)
+		}
+	}
+
+	qm, _ := buildSnapshotValidationQM(t, WithFingerprintPolicy(FingerprintRefuse))
+	err := qm.LoadSnapshot(changedSnapshot(t), nil)
+	var validationErr *SnapshotValidationError
+	if !errors.As(err, &validationErr) || len(validationErr.Issues) != 2 {
+		t.Fatalf("expected machine and universe fingerprint mismatches, got %v", err)
+	}
+	if issue := validationErr.Issues[1]; issue.Code != SnapshotIssueFingerprintMismatch || issue.Universe != "idle" {
+		t.Fatalf("unexpected issue %+v", issue)
+	}
+	for _, u := range qm.universes {
+		if u.initialized {
+			t.Fatalf("expected refused load to leave universe '%s' untouched", u.model.ID)
+		}
+	}
+
+	// snapshots taken before fingerprints were recorded are loaded
+	legacy := changedSnapshot(t)
+	legacy.Fingerprint, legacy.UniverseFingerprints = "", nil
+	if err = qm.LoadSnapshot(legacy, nil); err != nil {
+		t.Fatalf("expected snapshot without fingerprints to load, got %v", err)
+	}
+}
+
+func TestValidateSnapshot_FingerprintMismatch(t *testing.T) {
+	err := ValidateSnapshot(snapshotValidationModel(), changedSnapshot(t))
+	var validationErr *SnapshotValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("expected SnapshotValidationError, got %v", err)
+	}
+	for _, issue := range validationErr.Issues {
+		if issue.Code != SnapshotIssueFingerprintMismatch {
+			t.Fatalf("expected only fingerprint issues, got %+v", issue)
+		}
+	}
+}
+
+func TestMigrateSnapshot_Fingerprints(t *testing.T) {
+	snapshot := v1Snapshot(t)
+	to, _ := buildVersionedQM(t, "3.0.0", v3Realities())
+
+	migrated, err := MigrateSnapshot(snapshot, nil, to.model, v3Migrations...)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if migrated.Fingerprint != "" || len(migrated.UniverseFingerprints) != 0 {
+		t.Fatalf("expected migrated universes to drop their fingerprints, got %q %v", migrated.Fingerprint, migrated.UniverseFingerprints)
+	}
+
+	same, _ := buildVersionedQM(t, "1.0.0", map[string]*theoretical.RealityModel{
+		"PENDING": newTransitionReality("PENDING"),
+		"WAITING": newTransitionReality("WAITING"),
+	})
+	kept, err := MigrateSnapshot(snapshot, nil, same.model)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if kept.Fingerprint != snapshot.Fingerprint || kept.UniverseFingerprints["u1"] != snapshot.UniverseFingerprints["u1"] {
+		t.Fatal("expected fingerprints of unmigrated universes to be kept")
+	}
+}
+
+func TestCompileModel(t *testing.T) {
+	if _, err := CompileModel(nil); err == nil {
+		t.Fatal("expected error for a nil model")
+	}
+	compiled, err := CompileModel(snapshotValidationModel())
+	if err != nil {
+		t.Fatalf("CompileModel failed: %v", err)
+	}
+
+	// machines built from the compiled model are independent and record the same snapshots as NewExQuantumMachine
+	first, _ := compiled.NewMachine()
+	second, _ := compiled.NewMachine()
+	if err = first.Init(context.Background(), nil); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	if _, err = first.SendEvent(context.Background(), NewEventBuilder("go").Build()); err != nil {
+		t.Fatalf("SendEvent failed: %v", err)
+	}
+	if !reflect.DeepEqual(first.GetSnapshot(), validSnapshot(t)) {
+		t.Fatalf("unexpected snapshot %+v", first.GetSnapshot())
+	}
+	if len(second.GetSnapshot().Tracking) != 0 {
+		t.Fatal("expected machines built from the same compiled model not to share state")
+	}
+}
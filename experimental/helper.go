@@ -74,12 +74,3 @@ func sortUniversesByID(universes []*ExUniverse) {
 		return universes[i].model.ID < universes[j].model.ID
 	})
 }
-
-func sortedMapKeys[V any](m map[string]V) []string {
-	keys := make([]string, 0, len(m))
-	for k := range m {
-		keys = append(keys, k)
-	}
-	sort.Strings(keys)
-	return keys
-}
@@ -5,18 +5,16 @@ import (
 	"sync"
 
 	"github.com/rendis/statepro/v3/instrumentation"
+	"github.com/rendis/statepro/v3/internal/util"
 	"github.com/rendis/statepro/v3/theoretical"
 )
 
+// cloneAnyMap returns a deep copy of src (see util.CloneMap), an empty map for a nil one.
 func cloneAnyMap(src map[string]any) map[string]any {
 	if src == nil {
 		return map[string]any{}
 	}
-	dst := make(map[string]any, len(src))
-	for k, v := range src {
-		dst[k] = v
-	}
-	return dst
+	return util.CloneMap(src)
 }
 
 func withMetadataLock(mu *sync.Mutex, fn func()) {
@@ -130,6 +128,8 @@ func (o *observerExecutorArgs) UpdateUniverseMetadata(md map[string]any) {
 
 // --------- ActionExecutorArgs ---------//
 
+var _ instrumentation.SnapshotViewProvider = (*actionExecutorArgs)(nil)
+
 type actionExecutorArgs struct {
 	context               any
 	realityName           string
@@ -140,7 +140,7 @@ type actionExecutorArgs struct {
 	event                 instrumentation.Event
 	action                theoretical.ActionModel
 	actionType            instrumentation.ActionType
-	getSnapshotFn         func() *instrumentation.MachineSnapshot
+	view                  *machineView
 	emittedEvents         *[]instrumentation.EmittedEvent
 }
 
@@ -173,7 +173,17 @@ func (a *actionExecutorArgs) GetActionType() instrumentation.ActionType {
 }
 
 func (a *actionExecutorArgs) GetSnapshot() *instrumentation.MachineSnapshot {
-	return a.getSnapshotFn()
+	if a.view == nil {
+		return nil
+	}
+	return a.view.GetSnapshot()
+}
+
+func (a *actionExecutorArgs) GetSnapshotView() instrumentation.SnapshotView {
+	if a.view == nil {
+		return newMachineView(nil, nil)
+	}
+	return a.view
 }
 
 func (a *actionExecutorArgs) GetUniverseMetadata() map[string]any {
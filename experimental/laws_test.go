@@ -41,7 +41,7 @@ func (m *mockSnapshot) AddActiveUniverse(canonicalName, realityName string)
 func (m *mockSnapshot) AddFinalizedUniverse(canonicalName, realityName string)         {}
 func (m *mockSnapshot) AddSuperpositionUniverse(canonicalName, before string)          {}
 func (m *mockSnapshot) AddSuperpositionUniverseFinalized(canonicalName, before string) {}
-func (m *mockSnapshot) AddUniverseSnapshot(id string, snapshot instrumentation.SerializedUniverseSnapshot) {
+func (m *mockSnapshot) AddUniverseSnapshot(id string, snapshot *instrumentation.UniverseSnapshot) {
 }
 func (m *mockSnapshot) AddTracking(id string, tracking []string)     {}
 func (m *mockSnapshot) GetResume() interface{}                       { return nil }
@@ -133,7 +133,6 @@ func TestActionExecutorArgs_Getters(t *testing.T) {
 		event:                 event,
 		action:                action,
 		actionType:            instrumentation.ActionTypeEntry,
-		getSnapshotFn:         func() *instrumentation.MachineSnapshot { return nil },
 	}
 
 	if args.GetContext() != "testContext" {
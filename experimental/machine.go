@@ -2,11 +2,11 @@ package experimental
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"sync"
 
-	"github.com/rendis/statepro/v3/builtin"
 	"github.com/rendis/statepro/v3/instrumentation"
 	"github.com/rendis/statepro/v3/internal/util"
 	"github.com/rendis/statepro/v3/theoretical"
@@ -20,6 +20,17 @@ const (
 	maxExternalTargetDepth = 10
 )
 
+var (
+	// ErrCyclicTransition is returned when an always/transition cascade visits the same reality twice.
+	ErrCyclicTransition = errors.New("cyclic transition detected")
+
+	// ErrExternalTargetDepth is returned when a cross-universe cascade exceeds maxExternalTargetDepth.
+	ErrExternalTargetDepth = errors.New("external target cascade exceeded maximum depth")
+
+	// ErrEmitDepth is returned when emitted events nest deeper than maxEmitDepth.
+	ErrEmitDepth = errors.New("emitted event depth exceeded maximum")
+)
+
 type initFunc func(context.Context, any, *ExUniverse, []string, instrumentation.Event) ([]string, instrumentation.Event, error)
 
 var qmInitFunctions = map[refType]initFunc{
@@ -31,11 +42,36 @@ var qmInitFunctions = map[refType]initFunc{
 	},
 }
 
-func NewExQuantumMachine(qmm *theoretical.QuantumMachineModel, universes []*ExUniverse) (instrumentation.QuantumMachine, error) {
+func NewExQuantumMachine(qmm *theoretical.QuantumMachineModel, universes []*ExUniverse, opts ...MachineOption) (instrumentation.QuantumMachine, error) {
+	var fingerprints *modelFingerprints
+	if qmm != nil {
+		var err error
+		if fingerprints, err = newModelFingerprints(qmm); err != nil {
+			// snapshots are then taken without fingerprints and fingerprint checks are skipped
+			slog.Warn("model fingerprint unavailable", "error", err)
+		}
+	}
+	qm, err := newExQuantumMachine(qmm, fingerprints, universes, opts)
+	if err != nil {
+		return nil, err
+	}
+	return qm, nil
+}
 
+func newExQuantumMachine(
+	qmm *theoretical.QuantumMachineModel,
+	fingerprints *modelFingerprints,
+	universes []*ExUniverse,
+	opts []MachineOption,
+) (*ExQuantumMachine, error) {
 	qm := &ExQuantumMachine{
-		model:     qmm,
-		universes: map[string]*ExUniverse{},
+		model:        qmm,
+		universes:    map[string]*ExUniverse{},
+		fingerprints: fingerprints,
+	}
+
+	for _, opt := range opts {
+		opt(qm)
 	}
 
 	for _, u := range universes {
@@ -49,10 +85,15 @@ func NewExQuantumMachine(qmm *theoretical.QuantumMachineModel, universes []*ExUn
 		}
 
 		u.constantsLawsExecutor = qm
-		u.getSnapshotFn = qm.snapshotUnlocked
+		u.executors = qm.executors
 		qm.universes[u.model.ID] = u
 	}
 
+	qm.view = newMachineView(qm.universes, qm.snapshotUnlocked)
+	for _, u := range qm.universes {
+		u.view = qm.view
+	}
+
 	return qm, nil
 }
 
@@ -67,6 +108,24 @@ type ExQuantumMachine struct {
 	// key: theoretical.UniverseModel.ID
 	universes map[string]*ExUniverse
 
+	// migrations are applied by LoadSnapshot to snapshots taken with other definition versions
+	migrations []SnapshotMigration
+
+	// strictSnapshotLoad makes LoadSnapshot refuse the snapshots that ValidateSnapshot reports issues for
+	strictSnapshotLoad bool
+
+	// fingerprints of the model, recorded in snapshots; nil when the model cannot be fingerprinted
+	fingerprints *modelFingerprints
+
+	// fingerprintPolicy is what LoadSnapshot does on fingerprint mismatches
+	fingerprintPolicy FingerprintPolicy
+
+	// view is the read-only view of the machine handed to actions
+	view *machineView
+
+	// executors are the machine-scoped executors, resolved before the builtin registry (see WithExecutors)
+	executors *Executors
+
 	// quantumMachineMtx is the mutex for the quantum machine
 	quantumMachineMtx sync.Mutex
 }
@@ -118,6 +177,22 @@ func (qm *ExQuantumMachine) LoadSnapshot(snapshot *instrumentation.MachineSnapsh
 		return nil
 	}
 
+	if len(qm.migrations) > 0 {
+		migrated, err := migrateSnapshot(snapshot, nil, qm.model, qm.migrations)
+		if err != nil {
+			return err
+		}
+		snapshot = migrated
+	}
+
+	if qm.strictSnapshotLoad {
+		if err := qm.validateSnapshot(snapshot); err != nil {
+			return err
+		}
+	} else if err := qm.checkFingerprints(snapshot); err != nil {
+		return err
+	}
+
 	for _, u := range qm.universes {
 		universeSnapshot, ok := snapshot.Snapshots[u.model.ID]
 
@@ -149,12 +224,18 @@ func (qm *ExQuantumMachine) GetSnapshot() *instrumentation.MachineSnapshot {
 
 func (qm *ExQuantumMachine) snapshotUnlocked() *instrumentation.MachineSnapshot {
 	var machineSnapshot = &instrumentation.MachineSnapshot{}
+	if qm.fingerprints != nil {
+		machineSnapshot.Fingerprint = qm.fingerprints.machine
+	}
 
 	for _, u := range qm.universes {
 		universeSnapshot := u.getSnapshot()
 
 		// add snapshot
 		machineSnapshot.AddUniverseSnapshot(u.model.ID, universeSnapshot)
+		if fingerprint, ok := qm.fingerprints.universe(u.model.ID); ok {
+			machineSnapshot.AddUniverseFingerprint(u.model.ID, fingerprint)
+		}
 
 		// resume only if initialized
 		if !u.initialized {
@@ -477,7 +558,7 @@ func (qm *ExQuantumMachine) executeInvoke(ctx context.Context, invoke theoretica
 		invoke:                invoke,
 	}
 
-	if fn := builtin.GetInvoke(invoke.Src); fn != nil {
+	if fn := qm.executors.invoke(invoke.Src); fn != nil {
 		src := invoke.Src
 		go func() {
 			defer func() {
@@ -513,11 +594,11 @@ func (qm *ExQuantumMachine) executeAction(ctx context.Context, model *theoretica
 		event:                 args.Event,
 		action:                *model,
 		actionType:            actionType,
-		getSnapshotFn:         qm.snapshotUnlocked,
+		view:                  qm.view,
 		emittedEvents:         args.EmittedEvents,
 	}
 
-	if fn := builtin.GetAction(model.Src); fn != nil {
+	if fn := qm.executors.action(model.Src); fn != nil {
 		return fn(ctx, a)
 	}
 
@@ -576,8 +657,8 @@ func (qm *ExQuantumMachine) executeExternalTargetPairs(ctx context.Context, pair
 
 		if job.depth > maxExternalTargetDepth {
 			return fmt.Errorf(
-				"external target cascade exceeded maximum depth (%d) — possible notify/superposition loop",
-				maxExternalTargetDepth,
+				"%w (%d) — possible notify/superposition loop",
+				ErrExternalTargetDepth, maxExternalTargetDepth,
 			)
 		}
 
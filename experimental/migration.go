@@ -0,0 +1,271 @@
+package experimental
+
+import (
+	"fmt"
+
+	"github.com/rendis/statepro/v3/instrumentation"
+	"github.com/rendis/statepro/v3/internal/util"
+	"github.com/rendis/statepro/v3/theoretical"
+)
+
+// SnapshotMigration migrates the snapshot of a universe from one definition version to the next.
+// Migrations are chained: a snapshot taken with version 1.0.0 is loaded on version 1.2.0 through the
+// 1.0.0 -> 1.1.0 and 1.1.0 -> 1.2.0 migrations of its universe.
+type SnapshotMigration struct {
+	// Universe is the id of the migrated universe.
+	Universe string
+
+	// FromVersion and ToVersion are the universe versions (theoretical.UniverseModel.Version) of the step.
+	FromVersion string
+	ToVersion   string
+
+	// Realities maps old reality ids to new ones: several old realities can be remapped to the same new one.
+	// It applies to the current reality, the reality before superposition, accumulated events and tracking.
+	Realities map[string]string
+
+	// Migrate is an optional hook run after the reality renames, to rewrite metadata and accumulated events.
+	Migrate func(u *UniverseSnapshotMigration) error
+}
+
+// UniverseSnapshotMigration is the mutable state of a universe snapshot handed to SnapshotMigration.Migrate.
+type UniverseSnapshotMigration struct {
+	UniverseID  string
+	FromVersion string
+	ToVersion   string
+
+	// CurrentReality is nil when the universe has not been established on a reality.
+	CurrentReality *string
+
+	Metadata map[string]any
+
+	// AccumulatedEvents are the events accumulated by reality while the universe is in superposition.
+	AccumulatedEvents map[string][]*Event
+}
+
+// MachineOption configures a machine created by NewExQuantumMachine.
+type MachineOption func(*ExQuantumMachine)
+
+// WithSnapshotMigrations makes LoadSnapshot migrate universe snapshots taken with another version of the
+// definition, see MigrateSnapshot.
+func WithSnapshotMigrations(migrations ...SnapshotMigration) MachineOption {
+	return func(qm *ExQuantumMachine) {
+		qm.migrations = append(qm.migrations, migrations...)
+	}
+}
+
+// MigrateSnapshot returns a copy of a snapshot taken with the from definition, migrated to the to definition.
+//
+// For every universe, the migrations chain from the version recorded in its snapshot (or the from universe
+// version when missing) to the to universe version. Universe snapshots whose version already matches, or for
+// which no further migration exists, are kept as they are. Universes missing from the to definition are
+// dropped. Snapshot resumes are rebuilt from the migrated universe snapshots, under the new canonical names, and
+// tracking ends at the reality a Migrate hook moved the universe to. Fingerprints are kept for the universes
+// left as they are, and for the machine when no universe was migrated or dropped.
+//
+// An error is returned when a migrated universe would be on a reality that the to definition does not define.
+func MigrateSnapshot(snapshot *instrumentation.MachineSnapshot, from, to *theoretical.QuantumMachineModel, migrations ...SnapshotMigration) (*instrumentation.MachineSnapshot, error) {
+	if snapshot == nil {
+		return nil, nil
+	}
+	if to == nil {
+		return nil, fmt.Errorf("target model cannot be nil")
+	}
+	return migrateSnapshot(snapshot, from, to, migrations)
+}
+
+func migrateSnapshot(snapshot *instrumentation.MachineSnapshot, from, to *theoretical.QuantumMachineModel, migrations []SnapshotMigration) (*instrumentation.MachineSnapshot, error) {
+	migrated := &instrumentation.MachineSnapshot{}
+
+	// fingerprints only remain valid for the universes left as they are
+	unchanged := true
+
+	for universeID, universeSnapshot := range snapshot.Snapshots {
+		universeModel, ok := to.Universes[universeID]
+		if !ok {
+			unchanged = false
+			continue
+		}
+
+		if universeSnapshot == nil {
+			return nil, fmt.Errorf("error migrating snapshot for universe '%s': snapshot is nil", universeID)
+		}
+		info := universeSnapshot.Clone()
+		if info.Version == "" && from != nil && from.Universes[universeID] != nil {
+			info.Version = from.Universes[universeID].Version
+		}
+
+		version := info.Version
+		rename, err := migrateUniverseSnapshot(info, universeModel, migrations)
+		if err != nil {
+			return nil, err
+		}
+		if info.Version != version {
+			unchanged = false
+		} else if fingerprint, ok := snapshot.UniverseFingerprints[universeID]; ok {
+			migrated.AddUniverseFingerprint(universeID, fingerprint)
+		}
+		info.CanonicalName = universeModel.CanonicalName
+
+		migrated.AddUniverseSnapshot(universeID, info)
+
+		if tracking, ok := snapshot.Tracking[universeID]; ok {
+			renamed := make([]string, len(tracking), len(tracking)+1)
+			for i, reality := range tracking {
+				renamed[i] = renameReality(rename, reality)
+			}
+			// a Migrate hook may have moved the universe to another reality
+			if info.CurrentReality != nil && len(renamed) > 0 && renamed[len(renamed)-1] != *info.CurrentReality {
+				renamed = append(renamed, *info.CurrentReality)
+			}
+			migrated.AddTracking(universeID, renamed)
+		}
+
+		addMigratedResume(migrated, info, universeModel)
+	}
+
+	if unchanged {
+		migrated.Fingerprint = snapshot.Fingerprint
+	}
+
+	return migrated, nil
+}
+
+// migrateUniverseSnapshot applies the chain of migrations of the universe to info and returns the composed reality renames.
+func migrateUniverseSnapshot(info *instrumentation.UniverseSnapshot, model *theoretical.UniverseModel, migrations []SnapshotMigration) (map[string]string, error) {
+	rename := map[string]string{}
+	applied := 0
+	for info.Version != model.Version && applied < len(migrations) {
+		migration, ok := findSnapshotMigration(migrations, model.ID, info.Version)
+		if !ok {
+			break
+		}
+		applied++
+
+		// compose from a copy: the renames of a step apply simultaneously (e.g. swaps)
+		composed := make(map[string]string, len(rename)+len(migration.Realities))
+		for old, current := range rename {
+			composed[old] = renameReality(migration.Realities, current)
+		}
+		for reality, target := range migration.Realities {
+			if _, ok := rename[reality]; !ok {
+				composed[reality] = target
+			}
+		}
+		rename = composed
+		applySnapshotRenames(info, migration.Realities)
+
+		if migration.Migrate != nil {
+			if err := runSnapshotMigrationHook(info, migration); err != nil {
+				return nil, fmt.Errorf("error migrating snapshot for universe '%s' from version '%s' to '%s': %w",
+					model.ID, migration.FromVersion, migration.ToVersion, err)
+			}
+		}
+		info.Version = migration.ToVersion
+	}
+
+	for _, reality := range []*string{info.CurrentReality, info.RealityBeforeSuperposition} {
+		if reality == nil {
+			continue
+		}
+		if _, ok := model.Realities[*reality]; !ok {
+			return nil, fmt.Errorf("error migrating snapshot for universe '%s': reality '%s' of version '%s' does not exist in version '%s' and no migration remaps it",
+				model.ID, *reality, info.Version, model.Version)
+		}
+	}
+	return rename, nil
+}
+
+// addMigratedResume adds the resume entry of the migrated universe snapshot, as GetSnapshot would produce it.
+func addMigratedResume(migrated *instrumentation.MachineSnapshot, info *instrumentation.UniverseSnapshot, model *theoretical.UniverseModel) {
+	if !info.Initialized || info.CurrentReality == nil && !info.InSuperposition {
+		return
+	}
+	final := false
+	if info.CurrentReality != nil {
+		if reality, ok := model.Realities[*info.CurrentReality]; ok {
+			final = theoretical.IsFinalState(reality.Type)
+		}
+	}
+
+	switch {
+	case info.InSuperposition:
+		realityBeforeSuperposition := "*"
+		if info.RealityBeforeSuperposition != nil {
+			realityBeforeSuperposition = *info.RealityBeforeSuperposition
+		}
+		if final {
+			migrated.AddSuperpositionUniverseFinalized(info.CanonicalName, realityBeforeSuperposition)
+		} else {
+			migrated.AddSuperpositionUniverse(info.CanonicalName, realityBeforeSuperposition)
+		}
+	case final:
+		migrated.AddFinalizedUniverse(info.CanonicalName, *info.CurrentReality)
+	default:
+		migrated.AddActiveUniverse(info.CanonicalName, *info.CurrentReality)
+	}
+}
+
+func findSnapshotMigration(migrations []SnapshotMigration, universeID, fromVersion string) (SnapshotMigration, bool) {
+	for _, migration := range migrations {
+		if migration.Universe == universeID && migration.FromVersion == fromVersion {
+			return migration, true
+		}
+	}
+	return SnapshotMigration{}, false
+}
+
+func applySnapshotRenames(info *instrumentation.UniverseSnapshot, realities map[string]string) {
+	if len(realities) == 0 {
+		return
+	}
+	for _, reality := range []**string{&info.CurrentReality, &info.RealityBeforeSuperposition} {
+		if *reality != nil {
+			renamed := renameReality(realities, **reality)
+			*reality = &renamed
+		}
+	}
+	if info.Accumulator != nil && info.Accumulator.RealitiesEvents != nil {
+		events := map[string][]instrumentation.EventSnapshot{}
+		for _, reality := range util.SortedKeys(info.Accumulator.RealitiesEvents) {
+			renamed := renameReality(realities, reality)
+			events[renamed] = append(events[renamed], info.Accumulator.RealitiesEvents[reality]...)
+		}
+		info.Accumulator.RealitiesEvents = events
+	}
+}
+
+func runSnapshotMigrationHook(info *instrumentation.UniverseSnapshot, migration SnapshotMigration) error {
+	state := &UniverseSnapshotMigration{
+		UniverseID:     info.ID,
+		FromVersion:    migration.FromVersion,
+		ToVersion:      migration.ToVersion,
+		CurrentReality: info.CurrentReality,
+		Metadata:       info.Metadata,
+	}
+	if state.Metadata == nil {
+		state.Metadata = map[string]any{}
+	}
+	if info.Accumulator != nil {
+		state.AccumulatedEvents = eventsFromSnapshot(info.Accumulator.RealitiesEvents)
+	} else {
+		state.AccumulatedEvents = map[string][]*Event{}
+	}
+
+	if err := migration.Migrate(state); err != nil {
+		return err
+	}
+
+	info.CurrentReality = state.CurrentReality
+	info.Metadata = state.Metadata
+	if len(state.AccumulatedEvents) > 0 || info.Accumulator != nil {
+		info.Accumulator = &instrumentation.AccumulatorSnapshot{RealitiesEvents: eventsToSnapshot(state.AccumulatedEvents)}
+	}
+	return nil
+}
+
+func renameReality(realities map[string]string, reality string) string {
+	if renamed, ok := realities[reality]; ok {
+		return renamed
+	}
+	return reality
+}
@@ -0,0 +1,229 @@
+package experimental
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/rendis/statepro/v3/instrumentation"
+	"github.com/rendis/statepro/v3/theoretical"
+)
+
+func buildVersionedQM(t *testing.T, version string, realities map[string]*theoretical.RealityModel, opts ...MachineOption) (*ExQuantumMachine, *ExUniverse) {
+	t.Helper()
+	initial := "PENDING"
+	um := &theoretical.UniverseModel{
+		ID:            "u1",
+		CanonicalName: "orders-" + version,
+		Version:       version,
+		Initial:       &initial,
+		Realities:     realities,
+	}
+	qmm := &theoretical.QuantumMachineModel{
+		ID:            "qm1",
+		CanonicalName: "TestQM",
+		Version:       version,
+		Universes:     map[string]*theoretical.UniverseModel{"u1": um},
+		Initials:      []string{"U:u1"},
+	}
+	u := NewExUniverse(um)
+	qm, err := NewExQuantumMachine(qmm, []*ExUniverse{u}, opts...)
+	if err != nil {
+		t.Fatalf("failed to build QM: %v", err)
+	}
+	return qm.(*ExQuantumMachine), u
+}
+
+// v1: PENDING -go-> WAITING
+func v1Snapshot(t *testing.T) *instrumentation.MachineSnapshot {
+	t.Helper()
+	qm, _ := buildVersionedQM(t, "1.0.0", map[string]*theoretical.RealityModel{
+		"PENDING": newTransitionReality("PENDING", withOnTransition("go", []string{"WAITING"}, nil)),
+		"WAITING": newTransitionReality("WAITING", withOnTransition("done", []string{"DONE"}, nil)),
+		"DONE":    newFinalReality("DONE"),
+	})
+	if err := qm.Init(context.Background(), nil); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	if _, err := qm.SendEvent(context.Background(), NewEventBuilder("go").Build()); err != nil {
+		t.Fatalf("SendEvent failed: %v", err)
+	}
+	return qm.GetSnapshot()
+}
+
+// v3 renamed WAITING to AWAITING_PAYMENT (1.0.0 -> 2.0.0) and then to AWAITING (2.0.0 -> 3.0.0).
+func v3Realities() map[string]*theoretical.RealityModel {
+	return map[string]*theoretical.RealityModel{
+		"PENDING":  newTransitionReality("PENDING", withOnTransition("go", []string{"AWAITING"}, nil)),
+		"AWAITING": newTransitionReality("AWAITING", withOnTransition("done", []string{"DONE"}, nil)),
+		"DONE":     newFinalReality("DONE"),
+	}
+}
+
+var v3Migrations = []SnapshotMigration{
+	{Universe: "u1", FromVersion: "2.0.0", ToVersion: "3.0.0", Realities: map[string]string{"AWAITING_PAYMENT": "AWAITING"},
+		Migrate: func(u *UniverseSnapshotMigration) error {
+			u.Metadata["step"] = u.Metadata["step"].(string) + "," + u.ToVersion
+			return nil
+		}},
+	{Universe: "u1", FromVersion: "1.0.0", ToVersion: "2.0.0", Realities: map[string]string{"WAITING": "AWAITING_PAYMENT"},
+		Migrate: func(u *UniverseSnapshotMigration) error {
+			u.Metadata["step"] = u.ToVersion
+			return nil
+		}},
+}
+
+func TestLoadSnapshot_WithSnapshotMigrations(t *testing.T) {
+	snapshot := v1Snapshot(t)
+
+	qm, u := buildVersionedQM(t, "3.0.0", v3Realities(), WithSnapshotMigrations(v3Migrations...))
+	if err := qm.LoadSnapshot(snapshot, nil); err != nil {
+		t.Fatalf("LoadSnapshot failed: %v", err)
+	}
+	assertReality(t, u, "AWAITING")
+	if u.metadata["step"] != "2.0.0,3.0.0" {
+		t.Fatalf("expected both hooks to run in order, got %v", u.metadata["step"])
+	}
+
+	// the migrated machine keeps running on the new definition
+	if _, err := qm.SendEvent(context.Background(), NewEventBuilder("done").Build()); err != nil {
+		t.Fatalf("SendEvent failed: %v", err)
+	}
+	assertReality(t, u, "DONE")
+	if tracking := qm.GetSnapshot().Tracking["u1"]; strings.Join(tracking, ",") != "PENDING,AWAITING,DONE" {
+		t.Fatalf("expected renamed tracking, got %v", tracking)
+	}
+}
+
+func TestLoadSnapshot_WithoutMigrationFails(t *testing.T) {
+	qm, _ := buildVersionedQM(t, "3.0.0", v3Realities())
+	if err := qm.LoadSnapshot(v1Snapshot(t), nil); err == nil {
+		t.Fatal("expected error for a reality missing from the new definition")
+	}
+
+	qm, _ = buildVersionedQM(t, "3.0.0", v3Realities(), WithSnapshotMigrations(v3Migrations[1]))
+	err := qm.LoadSnapshot(v1Snapshot(t), nil)
+	if err == nil || !strings.Contains(err.Error(), "reality 'AWAITING_PAYMENT' of version '2.0.0' does not exist in version '3.0.0'") {
+		t.Fatalf("expected missing migration error, got %v", err)
+	}
+}
+
+func TestMigrateSnapshot(t *testing.T) {
+	snapshot := v1Snapshot(t)
+	from, _ := buildVersionedQM(t, "1.0.0", nil)
+	to, _ := buildVersionedQM(t, "3.0.0", v3Realities())
+
+	migrated, err := MigrateSnapshot(snapshot, from.model, to.model, v3Migrations...)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if migrated.Resume.ActiveUniverses["orders-3.0.0"] != "AWAITING" || len(migrated.Resume.ActiveUniverses) != 1 {
+		t.Fatalf("expected resume keyed by the new canonical name, got %v", migrated.Resume.ActiveUniverses)
+	}
+	if u1 := migrated.Snapshots["u1"]; u1.Version != "3.0.0" || *u1.CurrentReality != "AWAITING" {
+		t.Fatalf("unexpected universe snapshot %v", migrated.Snapshots["u1"])
+	}
+	if *snapshot.Snapshots["u1"].CurrentReality != "WAITING" {
+		t.Fatal("expected the source snapshot to be unchanged")
+	}
+}
+
+func TestMigrateSnapshot_HookError(t *testing.T) {
+	hookErr := errors.New("boom")
+	to, _ := buildVersionedQM(t, "2.0.0", nil)
+	_, err := MigrateSnapshot(v1Snapshot(t), nil, to.model, SnapshotMigration{
+		Universe: "u1", FromVersion: "1.0.0", ToVersion: "2.0.0",
+		Migrate: func(*UniverseSnapshotMigration) error { return hookErr },
+	})
+	if !errors.Is(err, hookErr) {
+		t.Fatalf("expected hook error, got %v", err)
+	}
+}
+
+func TestMigrateSnapshot_Accumulator(t *testing.T) {
+	to, _ := buildVersionedQM(t, "2.0.0", map[string]*theoretical.RealityModel{"MERGED": newFinalReality("MERGED")})
+	snapshot := &instrumentation.MachineSnapshot{Snapshots: map[string]*instrumentation.UniverseSnapshot{
+		"u1": {ID: "u1", CanonicalName: "orders", Version: "1.0.0", Initialized: true, InSuperposition: true,
+			Accumulator: &instrumentation.AccumulatorSnapshot{RealitiesEvents: map[string][]instrumentation.EventSnapshot{
+				"A": {{Name: "x", EvtType: instrumentation.EventTypeOn}},
+				"B": {{Name: "y", EvtType: instrumentation.EventTypeOn}},
+			}}},
+	}}
+
+	migrated, err := MigrateSnapshot(snapshot, nil, to.model, SnapshotMigration{
+		Universe: "u1", FromVersion: "1.0.0", ToVersion: "2.0.0", Realities: map[string]string{"A": "MERGED", "B": "MERGED"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	events := migrated.Snapshots["u1"].Accumulator.RealitiesEvents
+	if len(events) != 1 || len(events["MERGED"]) != 2 {
+		t.Fatalf("expected remapped realities to merge their events, got %v", events)
+	}
+}
+
+func TestMigrateSnapshot_SwapIsDeterministic(t *testing.T) {
+	swap := map[string]string{"PENDING": "WAITING", "WAITING": "PENDING"}
+	to, _ := buildVersionedQM(t, "2.0.0", map[string]*theoretical.RealityModel{
+		"PENDING": newTransitionReality("PENDING"),
+		"WAITING": newTransitionReality("WAITING"),
+	})
+	toTwice, _ := buildVersionedQM(t, "3.0.0", to.model.Universes["u1"].Realities)
+
+	// map iteration order must not change the result
+	for range 20 {
+		migrated, err := MigrateSnapshot(v1Snapshot(t), nil, to.model,
+			SnapshotMigration{Universe: "u1", FromVersion: "1.0.0", ToVersion: "2.0.0", Realities: swap})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if *migrated.Snapshots["u1"].CurrentReality != "PENDING" || migrated.Resume.ActiveUniverses["orders-2.0.0"] != "PENDING" ||
+			strings.Join(migrated.Tracking["u1"], ",") != "WAITING,PENDING" {
+			t.Fatalf("expected the realities to be swapped everywhere, got %v / %v / %v",
+				*migrated.Snapshots["u1"].CurrentReality, migrated.Resume, migrated.Tracking["u1"])
+		}
+		if err = ValidateSnapshot(to.model, migrated); err != nil {
+			t.Fatalf("expected a consistent migrated snapshot, got %v", err)
+		}
+
+		// swapping twice restores the original realities
+		migrated, err = MigrateSnapshot(v1Snapshot(t), nil, toTwice.model,
+			SnapshotMigration{Universe: "u1", FromVersion: "1.0.0", ToVersion: "2.0.0", Realities: swap},
+			SnapshotMigration{Universe: "u1", FromVersion: "2.0.0", ToVersion: "3.0.0", Realities: swap})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if migrated.Resume.ActiveUniverses["orders-3.0.0"] != "WAITING" || strings.Join(migrated.Tracking["u1"], ",") != "PENDING,WAITING" {
+			t.Fatalf("expected the double swap to be the identity, got %v / %v", migrated.Resume, migrated.Tracking["u1"])
+		}
+	}
+}
+
+func TestMigrateSnapshot_HookMovesReality(t *testing.T) {
+	to, _ := buildVersionedQM(t, "2.0.0", map[string]*theoretical.RealityModel{
+		"PENDING": newTransitionReality("PENDING"),
+		"WAITING": newTransitionReality("WAITING"),
+		"DONE":    newFinalReality("DONE"),
+	})
+	migrated, err := MigrateSnapshot(v1Snapshot(t), nil, to.model, SnapshotMigration{
+		Universe: "u1", FromVersion: "1.0.0", ToVersion: "2.0.0",
+		Migrate: func(u *UniverseSnapshotMigration) error {
+			done := "DONE"
+			u.CurrentReality = &done
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if migrated.Resume.FinalizedUniverses["orders-2.0.0"] != "DONE" || len(migrated.Resume.ActiveUniverses) != 0 {
+		t.Fatalf("expected the resume to follow the hook, got %+v", migrated.Resume)
+	}
+	if tracking := strings.Join(migrated.Tracking["u1"], ","); tracking != "PENDING,WAITING,DONE" {
+		t.Fatalf("expected the tracking to end at the new reality, got %v", tracking)
+	}
+	if err = ValidateSnapshot(to.model, migrated); err != nil {
+		t.Fatalf("expected a consistent migrated snapshot, got %v", err)
+	}
+}
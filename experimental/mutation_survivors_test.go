@@ -104,9 +104,7 @@ func TestMutation_LoadSnapshot_EmptyMetadataKeepsNilMap(t *testing.T) {
 		t.Fatalf("Init: %v", err)
 	}
 	snap := qm.GetSnapshot()
-	us := snap.Snapshots["u1"]
-	delete(us, "metadata")
-	snap.Snapshots["u1"] = us
+	snap.Snapshots["u1"].Metadata = nil
 
 	u.metadata = nil
 	if err := qm.LoadSnapshot(snap, nil); err != nil {
@@ -2,6 +2,7 @@ package experimental
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"sync"
@@ -609,6 +610,9 @@ func TestGap_AlwaysCycle_RetornaErrorSinColgarse(t *testing.T) {
 			!strings.Contains(err.Error(), "loop") {
 			t.Fatalf("expected cycle-related error, got: %v", err)
 		}
+		if !errors.Is(err, ErrCyclicTransition) {
+			t.Fatalf("expected ErrCyclicTransition, got: %v", err)
+		}
 	case <-time.After(2 * time.Second):
 		t.Fatal("Init hung on always A↔B cycle — missing cycle protection")
 	}
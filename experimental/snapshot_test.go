@@ -0,0 +1,124 @@
+package experimental
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/rendis/statepro/v3/instrumentation"
+	"github.com/rendis/statepro/v3/theoretical"
+)
+
+func TestGetSnapshot_Typed(t *testing.T) {
+	realities := map[string]*theoretical.RealityModel{
+		"stateA": newTransitionReality("stateA", withOnTransition("go", []string{"stateB"}, nil)),
+		"stateB": newTransitionReality("stateB"),
+	}
+	qm, u := buildQM(t, "stateA", realities)
+	if err := qm.Init(context.Background(), nil); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	u.metadata["count"] = 3
+
+	snapshot := qm.GetSnapshot()
+	u1 := snapshot.Snapshots["u1"]
+	if !u1.Initialized || *u1.CurrentReality != "stateA" {
+		t.Fatalf("unexpected universe snapshot %+v", u1)
+	}
+	// no JSON round-trip: metadata keeps its Go types
+	if count, ok := u1.Metadata["count"].(int); !ok || count != 3 {
+		t.Fatalf("expected int metadata, got %T %v", u1.Metadata["count"], u1.Metadata["count"])
+	}
+
+	// the snapshot does not alias the universe
+	*u1.CurrentReality = "stateB"
+	u1.Metadata["count"] = 4
+	assertReality(t, u, "stateA")
+	if u.metadata["count"] != 3 {
+		t.Fatalf("expected universe metadata unchanged, got %v", u.metadata["count"])
+	}
+}
+
+func TestSnapshot_AccumulatorRoundTrip(t *testing.T) {
+	realities := map[string]*theoretical.RealityModel{
+		"stateA": newTransitionReality("stateA"),
+		"PAID":   newFinalReality("PAID"),
+	}
+	qm, u := buildQM(t, "stateA", realities)
+	if err := qm.Init(context.Background(), nil); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	// u1 in superposition with an event accumulated for PAID
+	u.inSuperposition = true
+	u.eventAccumulator = newEventAccumulator()
+	u.eventAccumulator.Accumulate("PAID", NewEventBuilder("pay").SetData(map[string]any{"amount": 10}).Build())
+
+	// through JSON, as when persisted
+	b, err := json.Marshal(qm.GetSnapshot())
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	var snapshot instrumentation.MachineSnapshot
+	if err = json.Unmarshal(b, &snapshot); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+
+	qm2, u2 := buildQM(t, "stateA", realities)
+	if err = qm2.LoadSnapshot(&snapshot, nil); err != nil {
+		t.Fatalf("LoadSnapshot failed: %v", err)
+	}
+	events := u2.eventAccumulator.GetStatistics().GetRealityEvents("PAID")
+	if evt, ok := events["pay"]; !ok || evt.GetData()["amount"] != float64(10) {
+		t.Fatalf("expected accumulated event to be restored, got %v", events)
+	}
+}
+
+func TestSnapshot_NestedValuesNotAliased(t *testing.T) {
+	realities := map[string]*theoretical.RealityModel{
+		"stateA": newTransitionReality("stateA"),
+		"PAID":   newFinalReality("PAID"),
+	}
+	qm, u := buildQM(t, "stateA", realities)
+	if err := qm.Init(context.Background(), nil); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	u.metadata["nested"] = map[string]any{"k": "v", "list": []any{"a"}}
+	u.inSuperposition = true
+	u.eventAccumulator = newEventAccumulator()
+	u.eventAccumulator.Accumulate("PAID", NewEventBuilder("pay").SetData(map[string]any{"card": map[string]any{"last4": "1234"}}).Build())
+
+	// writes to a snapshot do not reach the universe
+	snapshot := qm.GetSnapshot()
+	u1 := snapshot.Snapshots["u1"]
+	u1.Metadata["nested"].(map[string]any)["k"] = "changed"
+	u1.Metadata["nested"].(map[string]any)["list"].([]any)[0] = "changed"
+	u1.Accumulator.RealitiesEvents["PAID"][0].Data["card"].(map[string]any)["last4"] = "0000"
+
+	again := qm.GetSnapshot().Snapshots["u1"]
+	nested := again.Metadata["nested"].(map[string]any)
+	if nested["k"] != "v" || nested["list"].([]any)[0] != "a" {
+		t.Fatalf("expected nested metadata unchanged, got %v", nested)
+	}
+	if card := again.Accumulator.RealitiesEvents["PAID"][0].Data["card"].(map[string]any); card["last4"] != "1234" {
+		t.Fatalf("expected accumulated event data unchanged, got %v", card)
+	}
+
+	// nor do writes to a loaded snapshot or to its clone
+	clone := again.Clone()
+	clone.Metadata["nested"].(map[string]any)["k"] = "cloned"
+	if again.Metadata["nested"].(map[string]any)["k"] != "v" {
+		t.Fatal("expected Clone to copy nested metadata")
+	}
+	if err := qm.LoadSnapshot(snapshot, nil); err != nil {
+		t.Fatalf("LoadSnapshot failed: %v", err)
+	}
+	snapshot.Snapshots["u1"].Metadata["nested"].(map[string]any)["k"] = "after load"
+	snapshot.Snapshots["u1"].Accumulator.RealitiesEvents["PAID"][0].Data["card"].(map[string]any)["last4"] = "9999"
+	loaded := qm.GetSnapshot().Snapshots["u1"]
+	if loaded.Metadata["nested"].(map[string]any)["k"] != "changed" {
+		t.Fatalf("expected the loaded metadata not to alias the snapshot, got %v", loaded.Metadata["nested"])
+	}
+	if card := loaded.Accumulator.RealitiesEvents["PAID"][0].Data["card"].(map[string]any); card["last4"] != "0000" {
+		t.Fatalf("expected the loaded events not to alias the snapshot, got %v", card)
+	}
+}
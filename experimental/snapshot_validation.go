@@ -0,0 +1,256 @@
+package experimental
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rendis/statepro/v3/instrumentation"
+	"github.com/rendis/statepro/v3/internal/util"
+	"github.com/rendis/statepro/v3/theoretical"
+)
+
+// SnapshotIssueCode identifies the kind of a SnapshotIssue.
+type SnapshotIssueCode string
+
+const (
+	SnapshotIssueUnknownUniverse     SnapshotIssueCode = "unknown_universe"
+	SnapshotIssueMissingUniverse     SnapshotIssueCode = "missing_universe"
+	SnapshotIssueInvalidUniverse     SnapshotIssueCode = "invalid_universe_snapshot"
+	SnapshotIssueUnknownReality      SnapshotIssueCode = "unknown_reality"
+	SnapshotIssueMissingAccumulator  SnapshotIssueCode = "missing_accumulator"
+	SnapshotIssueTrackingMismatch    SnapshotIssueCode = "tracking_mismatch"
+	SnapshotIssueResumeMismatch      SnapshotIssueCode = "resume_mismatch"
+	SnapshotIssueVersionMismatch     SnapshotIssueCode = "version_mismatch"
+	SnapshotIssueCanonicalNameChange SnapshotIssueCode = "canonical_name_mismatch"
+	SnapshotIssueFingerprintMismatch SnapshotIssueCode = "fingerprint_mismatch"
+)
+
+// SnapshotIssue is an inconsistency between a snapshot and itself or the definition it is loaded into.
+type SnapshotIssue struct {
+	Code SnapshotIssueCode `json:"code"`
+
+	// Universe is the universe id (or the resume key) the issue is about, empty for machine-wide issues.
+	Universe string `json:"universe,omitempty"`
+
+	Message string `json:"message"`
+}
+
+func (i SnapshotIssue) String() string {
+	return i.Message
+}
+
+// SnapshotValidationError is returned by ValidateSnapshot and by LoadSnapshot in strict mode.
+type SnapshotValidationError struct {
+	Issues []SnapshotIssue `json:"issues"`
+}
+
+func (e *SnapshotValidationError) Error() string {
+	messages := make([]string, len(e.Issues))
+	for i, issue := range e.Issues {
+		messages[i] = issue.String()
+	}
+	return "invalid snapshot: " + strings.Join(messages, "; ")
+}
+
+// WithStrictSnapshotLoad makes LoadSnapshot refuse, without changing the machine, the snapshots that
+// ValidateSnapshot reports issues for. Migrations (see WithSnapshotMigrations) are applied first.
+func WithStrictSnapshotLoad() MachineOption {
+	return func(qm *ExQuantumMachine) {
+		qm.strictSnapshotLoad = true
+	}
+}
+
+// ValidateSnapshot cross-checks a snapshot with the definition it is about to be loaded into. It reports:
+//   - universe snapshots, tracking or resume entries of universes the definition does not define;
+//   - universes of the definition without snapshot;
+//   - current, pre-superposition, accumulated or tracked realities the universe does not define;
+//   - universes in superposition without accumulator;
+//   - tracking that does not end at the current reality;
+//   - resumes that do not match the universe snapshots;
+//   - universe versions and canonical names that differ from the definition;
+//   - recorded machine or universe fingerprints that differ from the ones of the definition.
+//
+// It returns a *SnapshotValidationError listing every issue, or nil.
+func ValidateSnapshot(model *theoretical.QuantumMachineModel, snapshot *instrumentation.MachineSnapshot) error {
+	if model == nil {
+		return fmt.Errorf("source model cannot be nil")
+	}
+	if snapshot == nil {
+		return fmt.Errorf("snapshot cannot be nil")
+	}
+	fingerprints, err := newModelFingerprints(model)
+	if err != nil {
+		return err
+	}
+	return validateSnapshot(model, fingerprints, snapshot)
+}
+
+func validateSnapshot(model *theoretical.QuantumMachineModel, fingerprints *modelFingerprints, snapshot *instrumentation.MachineSnapshot) error {
+	// nil universes are skipped, as if the definition did not define them
+	v := &snapshotValidator{model: model, snapshot: snapshot, canonicalNames: map[string]*theoretical.UniverseModel{}}
+	for _, universe := range model.Universes {
+		if universe != nil {
+			v.canonicalNames[universe.CanonicalName] = universe
+		}
+	}
+
+	for _, id := range util.SortedKeys(snapshot.Snapshots) {
+		if model.Universes[id] == nil {
+			v.add(SnapshotIssueUnknownUniverse, id, "snapshot of universe '%s' that the definition does not define", id)
+		}
+	}
+	for _, id := range util.SortedKeys(snapshot.Tracking) {
+		if model.Universes[id] == nil {
+			v.add(SnapshotIssueUnknownUniverse, id, "tracking of universe '%s' that the definition does not define", id)
+		}
+	}
+
+	for _, id := range util.SortedKeys(model.Universes) {
+		if model.Universes[id] == nil {
+			continue
+		}
+		universeSnapshot, ok := snapshot.Snapshots[id]
+		if !ok {
+			v.add(SnapshotIssueMissingUniverse, id, "universe '%s' has no snapshot", id)
+			continue
+		}
+		v.validateUniverse(model.Universes[id], universeSnapshot)
+	}
+
+	v.validateResumeKeys()
+	v.issues = append(v.issues, fingerprints.issues(snapshot)...)
+
+	if len(v.issues) == 0 {
+		return nil
+	}
+	return &SnapshotValidationError{Issues: v.issues}
+}
+
+type snapshotValidator struct {
+	model    *theoretical.QuantumMachineModel
+	snapshot *instrumentation.MachineSnapshot
+	// canonicalNames indexes the universes by canonical name, the key of the resume maps.
+	canonicalNames map[string]*theoretical.UniverseModel
+	issues         []SnapshotIssue
+}
+
+func (v *snapshotValidator) add(code SnapshotIssueCode, universe, format string, args ...any) {
+	v.issues = append(v.issues, SnapshotIssue{Code: code, Universe: universe, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *snapshotValidator) validateUniverse(model *theoretical.UniverseModel, info *instrumentation.UniverseSnapshot) {
+	id := model.ID
+	if info == nil {
+		v.add(SnapshotIssueInvalidUniverse, id, "snapshot of universe '%s' is nil", id)
+		return
+	}
+
+	if info.ID != id {
+		v.add(SnapshotIssueInvalidUniverse, id, "snapshot of universe '%s' holds universe id '%s'", id, info.ID)
+	}
+	if info.Version != model.Version {
+		v.add(SnapshotIssueVersionMismatch, id, "snapshot of universe '%s' has version '%s', the definition has version '%s'", id, info.Version, model.Version)
+	}
+	if info.CanonicalName != model.CanonicalName {
+		v.add(SnapshotIssueCanonicalNameChange, id, "snapshot of universe '%s' has canonical name '%s', the definition has '%s'", id, info.CanonicalName, model.CanonicalName)
+	}
+
+	checkReality := func(reality, role string) bool {
+		if _, ok := model.Realities[reality]; ok {
+			return true
+		}
+		v.add(SnapshotIssueUnknownReality, id, "%s '%s' of universe '%s' does not exist", role, reality, id)
+		return false
+	}
+
+	if info.CurrentReality != nil {
+		checkReality(*info.CurrentReality, "current reality")
+	}
+	if info.RealityBeforeSuperposition != nil {
+		checkReality(*info.RealityBeforeSuperposition, "reality before superposition")
+	}
+	if info.Accumulator != nil {
+		for _, reality := range util.SortedKeys(info.Accumulator.RealitiesEvents) {
+			checkReality(reality, "accumulated reality")
+		}
+	}
+	if info.InSuperposition && info.Accumulator == nil {
+		v.add(SnapshotIssueMissingAccumulator, id, "universe '%s' is in superposition without accumulator", id)
+	}
+
+	tracking := v.snapshot.Tracking[id]
+	for _, reality := range tracking {
+		if !checkReality(reality, "tracked reality") {
+			break
+		}
+	}
+	if info.CurrentReality != nil && len(tracking) > 0 && tracking[len(tracking)-1] != *info.CurrentReality {
+		v.add(SnapshotIssueTrackingMismatch, id, "tracking of universe '%s' ends at '%s', the current reality is '%s'",
+			id, tracking[len(tracking)-1], *info.CurrentReality)
+	}
+
+	v.validateResume(model, info)
+}
+
+// validateResume checks that the resume entry of the universe is the one its snapshot produces.
+func (v *snapshotValidator) validateResume(model *theoretical.UniverseModel, info *instrumentation.UniverseSnapshot) {
+	name := model.CanonicalName
+	if info.CanonicalName != "" {
+		name = info.CanonicalName
+	}
+	resume := v.snapshot.Resume
+
+	expectedKind, expectedReality := "", ""
+	if info.Initialized {
+		switch {
+		case info.InSuperposition:
+			expectedKind, expectedReality = "superposition", "*"
+			if info.RealityBeforeSuperposition != nil {
+				expectedReality = *info.RealityBeforeSuperposition
+			}
+		case info.CurrentReality != nil:
+			expectedKind, expectedReality = "active", *info.CurrentReality
+			if reality, ok := model.Realities[*info.CurrentReality]; ok && theoretical.IsFinalState(reality.Type) {
+				expectedKind = "finalized"
+			}
+		}
+	}
+
+	entries := map[string]string{}
+	for kind, m := range map[string]map[string]string{
+		"active":                  resume.ActiveUniverses,
+		"finalized":               resume.FinalizedUniverses,
+		"superposition":           resume.SuperpositionUniverses,
+		"superposition finalized": resume.SuperpositionUniversesFinalized,
+	} {
+		if reality, ok := m[name]; ok {
+			entries[kind] = reality
+		}
+	}
+
+	for _, kind := range util.SortedKeys(entries) {
+		reality := entries[kind]
+		matches := kind == expectedKind ||
+			kind == "superposition finalized" && expectedKind == "superposition"
+		if !matches || reality != expectedReality {
+			v.add(SnapshotIssueResumeMismatch, model.ID, "resume lists universe '%s' as %s on '%s', its snapshot does not", name, kind, reality)
+		}
+	}
+	if expectedKind != "" && len(entries) == 0 {
+		v.add(SnapshotIssueResumeMismatch, model.ID, "resume does not list universe '%s'", name)
+	}
+}
+
+// validateResumeKeys reports resume entries of canonical names that no universe of the definition has.
+func (v *snapshotValidator) validateResumeKeys() {
+	resume := v.snapshot.Resume
+	seen := map[string]bool{}
+	for _, m := range []map[string]string{resume.ActiveUniverses, resume.FinalizedUniverses, resume.SuperpositionUniverses, resume.SuperpositionUniversesFinalized} {
+		for _, name := range util.SortedKeys(m) {
+			if _, ok := v.canonicalNames[name]; !ok && !seen[name] {
+				seen[name] = true
+				v.add(SnapshotIssueUnknownUniverse, name, "resume lists universe '%s' that the definition does not define", name)
+			}
+		}
+	}
+}
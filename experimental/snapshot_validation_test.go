@@ -0,0 +1,184 @@
+package experimental
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/rendis/statepro/v3/instrumentation"
+	"github.com/rendis/statepro/v3/theoretical"
+)
+
+// snapshotValidationModel has a universe that finalizes, one in superposition and one never started.
+func snapshotValidationModel() *theoretical.QuantumMachineModel {
+	return &theoretical.QuantumMachineModel{
+		ID:            "qm1",
+		CanonicalName: "TestQM",
+		Version:       "1.0.0",
+		Initials:      []string{"U:main", "U:side"},
+		Universes: map[string]*theoretical.UniverseModel{
+			"main": {ID: "main", CanonicalName: "Main", Version: "1.0.0", Initial: strPtr("PENDING"),
+				Realities: map[string]*theoretical.RealityModel{
+					"PENDING": newTransitionReality("PENDING", withOnTransition("go", []string{"DONE"}, nil)),
+					"DONE":    newFinalReality("DONE"),
+				}},
+			"side": {ID: "side", CanonicalName: "Side", Version: "1.0.0",
+				Realities: map[string]*theoretical.RealityModel{"WAITING": newTransitionReality("WAITING")}},
+			"idle": {ID: "idle", CanonicalName: "Idle", Version: "1.0.0",
+				Realities: map[string]*theoretical.RealityModel{"IDLE": newFinalReality("IDLE")}},
+		},
+	}
+}
+
+func buildSnapshotValidationQM(t *testing.T, opts ...MachineOption) (*ExQuantumMachine, *theoretical.QuantumMachineModel) {
+	t.Helper()
+	model := snapshotValidationModel()
+	var universes []*ExUniverse
+	for _, um := range model.Universes {
+		universes = append(universes, NewExUniverse(um))
+	}
+	qm, err := NewExQuantumMachine(model, universes, opts...)
+	if err != nil {
+		t.Fatalf("failed to build QM: %v", err)
+	}
+	return qm.(*ExQuantumMachine), model
+}
+
+func validSnapshot(t *testing.T) *instrumentation.MachineSnapshot {
+	t.Helper()
+	qm, _ := buildSnapshotValidationQM(t)
+	if err := qm.Init(context.Background(), nil); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	if _, err := qm.SendEvent(context.Background(), NewEventBuilder("go").Build()); err != nil {
+		t.Fatalf("SendEvent failed: %v", err)
+	}
+	return qm.GetSnapshot()
+}
+
+func TestValidateSnapshot_Valid(t *testing.T) {
+	if err := ValidateSnapshot(snapshotValidationModel(), validSnapshot(t)); err != nil {
+		t.Fatalf("expected snapshot produced by the machine to be valid, got %v", err)
+	}
+}
+
+func TestValidateSnapshot_Issues(t *testing.T) {
+	cases := map[string]struct {
+		corrupt  func(s *instrumentation.MachineSnapshot)
+		code     SnapshotIssueCode
+		contains string
+	}{
+		"unknown universe": {
+			corrupt:  func(s *instrumentation.MachineSnapshot) { s.Snapshots["ghost"] = s.Snapshots["idle"] },
+			code:     SnapshotIssueUnknownUniverse,
+			contains: "snapshot of universe 'ghost'",
+		},
+		"missing universe": {
+			corrupt:  func(s *instrumentation.MachineSnapshot) { delete(s.Snapshots, "idle") },
+			code:     SnapshotIssueMissingUniverse,
+			contains: "universe 'idle' has no snapshot",
+		},
+		"unknown reality": {
+			corrupt: func(s *instrumentation.MachineSnapshot) {
+				s.Snapshots["main"].CurrentReality = strPtr("GONE")
+				s.Tracking["main"] = append(s.Tracking["main"][:1], "GONE")
+				s.Resume.FinalizedUniverses["Main"] = "GONE"
+			},
+			code:     SnapshotIssueUnknownReality,
+			contains: "current reality 'GONE' of universe 'main' does not exist",
+		},
+		"superposition without accumulator": {
+			corrupt:  func(s *instrumentation.MachineSnapshot) { s.Snapshots["side"].Accumulator = nil },
+			code:     SnapshotIssueMissingAccumulator,
+			contains: "universe 'side' is in superposition without accumulator",
+		},
+		"tracking mismatch": {
+			corrupt:  func(s *instrumentation.MachineSnapshot) { s.Tracking["main"] = []string{"DONE", "PENDING"} },
+			code:     SnapshotIssueTrackingMismatch,
+			contains: "ends at 'PENDING', the current reality is 'DONE'",
+		},
+		"resume mismatch": {
+			corrupt: func(s *instrumentation.MachineSnapshot) {
+				delete(s.Resume.FinalizedUniverses, "Main")
+				s.AddActiveUniverse("Main", "PENDING")
+			},
+			code:     SnapshotIssueResumeMismatch,
+			contains: "resume lists universe 'Main' as active on 'PENDING'",
+		},
+		"unknown resume key": {
+			corrupt:  func(s *instrumentation.MachineSnapshot) { s.AddActiveUniverse("Ghost", "X") },
+			code:     SnapshotIssueUnknownUniverse,
+			contains: "resume lists universe 'Ghost'",
+		},
+		"version mismatch": {
+			corrupt:  func(s *instrumentation.MachineSnapshot) { s.Snapshots["idle"].Version = "0.9.0" },
+			code:     SnapshotIssueVersionMismatch,
+			contains: "has version '0.9.0', the definition has version '1.0.0'",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			snapshot := validSnapshot(t)
+			tc.corrupt(snapshot)
+
+			err := ValidateSnapshot(snapshotValidationModel(), snapshot)
+			var validationErr *SnapshotValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("expected SnapshotValidationError, got %v", err)
+			}
+			for _, issue := range validationErr.Issues {
+				if issue.Code == tc.code && strings.Contains(issue.Message, tc.contains) {
+					return
+				}
+			}
+			t.Fatalf("expected %s issue containing %q, got %v", tc.code, tc.contains, err)
+		})
+	}
+}
+
+func TestLoadSnapshot_Strict(t *testing.T) {
+	snapshot := validSnapshot(t)
+	snapshot.Snapshots["main"].CurrentReality = strPtr("GONE")
+
+	// the lenient default skips the checks and fails on the first universe it cannot load
+	lenient, _ := buildSnapshotValidationQM(t)
+	if err := lenient.LoadSnapshot(snapshot, nil); err == nil {
+		t.Fatal("expected load error")
+	}
+
+	strict, _ := buildSnapshotValidationQM(t, WithStrictSnapshotLoad())
+	err := strict.LoadSnapshot(snapshot, nil)
+	var validationErr *SnapshotValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("expected SnapshotValidationError, got %v", err)
+	}
+	for _, u := range strict.universes {
+		if u.initialized {
+			t.Fatalf("expected strict load to leave universe '%s' untouched", u.model.ID)
+		}
+	}
+
+	if err = strict.LoadSnapshot(validSnapshot(t), nil); err != nil {
+		t.Fatalf("expected valid snapshot to load, got %v", err)
+	}
+}
+
+func TestValidateSnapshot_NilUniverse(t *testing.T) {
+	snapshot := validSnapshot(t)
+	snapshot.Snapshots["ghost"] = snapshot.Snapshots["idle"]
+	model := snapshotValidationModel()
+	model.Universes["ghost"] = nil
+
+	var validationErr *SnapshotValidationError
+	if err := ValidateSnapshot(model, snapshot); !errors.As(err, &validationErr) {
+		t.Fatalf("expected *SnapshotValidationError, got %v", err)
+	}
+	for _, issue := range validationErr.Issues {
+		if issue.Code == SnapshotIssueUnknownUniverse && issue.Universe == "ghost" {
+			return
+		}
+	}
+	t.Fatalf("expected the snapshot of the nil universe to be reported as unknown, got %v", validationErr.Issues)
+}
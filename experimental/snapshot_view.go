@@ -0,0 +1,84 @@
+package experimental
+
+import (
+	"sort"
+
+	"github.com/rendis/statepro/v3/instrumentation"
+)
+
+// machineView implements instrumentation.SnapshotView over the universes of a machine. Actions run with the
+// machine mutex held, so universe state is read directly; metadata reads take the universe metadata mutex.
+type machineView struct {
+	// universes key: theoretical.UniverseModel.ID
+	universes map[string]*ExUniverse
+
+	// universeIDs are the sorted keys of universes
+	universeIDs []string
+
+	// snapshot materializes the full snapshot
+	snapshot func() *instrumentation.MachineSnapshot
+}
+
+func newMachineView(universes map[string]*ExUniverse, snapshot func() *instrumentation.MachineSnapshot) *machineView {
+	ids := make([]string, 0, len(universes))
+	for id := range universes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return &machineView{universes: universes, universeIDs: ids, snapshot: snapshot}
+}
+
+func (v *machineView) GetUniverseIDs() []string {
+	return cloneStringSlice(v.universeIDs)
+}
+
+func (v *machineView) GetCurrentReality(universeID string) (string, bool) {
+	u, ok := v.universes[universeID]
+	if !ok || u.currentReality == nil {
+		return "", false
+	}
+	return *u.currentReality, true
+}
+
+func (v *machineView) IsInitialized(universeID string) bool {
+	u, ok := v.universes[universeID]
+	return ok && u.initialized
+}
+
+func (v *machineView) IsFinalized(universeID string) bool {
+	u, ok := v.universes[universeID]
+	return ok && u.initialized && !u.inSuperposition && u.isFinalReality
+}
+
+func (v *machineView) IsInSuperposition(universeID string) bool {
+	u, ok := v.universes[universeID]
+	return ok && u.initialized && u.inSuperposition
+}
+
+func (v *machineView) GetFinalizedUniverses() []string {
+	var finalized []string
+	for _, id := range v.universeIDs {
+		if v.IsFinalized(id) {
+			finalized = append(finalized, id)
+		}
+	}
+	return finalized
+}
+
+func (v *machineView) GetUniverseMetadataValue(universeID string, key string) (any, bool) {
+	u, ok := v.universes[universeID]
+	if !ok {
+		return nil, false
+	}
+	u.metadataMu.Lock()
+	defer u.metadataMu.Unlock()
+	value, ok := u.metadata[key]
+	return value, ok
+}
+
+func (v *machineView) GetSnapshot() *instrumentation.MachineSnapshot {
+	if v.snapshot == nil {
+		return nil
+	}
+	return v.snapshot()
+}
@@ -0,0 +1,131 @@
+package experimental
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/rendis/statepro/v3/instrumentation"
+	"github.com/rendis/statepro/v3/theoretical"
+)
+
+func TestActionExecutorArgs_GetSnapshotView(t *testing.T) {
+	var got []string
+	registerTestAction(t, "test:view:inspect", func(_ context.Context, args instrumentation.ActionExecutorArgs) error {
+		view := args.(instrumentation.SnapshotViewProvider).GetSnapshotView()
+		args.AddToUniverseMetadata("seen", true)
+
+		current, _ := view.GetCurrentReality("main")
+		seen, _ := view.GetUniverseMetadataValue("main", "seen")
+		_, unknown := view.GetCurrentReality("ghost")
+		got = append(got,
+			"ids="+strings.Join(view.GetUniverseIDs(), ","),
+			"main="+current,
+			fmt.Sprintf("seen=%v", seen),
+			fmt.Sprintf("side=%t", view.IsInSuperposition("side")),
+			fmt.Sprintf("idle=%t", view.IsInitialized("idle")),
+			fmt.Sprintf("ghost=%t", unknown),
+			fmt.Sprintf("snapshot=%s", *view.GetSnapshot().Snapshots["main"].CurrentReality),
+		)
+		return nil
+	})
+
+	model := snapshotValidationModel()
+	withEntryAction("test:view:inspect")(model.Universes["main"].Realities["DONE"])
+	var universes []*ExUniverse
+	for _, um := range model.Universes {
+		universes = append(universes, NewExUniverse(um))
+	}
+	machine, err := NewExQuantumMachine(model, universes)
+	if err != nil {
+		t.Fatalf("failed to build QM: %v", err)
+	}
+	qm := machine.(*ExQuantumMachine)
+	if err = qm.Init(context.Background(), nil); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	if _, err = qm.SendEvent(context.Background(), NewEventBuilder("go").Build()); err != nil {
+		t.Fatalf("SendEvent failed: %v", err)
+	}
+
+	want := "ids=idle,main,side main=DONE seen=true side=true idle=false ghost=false snapshot=DONE"
+	if strings.Join(got, " ") != want {
+		t.Fatalf("unexpected view\n got: %s\nwant: %s", strings.Join(got, " "), want)
+	}
+	if finalized := qm.view.GetFinalizedUniverses(); strings.Join(finalized, ",") != "main" || !qm.view.IsFinalized("main") {
+		t.Fatalf("expected main to be finalized, got %v", finalized)
+	}
+}
+
+func TestActionExecutorArgs_GetSnapshotView_NoMachine(t *testing.T) {
+	args := &actionExecutorArgs{}
+	view := args.GetSnapshotView()
+	if len(view.GetUniverseIDs()) != 0 || view.GetSnapshot() != nil {
+		t.Fatal("expected an empty view outside a machine")
+	}
+
+	// a universe outside a machine builds its own view once
+	u := NewExUniverse(&theoretical.UniverseModel{ID: "solo", CanonicalName: "solo", Version: "1.0.0", Initial: strPtr("A"),
+		Realities: map[string]*theoretical.RealityModel{"A": newTransitionReality("A")}})
+	solo := u.snapshotView()
+	if solo != u.snapshotView() || strings.Join(solo.GetUniverseIDs(), ",") != "solo" || solo.GetSnapshot() != nil {
+		t.Fatalf("expected a single view of the universe alone, got %v", solo.GetUniverseIDs())
+	}
+}
+
+// buildViewBenchmarkQM builds an initialized machine of 20 universes holding 20 metadata keys each.
+func buildViewBenchmarkQM(b *testing.B) *ExQuantumMachine {
+	b.Helper()
+	model := &theoretical.QuantumMachineModel{ID: "qm", CanonicalName: "qm", Version: "1.0.0",
+		Universes: map[string]*theoretical.UniverseModel{}}
+	var universes []*ExUniverse
+	for i := 0; i < 20; i++ {
+		id := fmt.Sprintf("u%d", i)
+		model.Initials = append(model.Initials, "U:"+id)
+		um := &theoretical.UniverseModel{ID: id, CanonicalName: id, Version: "1.0.0", Initial: strPtr("A"),
+			Realities: map[string]*theoretical.RealityModel{"A": newTransitionReality("A")}, Metadata: map[string]any{}}
+		for k := 0; k < 20; k++ {
+			um.Metadata[fmt.Sprintf("key%d", k)] = k
+		}
+		model.Universes[id] = um
+		universes = append(universes, NewExUniverse(um))
+	}
+	qm, err := NewExQuantumMachine(model, universes)
+	if err != nil {
+		b.Fatalf("failed to build QM: %v", err)
+	}
+	if err = qm.Init(context.Background(), nil); err != nil {
+		b.Fatalf("Init failed: %v", err)
+	}
+	return qm.(*ExQuantumMachine)
+}
+
+// BenchmarkActionSnapshotRead compares an action reading another universe's reality and a metadata value
+// through the full snapshot and through the snapshot view.
+func BenchmarkActionSnapshotRead(b *testing.B) {
+	qm := buildViewBenchmarkQM(b)
+	args := &actionExecutorArgs{view: qm.view}
+
+	b.Run("GetSnapshot", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			u := args.GetSnapshot().Snapshots["u7"]
+			if *u.CurrentReality != "A" || u.Metadata["key3"] != 3 {
+				b.Fatal("unexpected snapshot")
+			}
+		}
+	})
+
+	b.Run("GetSnapshotView", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			view := args.GetSnapshotView()
+			reality, _ := view.GetCurrentReality("u7")
+			value, _ := view.GetUniverseMetadataValue("u7", "key3")
+			if reality != "A" || value != 3 {
+				b.Fatal("unexpected view")
+			}
+		}
+	})
+}
@@ -7,7 +7,6 @@ import (
 	"log/slog"
 	"sync"
 
-	"github.com/rendis/statepro/v3/builtin"
 	"github.com/rendis/statepro/v3/instrumentation"
 	"github.com/rendis/statepro/v3/internal/util"
 	"github.com/rendis/statepro/v3/theoretical"
@@ -27,18 +26,10 @@ const (
 	maxEmitDepth = 10
 )
 
-type UniverseInfoSnapshot struct {
-	ID                         string            `json:"id"`
-	CanonicalName              string            `json:"canonicalName"`
-	Version                    string            `json:"version"`
-	Initialized                bool              `json:"initialized"`
-	CurrentReality             *string           `json:"currentReality,omitempty"`
-	RealityInitialized         bool              `json:"realityInitialized"`
-	InSuperposition            bool              `json:"inSuperposition"`
-	RealityBeforeSuperposition *string           `json:"realityBeforeSuperposition,omitempty"`
-	Accumulator                *eventAccumulator `json:"accumulator,omitempty"`
-	Metadata                   map[string]any    `json:"metadata,omitempty"`
-}
+// UniverseInfoSnapshot is the state of a universe recorded in machine snapshots.
+//
+// Deprecated: use instrumentation.UniverseSnapshot.
+type UniverseInfoSnapshot = instrumentation.UniverseSnapshot
 
 func NewExUniverse(model *theoretical.UniverseModel) *ExUniverse {
 	u := &ExUniverse{
@@ -104,9 +95,12 @@ type ExUniverse struct {
 	// entry actions emit more events. Maximum depth is maxEmitDepth.
 	emitDepth int
 
-	// getSnapshotFn returns a snapshot without taking the machine mutex.
-	// Used from actions that already run under quantumMachineMtx.
-	getSnapshotFn func() *instrumentation.MachineSnapshot
+	// view is the read-only view of the machine handed to actions; its snapshot is taken without
+	// the machine mutex, as actions already run under quantumMachineMtx.
+	view *machineView
+
+	// executors are the machine-scoped executors, resolved before the builtin registry; nil outside a machine
+	executors *Executors
 }
 
 //------------------------------- External Operations -------------------------------//
@@ -181,51 +175,52 @@ func (u *ExUniverse) startOnReality(ctx context.Context, realityName string, uni
 }
 
 // getSnapshot returns a snapshot of the universe
-func (u *ExUniverse) getSnapshot() instrumentation.SerializedUniverseSnapshot {
+func (u *ExUniverse) getSnapshot() *instrumentation.UniverseSnapshot {
 	u.metadataMu.Lock()
 	metadataCopy := cloneAnyMap(u.metadata)
 	u.metadataMu.Unlock()
 
-	var infoSnapshot = UniverseInfoSnapshot{
+	var infoSnapshot = &instrumentation.UniverseSnapshot{
 		ID:                         u.model.ID,
 		CanonicalName:              u.model.CanonicalName,
 		Version:                    u.model.Version,
 		Initialized:                u.initialized,
-		CurrentReality:             u.currentReality,
+		CurrentReality:             util.CloneString(u.currentReality),
 		RealityInitialized:         u.realityInitialized,
 		InSuperposition:            u.inSuperposition,
-		RealityBeforeSuperposition: u.realityBeforeSuperposition,
+		RealityBeforeSuperposition: util.CloneString(u.realityBeforeSuperposition),
 		Metadata:                   metadataCopy,
 	}
 
 	if u.eventAccumulator != nil {
 		if accumulator, ok := u.eventAccumulator.(*eventAccumulator); ok {
-			infoSnapshot.Accumulator = accumulator
+			infoSnapshot.Accumulator = accumulator.snapshot()
 		}
 	}
 
-	m, _ := util.StructToMap(infoSnapshot)
-	return m
+	return infoSnapshot
 }
 
 // loadSnapshot loads a snapshot of the universe
-func (u *ExUniverse) loadSnapshot(universeSnapshot instrumentation.SerializedUniverseSnapshot) error {
-	snapshot, err := util.MapToStruct[UniverseInfoSnapshot](universeSnapshot)
-	if err != nil {
-		return errors.Join(fmt.Errorf("error loading snapshot for universe '%s'", u.model.ID), err)
+func (u *ExUniverse) loadSnapshot(snapshot *instrumentation.UniverseSnapshot) error {
+	if snapshot == nil {
+		return fmt.Errorf("error loading snapshot for universe '%s': snapshot is nil", u.model.ID)
 	}
 
 	u.initialized = snapshot.Initialized
-	u.currentReality = snapshot.CurrentReality
+	u.currentReality = util.CloneString(snapshot.CurrentReality)
 	u.realityInitialized = snapshot.RealityInitialized
 	u.inSuperposition = snapshot.InSuperposition
-	u.realityBeforeSuperposition = snapshot.RealityBeforeSuperposition
-	u.eventAccumulator = snapshot.Accumulator
+	u.realityBeforeSuperposition = util.CloneString(snapshot.RealityBeforeSuperposition)
+	u.eventAccumulator = nil
+	if snapshot.Accumulator != nil {
+		u.eventAccumulator = eventAccumulatorFromSnapshot(snapshot.Accumulator)
+	}
 	if len(snapshot.Metadata) > 0 && u.metadata == nil {
 		u.metadata = make(map[string]any)
 	}
 	for k, v := range snapshot.Metadata {
-		u.metadata[k] = v
+		u.metadata[k] = util.CloneValue(v)
 	}
 
 	if u.currentReality != nil {
@@ -612,8 +607,8 @@ func (u *ExUniverse) doCyclicTransition(
 		visitedTargets[next]++
 		if visitedTargets[next] > 1 {
 			return fmt.Errorf(
-				"cyclic transition detected in universe '%s': reality '%s' visited more than once in the same cascade",
-				u.model.ID, next,
+				"%w in universe '%s': reality '%s' visited more than once in the same cascade",
+				ErrCyclicTransition, u.model.ID, next,
 			)
 		}
 
@@ -835,8 +830,8 @@ func (u *ExUniverse) processEmittedEvents(
 			realityName = *u.currentReality
 		}
 		return fmt.Errorf(
-			"emitted event depth exceeded maximum (%d) in universe '%s', reality '%s' — possible infinite loop",
-			maxEmitDepth, u.model.ID, realityName,
+			"%w (%d) in universe '%s', reality '%s' — possible infinite loop",
+			ErrEmitDepth, maxEmitDepth, u.model.ID, realityName,
 		)
 	}
 
@@ -950,7 +945,7 @@ func (u *ExUniverse) executeActions(
 			event:                 event,
 			action:                *action,
 			actionType:            actionType,
-			getSnapshotFn:         u.snapshotProvider(),
+			view:                  u.snapshotView(),
 			emittedEvents:         emittedEvents,
 		}
 		if err := u.runActionExecutor(ctx, action.Src, args); err != nil {
@@ -1013,7 +1008,7 @@ func (u *ExUniverse) accumulateEventForReality(
 }
 
 func (u *ExUniverse) accumulateEventForAllRealities(ctx context.Context, event instrumentation.Event) (bool, string, error) {
-	for _, reality := range sortedMapKeys(u.model.Realities) {
+	for _, reality := range util.SortedKeys(u.model.Realities) {
 		isNewReality, err := u.accumulateEventForReality(ctx, reality, event, false)
 		if err != nil {
 			return false, "", errors.Join(fmt.Errorf("error accumulating Event for reality '%s'", reality), err)
@@ -1082,7 +1077,7 @@ func (u *ExUniverse) runObserverExecutor(ctx context.Context, src string, args *
 		return true, nil
 	}
 
-	if fn := builtin.GetObserver(src); fn != nil {
+	if fn := u.executors.observer(src); fn != nil {
 		return fn(ctx, args)
 	}
 
@@ -1095,7 +1090,7 @@ func (u *ExUniverse) runActionExecutor(ctx context.Context, src string, args *ac
 		return nil
 	}
 
-	if fn := builtin.GetAction(src); fn != nil {
+	if fn := u.executors.action(src); fn != nil {
 		return fn(ctx, args)
 	}
 
@@ -1108,7 +1103,7 @@ func (u *ExUniverse) runInvokeExecutor(ctx context.Context, args *invokeExecutor
 		return
 	}
 
-	if fn := builtin.GetInvoke(args.invoke.Src); fn != nil {
+	if fn := u.executors.invoke(args.invoke.Src); fn != nil {
 		src := args.invoke.Src
 		go func() {
 			defer func() {
@@ -1129,7 +1124,7 @@ func (u *ExUniverse) runConditionExecutor(ctx context.Context, args *conditionEx
 		return true, nil
 	}
 
-	if fn := builtin.GetCondition(args.condition.Src); fn != nil {
+	if fn := u.executors.condition(args.condition.Src); fn != nil {
 		return fn(ctx, args)
 	}
 
@@ -1145,14 +1140,18 @@ func (u *ExUniverse) getRealityModel(realityName string) (*theoretical.RealityMo
 	return realityModel, nil
 }
 
-func (u *ExUniverse) snapshotProvider() func() *instrumentation.MachineSnapshot {
-	if u.getSnapshotFn != nil {
-		return u.getSnapshotFn
-	}
-	if u.constantsLawsExecutor != nil {
-		return u.constantsLawsExecutor.GetSnapshot
+// snapshotView returns the view of the machine of the universe, or a view of the universe alone, built once,
+// when it does not belong to a machine.
+func (u *ExUniverse) snapshotView() *machineView {
+	if u.view == nil {
+		u.view = newMachineView(map[string]*ExUniverse{u.model.ID: u}, func() *instrumentation.MachineSnapshot {
+			if u.constantsLawsExecutor == nil {
+				return nil
+			}
+			return u.constantsLawsExecutor.GetSnapshot()
+		})
 	}
-	return func() *instrumentation.MachineSnapshot { return nil }
+	return u.view
 }
 
 func (u *ExUniverse) universeConstants() *theoretical.UniversalConstantsModel {
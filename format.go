@@ -0,0 +1,312 @@
+package statepro
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/rendis/statepro/v3/theoretical"
+	"gopkg.in/yaml.v3"
+)
+
+// NormalizeQuantumMachine returns a normalized copy of the definition, equivalent at runtime:
+//   - a transition with one condition uses Condition, one with several uses Conditions (in evaluation order);
+//     null conditions are dropped, other null elements are kept as they are;
+//   - the default transition type is omitted;
+//   - empty arrays and objects (actions, args, metadata, ...) are removed, except events without transitions,
+//     which are kept as [] because the reality still handles them.
+func NormalizeQuantumMachine(source *theoretical.QuantumMachineModel) (*theoretical.QuantumMachineModel, error) {
+	if source == nil {
+		return nil, fmt.Errorf("source model cannot be nil")
+	}
+
+	b, err := json.Marshal(source)
+	if err != nil {
+		return nil, err
+	}
+	model := &theoretical.QuantumMachineModel{}
+	if err = json.Unmarshal(b, model); err != nil {
+		return nil, err
+	}
+
+	model.Metadata = emptyMapToNil(model.Metadata)
+	model.UniversalConstants = normalizeConstants(model.UniversalConstants)
+	for _, universe := range model.Universes {
+		if universe == nil {
+			continue
+		}
+		universe.Metadata = emptyMapToNil(universe.Metadata)
+		universe.Tags = emptySliceToNil(universe.Tags)
+		universe.UniversalConstants = normalizeConstants(universe.UniversalConstants)
+		for _, reality := range universe.Realities {
+			normalizeReality(reality)
+		}
+	}
+	return model, nil
+}
+
+// FormatQuantumMachineJSON formats a JSON definition canonically: normalized (see NormalizeQuantumMachine),
+// keys in model field order, universes and realities sorted by id, 2-space indentation.
+// The definition must pass the schema validation, so no unknown property is dropped.
+func FormatQuantumMachineJSON(source []byte) ([]byte, error) {
+	if err := ValidateQuantumMachineBySchemaFromBinary(source); err != nil {
+		return nil, err
+	}
+	model, err := DeserializeQuantumMachineFromBinary(source)
+	if err != nil {
+		return nil, err
+	}
+	root, err := formattedNode(model)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	writeJSONNode(&buf, root, "")
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+// FormatQuantumMachineYAML formats a YAML definition canonically, as FormatQuantumMachineJSON does.
+// Comments are not preserved.
+func FormatQuantumMachineYAML(source []byte) ([]byte, error) {
+	if err := ValidateQuantumMachineBySchemaFromYAML(source); err != nil {
+		return nil, err
+	}
+	model, err := DeserializeQuantumMachineFromYAML(source)
+	if err != nil {
+		return nil, err
+	}
+	root, err := formattedNode(model)
+	if err != nil {
+		return nil, err
+	}
+	resetYAMLStyle(root)
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err = encoder.Encode(root); err != nil {
+		return nil, err
+	}
+	if err = encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// formattedNode returns the normalized definition as a YAML node tree, in serialization order and without null values.
+func formattedNode(source *theoretical.QuantumMachineModel) (*yaml.Node, error) {
+	model, err := NormalizeQuantumMachine(source)
+	if err != nil {
+		return nil, err
+	}
+	b, err := json.Marshal(model)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc yaml.Node
+	if err = yaml.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	root := doc.Content[0]
+	removeNullValues(root)
+	return root, nil
+}
+
+func normalizeReality(reality *theoretical.RealityModel) {
+	if reality == nil {
+		return
+	}
+	reality.Metadata = emptyMapToNil(reality.Metadata)
+	reality.Observers = emptySliceToNil(reality.Observers)
+	reality.EntryActions = emptySliceToNil(reality.EntryActions)
+	reality.ExitActions = emptySliceToNil(reality.ExitActions)
+	reality.EntryInvokes = emptySliceToNil(reality.EntryInvokes)
+	reality.ExitInvokes = emptySliceToNil(reality.ExitInvokes)
+	for _, o := range reality.Observers {
+		if o == nil {
+			continue
+		}
+		o.Args, o.Metadata = emptyMapToNil(o.Args), emptyMapToNil(o.Metadata)
+	}
+	normalizeActions(reality.EntryActions)
+	normalizeActions(reality.ExitActions)
+	normalizeInvokes(reality.EntryInvokes)
+	normalizeInvokes(reality.ExitInvokes)
+
+	reality.Always = emptySliceToNil(reality.Always)
+	for _, transition := range reality.Always {
+		normalizeTransition(transition)
+	}
+	for event, transitions := range reality.On {
+		// an event without transitions is still handled by the reality (SendEvent reports it handled)
+		if transitions == nil {
+			reality.On[event] = []*theoretical.TransitionModel{}
+		}
+		for _, transition := range transitions {
+			normalizeTransition(transition)
+		}
+	}
+	reality.On = emptyMapToNil(reality.On)
+}
+
+func normalizeTransition(transition *theoretical.TransitionModel) {
+	if transition == nil {
+		return
+	}
+
+	// the runtime evaluates Condition first, then Conditions; nil conditions are dropped
+	var conditions []*theoretical.ConditionModel
+	for _, c := range append([]*theoretical.ConditionModel{transition.Condition}, transition.Conditions...) {
+		if c != nil {
+			c.Args, c.Metadata = emptyMapToNil(c.Args), emptyMapToNil(c.Metadata)
+			conditions = append(conditions, c)
+		}
+	}
+	transition.Condition, transition.Conditions = nil, nil
+	switch len(conditions) {
+	case 0:
+	case 1:
+		transition.Condition = conditions[0]
+	default:
+		transition.Conditions = conditions
+	}
+
+	if transition.Type != nil && *transition.Type == theoretical.TransitionTypeDefault {
+		transition.Type = nil
+	}
+	transition.Metadata = emptyMapToNil(transition.Metadata)
+	transition.Actions = emptySliceToNil(transition.Actions)
+	transition.Invokes = emptySliceToNil(transition.Invokes)
+	normalizeActions(transition.Actions)
+	normalizeInvokes(transition.Invokes)
+}
+
+func normalizeConstants(constants *theoretical.UniversalConstantsModel) *theoretical.UniversalConstantsModel {
+	if constants == nil {
+		return nil
+	}
+	constants.EntryActions = emptySliceToNil(constants.EntryActions)
+	constants.ExitActions = emptySliceToNil(constants.ExitActions)
+	constants.ActionsOnTransition = emptySliceToNil(constants.ActionsOnTransition)
+	constants.EntryInvokes = emptySliceToNil(constants.EntryInvokes)
+	constants.ExitInvokes = emptySliceToNil(constants.ExitInvokes)
+	constants.InvokesOnTransition = emptySliceToNil(constants.InvokesOnTransition)
+	normalizeActions(constants.EntryActions)
+	normalizeActions(constants.ExitActions)
+	normalizeActions(constants.ActionsOnTransition)
+	normalizeInvokes(constants.EntryInvokes)
+	normalizeInvokes(constants.ExitInvokes)
+	normalizeInvokes(constants.InvokesOnTransition)
+
+	if constants.EntryActions == nil && constants.ExitActions == nil && constants.ActionsOnTransition == nil &&
+		constants.EntryInvokes == nil && constants.ExitInvokes == nil && constants.InvokesOnTransition == nil {
+		return nil
+	}
+	return constants
+}
+
+func normalizeActions(actions []*theoretical.ActionModel) {
+	for _, a := range actions {
+		if a == nil {
+			continue
+		}
+		a.Args, a.Metadata = emptyMapToNil(a.Args), emptyMapToNil(a.Metadata)
+	}
+}
+
+func normalizeInvokes(invokes []*theoretical.InvokeModel) {
+	for _, i := range invokes {
+		if i == nil {
+			continue
+		}
+		i.Args, i.Metadata = emptyMapToNil(i.Args), emptyMapToNil(i.Metadata)
+	}
+}
+
+func emptyMapToNil[V any](m map[string]V) map[string]V {
+	if len(m) == 0 {
+		return nil
+	}
+	return m
+}
+
+func emptySliceToNil[T any](s []T) []T {
+	if len(s) == 0 {
+		return nil
+	}
+	return s
+}
+
+// removeNullValues removes the object keys with null value (e.g. "on" of final realities).
+func removeNullValues(node *yaml.Node) {
+	if node.Kind == yaml.MappingNode {
+		content := node.Content[:0]
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i+1].Tag == "!!null" {
+				continue
+			}
+			content = append(content, node.Content[i], node.Content[i+1])
+		}
+		node.Content = content
+	}
+	for _, child := range node.Content {
+		removeNullValues(child)
+	}
+}
+
+// writeJSONNode writes a node decoded from JSON back as indented JSON, keeping the key order.
+func writeJSONNode(buf *bytes.Buffer, node *yaml.Node, indent string) {
+	inner := indent + "  "
+	switch node.Kind {
+	case yaml.MappingNode:
+		if len(node.Content) == 0 {
+			buf.WriteString("{}")
+			return
+		}
+		buf.WriteString("{\n")
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			buf.WriteString(inner)
+			writeJSONString(buf, node.Content[i].Value)
+			buf.WriteString(": ")
+			writeJSONNode(buf, node.Content[i+1], inner)
+			if i+2 < len(node.Content) {
+				buf.WriteByte(',')
+			}
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(indent + "}")
+	case yaml.SequenceNode:
+		if len(node.Content) == 0 {
+			buf.WriteString("[]")
+			return
+		}
+		buf.WriteString("[\n")
+		for i, child := range node.Content {
+			buf.WriteString(inner)
+			writeJSONNode(buf, child, inner)
+			if i+1 < len(node.Content) {
+				buf.WriteByte(',')
+			}
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(indent + "]")
+	default:
+		if node.Tag == "!!str" {
+			writeJSONString(buf, node.Value)
+			return
+		}
+		// numbers, booleans and null keep their JSON literal
+		buf.WriteString(strings.TrimSpace(node.Value))
+	}
+}
+
+func writeJSONString(buf *bytes.Buffer, s string) {
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	_ = encoder.Encode(s)
+	buf.Truncate(buf.Len() - 1) // trailing newline of Encode
+}
@@ -0,0 +1,225 @@
+package statepro
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/rendis/statepro/v3/theoretical"
+)
+
+const unformattedMachine = `{"version":"1.0.0","universes":{"main":{"realities":{
+	"PAID":{"type":"final","id":"PAID","on":null},
+	"CREATED":{"id":"CREATED","type":"transition","entryActions":[],"on":{
+		"pay":[{"type":"default","targets":["PAID"],"conditions":[{"src":"condition:funded","args":{}}],"actions":[]}],
+		"check":[{"condition":{"src":"condition:a"},"conditions":[{"src":"condition:b"}],"targets":["PAID"]}],
+		"noop":[]
+	}}},
+	"initial":"CREATED","id":"main","canonicalName":"main","version":"1.0.0","metadata":{}}},
+	"initials":["U:main"],"id":"order","canonicalName":"order"}`
+
+const formattedMachine = `{
+  "id": "order",
+  "canonicalName": "order",
+  "universes": {
+    "main": {
+      "id": "main",
+      "canonicalName": "main",
+      "initial": "CREATED",
+      "realities": {
+        "CREATED": {
+          "id": "CREATED",
+          "type": "transition",
+          "on": {
+            "check": [
+              {
+                "conditions": [
+                  {
+                    "src": "condition:a"
+                  },
+                  {
+                    "src": "condition:b"
+                  }
+                ],
+                "targets": [
+                  "PAID"
+                ]
+              }
+            ],
+            "noop": [],
+            "pay": [
+              {
+                "condition": {
+                  "src": "condition:funded"
+                },
+                "targets": [
+                  "PAID"
+                ]
+              }
+            ]
+          }
+        },
+        "PAID": {
+          "id": "PAID",
+          "type": "final"
+        }
+      },
+      "version": "1.0.0"
+    }
+  },
+  "initials": [
+    "U:main"
+  ],
+  "version": "1.0.0"
+}
+`
+
+func TestFormatQuantumMachineJSON(t *testing.T) {
+	out, err := FormatQuantumMachineJSON([]byte(unformattedMachine))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(out) != formattedMachine {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+
+	again, err := FormatQuantumMachineJSON(out)
+	if err != nil || string(again) != string(out) {
+		t.Fatalf("expected formatting to be idempotent, got %v:\n%s", err, again)
+	}
+}
+
+func TestFormatQuantumMachineJSON_SameModel(t *testing.T) {
+	b, err := os.ReadFile("example/cli/state_machine.json")
+	if err != nil {
+		t.Fatalf("error reading fixture: %v", err)
+	}
+	out, err := FormatQuantumMachineJSON(b)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	original, _ := DeserializeQuantumMachineFromBinary(b)
+	formatted, err := DeserializeQuantumMachineFromBinary(out)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	normalized, _ := NormalizeQuantumMachine(original)
+	expected, _ := json.Marshal(normalized)
+	actual, _ := json.Marshal(formatted)
+	if string(expected) != string(actual) {
+		t.Fatal("expected the formatted definition to deserialize to the normalized model")
+	}
+}
+
+func TestFormatQuantumMachineYAML(t *testing.T) {
+	out, err := FormatQuantumMachineYAML([]byte(yamlMachine))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(string(out), "#") || !strings.HasPrefix(string(out), "id: order\n") {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+	if strings.Contains(string(out), "null") {
+		t.Fatalf("expected null values to be removed:\n%s", out)
+	}
+
+	again, err := FormatQuantumMachineYAML(out)
+	if err != nil || string(again) != string(out) {
+		t.Fatalf("expected formatting to be idempotent, got %v:\n%s", err, again)
+	}
+}
+
+func TestFormatQuantumMachineRejectsUnknownProperties(t *testing.T) {
+	source := strings.Replace(unformattedMachine, `"id":"order"`, `"id":"order","owner":"ops"`, 1)
+	if _, err := FormatQuantumMachineJSON([]byte(source)); err == nil {
+		t.Fatal("expected schema error instead of dropping the unknown property")
+	}
+}
+
+func TestNormalizeQuantumMachine_DoesNotModifySource(t *testing.T) {
+	model, err := DeserializeQuantumMachineFromBinary([]byte(unformattedMachine))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err = NormalizeQuantumMachine(model); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	transition := model.Universes["main"].Realities["CREATED"].On["pay"][0]
+	if transition.Type == nil || *transition.Type != theoretical.TransitionTypeDefault || len(transition.Conditions) != 1 {
+		t.Fatalf("expected source model to be unchanged, got %+v", transition)
+	}
+}
+
+func TestNormalizeQuantumMachine_NilElements(t *testing.T) {
+	model, err := DeserializeQuantumMachineFromBinary([]byte(unformattedMachine))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	created := model.Universes["main"].Realities["CREATED"]
+	created.Observers = []*theoretical.ObserverModel{nil}
+	created.EntryActions = []*theoretical.ActionModel{nil}
+	created.ExitInvokes = []*theoretical.InvokeModel{nil}
+	created.Always = []*theoretical.TransitionModel{nil}
+	created.On["pay"][0].Conditions = append(created.On["pay"][0].Conditions, nil)
+	model.Universes["nil"] = nil
+	model.Universes["main"].Realities["NIL"] = nil
+
+	normalized, err := NormalizeQuantumMachine(model)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	pay := normalized.Universes["main"].Realities["CREATED"].On["pay"][0]
+	if pay.Condition == nil || pay.Condition.Src != "condition:funded" || pay.Conditions != nil {
+		t.Fatalf("expected the nil condition to be dropped, got %+v", pay)
+	}
+}
+
+func TestFormatQuantumMachine_KeepsHandledEvents(t *testing.T) {
+	source := `{"id":"m","canonicalName":"m","version":"1.0.0","initials":["U:main"],"universes":{"main":{
+		"id":"main","canonicalName":"main","version":"1.0.0","initial":"OPEN","realities":{
+			"OPEN":{"id":"OPEN","type":"transition","on":{"noop":[],"close":[{"targets":["CLOSED"]}]}},
+			"CLOSED":{"id":"CLOSED","type":"final"}}}}}`
+	formattedYAML, err := FormatQuantumMachineYAML([]byte(source))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(string(formattedYAML), "noop: []") {
+		t.Fatalf("expected the event without transitions to be kept:\n%s", formattedYAML)
+	}
+	formattedJSON, err := FormatQuantumMachineJSON([]byte(source))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	sendNoop := func(model *theoretical.QuantumMachineModel) bool {
+		t.Helper()
+		qm, err := NewQuantumMachine(model)
+		if err != nil {
+			t.Fatalf("unexpected machine error: %v", err)
+		}
+		if err = qm.Init(context.Background(), nil); err != nil {
+			t.Fatalf("Init failed: %v", err)
+		}
+		handled, err := qm.SendEvent(context.Background(), NewEventBuilder("noop").Build())
+		if err != nil {
+			t.Fatalf("SendEvent failed: %v", err)
+		}
+		return handled
+	}
+	original, _ := DeserializeQuantumMachineFromBinary([]byte(source))
+	fromJSON, _ := DeserializeQuantumMachineFromBinary(formattedJSON)
+	fromYAML, err := DeserializeQuantumMachineFromYAML(formattedYAML)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := sendNoop(original)
+	if !expected {
+		t.Fatal("expected the original definition to handle the event")
+	}
+	if sendNoop(fromJSON) != expected || sendNoop(fromYAML) != expected {
+		t.Fatal("expected the formatted definitions to handle the event as the original")
+	}
+}
@@ -81,7 +81,11 @@ type ActionExecutorArgs interface {
 	GetEvent() Event
 	GetAction() theoretical.ActionModel
 	GetActionType() ActionType
+
+	// GetSnapshot builds the full machine snapshot: every universe, with metadata and accumulated events copied.
+	// Prefer the SnapshotViewProvider view when only realities or metadata values are read.
 	GetSnapshot() *MachineSnapshot
+
 	GetUniverseMetadata() map[string]any
 	AddToUniverseMetadata(key string, value any)
 	DeleteFromUniverseMetadata(key string) (any, bool)
@@ -110,6 +114,17 @@ type ActionExecutorArgs interface {
 	// as invalid. This scenario indicates a bug in the state machine definition.
 	EmitEvent(eventName string, data map[string]any)
 }
+
+// SnapshotViewProvider is implemented by the ActionExecutorArgs the machine passes to actions. Actions that only
+// read realities or metadata values type-assert it to read the machine without building a snapshot:
+//
+//	if provider, ok := args.(instrumentation.SnapshotViewProvider); ok {
+//		reality, _ := provider.GetSnapshotView().GetCurrentReality("payment")
+//	}
+type SnapshotViewProvider interface {
+	// GetSnapshotView returns a read-only view of the machine served from its live state, valid while the action runs.
+	GetSnapshotView() SnapshotView
+}
 type ActionFn func(ctx context.Context, args ActionExecutorArgs) error
 
 type InvokeExecutorArgs interface {
@@ -1,20 +1,141 @@
 package instrumentation
 
-import "encoding/json"
+import (
+	"encoding/json"
 
+	"github.com/rendis/statepro/v3/internal/util"
+)
+
+// SerializedUniverseSnapshot is the map view of a UniverseSnapshot, with the keys of its JSON form.
 type SerializedUniverseSnapshot map[string]any
 
+// UniverseSnapshot is the state of a universe.
+type UniverseSnapshot struct {
+	ID            string `json:"id" bson:"id" xml:"id"`
+	CanonicalName string `json:"canonicalName" bson:"canonicalName" xml:"canonicalName"`
+	Version       string `json:"version" bson:"version" xml:"version"`
+
+	// Initialized is true once the universe has been started
+	Initialized bool `json:"initialized" bson:"initialized" xml:"initialized"`
+
+	// CurrentReality is the reality the universe is on, nil when not established on a reality
+	CurrentReality *string `json:"currentReality,omitempty" bson:"currentReality,omitempty" xml:"currentReality,omitempty"`
+
+	// RealityInitialized is true once the entry of the current reality has been executed
+	RealityInitialized bool `json:"realityInitialized" bson:"realityInitialized" xml:"realityInitialized"`
+
+	InSuperposition bool `json:"inSuperposition" bson:"inSuperposition" xml:"inSuperposition"`
+
+	// RealityBeforeSuperposition is the reality the universe was on when it entered superposition
+	RealityBeforeSuperposition *string `json:"realityBeforeSuperposition,omitempty" bson:"realityBeforeSuperposition,omitempty" xml:"realityBeforeSuperposition,omitempty"`
+
+	// Accumulator holds the events accumulated while in superposition
+	Accumulator *AccumulatorSnapshot `json:"accumulator,omitempty" bson:"accumulator,omitempty" xml:"accumulator,omitempty"`
+
+	Metadata map[string]any `json:"metadata,omitempty" bson:"metadata,omitempty" xml:"metadata,omitempty"`
+}
+
+// AccumulatorSnapshot is the state of the event accumulator of a universe in superposition.
+type AccumulatorSnapshot struct {
+	// RealitiesEvents is the map of the accumulated events
+	// key: reality id, value: events accumulated for the reality, in arrival order
+	RealitiesEvents map[string][]EventSnapshot `json:"realitiesEvents,omitempty" bson:"realitiesEvents,omitempty" xml:"realitiesEvents,omitempty"`
+}
+
+// EventSnapshot is an accumulated event.
+type EventSnapshot struct {
+	Name    string         `json:"name" bson:"name" xml:"name"`
+	Data    map[string]any `json:"data,omitempty" bson:"data,omitempty" xml:"data,omitempty"`
+	EvtType EventType      `json:"type" bson:"type" xml:"type"`
+	Flags   EventFlags     `json:"flags,omitempty" bson:"flags,omitempty" xml:"flags,omitempty"`
+}
+
+// Clone returns a deep copy of the universe snapshot, nested metadata and event data maps and slices included.
+func (us *UniverseSnapshot) Clone() *UniverseSnapshot {
+	if us == nil {
+		return nil
+	}
+	clone := *us
+	clone.CurrentReality = util.CloneString(us.CurrentReality)
+	clone.RealityBeforeSuperposition = util.CloneString(us.RealityBeforeSuperposition)
+	clone.Metadata = util.CloneMap(us.Metadata)
+	if us.Accumulator != nil {
+		clone.Accumulator = &AccumulatorSnapshot{}
+		if us.Accumulator.RealitiesEvents != nil {
+			clone.Accumulator.RealitiesEvents = make(map[string][]EventSnapshot, len(us.Accumulator.RealitiesEvents))
+			for reality, events := range us.Accumulator.RealitiesEvents {
+				cloned := make([]EventSnapshot, len(events))
+				for i, evt := range events {
+					cloned[i] = evt
+					cloned[i].Data = util.CloneMap(evt.Data)
+				}
+				clone.Accumulator.RealitiesEvents[reality] = cloned
+			}
+		}
+	}
+	return &clone
+}
+
+// ToMap returns the map view of the universe snapshot.
+func (us *UniverseSnapshot) ToMap() (SerializedUniverseSnapshot, error) {
+	return util.StructToMap(us)
+}
+
+// UniverseSnapshotFromMap returns the universe snapshot of a map view, see UniverseSnapshot.ToMap.
+func UniverseSnapshotFromMap(m SerializedUniverseSnapshot) (*UniverseSnapshot, error) {
+	return util.MapToStruct[UniverseSnapshot](m)
+}
+
 type MachineSnapshot struct {
 	// Resume is the resume of the machine
 	Resume UniversesResume `json:"resume" bson:"resume" xml:"resume"`
 
 	// Snapshots is the map of the universe snapshots
 	// key: universe id, value: universe snapshot
-	Snapshots map[string]SerializedUniverseSnapshot `json:"snapshots,omitempty" bson:"snapshots,omitempty" xml:"snapshots,omitempty"`
+	Snapshots map[string]*UniverseSnapshot `json:"snapshots,omitempty" bson:"snapshots,omitempty" xml:"snapshots,omitempty"`
 
 	// Tracking is the map of the universe status tracking
 	// key: universe id, value: list of states the universe has been through
 	Tracking map[string][]string `json:"tracking,omitempty" bson:"tracking,omitempty" xml:"tracking,omitempty"`
+
+	// Fingerprint is the fingerprint of the machine definition the snapshot was taken with
+	// (see theoretical.QuantumMachineModel.Fingerprint), empty for snapshots taken before fingerprints existed
+	Fingerprint string `json:"fingerprint,omitempty" bson:"fingerprint,omitempty" xml:"fingerprint,omitempty"`
+
+	// UniverseFingerprints is the map of the universe definition fingerprints
+	// key: universe id, value: fingerprint of the universe definition the universe snapshot was taken with
+	UniverseFingerprints map[string]string `json:"universeFingerprints,omitempty" bson:"universeFingerprints,omitempty" xml:"universeFingerprints,omitempty"`
+}
+
+// SnapshotView is a read-only view of a running machine, served from its live state without copying it.
+// Actions read it through SnapshotViewProvider; it is only valid while the action runs.
+// Universes are identified by id, as in MachineSnapshot.Snapshots.
+type SnapshotView interface {
+	// GetUniverseIDs returns the ids of the universes of the machine, sorted.
+	GetUniverseIDs() []string
+
+	// GetCurrentReality returns the current reality of a universe, false when the universe
+	// does not exist or is not established on a reality.
+	GetCurrentReality(universeID string) (string, bool)
+
+	// IsInitialized returns true when the universe has been started.
+	IsInitialized(universeID string) bool
+
+	// IsFinalized returns true when the universe is on a final reality and not in superposition
+	// (the universes of UniversesResume.FinalizedUniverses).
+	IsFinalized(universeID string) bool
+
+	// IsInSuperposition returns true when the universe is in superposition.
+	IsInSuperposition(universeID string) bool
+
+	// GetFinalizedUniverses returns the ids of the finalized universes, sorted.
+	GetFinalizedUniverses() []string
+
+	// GetUniverseMetadataValue returns a metadata value of a universe.
+	GetUniverseMetadataValue(universeID string, key string) (any, bool)
+
+	// GetSnapshot materializes the full machine snapshot.
+	GetSnapshot() *MachineSnapshot
 }
 
 type UniversesResume struct {
@@ -78,9 +199,9 @@ func (ms *MachineSnapshot) AddSuperpositionUniverseFinalized(universeCanonicalNa
 	ms.Resume.SuperpositionUniversesFinalized[universeCanonicalName] = reality
 }
 
-func (ms *MachineSnapshot) AddUniverseSnapshot(universeId string, snapshot SerializedUniverseSnapshot) {
+func (ms *MachineSnapshot) AddUniverseSnapshot(universeId string, snapshot *UniverseSnapshot) {
 	if ms.Snapshots == nil {
-		ms.Snapshots = make(map[string]SerializedUniverseSnapshot)
+		ms.Snapshots = make(map[string]*UniverseSnapshot)
 	}
 	ms.Snapshots[universeId] = snapshot
 }
@@ -92,6 +213,13 @@ func (ms *MachineSnapshot) AddTracking(universeId string, tracking []string) {
 	ms.Tracking[universeId] = tracking
 }
 
+func (ms *MachineSnapshot) AddUniverseFingerprint(universeId string, fingerprint string) {
+	if ms.UniverseFingerprints == nil {
+		ms.UniverseFingerprints = make(map[string]string)
+	}
+	ms.UniverseFingerprints[universeId] = fingerprint
+}
+
 func (ms *MachineSnapshot) GetResume() UniversesResume {
 	return ms.Resume
 }
@@ -112,6 +240,20 @@ func (ms *MachineSnapshot) GetTracking() map[string][]string {
 	return ms.Tracking
 }
 
+// GetSerializedSnapshots returns the map view of the universe snapshots
+// key: universe id, value: universe snapshot map view
+func (ms *MachineSnapshot) GetSerializedSnapshots() (map[string]SerializedUniverseSnapshot, error) {
+	serialized := make(map[string]SerializedUniverseSnapshot, len(ms.Snapshots))
+	for universeId, snapshot := range ms.Snapshots {
+		m, err := snapshot.ToMap()
+		if err != nil {
+			return nil, err
+		}
+		serialized[universeId] = m
+	}
+	return serialized, nil
+}
+
 func (ms *MachineSnapshot) ToJson() (string, error) {
 	b, err := json.Marshal(ms)
 	if err != nil {
@@ -0,0 +1,370 @@
+package instrumentation
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/rendis/statepro/v3/internal/util"
+)
+
+// ChangeKind classifies a change of a keyed value.
+type ChangeKind string
+
+const (
+	ChangeAdded   ChangeKind = "added"
+	ChangeRemoved ChangeKind = "removed"
+	ChangeChanged ChangeKind = "changed"
+)
+
+// SnapshotDiff is the set of changes between two machine snapshots.
+type SnapshotDiff struct {
+	// Universes holds the universes that changed, sorted by id.
+	Universes []*UniverseDiff `json:"universes,omitempty"`
+}
+
+// UniverseDiff is the set of changes of a universe between two machine snapshots.
+type UniverseDiff struct {
+	UniverseID    string `json:"universeId"`
+	CanonicalName string `json:"canonicalName,omitempty"`
+
+	// Added / Removed are set when the universe is only in the new / old snapshot.
+	Added   bool `json:"added,omitempty"`
+	Removed bool `json:"removed,omitempty"`
+
+	// Initialized is set when the universe was started between both snapshots.
+	Initialized bool `json:"initialized,omitempty"`
+
+	// FromReality / ToReality are set when the current reality changed (nil when there is none).
+	RealityChanged bool    `json:"realityChanged,omitempty"`
+	FromReality    *string `json:"fromReality,omitempty"`
+	ToReality      *string `json:"toReality,omitempty"`
+
+	EnteredSuperposition bool `json:"enteredSuperposition,omitempty"`
+	LeftSuperposition    bool `json:"leftSuperposition,omitempty"`
+
+	// Finalized is set when the universe reached a final reality between both snapshots,
+	// Unfinalized when it left it (e.g. repositioned).
+	Finalized   bool `json:"finalized,omitempty"`
+	Unfinalized bool `json:"unfinalized,omitempty"`
+
+	// Metadata holds the metadata keys added, removed or changed, sorted by key.
+	Metadata []MetadataChange `json:"metadata,omitempty"`
+
+	// Accumulator holds the accumulated events added or removed per reality, sorted by reality.
+	Accumulator []AccumulatorChange `json:"accumulator,omitempty"`
+
+	// TrackingAppended holds the realities appended to the tracking. TrackingRemoved holds the realities of the
+	// old tracking that are no longer in the new one, which only happens when the tracking was rewritten
+	// (e.g. a rollback); TrackingAppended then holds what replaced them.
+	TrackingAppended []string `json:"trackingAppended,omitempty"`
+	TrackingRemoved  []string `json:"trackingRemoved,omitempty"`
+}
+
+// MetadataChange is a metadata key added, removed or changed.
+type MetadataChange struct {
+	Key  string     `json:"key"`
+	Kind ChangeKind `json:"kind"`
+
+	// From is nil for added keys, To for removed keys.
+	From any `json:"from,omitempty"`
+	To   any `json:"to,omitempty"`
+}
+
+// AccumulatorChange is the change of the events accumulated for a reality. Events past the longest common
+// prefix of both lists are reported as removed (old list) and added (new list).
+type AccumulatorChange struct {
+	Reality string          `json:"reality"`
+	Added   []EventSnapshot `json:"added,omitempty"`
+	Removed []EventSnapshot `json:"removed,omitempty"`
+}
+
+// DiffSnapshots compares two machine snapshots and returns the changes from the old to the new one.
+// A nil snapshot is treated as empty. Values are compared by their JSON form when their Go types differ,
+// so a snapshot decoded from JSON compares equal to the one it was encoded from.
+func DiffSnapshots(from, to *MachineSnapshot) *SnapshotDiff {
+	if from == nil {
+		from = &MachineSnapshot{}
+	}
+	if to == nil {
+		to = &MachineSnapshot{}
+	}
+
+	ids := map[string]bool{}
+	for _, m := range []*MachineSnapshot{from, to} {
+		for id := range m.Snapshots {
+			ids[id] = true
+		}
+		for id := range m.Tracking {
+			ids[id] = true
+		}
+	}
+	diff := &SnapshotDiff{}
+	for _, id := range util.SortedKeys(ids) {
+		if ud := diffUniverse(id, from, to); ud != nil {
+			diff.Universes = append(diff.Universes, ud)
+		}
+	}
+	return diff
+}
+
+// IsEmpty reports whether both snapshots are equivalent.
+func (d *SnapshotDiff) IsEmpty() bool {
+	return d == nil || len(d.Universes) == 0
+}
+
+// Universe returns the changes of the universe, nil when it did not change.
+func (d *SnapshotDiff) Universe(universeID string) *UniverseDiff {
+	if d == nil {
+		return nil
+	}
+	for _, ud := range d.Universes {
+		if ud.UniverseID == universeID {
+			return ud
+		}
+	}
+	return nil
+}
+
+// String describes the changes, one line per change.
+func (d *SnapshotDiff) String() string {
+	if d.IsEmpty() {
+		return "no changes"
+	}
+	var lines []string
+	for _, ud := range d.Universes {
+		for _, change := range ud.describe() {
+			lines = append(lines, fmt.Sprintf("%s: %s", ud.UniverseID, change))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (ud *UniverseDiff) describe() []string {
+	var changes []string
+	switch {
+	case ud.Added:
+		changes = append(changes, "added")
+	case ud.Removed:
+		changes = append(changes, "removed")
+	case ud.Initialized:
+		changes = append(changes, "initialized")
+	}
+	if ud.RealityChanged {
+		changes = append(changes, fmt.Sprintf("reality %s -> %s", describeReality(ud.FromReality), describeReality(ud.ToReality)))
+	}
+	if ud.EnteredSuperposition {
+		changes = append(changes, "entered superposition")
+	}
+	if ud.LeftSuperposition {
+		changes = append(changes, "left superposition")
+	}
+	if ud.Finalized {
+		changes = append(changes, "finalized")
+	}
+	if ud.Unfinalized {
+		changes = append(changes, "no longer finalized")
+	}
+	for _, mc := range ud.Metadata {
+		switch mc.Kind {
+		case ChangeAdded:
+			changes = append(changes, fmt.Sprintf("metadata '%s' added: %s", mc.Key, describeValue(mc.To)))
+		case ChangeRemoved:
+			changes = append(changes, fmt.Sprintf("metadata '%s' removed (was %s)", mc.Key, describeValue(mc.From)))
+		default:
+			changes = append(changes, fmt.Sprintf("metadata '%s' changed: %s -> %s", mc.Key, describeValue(mc.From), describeValue(mc.To)))
+		}
+	}
+	for _, ac := range ud.Accumulator {
+		if len(ac.Removed) > 0 {
+			changes = append(changes, fmt.Sprintf("accumulator '%s' removed %s", ac.Reality, describeEvents(ac.Removed)))
+		}
+		if len(ac.Added) > 0 {
+			changes = append(changes, fmt.Sprintf("accumulator '%s' added %s", ac.Reality, describeEvents(ac.Added)))
+		}
+	}
+	if len(ud.TrackingRemoved) > 0 {
+		changes = append(changes, fmt.Sprintf("tracking removed %v", ud.TrackingRemoved))
+	}
+	if len(ud.TrackingAppended) > 0 {
+		changes = append(changes, fmt.Sprintf("tracking appended %v", ud.TrackingAppended))
+	}
+	return changes
+}
+
+func diffUniverse(id string, from, to *MachineSnapshot) *UniverseDiff {
+	old, current := from.Snapshots[id], to.Snapshots[id]
+	ud := &UniverseDiff{UniverseID: id}
+	switch {
+	case current != nil:
+		ud.CanonicalName = current.CanonicalName
+	case old != nil:
+		ud.CanonicalName = old.CanonicalName
+	}
+
+	ud.Added = old == nil && current != nil
+	ud.Removed = old != nil && current == nil
+	if old == nil {
+		old = &UniverseSnapshot{}
+	}
+	if current == nil {
+		current = &UniverseSnapshot{}
+	}
+
+	ud.Initialized = !old.Initialized && current.Initialized
+	if !equalStringPtr(old.CurrentReality, current.CurrentReality) {
+		ud.RealityChanged = true
+		ud.FromReality, ud.ToReality = util.CloneString(old.CurrentReality), util.CloneString(current.CurrentReality)
+	}
+	ud.EnteredSuperposition = !old.InSuperposition && current.InSuperposition
+	ud.LeftSuperposition = old.InSuperposition && !current.InSuperposition
+
+	wasFinalized, isFinalized := from.isFinalized(ud.CanonicalName), to.isFinalized(ud.CanonicalName)
+	ud.Finalized = !wasFinalized && isFinalized
+	ud.Unfinalized = wasFinalized && !isFinalized
+
+	ud.Metadata = diffMetadata(old.Metadata, current.Metadata)
+	ud.Accumulator = diffAccumulator(old.Accumulator, current.Accumulator)
+
+	oldTracking, newTracking := from.Tracking[id], to.Tracking[id]
+	common := 0
+	for common < len(oldTracking) && common < len(newTracking) && oldTracking[common] == newTracking[common] {
+		common++
+	}
+	ud.TrackingRemoved = cloneStrings(oldTracking[common:])
+	ud.TrackingAppended = cloneStrings(newTracking[common:])
+
+	changed := ud.Added || ud.Removed || ud.Initialized || ud.RealityChanged || ud.EnteredSuperposition || ud.LeftSuperposition ||
+		ud.Finalized || ud.Unfinalized || len(ud.Metadata) > 0 || len(ud.Accumulator) > 0 ||
+		len(ud.TrackingRemoved) > 0 || len(ud.TrackingAppended) > 0
+	if !changed {
+		return nil
+	}
+	return ud
+}
+
+func (ms *MachineSnapshot) isFinalized(canonicalName string) bool {
+	if _, ok := ms.Resume.FinalizedUniverses[canonicalName]; ok {
+		return true
+	}
+	_, ok := ms.Resume.SuperpositionUniversesFinalized[canonicalName]
+	return ok
+}
+
+func diffMetadata(from, to map[string]any) []MetadataChange {
+	var changes []MetadataChange
+	for key, value := range from {
+		newValue, ok := to[key]
+		switch {
+		case !ok:
+			changes = append(changes, MetadataChange{Key: key, Kind: ChangeRemoved, From: value})
+		case !equalValues(value, newValue):
+			changes = append(changes, MetadataChange{Key: key, Kind: ChangeChanged, From: value, To: newValue})
+		}
+	}
+	for key, value := range to {
+		if _, ok := from[key]; !ok {
+			changes = append(changes, MetadataChange{Key: key, Kind: ChangeAdded, To: value})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return changes
+}
+
+func diffAccumulator(from, to *AccumulatorSnapshot) []AccumulatorChange {
+	var oldEvents, newEvents map[string][]EventSnapshot
+	if from != nil {
+		oldEvents = from.RealitiesEvents
+	}
+	if to != nil {
+		newEvents = to.RealitiesEvents
+	}
+
+	realities := map[string]bool{}
+	for reality := range oldEvents {
+		realities[reality] = true
+	}
+	for reality := range newEvents {
+		realities[reality] = true
+	}
+
+	var changes []AccumulatorChange
+	for reality := range realities {
+		old, current := oldEvents[reality], newEvents[reality]
+		common := 0
+		for common < len(old) && common < len(current) && equalValues(old[common], current[common]) {
+			common++
+		}
+		if common == len(old) && common == len(current) {
+			continue
+		}
+		changes = append(changes, AccumulatorChange{
+			Reality: reality,
+			Added:   cloneEvents(current[common:]),
+			Removed: cloneEvents(old[common:]),
+		})
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Reality < changes[j].Reality })
+	return changes
+}
+
+// equalValues compares values deeply, falling back to their JSON form so that e.g. int(1) equals float64(1).
+func equalValues(a, b any) bool {
+	if reflect.DeepEqual(a, b) {
+		return true
+	}
+	aJSON, errA := json.Marshal(a)
+	bJSON, errB := json.Marshal(b)
+	return errA == nil && errB == nil && string(aJSON) == string(bJSON)
+}
+
+func equalStringPtr(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func cloneStrings(s []string) []string {
+	if len(s) == 0 {
+		return nil
+	}
+	return append([]string(nil), s...)
+}
+
+func cloneEvents(events []EventSnapshot) []EventSnapshot {
+	if len(events) == 0 {
+		return nil
+	}
+	clone := make([]EventSnapshot, len(events))
+	for i, evt := range events {
+		evt.Data = util.CloneMap(evt.Data)
+		clone[i] = evt
+	}
+	return clone
+}
+
+func describeReality(reality *string) string {
+	if reality == nil {
+		return "<none>"
+	}
+	return "'" + *reality + "'"
+}
+
+func describeValue(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(b)
+}
+
+func describeEvents(events []EventSnapshot) string {
+	names := make([]string, len(events))
+	for i, evt := range events {
+		names[i] = evt.Name
+	}
+	return fmt.Sprintf("%v", names)
+}
@@ -0,0 +1,136 @@
+package instrumentation_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rendis/statepro/v3/instrumentation"
+)
+
+func strPtr(s string) *string { return &s }
+
+// diffFrom has an active universe, one in superposition and one not started.
+func diffFrom() *instrumentation.MachineSnapshot {
+	s := &instrumentation.MachineSnapshot{}
+	s.AddUniverseSnapshot("order", &instrumentation.UniverseSnapshot{
+		ID: "order", CanonicalName: "Order", Initialized: true, CurrentReality: strPtr("PENDING"),
+		Metadata: map[string]any{"attempts": 1, "tmp": "x", "same": []any{"a", 1}},
+	})
+	s.AddActiveUniverse("Order", "PENDING")
+	s.AddTracking("order", []string{"NEW", "PENDING"})
+
+	s.AddUniverseSnapshot("payment", &instrumentation.UniverseSnapshot{
+		ID: "payment", CanonicalName: "Payment", Initialized: true, InSuperposition: true,
+		RealityBeforeSuperposition: strPtr("WAITING"),
+		Accumulator: &instrumentation.AccumulatorSnapshot{RealitiesEvents: map[string][]instrumentation.EventSnapshot{
+			"PAID": {{Name: "pay", EvtType: instrumentation.EventTypeOn}},
+		}},
+	})
+	s.AddSuperpositionUniverse("Payment", "WAITING")
+	s.AddTracking("payment", []string{"WAITING"})
+
+	s.AddUniverseSnapshot("shipping", &instrumentation.UniverseSnapshot{ID: "shipping", CanonicalName: "Shipping"})
+	return s
+}
+
+func TestDiffSnapshots(t *testing.T) {
+	from := diffFrom()
+
+	to := diffFrom()
+	order := to.Snapshots["order"]
+	order.CurrentReality = strPtr("DONE")
+	order.Metadata = map[string]any{"attempts": 2, "same": []any{"a", 1}, "result": "ok"}
+	to.Resume.ActiveUniverses = nil
+	to.AddFinalizedUniverse("Order", "DONE")
+	to.AddTracking("order", []string{"NEW", "PENDING", "DONE"})
+
+	payment := to.Snapshots["payment"]
+	payment.Accumulator.RealitiesEvents["PAID"] = append(payment.Accumulator.RealitiesEvents["PAID"],
+		instrumentation.EventSnapshot{Name: "confirm", EvtType: instrumentation.EventTypeOn})
+	payment.Accumulator.RealitiesEvents["FAILED"] = []instrumentation.EventSnapshot{{Name: "decline"}}
+
+	to.Snapshots["shipping"] = &instrumentation.UniverseSnapshot{
+		ID: "shipping", CanonicalName: "Shipping", Initialized: true, InSuperposition: true,
+		RealityBeforeSuperposition: strPtr("READY"),
+	}
+	to.AddSuperpositionUniverse("Shipping", "READY")
+	to.AddTracking("shipping", []string{"READY"})
+
+	diff := instrumentation.DiffSnapshots(from, to)
+	expected := strings.Join([]string{
+		"order: reality 'PENDING' -> 'DONE'",
+		"order: finalized",
+		"order: metadata 'attempts' changed: 1 -> 2",
+		`order: metadata 'result' added: "ok"`,
+		`order: metadata 'tmp' removed (was "x")`,
+		"order: tracking appended [DONE]",
+		"payment: accumulator 'FAILED' added [decline]",
+		"payment: accumulator 'PAID' added [confirm]",
+		"shipping: initialized",
+		"shipping: entered superposition",
+		"shipping: tracking appended [READY]",
+	}, "\n")
+	if diff.String() != expected {
+		t.Fatalf("unexpected diff\n got: %s\nwant: %s", diff, expected)
+	}
+
+	ud := diff.Universe("order")
+	if ud == nil || !ud.RealityChanged || *ud.FromReality != "PENDING" || *ud.ToReality != "DONE" || !ud.Finalized {
+		t.Fatalf("unexpected order diff %+v", ud)
+	}
+	if diff.Universe("payment").RealityChanged || diff.Universe("unknown") != nil {
+		t.Fatal("expected only changed universes to be reported")
+	}
+
+	// the reverse diff undoes every change
+	reverse := instrumentation.DiffSnapshots(to, from)
+	for _, want := range []string{
+		"order: no longer finalized",
+		"order: tracking removed [DONE]",
+		"payment: accumulator 'PAID' removed [confirm]",
+		"shipping: left superposition",
+	} {
+		if !strings.Contains(reverse.String(), want) {
+			t.Fatalf("expected reverse diff to contain %q, got:\n%s", want, reverse)
+		}
+	}
+}
+
+func TestDiffSnapshots_Equivalent(t *testing.T) {
+	from := diffFrom()
+	if diff := instrumentation.DiffSnapshots(from, diffFrom()); !diff.IsEmpty() || diff.String() != "no changes" {
+		t.Fatalf("expected no changes, got %s", diff)
+	}
+
+	// metadata decoded from JSON (float64) equals the Go values it was encoded from (int)
+	b, _ := json.Marshal(from)
+	var decoded instrumentation.MachineSnapshot
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	if diff := instrumentation.DiffSnapshots(from, &decoded); !diff.IsEmpty() {
+		t.Fatalf("expected a JSON round-trip to have no changes, got %s", diff)
+	}
+}
+
+func TestDiffSnapshots_RewrittenAndNil(t *testing.T) {
+	from := &instrumentation.MachineSnapshot{}
+	from.AddTracking("u", []string{"A", "B", "C"})
+	to := &instrumentation.MachineSnapshot{}
+	to.AddTracking("u", []string{"A", "X"})
+
+	ud := instrumentation.DiffSnapshots(from, to).Universe("u")
+	if !reflect.DeepEqual(ud.TrackingRemoved, []string{"B", "C"}) || !reflect.DeepEqual(ud.TrackingAppended, []string{"X"}) {
+		t.Fatalf("unexpected tracking diff %+v", ud)
+	}
+
+	added := instrumentation.DiffSnapshots(nil, diffFrom())
+	if len(added.Universes) != 3 || !added.Universe("shipping").Added || !added.Universe("order").Initialized {
+		t.Fatalf("expected every universe to be added, got %s", added)
+	}
+	if removed := instrumentation.DiffSnapshots(diffFrom(), nil); !removed.Universe("order").Removed {
+		t.Fatalf("expected universes to be removed, got %s", removed)
+	}
+}
@@ -0,0 +1,106 @@
+package instrumentation_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/rendis/statepro/v3/instrumentation"
+)
+
+// legacySnapshot is the JSON form written when universe snapshots were map[string]any.
+const legacySnapshot = `{
+  "resume": {"superpositionUniverses": {"orders": "PENDING"}},
+  "snapshots": {
+    "u1": {
+      "id": "u1", "canonicalName": "orders", "version": "1.0.0",
+      "initialized": true, "realityInitialized": true, "inSuperposition": true,
+      "realityBeforeSuperposition": "PENDING",
+      "accumulator": {"realitiesEvents": {"A": [{"name": "pay", "data": {"amount": 10}, "type": "On", "flags": {"replayOnEntry": false}}]}},
+      "metadata": {"retries": 2}
+    }
+  },
+  "tracking": {"u1": ["PENDING"]}
+}`
+
+func TestMachineSnapshot_JSONCompatibility(t *testing.T) {
+	var snapshot instrumentation.MachineSnapshot
+	if err := json.Unmarshal([]byte(legacySnapshot), &snapshot); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	u1 := snapshot.Snapshots["u1"]
+	if u1 == nil || !u1.InSuperposition || u1.CurrentReality != nil || *u1.RealityBeforeSuperposition != "PENDING" {
+		t.Fatalf("unexpected universe snapshot %+v", u1)
+	}
+	events := u1.Accumulator.RealitiesEvents["A"]
+	if len(events) != 1 || events[0].Name != "pay" || events[0].EvtType != instrumentation.EventTypeOn {
+		t.Fatalf("unexpected accumulated events %+v", events)
+	}
+
+	// the JSON form is unchanged
+	out, err := json.Marshal(&snapshot)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var before, after map[string]any
+	_ = json.Unmarshal([]byte(legacySnapshot), &before)
+	_ = json.Unmarshal(out, &after)
+	b1, _ := json.Marshal(before)
+	b2, _ := json.Marshal(after)
+	if string(b1) != string(b2) {
+		t.Fatalf("JSON form changed:\nbefore: %s\nafter:  %s", b1, b2)
+	}
+}
+
+func TestUniverseSnapshot_MapView(t *testing.T) {
+	reality := "A"
+	snapshot := &instrumentation.UniverseSnapshot{ID: "u1", Initialized: true, CurrentReality: &reality}
+
+	m, err := snapshot.ToMap()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if m["currentReality"] != "A" || m["initialized"] != true {
+		t.Fatalf("unexpected map view %v", m)
+	}
+	if _, ok := m["accumulator"]; ok {
+		t.Fatalf("expected empty fields to be omitted, got %v", m)
+	}
+
+	back, err := instrumentation.UniverseSnapshotFromMap(m)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if back.ID != "u1" || *back.CurrentReality != "A" {
+		t.Fatalf("unexpected universe snapshot %+v", back)
+	}
+
+	all, err := (&instrumentation.MachineSnapshot{Snapshots: map[string]*instrumentation.UniverseSnapshot{"u1": snapshot}}).GetSerializedSnapshots()
+	if err != nil || all["u1"]["id"] != "u1" {
+		t.Fatalf("unexpected serialized snapshots %v (%v)", all, err)
+	}
+}
+
+func TestUniverseSnapshot_Clone(t *testing.T) {
+	reality := "A"
+	snapshot := &instrumentation.UniverseSnapshot{
+		ID: "u1", CurrentReality: &reality, Metadata: map[string]any{"k": 1},
+		Accumulator: &instrumentation.AccumulatorSnapshot{RealitiesEvents: map[string][]instrumentation.EventSnapshot{
+			"A": {{Name: "x", Data: map[string]any{"v": 1}}},
+		}},
+	}
+
+	clone := snapshot.Clone()
+	*clone.CurrentReality = "B"
+	clone.Metadata["k"] = 2
+	clone.Accumulator.RealitiesEvents["A"][0].Data["v"] = 2
+	clone.Accumulator.RealitiesEvents["B"] = nil
+
+	if reality != "A" || snapshot.Metadata["k"] != 1 || snapshot.Accumulator.RealitiesEvents["A"][0].Data["v"] != 1 ||
+		len(snapshot.Accumulator.RealitiesEvents) != 1 {
+		t.Fatalf("expected the clone not to alias the source, got %+v", snapshot)
+	}
+	if (*instrumentation.UniverseSnapshot)(nil).Clone() != nil {
+		t.Fatal("expected nil clone of nil snapshot")
+	}
+}
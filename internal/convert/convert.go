@@ -0,0 +1,71 @@
+// Package convert holds what the scxml and xstate converters share: the conversion report and the defaults
+// used when importing foreign definitions.
+package convert
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rendis/statepro/v3/theoretical"
+)
+
+// DefaultVersion is the version of imported machines and universes that do not carry one.
+const DefaultVersion = "1.0.0"
+
+// LossKind identifies a construct that could not be converted faithfully.
+type LossKind string
+
+// Loss is a construct that has no equivalent in the target format.
+type Loss struct {
+	Kind LossKind `json:"kind"`
+
+	// Location is a JSON Pointer into the model (Export) or a state path of the source document (Import).
+	Location string `json:"location"`
+
+	Message string `json:"message"`
+}
+
+func (l Loss) String() string {
+	return fmt.Sprintf("%s at '%s': %s", l.Kind, l.Location, l.Message)
+}
+
+// Report lists what a conversion could not carry over.
+type Report struct {
+	Losses []Loss `json:"losses"`
+}
+
+// Add appends a loss to the report.
+func (r *Report) Add(kind LossKind, location string, format string, args ...any) {
+	r.Losses = append(r.Losses, Loss{Kind: kind, Location: location, Message: fmt.Sprintf(format, args...)})
+}
+
+// HasLosses returns true when the conversion was not faithful.
+func (r *Report) HasLosses() bool {
+	return r != nil && len(r.Losses) > 0
+}
+
+// FirstNonEmpty returns the first non-empty value, or "" if there is none.
+func FirstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// InitialsStartEveryUniverse returns true when the machine initials reference every universe, which is how
+// both SCXML and XState start the regions of a parallel state.
+func InitialsStartEveryUniverse(model *theoretical.QuantumMachineModel) bool {
+	started := map[string]bool{}
+	for _, ref := range model.Initials {
+		parts := strings.SplitN(strings.TrimPrefix(ref, "U:"), ":", 2)
+		started[parts[0]] = true
+	}
+	for universeID := range model.Universes {
+		if !started[universeID] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,72 @@
+// Package converttest provides the fixtures shared by the scxml and xstate converter tests.
+package converttest
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/rendis/statepro/v3/internal/convert"
+	"github.com/rendis/statepro/v3/theoretical"
+)
+
+// Machine covers what the converters map one to one and the statepro-only constructs they report:
+// observers, notify and superposition transitions, partial initials, condition args and invokes.
+const Machine = `{
+	"id":"machine",
+	"canonicalName":"machine",
+	"version":"1.0.0",
+	"initials":["U:main"],
+	"universes":{
+		"main":{
+			"id":"main",
+			"canonicalName":"main",
+			"version":"1.0.0",
+			"initial":"A",
+			"realities":{
+				"A":{"id":"A","type":"transition",
+					"entryActions":[{"src":"action:log","args":{"level":"info"}}],
+					"always":[{"targets":["B"],"condition":{"src":"condition:skip"}}],
+					"on":{
+						"go":[{"targets":["DONE"],"conditions":[{"src":"condition:a"},{"src":"condition:b","args":{"n":1}}],"actions":[{"src":"action:go"}]}],
+						"ping":[{"targets":["U:side"],"type":"notify"}],
+						"split":[{"targets":["U:side:S","U:other:X"]}]
+					}},
+				"B":{"id":"B","type":"transition","exitInvokes":[{"src":"invoke:audit"}],"on":{"fail":[{"targets":["FAILED"]}]}},
+				"DONE":{"id":"DONE","type":"final"},
+				"FAILED":{"id":"FAILED","type":"unsuccessfulFinal"}
+			}
+		},
+		"side":{
+			"id":"side",
+			"canonicalName":"side",
+			"version":"1.0.0",
+			"realities":{"S":{"id":"S","type":"transition","observers":[{"src":"builtin:observer:alwaysTrue"}],"on":{"done":[{"targets":["F"]}]}},"F":{"id":"F","type":"final"}}
+		},
+		"other":{
+			"id":"other",
+			"canonicalName":"other",
+			"version":"1.0.0",
+			"initial":"X",
+			"realities":{"X":{"id":"X","type":"final"}}
+		}
+	}
+}`
+
+// Model returns a fresh copy of Machine.
+func Model(t testing.TB) *theoretical.QuantumMachineModel {
+	t.Helper()
+	model := &theoretical.QuantumMachineModel{}
+	if err := json.Unmarshal([]byte(Machine), model); err != nil {
+		t.Fatalf("unexpected unmarshal error: %v", err)
+	}
+	return model
+}
+
+// LossKinds counts the losses of a report by kind.
+func LossKinds(report *convert.Report) map[convert.LossKind]int {
+	kinds := map[convert.LossKind]int{}
+	for _, loss := range report.Losses {
+		kinds[loss.Kind]++
+	}
+	return kinds
+}
@@ -0,0 +1,27 @@
+// Package filelock provides exclusive advisory locks on files, held across processes.
+package filelock
+
+import (
+	"os"
+)
+
+// Lock opens path, creating it if needed, and blocks until it holds an exclusive lock on it.
+// The lock is released by calling unlock or when the process exits. Lock files must not be removed while in
+// use: a process waiting on a removed file would acquire a lock nobody else sees.
+func Lock(path string) (unlock func() error, err error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err = lock(file); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return func() error {
+		if err := unlockFile(file); err != nil {
+			_ = file.Close()
+			return err
+		}
+		return file.Close()
+	}, nil
+}
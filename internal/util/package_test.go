@@ -1,7 +1,80 @@
 package util_test
 
-import "testing"
+import (
+	"strings"
+	"testing"
+
+	"github.com/rendis/statepro/v3/internal/util"
+)
 
 func TestPackageCompiles(t *testing.T) {
 	t.Helper()
 }
+
+func TestSanitizeIdentifier(t *testing.T) {
+	cases := map[string]string{"a.b": "a_b", "1st": "S1st", "end-": "end", "ok": "ok", "": "S"}
+	for in, want := range cases {
+		if got := util.SanitizeIdentifier(in); got != want {
+			t.Fatalf("SanitizeIdentifier(%q) = %q, want %q", in, got, want)
+		}
+	}
+	if got := util.SanitizeEventName("power.on-"); got != "power_on-" {
+		t.Fatalf("unexpected event name %q", got)
+	}
+}
+
+func TestCloneMap(t *testing.T) {
+	src := map[string]any{"m": map[string]any{"k": "v"}, "s": []any{map[string]any{"k": "v"}}, "n": 1}
+	clone := util.CloneMap(src)
+	clone["m"].(map[string]any)["k"] = "x"
+	clone["s"].([]any)[0].(map[string]any)["k"] = "x"
+	if src["m"].(map[string]any)["k"] != "v" || src["s"].([]any)[0].(map[string]any)["k"] != "v" || clone["n"] != 1 {
+		t.Fatalf("expected a deep copy, source is now %v", src)
+	}
+	if util.CloneMap(nil) != nil {
+		t.Fatal("expected nil for a nil map")
+	}
+}
+
+func TestCloneString(t *testing.T) {
+	src := "a"
+	clone := util.CloneString(&src)
+	*clone = "b"
+	if src != "a" {
+		t.Fatalf("expected a copy, source is now %q", src)
+	}
+	if util.CloneString(nil) != nil {
+		t.Fatal("expected nil for a nil pointer")
+	}
+}
+
+func TestSortedKeys(t *testing.T) {
+	if got := strings.Join(util.SortedKeys(map[string]int{"b": 1, "a": 2, "c": 3}), ","); got != "a,b,c" {
+		t.Fatalf("unexpected keys %q", got)
+	}
+	if got := util.SortedKeys[int](nil); len(got) != 0 {
+		t.Fatalf("expected no keys, got %v", got)
+	}
+}
+
+func TestJSONPointer(t *testing.T) {
+	if got := util.JSONPointer("universes", "a/b", "m~n", "0"); got != "/universes/a~1b/m~0n/0" {
+		t.Fatalf("unexpected pointer %q", got)
+	}
+	if got := util.JSONPointer(); got != "" {
+		t.Fatalf("expected the whole document pointer, got %q", got)
+	}
+}
+
+func TestJSONPointerTokens(t *testing.T) {
+	tokens, err := util.JSONPointerTokens("/universes/a~1b/m~0n")
+	if err != nil || strings.Join(tokens, "|") != "universes|a/b|m~n" {
+		t.Fatalf("unexpected tokens %q (%v)", tokens, err)
+	}
+	if tokens, err = util.JSONPointerTokens(""); err != nil || len(tokens) != 0 {
+		t.Fatalf("expected no tokens for the whole document, got %q (%v)", tokens, err)
+	}
+	if _, err = util.JSONPointerTokens("universes"); err == nil {
+		t.Fatal("expected error for a pointer without leading '/'")
+	}
+}
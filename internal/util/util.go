@@ -1,6 +1,11 @@
 package util
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
 
 // ToInt converts any built-in numeric type to int.
 // Returns false if v is not a recognized numeric type.
@@ -61,6 +66,44 @@ func MapToStruct[T any](m map[string]any) (*T, error) {
 	return &out, nil
 }
 
+// CloneMap returns a deep copy of m: nested map[string]any and []any values are copied too, other values are
+// shared. Returns nil for a nil map.
+func CloneMap(m map[string]any) map[string]any {
+	if m == nil {
+		return nil
+	}
+	clone := make(map[string]any, len(m))
+	for k, v := range m {
+		clone[k] = CloneValue(v)
+	}
+	return clone
+}
+
+// CloneValue returns a deep copy of v as CloneMap does.
+func CloneValue(v any) any {
+	switch value := v.(type) {
+	case map[string]any:
+		return CloneMap(value)
+	case []any:
+		clone := make([]any, len(value))
+		for i, item := range value {
+			clone[i] = CloneValue(item)
+		}
+		return clone
+	default:
+		return v
+	}
+}
+
+// CloneString returns a pointer to a copy of *s, or nil for a nil pointer.
+func CloneString(s *string) *string {
+	if s == nil {
+		return nil
+	}
+	c := *s
+	return &c
+}
+
 // Pair groups two values of arbitrary types.
 type Pair[F any, S any] struct {
 	First  F
@@ -73,3 +116,74 @@ func NewPair[F any, S any](first F, second S) Pair[F, S] {
 
 func (p *Pair[F, S]) GetFirst() F    { return p.First }
 func (p *Pair[F, S]) GetAll() (F, S) { return p.First, p.Second }
+
+// SortedKeys returns the keys of m in increasing order.
+func SortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// JSONPointer builds an RFC 6901 JSON Pointer from unescaped reference tokens.
+func JSONPointer(tokens ...string) string {
+	var sb strings.Builder
+	for _, token := range tokens {
+		sb.WriteByte('/')
+		sb.WriteString(pointerEscaper.Replace(token))
+	}
+	return sb.String()
+}
+
+var pointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+
+// JSONPointerTokens returns the unescaped reference tokens of an RFC 6901 JSON Pointer, none for "".
+func JSONPointerTokens(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid JSON Pointer '%s'", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = pointerUnescaper.Replace(token)
+	}
+	return tokens, nil
+}
+
+// SanitizeIdentifier turns id into a valid universe or reality identifier: characters other than letters,
+// digits, '_' and '-' become '_', trailing '_' and '-' are dropped and an 'S' is prepended when it does not
+// start with a letter.
+func SanitizeIdentifier(id string) string {
+	return sanitize(id, true)
+}
+
+// SanitizeEventName is SanitizeIdentifier for event names, which may end with '_' or '-'.
+func SanitizeEventName(name string) string {
+	return sanitize(name, false)
+}
+
+func sanitize(id string, identifier bool) string {
+	var sb strings.Builder
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			sb.WriteRune(r)
+		default:
+			sb.WriteByte('_')
+		}
+	}
+	s := sb.String()
+	if identifier {
+		s = strings.TrimRight(s, "_-")
+	}
+	if s == "" || !(s[0] >= 'a' && s[0] <= 'z' || s[0] >= 'A' && s[0] <= 'Z') {
+		s = "S" + s
+	}
+	return s
+}
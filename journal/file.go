@@ -0,0 +1,251 @@
+package journal
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/rendis/statepro/v3/internal/filelock"
+)
+
+const (
+	fileExtension = ".jsonl"
+	lockExtension = ".lock"
+)
+
+// FileJournal is a Journal keeping one JSON Lines file per stream under <dir>/<stream id>.jsonl (ids are
+// path-escaped). Every append is synced before it returns. A line left incomplete by a crash is ignored by Read
+// and removed by the next Append.
+//
+// Sequence numbers are assigned under an exclusive lock on <stream id>.jsonl.lock, held across processes, so
+// several FileJournals (in one or more processes) can append to a directory. The last sequence of a stream is
+// cached along with the size of its file and read again whenever another writer changed the file.
+// Event data is stored as JSON, so replayed numbers are float64.
+type FileJournal struct {
+	mu    sync.Mutex
+	dir   string
+	tails map[string]streamTail
+}
+
+// streamTail is the last sequence number of a stream and the size of its file after that entry.
+type streamTail struct {
+	sequence uint64
+	size     int64
+}
+
+// NewFileJournal creates a journal in dir, creating the directory if needed.
+func NewFileJournal(dir string) (*FileJournal, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating journal directory: %w", err)
+	}
+	return &FileJournal{dir: dir, tails: map[string]streamTail{}}, nil
+}
+
+func (f *FileJournal) Append(ctx context.Context, streamID string, entry Entry) (Entry, error) {
+	if err := ctx.Err(); err != nil {
+		return Entry{}, err
+	}
+	path, err := f.path(streamID)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	unlock, err := filelock.Lock(path + lockExtension)
+	if err != nil {
+		return Entry{}, fmt.Errorf("error locking journal stream '%s': %w", streamID, err)
+	}
+	defer func() { _ = unlock() }()
+
+	tail, err := f.tail(streamID, path)
+	if err != nil {
+		return Entry{}, fmt.Errorf("error opening journal stream '%s': %w", streamID, err)
+	}
+
+	entry.Sequence = tail.sequence + 1
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return Entry{}, fmt.Errorf("error encoding journal entry: %w", err)
+	}
+	if err = appendLine(path, line); err != nil {
+		// the file may end with a partial line: repair it on the next append
+		f.setTail(streamID, nil)
+		return Entry{}, fmt.Errorf("error appending to journal stream '%s': %w", streamID, err)
+	}
+	f.setTail(streamID, &streamTail{sequence: entry.Sequence, size: tail.size + int64(len(line)) + 1})
+	return entry, nil
+}
+
+func (f *FileJournal) Read(ctx context.Context, streamID string, after uint64) ([]Entry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	path, err := f.path(streamID)
+	if err != nil {
+		return nil, err
+	}
+	entries, _, err := readStream(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading journal stream '%s': %w", streamID, err)
+	}
+
+	result := make([]Entry, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Sequence > after {
+			result = append(result, entry)
+		}
+	}
+	return result, nil
+}
+
+func (f *FileJournal) LastSequence(ctx context.Context, streamID string) (uint64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	path, err := f.path(streamID)
+	if err != nil {
+		return 0, err
+	}
+
+	unlock, err := filelock.Lock(path + lockExtension)
+	if err != nil {
+		return 0, fmt.Errorf("error locking journal stream '%s': %w", streamID, err)
+	}
+	defer func() { _ = unlock() }()
+
+	tail, err := f.tail(streamID, path)
+	if err != nil {
+		return 0, fmt.Errorf("error opening journal stream '%s': %w", streamID, err)
+	}
+	return tail.sequence, nil
+}
+
+// tail returns the last sequence of the stream, repairing the file when another writer changed it since the
+// cached tail (or nothing is cached). It must be called under the stream lock.
+func (f *FileJournal) tail(streamID, path string) (streamTail, error) {
+	size, err := fileSize(path)
+	if err != nil {
+		return streamTail{}, err
+	}
+	f.mu.Lock()
+	cached, ok := f.tails[streamID]
+	f.mu.Unlock()
+	if ok && cached.size == size {
+		return cached, nil
+	}
+
+	tail, err := repairStream(path)
+	if err != nil {
+		return streamTail{}, err
+	}
+	f.setTail(streamID, &tail)
+	return tail, nil
+}
+
+// setTail caches the tail of a stream, or forgets it when tail is nil.
+func (f *FileJournal) setTail(streamID string, tail *streamTail) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if tail == nil {
+		delete(f.tails, streamID)
+		return
+	}
+	f.tails[streamID] = *tail
+}
+
+func (f *FileJournal) path(streamID string) (string, error) {
+	if streamID == "" {
+		return "", fmt.Errorf("stream id cannot be empty")
+	}
+	return filepath.Join(f.dir, url.PathEscape(streamID)+fileExtension), nil
+}
+
+// readStream returns the complete entries of the stream and the size of the file they occupy.
+func readStream(path string) ([]Entry, int64, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var entries []Entry
+	var size int64
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(data)+1)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if int(size)+len(line) >= len(data) {
+			break // last line without newline: incomplete write
+		}
+		var entry Entry
+		if err = json.Unmarshal(line, &entry); err != nil {
+			return nil, 0, fmt.Errorf("line %d: %w", len(entries)+1, err)
+		}
+		entries = append(entries, entry)
+		size += int64(len(line)) + 1
+	}
+	return entries, size, scanner.Err()
+}
+
+// repairStream truncates an incomplete last line and returns the last sequence number of the stream.
+func repairStream(path string) (streamTail, error) {
+	entries, size, err := readStream(path)
+	if err != nil {
+		return streamTail{}, err
+	}
+	if err = truncateIfExists(path, size); err != nil {
+		return streamTail{}, err
+	}
+	if len(entries) == 0 {
+		return streamTail{size: size}, nil
+	}
+	return streamTail{sequence: entries[len(entries)-1].Sequence, size: size}, nil
+}
+
+// fileSize returns the size of the file, 0 when it does not exist.
+func fileSize(path string) (int64, error) {
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+func truncateIfExists(path string, size int64) error {
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil || info.Size() == size {
+		return err
+	}
+	return os.Truncate(path, size)
+}
+
+func appendLine(path string, line []byte) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err = file.Write(append(line, '\n')); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err = file.Sync(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,121 @@
+// Package journal records the inputs accepted by a quantum machine and rebuilds machines from them.
+//
+// A Recorder wraps a machine and appends an Entry to a Journal stream for every successful Init, InitWithEvent,
+// SendEvent, ReplayOnEntry and PositionMachine* call, with a sequence number and a timestamp. Rebuild replays a
+// stream, optionally on top of a snapshot taken at a known sequence, to reproduce how an instance reached its
+// state. Events emitted by actions are not journaled: replaying the inputs emits them again.
+//
+// MemoryJournal and FileJournal are the built-in backends.
+package journal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rendis/statepro/v3/instrumentation"
+	"github.com/rendis/statepro/v3/internal/util"
+)
+
+// ErrInvalidEntry is returned when a journal entry cannot be replayed.
+var ErrInvalidEntry = errors.New("invalid journal entry")
+
+// Kind identifies the machine call an entry records.
+type Kind string
+
+const (
+	// KindInit records Init (Event is nil) or InitWithEvent.
+	KindInit Kind = "init"
+
+	// KindEvent records SendEvent.
+	KindEvent Kind = "event"
+
+	// KindPosition records PositionMachine, PositionMachineOnInitial and their canonical name variants.
+	KindPosition Kind = "position"
+
+	// KindReplayOnEntry records ReplayOnEntry.
+	KindReplayOnEntry Kind = "replayOnEntry"
+)
+
+// Position holds the arguments of a PositionMachine* call.
+type Position struct {
+	// UniverseID is set for PositionMachine and PositionMachineOnInitial.
+	UniverseID string `json:"universeId,omitempty"`
+
+	// UniverseCanonicalName is set for the ByCanonicalName variants.
+	UniverseCanonicalName string `json:"universeCanonicalName,omitempty"`
+
+	// RealityID is empty when positioning on the initial reality.
+	RealityID string `json:"realityId,omitempty"`
+
+	ExecuteFlow bool `json:"executeFlow"`
+}
+
+// Entry is an input accepted by a machine.
+type Entry struct {
+	// Sequence is assigned by the journal, starting at 1 in every stream.
+	Sequence  uint64    `json:"sequence"`
+	Timestamp time.Time `json:"timestamp"`
+	Kind      Kind      `json:"kind"`
+
+	// Event is set for KindEvent, and for KindInit when the machine was initialized with an event.
+	Event *instrumentation.EventSnapshot `json:"event,omitempty"`
+
+	// Position is set for KindPosition.
+	Position *Position `json:"position,omitempty"`
+
+	// Handled is the result of SendEvent.
+	Handled bool `json:"handled,omitempty"`
+}
+
+func (e Entry) String() string {
+	switch {
+	case e.Event != nil:
+		return fmt.Sprintf("#%d %s '%s'", e.Sequence, e.Kind, e.Event.Name)
+	case e.Position != nil:
+		return fmt.Sprintf("#%d %s %s%s:%s", e.Sequence, e.Kind, e.Position.UniverseID,
+			e.Position.UniverseCanonicalName, e.Position.RealityID)
+	default:
+		return fmt.Sprintf("#%d %s", e.Sequence, e.Kind)
+	}
+}
+
+// Journal is an append-only log of entries, split in streams (typically one per machine instance).
+// Implementations must be safe for concurrent use.
+type Journal interface {
+	// Append adds the entry at the end of the stream and returns it with its assigned sequence number.
+	Append(ctx context.Context, streamID string, entry Entry) (Entry, error)
+
+	// Read returns the entries of the stream with a sequence number greater than after, in order.
+	// A stream that has no entries returns an empty slice.
+	Read(ctx context.Context, streamID string, after uint64) ([]Entry, error)
+
+	// LastSequence returns the sequence number of the last entry of the stream, 0 if it has no entries.
+	LastSequence(ctx context.Context, streamID string) (uint64, error)
+}
+
+func eventSnapshot(event instrumentation.Event) *instrumentation.EventSnapshot {
+	if event == nil {
+		return nil
+	}
+	return &instrumentation.EventSnapshot{
+		Name:    event.GetEventName(),
+		Data:    util.CloneMap(event.GetData()),
+		EvtType: event.GetEvtType(),
+		Flags:   event.GetFlags(),
+	}
+}
+
+func cloneEntry(e Entry) Entry {
+	if e.Event != nil {
+		evt := *e.Event
+		evt.Data = util.CloneMap(evt.Data)
+		e.Event = &evt
+	}
+	if e.Position != nil {
+		position := *e.Position
+		e.Position = &position
+	}
+	return e
+}
@@ -0,0 +1,341 @@
+package journal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rendis/statepro/v3"
+	"github.com/rendis/statepro/v3/builder"
+	"github.com/rendis/statepro/v3/builtin"
+	"github.com/rendis/statepro/v3/instrumentation"
+	"github.com/rendis/statepro/v3/theoretical"
+)
+
+func init() {
+	_ = builtin.RegisterAction("action:journalDeposit", func(_ context.Context, args instrumentation.ActionExecutorArgs) error {
+		balance, _ := args.GetUniverseMetadata()["balance"].(float64)
+		amount, _ := args.GetEvent().GetData()["amount"].(float64)
+		args.AddToUniverseMetadata("balance", balance+amount)
+		return nil
+	})
+}
+
+// journalModel is an account, whose deposits add to its balance metadata, next to an audit universe.
+func journalModel() *theoretical.QuantumMachineModel {
+	return builder.New("accounts").
+		Initials(builder.Universe("account")).
+		Universe("account", func(u *builder.UniverseBuilder) {
+			u.Initial("OPEN")
+			u.Reality("OPEN", func(r *builder.RealityBuilder) {
+				r.On("deposit", "OPEN").Action("action:journalDeposit", nil)
+				r.On("close", "CLOSED")
+			})
+			u.Final("CLOSED", nil)
+		}).
+		Universe("audit", func(u *builder.UniverseBuilder) {
+			u.Initial("PENDING")
+			u.Reality("PENDING", func(r *builder.RealityBuilder) {
+				r.On("approve", "APPROVED")
+			})
+			u.Final("APPROVED", nil)
+		}).
+		MustBuild()
+}
+
+func newMachine(t *testing.T) instrumentation.QuantumMachine {
+	t.Helper()
+	qm, err := statepro.NewQuantumMachine(journalModel())
+	if err != nil {
+		t.Fatalf("unexpected machine error: %v", err)
+	}
+	return qm
+}
+
+func snapshotJSON(t *testing.T, snapshot *instrumentation.MachineSnapshot) string {
+	t.Helper()
+	b, err := json.Marshal(snapshot)
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	return string(b)
+}
+
+func deposit(amount float64) instrumentation.Event {
+	return statepro.NewEventBuilder("deposit").SetData(map[string]any{"amount": amount}).Build()
+}
+
+func journals(t *testing.T) map[string]Journal {
+	fileJournal, err := NewFileJournal(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileJournal failed: %v", err)
+	}
+	return map[string]Journal{"memory": NewMemoryJournal(), "file": fileJournal}
+}
+
+// record drives a recorder through every journaled call and returns it.
+func record(t *testing.T, j Journal, stream string) *Recorder {
+	t.Helper()
+	ctx := context.Background()
+	clock := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	r, err := NewRecorder(ctx, newMachine(t), j, stream, WithClock(func() time.Time {
+		clock = clock.Add(time.Second)
+		return clock
+	}))
+	if err != nil {
+		t.Fatalf("NewRecorder failed: %v", err)
+	}
+
+	if err := r.InitWithEvent(ctx, nil, statepro.NewEventBuilder("open").SetData(map[string]any{"by": "alice"}).Build()); err != nil {
+		t.Fatalf("InitWithEvent failed: %v", err)
+	}
+	for _, amount := range []float64{10, 2.5} {
+		if _, err := r.SendEvent(ctx, deposit(amount)); err != nil {
+			t.Fatalf("SendEvent failed: %v", err)
+		}
+	}
+	if handled, err := r.SendEvent(ctx, statepro.NewEventBuilder("unknown").Build()); err != nil || handled {
+		t.Fatalf("expected unknown event to be accepted but not handled, got %v (%v)", handled, err)
+	}
+	if err := r.PositionMachineOnInitialByCanonicalName(ctx, nil, "audit", true); err != nil {
+		t.Fatalf("PositionMachineOnInitialByCanonicalName failed: %v", err)
+	}
+	if err := r.PositionMachine(ctx, nil, "ghost", "X", false); err == nil {
+		t.Fatal("expected error positioning an unknown universe")
+	}
+	if _, err := r.SendEvent(ctx, deposit(7)); err != nil {
+		t.Fatalf("SendEvent failed: %v", err)
+	}
+	if _, err := r.SendEvent(ctx, statepro.NewEventBuilder("close").Build()); err != nil {
+		t.Fatalf("SendEvent failed: %v", err)
+	}
+	return r
+}
+
+func TestRecorder_Entries(t *testing.T) {
+	for name, j := range journals(t) {
+		t.Run(name, func(t *testing.T) {
+			r := record(t, j, "acc-1")
+			entries, err := j.Read(context.Background(), "acc-1", 0)
+			if err != nil {
+				t.Fatalf("Read failed: %v", err)
+			}
+
+			expected := []string{
+				"#1 init 'open'", "#2 event 'deposit'", "#3 event 'deposit'", "#4 event 'unknown'",
+				"#5 position audit:", "#6 event 'deposit'", "#7 event 'close'",
+			}
+			if len(entries) != len(expected) || r.Sequence() != 7 {
+				t.Fatalf("expected %d entries (failed calls skipped), got %v", len(expected), entries)
+			}
+			for i, entry := range entries {
+				if entry.String() != expected[i] {
+					t.Fatalf("entry %d: expected %q, got %q", i, expected[i], entry.String())
+				}
+				if want := time.Date(2026, 1, 1, 0, 0, i+1, 0, time.UTC); !entry.Timestamp.Equal(want) {
+					t.Fatalf("entry %d: expected timestamp %v, got %v", i, want, entry.Timestamp)
+				}
+			}
+			if entries[0].Event.Data["by"] != "alice" || entries[2].Event.Data["amount"] != 2.5 {
+				t.Fatalf("expected event data to be journaled, got %+v %+v", entries[0].Event, entries[2].Event)
+			}
+			if entries[3].Handled || !entries[1].Handled {
+				t.Fatal("expected Handled to record the SendEvent result")
+			}
+
+			if tail, _ := j.Read(context.Background(), "acc-1", 5); len(tail) != 2 || tail[0].Sequence != 6 {
+				t.Fatalf("expected entries after 5, got %v", tail)
+			}
+			if other, _ := j.Read(context.Background(), "acc-2", 0); len(other) != 0 {
+				t.Fatalf("expected an empty stream, got %v", other)
+			}
+		})
+	}
+}
+
+func TestRebuild(t *testing.T) {
+	ctx := context.Background()
+	for name, j := range journals(t) {
+		t.Run(name, func(t *testing.T) {
+			r := record(t, j, "acc-1")
+			want := snapshotJSON(t, r.GetSnapshot())
+
+			qm := newMachine(t)
+			sequence, err := Rebuild(ctx, j, "acc-1", qm, nil)
+			if err != nil || sequence != 7 {
+				t.Fatalf("Rebuild failed at %d: %v", sequence, err)
+			}
+			if got := snapshotJSON(t, qm.GetSnapshot()); got != want {
+				t.Fatalf("rebuilt machine differs\n got: %s\nwant: %s", got, want)
+			}
+
+			// intermediate state: after the two first deposits
+			partial := newMachine(t)
+			if sequence, err = Rebuild(ctx, j, "acc-1", partial, nil, UntilSequence(3)); err != nil || sequence != 3 {
+				t.Fatalf("Rebuild failed at %d: %v", sequence, err)
+			}
+			snapshot := partial.GetSnapshot()
+			if balance := snapshot.Snapshots["account"].Metadata["balance"]; balance != 12.5 {
+				t.Fatalf("expected balance 12.5 after sequence 3, got %v", balance)
+			}
+
+			// resume from the intermediate snapshot
+			resumed := newMachine(t)
+			if sequence, err = Rebuild(ctx, j, "acc-1", resumed, nil, FromSnapshot(snapshot, 3)); err != nil || sequence != 7 {
+				t.Fatalf("Rebuild failed at %d: %v", sequence, err)
+			}
+			if got := snapshotJSON(t, resumed.GetSnapshot()); got != want {
+				t.Fatalf("machine rebuilt from a snapshot differs\n got: %s\nwant: %s", got, want)
+			}
+		})
+	}
+}
+
+func TestRecorder_Checkpoint(t *testing.T) {
+	ctx := context.Background()
+	j := NewMemoryJournal()
+	r, _ := NewRecorder(ctx, newMachine(t), j, "acc-1")
+	_ = r.Init(ctx, nil)
+	_, _ = r.SendEvent(ctx, deposit(5))
+	snapshot, sequence := r.Checkpoint()
+	_, _ = r.SendEvent(ctx, deposit(1))
+
+	qm := newMachine(t)
+	if _, err := Rebuild(ctx, j, "acc-1", qm, nil, FromSnapshot(snapshot, sequence)); err != nil {
+		t.Fatalf("Rebuild failed: %v", err)
+	}
+	if balance := qm.GetSnapshot().Snapshots["account"].Metadata["balance"]; balance != 6.0 {
+		t.Fatalf("expected every deposit applied once, got balance %v", balance)
+	}
+}
+
+func TestRecorder_ExistingStream(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	j, _ := NewFileJournal(dir)
+	r, _ := NewRecorder(ctx, newMachine(t), j, "acc-1")
+	_ = r.Init(ctx, nil)
+	_, _ = r.SendEvent(ctx, deposit(5))
+	snapshot := r.GetSnapshot()
+
+	// after a restart, the machine is restored and a new recorder continues the stream
+	reopened, _ := NewFileJournal(dir)
+	qm := newMachine(t)
+	if err := qm.LoadSnapshot(snapshot, nil); err != nil {
+		t.Fatalf("LoadSnapshot failed: %v", err)
+	}
+	r, err := NewRecorder(ctx, qm, reopened, "acc-1")
+	if err != nil {
+		t.Fatalf("NewRecorder failed: %v", err)
+	}
+	checkpoint, sequence := r.Checkpoint()
+	if sequence != 2 || r.Sequence() != 2 {
+		t.Fatalf("expected the recorder to start at the last sequence of the stream, got %d", sequence)
+	}
+	_, _ = r.SendEvent(ctx, deposit(1))
+
+	rebuilt := newMachine(t)
+	last, err := Rebuild(ctx, reopened, "acc-1", rebuilt, nil, FromSnapshot(checkpoint, sequence))
+	if err != nil || last != 3 {
+		t.Fatalf("Rebuild failed: %d (%v)", last, err)
+	}
+	if balance := rebuilt.GetSnapshot().Snapshots["account"].Metadata["balance"]; balance != 6.0 {
+		t.Fatalf("expected every deposit applied once, got balance %v", balance)
+	}
+
+	if _, err = NewRecorder(ctx, qm, reopened, ""); err == nil {
+		t.Fatal("expected error for an empty stream id")
+	}
+}
+
+func TestFileJournal_IncompleteLine(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	j, _ := NewFileJournal(dir)
+	if _, err := j.Append(ctx, "a/b", Entry{Kind: KindInit}); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+
+	path := filepath.Join(dir, "a%2Fb.jsonl")
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("expected escaped stream file: %v", err)
+	}
+	_, _ = file.WriteString(`{"sequence":2,"kind":"ev`)
+	_ = file.Close()
+
+	reopened, _ := NewFileJournal(dir)
+	if entries, err := reopened.Read(ctx, "a/b", 0); err != nil || len(entries) != 1 {
+		t.Fatalf("expected the incomplete line to be ignored, got %v (%v)", entries, err)
+	}
+	entry, err := reopened.Append(ctx, "a/b", Entry{Kind: KindReplayOnEntry})
+	if err != nil || entry.Sequence != 2 {
+		t.Fatalf("expected append at sequence 2, got %v (%v)", entry, err)
+	}
+	if entries, err := reopened.Read(ctx, "a/b", 0); err != nil || len(entries) != 2 || entries[1].Kind != KindReplayOnEntry {
+		t.Fatalf("expected the incomplete line to be replaced, got %v (%v)", entries, err)
+	}
+}
+
+func TestFileJournal_SharedDirectory(t *testing.T) {
+	// two journals on one directory stand for two processes appending to the same stream
+	ctx := context.Background()
+	dir := t.TempDir()
+	first, _ := NewFileJournal(dir)
+	second, _ := NewFileJournal(dir)
+
+	for i, j := range []*FileJournal{first, second, first, second, first} {
+		entry, err := j.Append(ctx, "s", Entry{Kind: KindReplayOnEntry})
+		if err != nil || entry.Sequence != uint64(i+1) {
+			t.Fatalf("expected append at sequence %d, got %v (%v)", i+1, entry, err)
+		}
+	}
+	if last, err := second.LastSequence(ctx, "s"); err != nil || last != 5 {
+		t.Fatalf("expected last sequence 5, got %d (%v)", last, err)
+	}
+
+	const writers, appends = 4, 10
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(j *FileJournal) {
+			defer wg.Done()
+			for i := 0; i < appends; i++ {
+				if _, err := j.Append(ctx, "s", Entry{Kind: KindReplayOnEntry}); err != nil {
+					t.Errorf("Append failed: %v", err)
+				}
+			}
+		}([]*FileJournal{first, second}[w%2])
+	}
+	wg.Wait()
+
+	entries, err := first.Read(ctx, "s", 0)
+	if err != nil || len(entries) != 5+writers*appends {
+		t.Fatalf("expected %d entries, got %d (%v)", 5+writers*appends, len(entries), err)
+	}
+	for i, entry := range entries {
+		if entry.Sequence != uint64(i+1) {
+			t.Fatalf("expected consecutive sequence numbers, got %d at %d", entry.Sequence, i)
+		}
+	}
+}
+
+func TestApply_InvalidEntries(t *testing.T) {
+	ctx := context.Background()
+	entries := []Entry{
+		{Sequence: 1, Kind: "unknown"},
+		{Sequence: 2, Kind: KindEvent},
+		{Sequence: 3, Kind: KindPosition},
+		{Sequence: 4, Kind: KindPosition, Position: &Position{UniverseID: "a", UniverseCanonicalName: "a"}},
+	}
+	for _, entry := range entries {
+		if err := Apply(ctx, newMachine(t), nil, entry); !errors.Is(err, ErrInvalidEntry) {
+			t.Fatalf("%s: expected ErrInvalidEntry, got %v", entry, err)
+		}
+	}
+}
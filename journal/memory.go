@@ -0,0 +1,55 @@
+package journal
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryJournal is a Journal kept in memory.
+type MemoryJournal struct {
+	mu      sync.RWMutex
+	streams map[string][]Entry
+}
+
+// NewMemoryJournal creates an empty in-memory journal.
+func NewMemoryJournal() *MemoryJournal {
+	return &MemoryJournal{streams: map[string][]Entry{}}
+}
+
+func (m *MemoryJournal) Append(ctx context.Context, streamID string, entry Entry) (Entry, error) {
+	if err := ctx.Err(); err != nil {
+		return Entry{}, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry = cloneEntry(entry)
+	entry.Sequence = uint64(len(m.streams[streamID])) + 1
+	m.streams[streamID] = append(m.streams[streamID], entry)
+	return cloneEntry(entry), nil
+}
+
+func (m *MemoryJournal) Read(ctx context.Context, streamID string, after uint64) ([]Entry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	stream := m.streams[streamID]
+	if after >= uint64(len(stream)) {
+		return []Entry{}, nil
+	}
+	entries := make([]Entry, 0, uint64(len(stream))-after)
+	for _, entry := range stream[after:] {
+		entries = append(entries, cloneEntry(entry))
+	}
+	return entries, nil
+}
+
+func (m *MemoryJournal) LastSequence(ctx context.Context, streamID string) (uint64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return uint64(len(m.streams[streamID])), nil
+}
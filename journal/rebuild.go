@@ -0,0 +1,125 @@
+package journal
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rendis/statepro/v3/experimental"
+	"github.com/rendis/statepro/v3/instrumentation"
+	"github.com/rendis/statepro/v3/internal/util"
+)
+
+// RebuildOption configures Rebuild.
+type RebuildOption func(*rebuildOptions)
+
+type rebuildOptions struct {
+	base     *instrumentation.MachineSnapshot
+	after    uint64
+	untilSeq uint64
+}
+
+// FromSnapshot loads the snapshot before replaying, and replays only the entries after sequence
+// (the snapshot and sequence returned by Recorder.Checkpoint).
+func FromSnapshot(snapshot *instrumentation.MachineSnapshot, sequence uint64) RebuildOption {
+	return func(o *rebuildOptions) {
+		o.base = snapshot
+		o.after = sequence
+	}
+}
+
+// UntilSequence stops the replay after the entry with the given sequence, to reproduce an intermediate state.
+func UntilSequence(sequence uint64) RebuildOption {
+	return func(o *rebuildOptions) {
+		o.untilSeq = sequence
+	}
+}
+
+// Rebuild replays the stream on qm, a new machine built from the definition the stream was recorded with, and
+// returns the sequence of the last entry applied. machineContext is passed to every call that takes one.
+// Actions run again while replaying, so their side effects are repeated.
+func Rebuild(
+	ctx context.Context,
+	journal Journal,
+	streamID string,
+	qm instrumentation.QuantumMachine,
+	machineContext any,
+	opts ...RebuildOption,
+) (uint64, error) {
+	o := &rebuildOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if o.base != nil {
+		if err := qm.LoadSnapshot(o.base, machineContext); err != nil {
+			return 0, fmt.Errorf("error loading base snapshot: %w", err)
+		}
+	}
+
+	entries, err := journal.Read(ctx, streamID, o.after)
+	if err != nil {
+		return 0, err
+	}
+
+	sequence := o.after
+	for _, entry := range entries {
+		if o.untilSeq > 0 && entry.Sequence > o.untilSeq {
+			break
+		}
+		if err = Apply(ctx, qm, machineContext, entry); err != nil {
+			return sequence, fmt.Errorf("error replaying journal entry %s: %w", entry, err)
+		}
+		sequence = entry.Sequence
+	}
+	return sequence, nil
+}
+
+// Apply performs the machine call recorded by the entry.
+func Apply(ctx context.Context, qm instrumentation.QuantumMachine, machineContext any, entry Entry) error {
+	switch entry.Kind {
+	case KindInit:
+		if entry.Event == nil {
+			return qm.Init(ctx, machineContext)
+		}
+		return qm.InitWithEvent(ctx, machineContext, buildEvent(entry.Event))
+	case KindEvent:
+		if entry.Event == nil {
+			return fmt.Errorf("%w: %s has no event", ErrInvalidEntry, entry)
+		}
+		_, err := qm.SendEvent(ctx, buildEvent(entry.Event))
+		return err
+	case KindReplayOnEntry:
+		return qm.ReplayOnEntry(ctx)
+	case KindPosition:
+		return applyPosition(ctx, qm, machineContext, entry)
+	default:
+		return fmt.Errorf("%w: unknown kind '%s'", ErrInvalidEntry, entry.Kind)
+	}
+}
+
+func applyPosition(ctx context.Context, qm instrumentation.QuantumMachine, machineContext any, entry Entry) error {
+	p := entry.Position
+	switch {
+	case p == nil || (p.UniverseID == "") == (p.UniverseCanonicalName == ""):
+		return fmt.Errorf("%w: %s needs a universe id or a canonical name", ErrInvalidEntry, entry)
+	case p.UniverseID != "" && p.RealityID != "":
+		return qm.PositionMachine(ctx, machineContext, p.UniverseID, p.RealityID, p.ExecuteFlow)
+	case p.UniverseID != "":
+		return qm.PositionMachineOnInitial(ctx, machineContext, p.UniverseID, p.ExecuteFlow)
+	case p.RealityID != "":
+		return qm.PositionMachineByCanonicalName(ctx, machineContext, p.UniverseCanonicalName, p.RealityID, p.ExecuteFlow)
+	default:
+		return qm.PositionMachineOnInitialByCanonicalName(ctx, machineContext, p.UniverseCanonicalName, p.ExecuteFlow)
+	}
+}
+
+func buildEvent(evt *instrumentation.EventSnapshot) instrumentation.Event {
+	builder := experimental.NewEventBuilder(evt.Name).SetFlags(evt.Flags)
+	if evt.Data != nil {
+		builder = builder.SetData(util.CloneMap(evt.Data))
+	}
+	if evt.EvtType != "" {
+		builder = builder.SetEvtType(evt.EvtType)
+	}
+	return builder.Build()
+}
@@ -0,0 +1,185 @@
+package journal
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rendis/statepro/v3/instrumentation"
+)
+
+// RecorderOption configures a Recorder.
+type RecorderOption func(*Recorder)
+
+// WithClock sets the function that timestamps entries (default time.Now).
+func WithClock(now func() time.Time) RecorderOption {
+	return func(r *Recorder) {
+		r.now = now
+	}
+}
+
+// Recorder is a QuantumMachine that journals every input its machine accepts. Calls are serialized, so the
+// journal order is the order in which the machine applied them. A call that fails is not journaled; a call that
+// succeeds but cannot be journaled returns the journal error, the machine keeping its new state.
+//
+// LoadSnapshot and GetSnapshot are not journaled. Use Checkpoint to take a snapshot together with the sequence
+// it corresponds to, so Rebuild can start from it.
+type Recorder struct {
+	mu       sync.Mutex
+	qm       instrumentation.QuantumMachine
+	journal  Journal
+	streamID string
+	now      func() time.Time
+	sequence uint64
+}
+
+var _ instrumentation.QuantumMachine = (*Recorder)(nil)
+
+// NewRecorder wraps qm, journaling its inputs in the given stream. The recorder starts at the last sequence of
+// the stream, so a recorder created on an existing stream (e.g. after a restart) checkpoints the right sequence;
+// qm is expected to be in the state the stream leads to.
+func NewRecorder(ctx context.Context, qm instrumentation.QuantumMachine, journal Journal, streamID string, opts ...RecorderOption) (*Recorder, error) {
+	sequence, err := journal.LastSequence(ctx, streamID)
+	if err != nil {
+		return nil, fmt.Errorf("error reading the last sequence of journal stream '%s': %w", streamID, err)
+	}
+	r := &Recorder{qm: qm, journal: journal, streamID: streamID, now: time.Now, sequence: sequence}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r, nil
+}
+
+// Sequence returns the sequence of the last entry of the stream, as of the last append of the recorder (or its
+// creation), 0 if none.
+func (r *Recorder) Sequence() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.sequence
+}
+
+// Checkpoint returns a snapshot of the machine and the sequence of the last entry it includes.
+func (r *Recorder) Checkpoint() (*instrumentation.MachineSnapshot, uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.qm.GetSnapshot(), r.sequence
+}
+
+func (r *Recorder) record(ctx context.Context, entry Entry) error {
+	entry.Timestamp = r.now()
+	appended, err := r.journal.Append(ctx, r.streamID, entry)
+	if err != nil {
+		return fmt.Errorf("error journaling %s: %w", entry.Kind, err)
+	}
+	r.sequence = appended.Sequence
+	return nil
+}
+
+func (r *Recorder) Init(ctx context.Context, machineContext any) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.qm.Init(ctx, machineContext); err != nil {
+		return err
+	}
+	return r.record(ctx, Entry{Kind: KindInit})
+}
+
+func (r *Recorder) InitWithEvent(ctx context.Context, machineContext any, event instrumentation.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.qm.InitWithEvent(ctx, machineContext, event); err != nil {
+		return err
+	}
+	return r.record(ctx, Entry{Kind: KindInit, Event: eventSnapshot(event)})
+}
+
+func (r *Recorder) SendEvent(ctx context.Context, event instrumentation.Event) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	handled, err := r.qm.SendEvent(ctx, event)
+	if err != nil {
+		return handled, err
+	}
+	return handled, r.record(ctx, Entry{Kind: KindEvent, Event: eventSnapshot(event), Handled: handled})
+}
+
+func (r *Recorder) LoadSnapshot(snapshot *instrumentation.MachineSnapshot, machineContext any) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.qm.LoadSnapshot(snapshot, machineContext)
+}
+
+func (r *Recorder) GetSnapshot() *instrumentation.MachineSnapshot {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.qm.GetSnapshot()
+}
+
+func (r *Recorder) ReplayOnEntry(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.qm.ReplayOnEntry(ctx); err != nil {
+		return err
+	}
+	return r.record(ctx, Entry{Kind: KindReplayOnEntry})
+}
+
+func (r *Recorder) position(ctx context.Context, position Position, apply func() error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := apply(); err != nil {
+		return err
+	}
+	return r.record(ctx, Entry{Kind: KindPosition, Position: &position})
+}
+
+func (r *Recorder) PositionMachine(
+	ctx context.Context,
+	machineContext any,
+	universeID string,
+	realityID string,
+	executeFlow bool,
+) error {
+	position := Position{UniverseID: universeID, RealityID: realityID, ExecuteFlow: executeFlow}
+	return r.position(ctx, position, func() error {
+		return r.qm.PositionMachine(ctx, machineContext, universeID, realityID, executeFlow)
+	})
+}
+
+func (r *Recorder) PositionMachineOnInitial(
+	ctx context.Context,
+	machineContext any,
+	universeID string,
+	executeFlow bool,
+) error {
+	position := Position{UniverseID: universeID, ExecuteFlow: executeFlow}
+	return r.position(ctx, position, func() error {
+		return r.qm.PositionMachineOnInitial(ctx, machineContext, universeID, executeFlow)
+	})
+}
+
+func (r *Recorder) PositionMachineByCanonicalName(
+	ctx context.Context,
+	machineContext any,
+	universeCanonicalName string,
+	realityID string,
+	executeFlow bool,
+) error {
+	position := Position{UniverseCanonicalName: universeCanonicalName, RealityID: realityID, ExecuteFlow: executeFlow}
+	return r.position(ctx, position, func() error {
+		return r.qm.PositionMachineByCanonicalName(ctx, machineContext, universeCanonicalName, realityID, executeFlow)
+	})
+}
+
+func (r *Recorder) PositionMachineOnInitialByCanonicalName(
+	ctx context.Context,
+	machineContext any,
+	universeCanonicalName string,
+	executeFlow bool,
+) error {
+	position := Position{UniverseCanonicalName: universeCanonicalName, ExecuteFlow: executeFlow}
+	return r.position(ctx, position, func() error {
+		return r.qm.PositionMachineOnInitialByCanonicalName(ctx, machineContext, universeCanonicalName, executeFlow)
+	})
+}
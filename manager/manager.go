@@ -0,0 +1,315 @@
+// Package manager runs many instances of a machine definition on top of a snapshot store.
+//
+// A Manager keys instances by id. It builds their machines from a shared experimental.CompiledModel, loads them
+// from the store on first use, serializes the operations on each instance (operations on different instances run
+// in parallel) and saves the snapshot after every operation that changes it, with the store's optimistic
+// concurrency. Loaded instances are kept in memory up to a capacity; beyond it, the least recently used idle
+// instances are evicted. Since every change is already persisted, evicting an instance only drops its machine.
+package manager
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/rendis/statepro/v3/experimental"
+	"github.com/rendis/statepro/v3/instrumentation"
+	"github.com/rendis/statepro/v3/store"
+)
+
+const defaultMaxInstances = 1000
+
+// ErrInstanceExists is returned when creating an instance that already exists.
+var ErrInstanceExists = errors.New("instance already exists")
+
+// Option configures a Manager.
+type Option func(*Manager)
+
+// WithMaxInstances sets how many instances are kept in memory (default 1000). Instances in use are never evicted,
+// so the capacity can be exceeded while more instances than that are being operated on.
+func WithMaxInstances(n int) Option {
+	return func(m *Manager) {
+		m.maxInstances = n
+	}
+}
+
+// WithMachineOptions sets the options used to build the instance machines.
+func WithMachineOptions(opts ...experimental.MachineOption) Option {
+	return func(m *Manager) {
+		m.machineOpts = opts
+	}
+}
+
+// WithMachineContext sets the function returning the machine context of an instance,
+// used when it is created or loaded (default nil context).
+func WithMachineContext(fn func(instanceID string) any) Option {
+	return func(m *Manager) {
+		m.machineContext = fn
+	}
+}
+
+// Manager runs the instances of a machine definition. It is safe for concurrent use.
+type Manager struct {
+	model          *experimental.CompiledModel
+	store          store.SnapshotStore
+	maxInstances   int
+	machineOpts    []experimental.MachineOption
+	machineContext func(instanceID string) any
+
+	// mu guards instances and lru
+	mu        sync.Mutex
+	instances map[string]*instance
+
+	// lru orders the instances from the most to the least recently used
+	lru *list.List
+}
+
+// instance is an entry of the manager cache. Its machine is nil until loaded, and again after a failed operation
+// so that the next one reloads the stored state.
+type instance struct {
+	id      string
+	element *list.Element
+
+	// refs is the number of operations holding the instance, guarded by Manager.mu
+	refs int
+
+	// mu serializes the operations on the instance and guards qm and revision
+	mu       sync.Mutex
+	qm       instrumentation.QuantumMachine
+	revision uint64
+}
+
+// New creates a manager for the instances of the model stored in s, under the model id.
+func New(model *experimental.CompiledModel, s store.SnapshotStore, opts ...Option) (*Manager, error) {
+	if model == nil {
+		return nil, fmt.Errorf("model cannot be nil")
+	}
+	if s == nil {
+		return nil, fmt.Errorf("store cannot be nil")
+	}
+	m := &Manager{
+		model:          model,
+		store:          s,
+		maxInstances:   defaultMaxInstances,
+		machineContext: func(string) any { return nil },
+		instances:      map[string]*instance{},
+		lru:            list.New(),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	if m.maxInstances < 1 {
+		return nil, fmt.Errorf("max instances must be positive, got %d", m.maxInstances)
+	}
+	return m, nil
+}
+
+// Init creates the instance: it initializes a new machine and saves it. It returns ErrInstanceExists, without
+// initializing a machine, when the instance is already stored.
+func (m *Manager) Init(ctx context.Context, instanceID string) error {
+	return m.create(ctx, instanceID, func(qm instrumentation.QuantumMachine) error {
+		return qm.Init(ctx, m.machineContext(instanceID))
+	})
+}
+
+// InitWithEvent creates the instance as Init does, initializing the machine with the event.
+func (m *Manager) InitWithEvent(ctx context.Context, instanceID string, event instrumentation.Event) error {
+	return m.create(ctx, instanceID, func(qm instrumentation.QuantumMachine) error {
+		return qm.InitWithEvent(ctx, m.machineContext(instanceID), event)
+	})
+}
+
+// SendEvent sends the event to the instance, loading it if needed, and saves the resulting snapshot.
+// It returns store.ErrNotFound for an unknown instance.
+func (m *Manager) SendEvent(ctx context.Context, instanceID string, event instrumentation.Event) (bool, error) {
+	var handled bool
+	err := m.Do(ctx, instanceID, func(qm instrumentation.QuantumMachine) error {
+		var err error
+		handled, err = qm.SendEvent(ctx, event)
+		return err
+	})
+	return handled, err
+}
+
+// Do runs fn on the machine of the instance, loading it if needed, and saves the resulting snapshot.
+// Operations on the same instance are serialized. When fn or the save fails the machine is dropped, so the next
+// operation starts from the stored state; a store.ErrConflict means another manager saved the instance in the
+// meantime. fn must not keep the machine after returning.
+func (m *Manager) Do(ctx context.Context, instanceID string, fn func(qm instrumentation.QuantumMachine) error) error {
+	inst := m.acquire(instanceID)
+	defer m.release(inst)
+
+	if err := m.load(ctx, inst); err != nil {
+		return err
+	}
+	if err := fn(inst.qm); err != nil {
+		inst.qm = nil
+		return err
+	}
+	return m.save(ctx, inst)
+}
+
+// GetSnapshot returns the current snapshot of the instance, loading it if needed.
+func (m *Manager) GetSnapshot(ctx context.Context, instanceID string) (*instrumentation.MachineSnapshot, error) {
+	inst := m.acquire(instanceID)
+	defer m.release(inst)
+
+	if err := m.load(ctx, inst); err != nil {
+		return nil, err
+	}
+	return inst.qm.GetSnapshot(), nil
+}
+
+// Delete removes the instance from the manager and the store.
+func (m *Manager) Delete(ctx context.Context, instanceID string) error {
+	inst := m.acquire(instanceID)
+	defer m.release(inst)
+
+	if err := m.load(ctx, inst); err != nil {
+		return err
+	}
+	err := m.store.Delete(ctx, m.key(instanceID), inst.revision)
+	inst.qm = nil
+	return err
+}
+
+// Evict drops the instance from memory if it is loaded and idle, and reports whether it was.
+func (m *Manager) Evict(instanceID string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	inst, ok := m.instances[instanceID]
+	if !ok || inst.refs > 0 {
+		return false
+	}
+	m.remove(inst)
+	return true
+}
+
+// Len returns the number of instances in memory.
+func (m *Manager) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.instances)
+}
+
+func (m *Manager) key(instanceID string) store.Key {
+	return store.Key{MachineID: m.model.Model().ID, InstanceID: instanceID}
+}
+
+func (m *Manager) create(ctx context.Context, instanceID string, init func(qm instrumentation.QuantumMachine) error) error {
+	inst := m.acquire(instanceID)
+	defer m.release(inst)
+
+	if inst.qm != nil {
+		return fmt.Errorf("%w: '%s'", ErrInstanceExists, instanceID)
+	}
+	// check before initializing: init actions may have side effects
+	_, err := m.store.Load(ctx, m.key(instanceID))
+	if err == nil {
+		return fmt.Errorf("%w: '%s'", ErrInstanceExists, instanceID)
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+
+	qm, err := m.model.NewMachine(m.machineOpts...)
+	if err != nil {
+		return fmt.Errorf("error building machine of instance '%s': %w", instanceID, err)
+	}
+	if err = init(qm); err != nil {
+		return err
+	}
+
+	// another process may still have created the instance in the meantime
+	revision, err := m.store.Save(ctx, m.key(instanceID), qm.GetSnapshot(), store.NoRevision)
+	if errors.Is(err, store.ErrConflict) {
+		return fmt.Errorf("%w: '%s'", ErrInstanceExists, instanceID)
+	}
+	if err != nil {
+		return err
+	}
+	inst.qm, inst.revision = qm, revision
+	return nil
+}
+
+// acquire returns the locked instance, registering it as the most recently used.
+func (m *Manager) acquire(instanceID string) *instance {
+	m.mu.Lock()
+	inst, ok := m.instances[instanceID]
+	if !ok {
+		inst = &instance{id: instanceID}
+		inst.element = m.lru.PushFront(inst)
+		m.instances[instanceID] = inst
+	} else {
+		m.lru.MoveToFront(inst.element)
+	}
+	inst.refs++
+	m.evict()
+	m.mu.Unlock()
+
+	inst.mu.Lock()
+	return inst
+}
+
+// release unlocks the instance. Instances left without a machine are forgotten once idle.
+func (m *Manager) release(inst *instance) {
+	loaded := inst.qm != nil
+	inst.mu.Unlock()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	inst.refs--
+	if inst.refs == 0 && !loaded && m.instances[inst.id] == inst {
+		m.remove(inst)
+	}
+	m.evict()
+}
+
+// evict removes the least recently used idle instances beyond the capacity. Called with mu held.
+func (m *Manager) evict() {
+	for e := m.lru.Back(); e != nil && len(m.instances) > m.maxInstances; {
+		inst := e.Value.(*instance)
+		e = e.Prev()
+		if inst.refs == 0 {
+			m.remove(inst)
+		}
+	}
+}
+
+func (m *Manager) remove(inst *instance) {
+	m.lru.Remove(inst.element)
+	delete(m.instances, inst.id)
+}
+
+// load builds the machine of the instance from its stored snapshot if it is not loaded. Called with inst.mu held.
+func (m *Manager) load(ctx context.Context, inst *instance) error {
+	if inst.qm != nil {
+		return nil
+	}
+	record, err := m.store.Load(ctx, m.key(inst.id))
+	if err != nil {
+		return err
+	}
+	qm, err := m.model.NewMachine(m.machineOpts...)
+	if err != nil {
+		return fmt.Errorf("error building machine of instance '%s': %w", inst.id, err)
+	}
+	if err = qm.LoadSnapshot(record.Snapshot, m.machineContext(inst.id)); err != nil {
+		return fmt.Errorf("error loading snapshot of instance '%s': %w", inst.id, err)
+	}
+	inst.qm, inst.revision = qm, record.Revision
+	return nil
+}
+
+// save persists the snapshot of the instance. Called with inst.mu held.
+func (m *Manager) save(ctx context.Context, inst *instance) error {
+	revision, err := m.store.Save(ctx, m.key(inst.id), inst.qm.GetSnapshot(), inst.revision)
+	if err != nil {
+		inst.qm = nil
+		return err
+	}
+	inst.revision = revision
+	return nil
+}
@@ -0,0 +1,316 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/rendis/statepro/v3"
+	"github.com/rendis/statepro/v3/builder"
+	"github.com/rendis/statepro/v3/builtin"
+	"github.com/rendis/statepro/v3/experimental"
+	"github.com/rendis/statepro/v3/instrumentation"
+	"github.com/rendis/statepro/v3/store"
+	"github.com/rendis/statepro/v3/theoretical"
+)
+
+// managerModel is a single-universe order: tick stays OPEN, close ends it.
+func managerModel() *theoretical.QuantumMachineModel {
+	return builder.New("orders").
+		Initials(builder.Universe("order")).
+		Universe("order", func(u *builder.UniverseBuilder) {
+			u.Initial("OPEN")
+			u.Reality("OPEN", func(r *builder.RealityBuilder) {
+				r.On("tick", "OPEN")
+				r.On("close", "CLOSED")
+			})
+			u.Final("CLOSED", nil)
+		}).
+		MustBuild()
+}
+
+// initActions counts the executions of the entry action of effectModel.
+var initActions atomic.Int64
+
+// effectModel is a job whose initial reality runs an entry action.
+func effectModel() *theoretical.QuantumMachineModel {
+	return builder.New("effects").
+		Initials(builder.Universe("job")).
+		Universe("job", func(u *builder.UniverseBuilder) {
+			u.Initial("STARTED")
+			u.Reality("STARTED", func(r *builder.RealityBuilder) {
+				r.EntryAction("action:managerInit", nil)
+				r.On("finish", "DONE")
+			})
+			u.Final("DONE", nil)
+		}).
+		MustBuild()
+}
+
+func init() {
+	_ = builtin.RegisterAction("action:managerInit", func(context.Context, instrumentation.ActionExecutorArgs) error {
+		initActions.Add(1)
+		return nil
+	})
+}
+
+// countingStore counts the snapshots loaded from the wrapped store.
+type countingStore struct {
+	store.SnapshotStore
+	loads atomic.Int64
+}
+
+func (c *countingStore) Load(ctx context.Context, key store.Key) (*store.Record, error) {
+	c.loads.Add(1)
+	return c.SnapshotStore.Load(ctx, key)
+}
+
+func newManager(t *testing.T, opts ...Option) (*Manager, *countingStore) {
+	t.Helper()
+	return newManagerOf(t, managerModel(), opts...)
+}
+
+func newManagerOf(t *testing.T, model *theoretical.QuantumMachineModel, opts ...Option) (*Manager, *countingStore) {
+	t.Helper()
+	compiled, err := experimental.CompileModel(model)
+	if err != nil {
+		t.Fatalf("unexpected compile error: %v", err)
+	}
+	s := &countingStore{SnapshotStore: store.NewMemoryStore()}
+	m, err := New(compiled, s, opts...)
+	if err != nil {
+		t.Fatalf("unexpected manager error: %v", err)
+	}
+	return m, s
+}
+
+func tracking(t *testing.T, m *Manager, id string) []string {
+	t.Helper()
+	snapshot, err := m.GetSnapshot(context.Background(), id)
+	if err != nil {
+		t.Fatalf("GetSnapshot(%s) failed: %v", id, err)
+	}
+	return snapshot.Tracking["order"]
+}
+
+func TestManager_Lifecycle(t *testing.T) {
+	ctx := context.Background()
+	m, s := newManager(t)
+
+	if err := m.Init(ctx, "o1"); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	if err := m.Init(ctx, "o1"); !errors.Is(err, ErrInstanceExists) {
+		t.Fatalf("expected ErrInstanceExists, got %v", err)
+	}
+	handled, err := m.SendEvent(ctx, "o1", statepro.NewEventBuilder("close").Build())
+	if err != nil || !handled {
+		t.Fatalf("SendEvent failed: handled=%v err=%v", handled, err)
+	}
+
+	// every event is persisted
+	record, err := s.SnapshotStore.Load(ctx, store.Key{MachineID: "orders", InstanceID: "o1"})
+	if err != nil || record.Revision != 2 || len(record.Snapshot.Tracking["order"]) != 2 {
+		t.Fatalf("unexpected stored record %+v (%v)", record, err)
+	}
+	if s.loads.Load() != 1 {
+		t.Fatalf("expected the created instance to stay loaded, got %d loads", s.loads.Load())
+	}
+
+	if _, err = m.SendEvent(ctx, "unknown", statepro.NewEventBuilder("tick").Build()); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if m.Len() != 1 {
+		t.Fatalf("expected unknown instances not to be kept, got %d", m.Len())
+	}
+
+	if err = m.Delete(ctx, "o1"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err = m.GetSnapshot(ctx, "o1"); !errors.Is(err, store.ErrNotFound) || m.Len() != 0 {
+		t.Fatalf("expected deleted instance to be gone, got %v (len %d)", err, m.Len())
+	}
+}
+
+func TestManager_InitStoredInstance(t *testing.T) {
+	ctx := context.Background()
+	m, _ := newManagerOf(t, effectModel())
+	initActions.Store(0)
+
+	if err := m.Init(ctx, "j1"); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	if !m.Evict("j1") {
+		t.Fatal("expected the instance to be evicted")
+	}
+
+	// the instance is stored but not in memory: its init actions must not run again
+	for _, init := range []func() error{
+		func() error { return m.Init(ctx, "j1") },
+		func() error { return m.InitWithEvent(ctx, "j1", statepro.NewEventBuilder("start").Build()) },
+	} {
+		if err := init(); !errors.Is(err, ErrInstanceExists) {
+			t.Fatalf("expected ErrInstanceExists, got %v", err)
+		}
+	}
+	if initActions.Load() != 1 {
+		t.Fatalf("expected the entry action to run once, got %d", initActions.Load())
+	}
+}
+
+func TestManager_EvictionAndReload(t *testing.T) {
+	ctx := context.Background()
+	m, s := newManager(t, WithMaxInstances(2))
+	tick := statepro.NewEventBuilder("tick").Build()
+
+	for _, id := range []string{"a", "b", "c"} {
+		if err := m.Init(ctx, id); err != nil {
+			t.Fatalf("Init(%s) failed: %v", id, err)
+		}
+	}
+	if m.Len() != 2 {
+		t.Fatalf("expected 2 instances in memory, got %d", m.Len())
+	}
+	s.loads.Store(0) // existence checks of Init
+
+	// "b" and "c" are loaded, "a" was the least recently used
+	if _, err := m.SendEvent(ctx, "b", tick); err != nil {
+		t.Fatalf("SendEvent failed: %v", err)
+	}
+	if s.loads.Load() != 0 {
+		t.Fatalf("expected no loads, got %d", s.loads.Load())
+	}
+	if _, err := m.SendEvent(ctx, "a", tick); err != nil {
+		t.Fatalf("SendEvent failed: %v", err)
+	}
+	if s.loads.Load() != 1 {
+		t.Fatalf("expected the evicted instance to be reloaded, got %d loads", s.loads.Load())
+	}
+	if got := tracking(t, m, "a"); len(got) != 2 {
+		t.Fatalf("expected the reloaded instance to keep its state, got %v", got)
+	}
+
+	// loading "a" evicted "c", the least recently used
+	if _, err := m.GetSnapshot(ctx, "b"); err != nil || s.loads.Load() != 1 {
+		t.Fatalf("expected 'b' to stay loaded, got %d loads (%v)", s.loads.Load(), err)
+	}
+	if _, err := m.GetSnapshot(ctx, "c"); err != nil || s.loads.Load() != 2 {
+		t.Fatalf("expected 'c' to be reloaded, got %d loads (%v)", s.loads.Load(), err)
+	}
+
+	if !m.Evict("c") || m.Evict("c") {
+		t.Fatal("expected Evict to drop the instance once")
+	}
+}
+
+func TestManager_Conflict(t *testing.T) {
+	ctx := context.Background()
+	m, s := newManager(t)
+	tick := statepro.NewEventBuilder("tick").Build()
+
+	if err := m.Init(ctx, "o1"); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	s.loads.Store(0)
+
+	// another process saves the instance behind the manager's back
+	key := store.Key{MachineID: "orders", InstanceID: "o1"}
+	record, _ := s.SnapshotStore.Load(ctx, key)
+	if _, err := s.SnapshotStore.Save(ctx, key, record.Snapshot, record.Revision); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	if _, err := m.SendEvent(ctx, "o1", tick); !errors.Is(err, store.ErrConflict) {
+		t.Fatalf("expected ErrConflict, got %v", err)
+	}
+	// the next event starts from the stored state
+	if _, err := m.SendEvent(ctx, "o1", tick); err != nil {
+		t.Fatalf("expected the instance to be reloaded after a conflict, got %v", err)
+	}
+	if s.loads.Load() != 1 {
+		t.Fatalf("expected a reload, got %d loads", s.loads.Load())
+	}
+}
+
+func TestManager_DoError(t *testing.T) {
+	ctx := context.Background()
+	m, s := newManager(t)
+	if err := m.Init(ctx, "o1"); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	s.loads.Store(0)
+
+	boom := errors.New("boom")
+	err := m.Do(ctx, "o1", func(qm instrumentation.QuantumMachine) error {
+		if _, err := qm.SendEvent(ctx, statepro.NewEventBuilder("close").Build()); err != nil {
+			return err
+		}
+		return boom
+	})
+	if !errors.Is(err, boom) {
+		t.Fatalf("expected the operation error, got %v", err)
+	}
+
+	// the failed operation is not persisted nor kept in memory
+	if got := tracking(t, m, "o1"); len(got) != 1 || s.loads.Load() != 1 {
+		t.Fatalf("expected the stored state to be reloaded, got %v (%d loads)", got, s.loads.Load())
+	}
+}
+
+func TestManager_Concurrent(t *testing.T) {
+	ctx := context.Background()
+	m, _ := newManager(t, WithMaxInstances(3))
+	tick := statepro.NewEventBuilder("tick").Build()
+
+	const instances, events = 8, 20
+	for i := range instances {
+		if err := m.Init(ctx, fmt.Sprintf("o%d", i)); err != nil {
+			t.Fatalf("Init failed: %v", err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, instances*events)
+	for i := range instances {
+		for range events {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := m.SendEvent(ctx, fmt.Sprintf("o%d", i), tick); err != nil {
+					errs <- err
+				}
+			}()
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("SendEvent failed: %v", err)
+	}
+
+	// no event is lost, whatever the evictions in between
+	for i := range instances {
+		if got := tracking(t, m, fmt.Sprintf("o%d", i)); len(got) != events+1 {
+			t.Fatalf("instance o%d: expected %d tracked realities, got %d", i, events+1, len(got))
+		}
+	}
+	if m.Len() > 3 {
+		t.Fatalf("expected at most 3 instances in memory, got %d", m.Len())
+	}
+}
+
+func TestNew_Validation(t *testing.T) {
+	if _, err := New(nil, store.NewMemoryStore()); err == nil {
+		t.Fatal("expected error for a nil model")
+	}
+	compiled := &experimental.CompiledModel{}
+	if _, err := New(compiled, nil); err == nil {
+		t.Fatal("expected error for a nil store")
+	}
+	if _, err := New(compiled, store.NewMemoryStore(), WithMaxInstances(0)); err == nil {
+		t.Fatal("expected error for a non-positive capacity")
+	}
+}
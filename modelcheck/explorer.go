@@ -0,0 +1,382 @@
+package modelcheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/rendis/statepro/v3/experimental"
+	"github.com/rendis/statepro/v3/instrumentation"
+	"github.com/rendis/statepro/v3/internal/util"
+	"github.com/rendis/statepro/v3/theoretical"
+)
+
+// Check explores the state space of the model and reports the problems found.
+// Configurations are compared by the position of each universe (initialized, current reality, superposition);
+// accumulated events and metadata are not part of a configuration since stubs do not depend on them.
+func Check(ctx context.Context, model *theoretical.QuantumMachineModel, opts ...Option) (*Report, error) {
+	if model == nil {
+		return nil, fmt.Errorf("source model cannot be nil")
+	}
+
+	cfg := &config{
+		maxDepth:    defaultMaxDepth,
+		maxStates:   defaultMaxStates,
+		maxBranches: defaultMaxBranches,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if cfg.events == nil {
+		cfg.events = declaredEvents(model)
+	}
+
+	stubbed, err := stubModel(model)
+	if err != nil {
+		return nil, fmt.Errorf("error preparing model: %w", err)
+	}
+
+	e := &explorer{
+		ctx:        ctx,
+		cfg:        cfg,
+		model:      stubbed,
+		states:     map[string]*state{},
+		violations: map[string]bool{},
+	}
+
+	if err = e.explore(); err != nil {
+		return nil, err
+	}
+	e.reportDeadlocksAndCycles()
+	e.reportUnreachableFinals()
+
+	return &Report{Findings: e.findings, States: len(e.order), Truncated: e.truncated}, nil
+}
+
+// state is an explored configuration.
+type state struct {
+	key      string
+	snapshot *instrumentation.MachineSnapshot
+	trace    []Step
+	parent   *state
+
+	// expanded is true when every event has been sent from the configuration.
+	expanded   bool
+	successors map[string]bool
+}
+
+type explorer struct {
+	ctx   context.Context
+	cfg   *config
+	model *theoretical.QuantumMachineModel
+
+	states map[string]*state
+	// order is the discovery order of the states (breadth first).
+	order []*state
+
+	findings   []Finding
+	violations map[string]bool
+	truncated  bool
+}
+
+func (e *explorer) explore() error {
+	var queue []*state
+
+	_, err := e.forEachBranch(nil, "", func(qm instrumentation.QuantumMachine, ctx context.Context) error {
+		return qm.Init(ctx, nil)
+	}, func(step Step, snapshot *instrumentation.MachineSnapshot) bool {
+		s, created := e.addState(nil, step, snapshot)
+		if created {
+			queue = append(queue, s)
+		}
+		return s != nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		// the first step of a trace is Init
+		if len(current.trace)-1 >= e.cfg.maxDepth {
+			continue
+		}
+
+		expanded := true
+		for _, eventName := range e.cfg.events {
+			event := experimental.NewEventBuilder(eventName).Build()
+			complete, err := e.forEachBranch(current, eventName, func(qm instrumentation.QuantumMachine, ctx context.Context) error {
+				_, sendErr := qm.SendEvent(ctx, event)
+				return sendErr
+			}, func(step Step, snapshot *instrumentation.MachineSnapshot) bool {
+				next, created := e.addState(current, step, snapshot)
+				if next == nil {
+					return false
+				}
+				if next != current {
+					current.successors[next.key] = true
+				}
+				if created {
+					queue = append(queue, next)
+				}
+				return true
+			})
+			if err != nil {
+				return err
+			}
+			expanded = expanded && complete
+		}
+		current.expanded = expanded
+	}
+
+	return nil
+}
+
+// forEachBranch runs op once for every combination of stub decisions (bounded by maxBranches), on a fresh
+// machine loaded with the snapshot of from (or uninitialized when from is nil). Runtime errors are reported
+// as findings; onSnapshot receives the resulting configuration of every successful run and returns false
+// when it could not be recorded. The returned bool is false when some branch was not explored or recorded.
+func (e *explorer) forEachBranch(
+	from *state,
+	eventName string,
+	op func(qm instrumentation.QuantumMachine, ctx context.Context) error,
+	onSnapshot func(step Step, snapshot *instrumentation.MachineSnapshot) bool,
+) (bool, error) {
+	complete := true
+	var prefix []bool
+	for branch := 0; ; branch++ {
+		if branch >= e.cfg.maxBranches {
+			e.truncated = true
+			return false, nil
+		}
+
+		qm, err := e.newMachine()
+		if err != nil {
+			return false, err
+		}
+		if from != nil {
+			if err = qm.LoadSnapshot(from.snapshot, nil); err != nil {
+				return false, fmt.Errorf("error loading explored configuration: %w", err)
+			}
+		}
+
+		c := &chooser{prefix: prefix, emits: e.cfg.emits}
+		runErr := op(qm, context.WithValue(e.ctx, chooserKey{}, c))
+		step := Step{Event: eventName, Decisions: c.decisions}
+
+		if runErr != nil {
+			e.addViolation(from, step, runErr)
+		} else if !onSnapshot(step, qm.GetSnapshot()) {
+			complete = false
+		}
+
+		var ok bool
+		if prefix, ok = nextPrefix(c.decisions); !ok {
+			return complete, nil
+		}
+	}
+}
+
+func (e *explorer) newMachine() (instrumentation.QuantumMachine, error) {
+	universes := make([]*experimental.ExUniverse, 0, len(e.model.Universes))
+	for _, universe := range e.model.Universes {
+		universes = append(universes, experimental.NewExUniverse(universe))
+	}
+	return experimental.NewExQuantumMachine(e.model, universes, experimental.WithExecutors(stubExecutors))
+}
+
+// addState registers the configuration reached from parent through step and returns its state, and whether
+// it was discovered by this call. It returns nil when the configuration is new but maxStates has been reached.
+func (e *explorer) addState(parent *state, step Step, snapshot *instrumentation.MachineSnapshot) (*state, bool) {
+	key := configurationKey(snapshot)
+	if s, ok := e.states[key]; ok {
+		return s, false
+	}
+
+	if len(e.order) >= e.cfg.maxStates {
+		e.truncated = true
+		return nil, false
+	}
+
+	s := &state{
+		key:        key,
+		snapshot:   snapshot,
+		trace:      appendStep(parent, step),
+		parent:     parent,
+		successors: map[string]bool{},
+	}
+	e.states[key] = s
+	e.order = append(e.order, s)
+	return s, true
+}
+
+func (e *explorer) addViolation(from *state, step Step, err error) {
+	kind := FindingRuntimeError
+	switch {
+	case errors.Is(err, experimental.ErrCyclicTransition):
+		kind = FindingCyclicTransition
+	case errors.Is(err, experimental.ErrExternalTargetDepth):
+		kind = FindingCascadeDepth
+	case errors.Is(err, experimental.ErrEmitDepth):
+		kind = FindingEmitDepth
+	}
+
+	message := err.Error()
+	if e.violations[string(kind)+message] {
+		return
+	}
+	e.violations[string(kind)+message] = true
+
+	e.findings = append(e.findings, Finding{Kind: kind, Message: message, Trace: appendStep(from, step)})
+}
+
+func (e *explorer) reportDeadlocksAndCycles() {
+	// configurations that can reach a finished configuration; configurations that were not expanded
+	// (depth or state bound) are assumed to, so that bounds never produce false positives
+	canFinish := map[string]bool{}
+	predecessors := map[string][]string{}
+	var queue []string
+	for _, s := range e.order {
+		for successor := range s.successors {
+			predecessors[successor] = append(predecessors[successor], s.key)
+		}
+		if !s.expanded || isFinished(s.snapshot) {
+			canFinish[s.key] = true
+			queue = append(queue, s.key)
+		}
+	}
+	for len(queue) > 0 {
+		key := queue[0]
+		queue = queue[1:]
+		for _, predecessor := range predecessors[key] {
+			if !canFinish[predecessor] {
+				canFinish[predecessor] = true
+				queue = append(queue, predecessor)
+			}
+		}
+	}
+
+	for _, s := range e.order {
+		if canFinish[s.key] {
+			continue
+		}
+
+		if len(s.successors) == 0 {
+			e.findings = append(e.findings, Finding{
+				Kind:    FindingDeadlock,
+				Message: fmt.Sprintf("no event changes configuration %s and the machine is not finished", describeConfiguration(s.snapshot)),
+				Trace:   s.trace,
+			})
+			continue
+		}
+
+		// report only the point of no return of each trace
+		if s.parent != nil && !canFinish[s.parent.key] {
+			continue
+		}
+		e.findings = append(e.findings, Finding{
+			Kind:    FindingCycle,
+			Message: fmt.Sprintf("from configuration %s events keep moving the machine but no finished configuration can be reached", describeConfiguration(s.snapshot)),
+			Trace:   s.trace,
+		})
+	}
+}
+
+func (e *explorer) reportUnreachableFinals() {
+	reached := map[string]bool{}
+	for _, s := range e.order {
+		for universeID, snapshot := range s.snapshot.Snapshots {
+			// a final reality with always transitions to other universes leaves its universe in superposition
+			for _, reality := range []*string{snapshot.CurrentReality, snapshot.RealityBeforeSuperposition} {
+				if reality != nil {
+					reached[universeID+":"+*reality] = true
+				}
+			}
+		}
+	}
+
+	for _, universeID := range util.SortedKeys(e.model.Universes) {
+		universe := e.model.Universes[universeID]
+		if universe == nil {
+			continue
+		}
+		for _, realityID := range util.SortedKeys(universe.Realities) {
+			reality := universe.Realities[realityID]
+			if reality == nil || !theoretical.IsFinalState(reality.Type) || reached[universeID+":"+realityID] {
+				continue
+			}
+			e.findings = append(e.findings, Finding{
+				Kind:    FindingUnreachableFinal,
+				Message: fmt.Sprintf("final reality '%s' in universe '%s' is not reached within %d events", realityID, universeID, e.cfg.maxDepth),
+			})
+		}
+	}
+}
+
+// configurationKey identifies a configuration by the position of each universe.
+func configurationKey(snapshot *instrumentation.MachineSnapshot) string {
+	var sb strings.Builder
+	for _, universeID := range util.SortedKeys(snapshot.Snapshots) {
+		u := snapshot.Snapshots[universeID]
+		var currentReality string
+		if u.CurrentReality != nil {
+			currentReality = *u.CurrentReality
+		}
+		_, _ = fmt.Fprintf(&sb, "%s|%t|%s|%t|%t;", universeID, u.Initialized, currentReality, u.RealityInitialized, u.InSuperposition)
+	}
+	return sb.String()
+}
+
+// isFinished returns true when no universe is active and at least one universe has finalized.
+func isFinished(snapshot *instrumentation.MachineSnapshot) bool {
+	resume := snapshot.Resume
+	return len(resume.ActiveUniverses) == 0 && len(resume.FinalizedUniverses)+len(resume.SuperpositionUniversesFinalized) > 0
+}
+
+func describeConfiguration(snapshot *instrumentation.MachineSnapshot) string {
+	var parts []string
+	for _, name := range util.SortedKeys(snapshot.Resume.ActiveUniverses) {
+		parts = append(parts, fmt.Sprintf("%s:%s", name, snapshot.Resume.ActiveUniverses[name]))
+	}
+	for _, name := range util.SortedKeys(snapshot.Resume.FinalizedUniverses) {
+		parts = append(parts, fmt.Sprintf("%s:%s (final)", name, snapshot.Resume.FinalizedUniverses[name]))
+	}
+	for _, name := range util.SortedKeys(snapshot.Resume.SuperpositionUniverses) {
+		parts = append(parts, fmt.Sprintf("%s (superposition)", name))
+	}
+	for _, name := range util.SortedKeys(snapshot.Resume.SuperpositionUniversesFinalized) {
+		parts = append(parts, fmt.Sprintf("%s:%s (final, superposition)", name, snapshot.Resume.SuperpositionUniversesFinalized[name]))
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
+
+// declaredEvents returns the names of every event declared in an On handler, sorted.
+func declaredEvents(model *theoretical.QuantumMachineModel) []string {
+	set := map[string]bool{}
+	for _, universe := range model.Universes {
+		if universe == nil {
+			continue
+		}
+		for _, reality := range universe.Realities {
+			if reality == nil {
+				continue
+			}
+			for eventName := range reality.On {
+				set[eventName] = true
+			}
+		}
+	}
+	return util.SortedKeys(set)
+}
+
+func appendStep(parent *state, step Step) []Step {
+	var trace []Step
+	if parent != nil {
+		trace = make([]Step, 0, len(parent.trace)+1)
+		trace = append(trace, parent.trace...)
+	}
+	return append(trace, step)
+}
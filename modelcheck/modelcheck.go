@@ -0,0 +1,175 @@
+// Package modelcheck explores the state space of a quantum machine definition.
+//
+// The definition runs on the experimental runtime with stub executors: every condition and observer is tried
+// both as true and false, actions do nothing (unless configured to emit events, see WithActionEmits) and
+// invokes are dropped. Starting from every outcome of Init, each declared On event is sent from every reachable
+// configuration up to a maximum depth. The checker reports deadlocks, cyclic always transitions, external target
+// and emit depth violations, cycles that can no longer reach a final configuration and final realities that are
+// never reached, each with a counterexample trace.
+package modelcheck
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	defaultMaxDepth    = 8
+	defaultMaxStates   = 5000
+	defaultMaxBranches = 1024
+)
+
+// FindingKind identifies the kind of problem found.
+type FindingKind string
+
+const (
+	// FindingDeadlock is a configuration that is not finished and that no event can change.
+	FindingDeadlock FindingKind = "deadlock"
+
+	// FindingCyclicTransition is a cascade that visits the same reality twice (experimental.ErrCyclicTransition).
+	FindingCyclicTransition FindingKind = "cyclic_transition"
+
+	// FindingCascadeDepth is a cross-universe cascade that exceeds the runtime limit (experimental.ErrExternalTargetDepth).
+	FindingCascadeDepth FindingKind = "cascade_depth"
+
+	// FindingEmitDepth is a chain of emitted events that exceeds the runtime limit (experimental.ErrEmitDepth).
+	FindingEmitDepth FindingKind = "emit_depth"
+
+	// FindingRuntimeError is any other error returned by the runtime.
+	FindingRuntimeError FindingKind = "runtime_error"
+
+	// FindingCycle is a configuration from which no finished configuration can be reached anymore,
+	// while events keep moving the machine between configurations.
+	FindingCycle FindingKind = "cycle"
+
+	// FindingUnreachableFinal is a final reality that is never established within the explored space.
+	FindingUnreachableFinal FindingKind = "unreachable_final"
+)
+
+// Decision is the outcome chosen for a stub condition or observer.
+type Decision struct {
+	// Kind is "condition" or "observer".
+	Kind string `json:"kind"`
+
+	// Src is the src of the executor in the original definition.
+	Src string `json:"src"`
+
+	UniverseID string `json:"universeId"`
+	RealityID  string `json:"realityId"`
+
+	// Value is the outcome returned by the stub.
+	Value bool `json:"value"`
+}
+
+func (d Decision) String() string {
+	return fmt.Sprintf("%s '%s' in %s:%s = %t", d.Kind, d.Src, d.UniverseID, d.RealityID, d.Value)
+}
+
+// Step is one operation of a counterexample trace.
+type Step struct {
+	// Event is the name of the event sent. It is empty for the Init step, which always starts a trace.
+	Event string `json:"event,omitempty"`
+
+	// Decisions are the stub outcomes chosen while processing the step, in call order.
+	Decisions []Decision `json:"decisions,omitempty"`
+}
+
+func (s Step) String() string {
+	operation := "init"
+	if s.Event != "" {
+		operation = fmt.Sprintf("send '%s'", s.Event)
+	}
+	if len(s.Decisions) == 0 {
+		return operation
+	}
+
+	decisions := make([]string, 0, len(s.Decisions))
+	for _, decision := range s.Decisions {
+		decisions = append(decisions, decision.String())
+	}
+	return fmt.Sprintf("%s [%s]", operation, strings.Join(decisions, ", "))
+}
+
+// Finding is a problem found while exploring the state space.
+type Finding struct {
+	Kind    FindingKind `json:"kind"`
+	Message string      `json:"message"`
+
+	// Trace is the sequence of steps that leads to the problem. It is empty for FindingUnreachableFinal.
+	Trace []Step `json:"trace,omitempty"`
+}
+
+func (f Finding) String() string {
+	if len(f.Trace) == 0 {
+		return fmt.Sprintf("%s: %s", f.Kind, f.Message)
+	}
+
+	steps := make([]string, 0, len(f.Trace))
+	for _, step := range f.Trace {
+		steps = append(steps, step.String())
+	}
+	return fmt.Sprintf("%s: %s (trace: %s)", f.Kind, f.Message, strings.Join(steps, " -> "))
+}
+
+// Report is the result of Check.
+type Report struct {
+	Findings []Finding `json:"findings"`
+
+	// States is the number of distinct configurations explored.
+	States int `json:"states"`
+
+	// Truncated is true when a bound (states or branches) stopped the exploration early.
+	// Reaching the maximum depth does not truncate the report.
+	Truncated bool `json:"truncated"`
+}
+
+// Option configures Check.
+type Option func(*config)
+
+type config struct {
+	maxDepth    int
+	maxStates   int
+	maxBranches int
+	events      []string
+	emits       map[string][]string
+}
+
+// WithMaxDepth sets the maximum number of events in a trace (default 8).
+func WithMaxDepth(depth int) Option {
+	return func(c *config) {
+		c.maxDepth = depth
+	}
+}
+
+// WithMaxStates sets the maximum number of distinct configurations explored (default 5000).
+func WithMaxStates(states int) Option {
+	return func(c *config) {
+		c.maxStates = states
+	}
+}
+
+// WithMaxBranches sets the maximum number of stub outcome combinations tried for a single Init or event (default 1024).
+func WithMaxBranches(branches int) Option {
+	return func(c *config) {
+		c.maxBranches = branches
+	}
+}
+
+// WithEvents replaces the events sent from every configuration.
+// By default, every event declared in an On handler of the definition is sent.
+func WithEvents(events ...string) Option {
+	return func(c *config) {
+		c.events = events
+	}
+}
+
+// WithActionEmits makes the stub of the action src emit the given events when it runs as an entry action,
+// as the real action would with ActionExecutorArgs.EmitEvent. Use it to explore emit chains.
+func WithActionEmits(src string, events ...string) Option {
+	return func(c *config) {
+		if c.emits == nil {
+			c.emits = map[string][]string{}
+		}
+		c.emits[src] = append(c.emits[src], events...)
+	}
+}
@@ -0,0 +1,252 @@
+package modelcheck
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/rendis/statepro/v3/builtin"
+	"github.com/rendis/statepro/v3/theoretical"
+)
+
+func checkFromJSON(t *testing.T, payload string, opts ...Option) *Report {
+	t.Helper()
+
+	model := &theoretical.QuantumMachineModel{}
+	if err := json.Unmarshal([]byte(payload), model); err != nil {
+		t.Fatalf("unexpected unmarshal error: %v", err)
+	}
+
+	report, err := Check(context.Background(), model, opts...)
+	if err != nil {
+		t.Fatalf("unexpected check error: %v", err)
+	}
+	return report
+}
+
+func findingsOfKind(report *Report, kind FindingKind) []Finding {
+	var findings []Finding
+	for _, finding := range report.Findings {
+		if finding.Kind == kind {
+			findings = append(findings, finding)
+		}
+	}
+	return findings
+}
+
+func traceEvents(trace []Step) []string {
+	events := make([]string, 0, len(trace))
+	for _, step := range trace {
+		events = append(events, step.Event)
+	}
+	return events
+}
+
+func TestCheck_CleanModel(t *testing.T) {
+	report := checkFromJSON(t, `{
+		"id":"machine","canonicalName":"machine","version":"1.0.0",
+		"initials":["U:main"],
+		"universes":{
+			"main":{
+				"id":"main","canonicalName":"main","version":"1.0.0","initial":"A",
+				"realities":{
+					"A":{"id":"A","type":"transition","on":{"go":[{"targets":["END"],"condition":{"src":"test:condition:ready"}}]}},
+					"END":{"id":"END","type":"final"}
+				}
+			}
+		}
+	}`)
+
+	if len(report.Findings) != 0 {
+		t.Fatalf("expected no findings, got %v", report.Findings)
+	}
+	if report.States != 2 || report.Truncated {
+		t.Fatalf("expected 2 explored states without truncation, got %d (truncated: %t)", report.States, report.Truncated)
+	}
+
+	// the stubs are scoped to the explorer's machines
+	for kind, src := range map[builtin.ExecutorKind]string{
+		builtin.ExecutorKindCondition: oracleConditionSrc,
+		builtin.ExecutorKindObserver:  oracleObserverSrc,
+		builtin.ExecutorKindAction:    stubActionSrc,
+	} {
+		if builtin.IsRegistered(kind, src) {
+			t.Fatalf("expected '%s' not to be registered", src)
+		}
+	}
+}
+
+func TestCheck_NotifyLoop(t *testing.T) {
+	report := checkFromJSON(t, `{
+		"id":"machine","canonicalName":"machine","version":"1.0.0",
+		"initials":["U:ping","U:pong"],
+		"universes":{
+			"ping":{
+				"id":"ping","canonicalName":"ping","version":"1.0.0","initial":"P",
+				"realities":{"P":{"id":"P","type":"transition","on":{"ball":[{"targets":["U:pong"],"type":"notify"}],"stop":[{"targets":["END"]}]}},"END":{"id":"END","type":"final"}}
+			},
+			"pong":{
+				"id":"pong","canonicalName":"pong","version":"1.0.0","initial":"P",
+				"realities":{"P":{"id":"P","type":"transition","on":{"ball":[{"targets":["U:ping"],"type":"notify"}],"stop":[{"targets":["END"]}]}},"END":{"id":"END","type":"final"}}
+			}
+		}
+	}`)
+
+	findings := findingsOfKind(report, FindingCascadeDepth)
+	if len(findings) == 0 {
+		t.Fatalf("expected a cascade depth finding, got %v", report.Findings)
+	}
+	if got := traceEvents(findings[0].Trace); strings.Join(got, ",") != ",ball" {
+		t.Fatalf("expected trace [init, ball], got %q", got)
+	}
+}
+
+func TestCheck_ConditionalAlwaysCycle(t *testing.T) {
+	report := checkFromJSON(t, `{
+		"id":"machine","canonicalName":"machine","version":"1.0.0",
+		"initials":["U:main"],
+		"universes":{
+			"main":{
+				"id":"main","canonicalName":"main","version":"1.0.0","initial":"A",
+				"realities":{
+					"A":{"id":"A","type":"transition","on":{"go":[{"targets":["B"]}]}},
+					"B":{"id":"B","type":"transition","always":[{"targets":["C"],"condition":{"src":"test:condition:retry"}},{"targets":["END"]}]},
+					"C":{"id":"C","type":"transition","always":[{"targets":["B"]}]},
+					"END":{"id":"END","type":"final"}
+				}
+			}
+		}
+	}`)
+
+	findings := findingsOfKind(report, FindingCyclicTransition)
+	if len(findings) != 1 {
+		t.Fatalf("expected one cyclic transition finding, got %v", report.Findings)
+	}
+
+	trace := findings[0].Trace
+	last := trace[len(trace)-1]
+	if last.Event != "go" || len(last.Decisions) == 0 || !last.Decisions[0].Value || last.Decisions[0].Src != "test:condition:retry" {
+		t.Fatalf("expected trace to end with 'go' and the retry condition approved, got %v", trace)
+	}
+}
+
+func TestCheck_EmitLoop(t *testing.T) {
+	payload := `{
+		"id":"machine","canonicalName":"machine","version":"1.0.0",
+		"initials":["U:main"],
+		"universes":{
+			"main":{
+				"id":"main","canonicalName":"main","version":"1.0.0","initial":"IDLE",
+				"realities":{
+					"IDLE":{"id":"IDLE","type":"transition","on":{"start":[{"targets":["PING"]}],"stop":[{"targets":["END"]}]}},
+					"PING":{"id":"PING","type":"transition","entryActions":[{"src":"test:action:ping"}],"on":{"pong":[{"targets":["PONG"]}]}},
+					"PONG":{"id":"PONG","type":"transition","entryActions":[{"src":"test:action:pong"}],"on":{"ping":[{"targets":["PING"]}]}},
+					"END":{"id":"END","type":"final"}
+				}
+			}
+		}
+	}`
+
+	if findings := findingsOfKind(checkFromJSON(t, payload), FindingEmitDepth); len(findings) != 0 {
+		t.Fatalf("stub actions must not emit by default, got %v", findings)
+	}
+
+	report := checkFromJSON(t, payload,
+		WithActionEmits("test:action:ping", "pong"),
+		WithActionEmits("test:action:pong", "ping"),
+	)
+	findings := findingsOfKind(report, FindingEmitDepth)
+	if len(findings) != 1 {
+		t.Fatalf("expected one emit depth finding, got %v", report.Findings)
+	}
+	if got := traceEvents(findings[0].Trace); strings.Join(got, ",") != ",start" {
+		t.Fatalf("expected trace [init, start], got %q", got)
+	}
+}
+
+func TestCheck_DeadlockCycleAndUnreachableFinal(t *testing.T) {
+	report := checkFromJSON(t, `{
+		"id":"machine","canonicalName":"machine","version":"1.0.0",
+		"initials":["U:main"],
+		"universes":{
+			"main":{
+				"id":"main","canonicalName":"main","version":"1.0.0","initial":"A",
+				"realities":{
+					"A":{"id":"A","type":"transition","on":{"stuck":[{"targets":["STUCK"]}],"loop":[{"targets":["B"]}],"done":[{"targets":["END"]}]}},
+					"B":{"id":"B","type":"transition","on":{"loop":[{"targets":["C"]}]}},
+					"C":{"id":"C","type":"transition","on":{"loop":[{"targets":["B"]}]}},
+					"STUCK":{"id":"STUCK","type":"transition","on":{}},
+					"END":{"id":"END","type":"final"},
+					"NEVER":{"id":"NEVER","type":"unsuccessfulFinal"}
+				}
+			}
+		}
+	}`)
+
+	deadlocks := findingsOfKind(report, FindingDeadlock)
+	if len(deadlocks) != 1 || strings.Join(traceEvents(deadlocks[0].Trace), ",") != ",stuck" {
+		t.Fatalf("expected one deadlock after 'stuck', got %v", deadlocks)
+	}
+
+	cycles := findingsOfKind(report, FindingCycle)
+	if len(cycles) != 1 || strings.Join(traceEvents(cycles[0].Trace), ",") != ",loop" {
+		t.Fatalf("expected one cycle entered with 'loop', got %v", cycles)
+	}
+
+	unreachable := findingsOfKind(report, FindingUnreachableFinal)
+	if len(unreachable) != 1 || !strings.Contains(unreachable[0].Message, "'NEVER'") {
+		t.Fatalf("expected NEVER to be unreachable, got %v", unreachable)
+	}
+}
+
+func TestCheck_Bounds(t *testing.T) {
+	payload := `{
+		"id":"machine","canonicalName":"machine","version":"1.0.0",
+		"initials":["U:main"],
+		"universes":{
+			"main":{
+				"id":"main","canonicalName":"main","version":"1.0.0","initial":"A",
+				"realities":{
+					"A":{"id":"A","type":"transition","on":{"next":[{"targets":["B"]}]}},
+					"B":{"id":"B","type":"transition","on":{"next":[{"targets":["C"]}]}},
+					"C":{"id":"C","type":"transition","on":{"next":[{"targets":["END"]}]}},
+					"END":{"id":"END","type":"final"}
+				}
+			}
+		}
+	}`
+
+	report := checkFromJSON(t, payload, WithMaxDepth(1))
+	if report.States != 2 || report.Truncated {
+		t.Fatalf("expected 2 states within depth 1, got %d (truncated: %t)", report.States, report.Truncated)
+	}
+	if len(findingsOfKind(report, FindingDeadlock)) != 0 || len(findingsOfKind(report, FindingCycle)) != 0 {
+		t.Fatalf("depth bound must not produce deadlocks or cycles, got %v", report.Findings)
+	}
+	if len(findingsOfKind(report, FindingUnreachableFinal)) != 1 {
+		t.Fatalf("expected END not to be reached within depth 1, got %v", report.Findings)
+	}
+
+	if report = checkFromJSON(t, payload, WithMaxStates(2)); !report.Truncated {
+		t.Fatal("expected truncated report when the state bound is reached")
+	}
+}
+
+func TestNextPrefix(t *testing.T) {
+	decisions := []Decision{{Value: false}, {Value: true}, {Value: true}}
+	prefix, ok := nextPrefix(decisions)
+	if !ok || len(prefix) != 1 || !prefix[0] {
+		t.Fatalf("unexpected prefix %v", prefix)
+	}
+
+	if _, ok = nextPrefix([]Decision{{Value: true}, {Value: true}}); ok {
+		t.Fatal("expected all combinations to be exhausted")
+	}
+}
+
+func TestCheck_NilModel(t *testing.T) {
+	if _, err := Check(context.Background(), nil); err == nil {
+		t.Fatal("expected error for nil model")
+	}
+}
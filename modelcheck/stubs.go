@@ -0,0 +1,196 @@
+package modelcheck
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/rendis/statepro/v3/experimental"
+	"github.com/rendis/statepro/v3/instrumentation"
+	"github.com/rendis/statepro/v3/theoretical"
+)
+
+const (
+	oracleConditionSrc = "modelcheck:condition:oracle"
+	oracleObserverSrc  = "modelcheck:observer:oracle"
+	stubActionSrc      = "modelcheck:action:stub"
+
+	// originalSrcArg is the stub arg holding the src of the replaced executor.
+	originalSrcArg = "src"
+)
+
+// stubExecutors resolves the stubs in the machines of the explorer only, so they never reach the builtin registry.
+var stubExecutors = experimental.Executors{
+	Observers:  map[string]instrumentation.ObserverFn{oracleObserverSrc: oracleObserver},
+	Actions:    map[string]instrumentation.ActionFn{stubActionSrc: stubAction},
+	Conditions: map[string]instrumentation.ConditionFn{oracleConditionSrc: oracleCondition},
+}
+
+type chooserKey struct{}
+
+// chooser replays a prefix of decisions and records every decision taken during a run.
+// Decisions past the prefix default to false.
+type chooser struct {
+	prefix    []bool
+	decisions []Decision
+	emits     map[string][]string
+}
+
+func chooserFrom(ctx context.Context) *chooser {
+	c, _ := ctx.Value(chooserKey{}).(*chooser)
+	return c
+}
+
+func (c *chooser) choose(decision Decision) bool {
+	if i := len(c.decisions); i < len(c.prefix) {
+		decision.Value = c.prefix[i]
+	}
+	c.decisions = append(c.decisions, decision)
+	return decision.Value
+}
+
+// nextPrefix returns the prefix of the next unexplored combination of decisions, or false when
+// every combination has been tried (depth-first, false before true).
+func nextPrefix(decisions []Decision) ([]bool, bool) {
+	for i := len(decisions) - 1; i >= 0; i-- {
+		if decisions[i].Value {
+			continue
+		}
+		prefix := make([]bool, i+1)
+		for j := 0; j < i; j++ {
+			prefix[j] = decisions[j].Value
+		}
+		prefix[i] = true
+		return prefix, true
+	}
+	return nil, false
+}
+
+func oracleCondition(ctx context.Context, args instrumentation.ConditionExecutorArgs) (bool, error) {
+	c := chooserFrom(ctx)
+	if c == nil {
+		return false, nil
+	}
+	src, _ := args.GetCondition().Args[originalSrcArg].(string)
+	return c.choose(Decision{
+		Kind:       "condition",
+		Src:        src,
+		UniverseID: args.GetUniverseId(),
+		RealityID:  args.GetRealityName(),
+	}), nil
+}
+
+func oracleObserver(ctx context.Context, args instrumentation.ObserverExecutorArgs) (bool, error) {
+	c := chooserFrom(ctx)
+	if c == nil {
+		return false, nil
+	}
+	src, _ := args.GetObserver().Args[originalSrcArg].(string)
+	return c.choose(Decision{
+		Kind:       "observer",
+		Src:        src,
+		UniverseID: args.GetUniverseId(),
+		RealityID:  args.GetRealityName(),
+	}), nil
+}
+
+func stubAction(ctx context.Context, args instrumentation.ActionExecutorArgs) error {
+	c := chooserFrom(ctx)
+	if c == nil || args.GetActionType() != instrumentation.ActionTypeEntry {
+		return nil
+	}
+	src, _ := args.GetAction().Args[originalSrcArg].(string)
+	for _, event := range c.emits[src] {
+		args.EmitEvent(event, nil)
+	}
+	return nil
+}
+
+// stubModel returns a deep copy of the model where conditions, observers and actions are replaced by stubs
+// and invokes are removed. Executors with an empty src are kept as they are (the runtime approves them).
+func stubModel(model *theoretical.QuantumMachineModel) (*theoretical.QuantumMachineModel, error) {
+	b, err := json.Marshal(model)
+	if err != nil {
+		return nil, err
+	}
+
+	stubbed := &theoretical.QuantumMachineModel{}
+	if err = json.Unmarshal(b, stubbed); err != nil {
+		return nil, err
+	}
+
+	stubConstants(stubbed.UniversalConstants)
+	for _, universe := range stubbed.Universes {
+		if universe == nil {
+			continue
+		}
+		stubConstants(universe.UniversalConstants)
+
+		for _, reality := range universe.Realities {
+			if reality == nil {
+				continue
+			}
+			for _, observer := range reality.Observers {
+				if observer != nil && observer.Src != "" {
+					observer.Args = map[string]any{originalSrcArg: observer.Src}
+					observer.Src = oracleObserverSrc
+				}
+			}
+			stubActions(reality.EntryActions)
+			stubActions(reality.ExitActions)
+			reality.EntryInvokes = nil
+			reality.ExitInvokes = nil
+
+			for _, transition := range reality.Always {
+				stubTransition(transition)
+			}
+			for _, transitions := range reality.On {
+				for _, transition := range transitions {
+					stubTransition(transition)
+				}
+			}
+		}
+	}
+
+	return stubbed, nil
+}
+
+func stubConstants(constants *theoretical.UniversalConstantsModel) {
+	if constants == nil {
+		return
+	}
+	stubActions(constants.EntryActions)
+	stubActions(constants.ExitActions)
+	stubActions(constants.ActionsOnTransition)
+	constants.EntryInvokes = nil
+	constants.ExitInvokes = nil
+	constants.InvokesOnTransition = nil
+}
+
+func stubTransition(transition *theoretical.TransitionModel) {
+	if transition == nil {
+		return
+	}
+	stubCondition(transition.Condition)
+	for _, condition := range transition.Conditions {
+		stubCondition(condition)
+	}
+	stubActions(transition.Actions)
+	transition.Invokes = nil
+}
+
+func stubCondition(condition *theoretical.ConditionModel) {
+	if condition == nil || condition.Src == "" {
+		return
+	}
+	condition.Args = map[string]any{originalSrcArg: condition.Src}
+	condition.Src = oracleConditionSrc
+}
+
+func stubActions(actions []*theoretical.ActionModel) {
+	for _, action := range actions {
+		if action != nil && action.Src != "" {
+			action.Args = map[string]any{originalSrcArg: action.Src}
+			action.Src = stubActionSrc
+		}
+	}
+}
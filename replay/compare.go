@@ -0,0 +1,167 @@
+package replay
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rendis/statepro/v3/instrumentation"
+	"github.com/rendis/statepro/v3/internal/util"
+)
+
+// compareValues appends the differences between two JSON values at path, in path order.
+func compareValues(path string, recorded, produced any, ignored []string, differences *[]Difference) {
+	if isIgnored(path, ignored) {
+		return
+	}
+
+	switch r := recorded.(type) {
+	case map[string]any:
+		if p, ok := produced.(map[string]any); ok {
+			compareObjects(path, r, p, ignored, differences)
+			return
+		}
+	case []any:
+		if p, ok := produced.([]any); ok {
+			compareArrays(path, r, p, ignored, differences)
+			return
+		}
+	}
+
+	if !reflect.DeepEqual(recorded, produced) {
+		*differences = append(*differences, Difference{Kind: DifferenceChanged, Path: path, Recorded: recorded, Produced: produced})
+	}
+}
+
+func compareObjects(path string, recorded, produced map[string]any, ignored []string, differences *[]Difference) {
+	keys := make([]string, 0, len(recorded)+len(produced))
+	for k := range recorded {
+		keys = append(keys, k)
+	}
+	for k := range produced {
+		if _, ok := recorded[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		childPath := path + util.JSONPointer(k)
+		r, inRecorded := recorded[k]
+		p, inProduced := produced[k]
+		switch {
+		case !inProduced:
+			appendUnless(childPath, Difference{Kind: DifferenceMissing, Path: childPath, Recorded: r}, ignored, differences)
+		case !inRecorded:
+			appendUnless(childPath, Difference{Kind: DifferenceUnexpected, Path: childPath, Produced: p}, ignored, differences)
+		default:
+			compareValues(childPath, r, p, ignored, differences)
+		}
+	}
+}
+
+func compareArrays(path string, recorded, produced []any, ignored []string, differences *[]Difference) {
+	for i := 0; i < len(recorded) || i < len(produced); i++ {
+		childPath := path + util.JSONPointer(strconv.Itoa(i))
+		switch {
+		case i >= len(produced):
+			appendUnless(childPath, Difference{Kind: DifferenceMissing, Path: childPath, Recorded: recorded[i]}, ignored, differences)
+		case i >= len(recorded):
+			appendUnless(childPath, Difference{Kind: DifferenceUnexpected, Path: childPath, Produced: produced[i]}, ignored, differences)
+		default:
+			compareValues(childPath, recorded[i], produced[i], ignored, differences)
+		}
+	}
+}
+
+func appendUnless(path string, difference Difference, ignored []string, differences *[]Difference) {
+	if !isIgnored(path, ignored) {
+		*differences = append(*differences, difference)
+	}
+}
+
+func isIgnored(path string, ignored []string) bool {
+	for _, prefix := range ignored {
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// diffSnapshots describes the changes from the recorded to the produced snapshot, leaving out the values under the
+// ignored JSON Pointers: both snapshots go through their JSON form, where the produced values under the ignored
+// paths are replaced by the recorded ones.
+func diffSnapshots(recorded, produced *instrumentation.MachineSnapshot, ignored []string) (*instrumentation.SnapshotDiff, error) {
+	recordedValue, err := jsonValue(recorded)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding recorded snapshot: %w", err)
+	}
+	producedValue, err := jsonValue(produced)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding produced snapshot: %w", err)
+	}
+	for _, path := range ignored {
+		tokens, err := util.JSONPointerTokens(path)
+		if err != nil {
+			return nil, fmt.Errorf("ignored path: %w", err)
+		}
+		producedValue = maskValue(recordedValue, producedValue, tokens)
+	}
+
+	recordedSnapshot, err := snapshotFromValue(recordedValue)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding recorded snapshot: %w", err)
+	}
+	producedSnapshot, err := snapshotFromValue(producedValue)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding produced snapshot: %w", err)
+	}
+	return instrumentation.DiffSnapshots(recordedSnapshot, producedSnapshot), nil
+}
+
+// maskValue returns produced with the value at tokens replaced by the recorded one, or removed from its object
+// when the recorded value has none.
+func maskValue(recorded, produced any, tokens []string) any {
+	if len(tokens) == 0 {
+		return recorded
+	}
+	switch p := produced.(type) {
+	case map[string]any:
+		child, ok := p[tokens[0]]
+		if !ok {
+			return produced
+		}
+		r, _ := recorded.(map[string]any)
+		recordedChild, inRecorded := r[tokens[0]]
+		if !inRecorded && len(tokens) == 1 {
+			delete(p, tokens[0])
+			return produced
+		}
+		p[tokens[0]] = maskValue(recordedChild, child, tokens[1:])
+	case []any:
+		r, _ := recorded.([]any)
+		i, err := strconv.Atoi(tokens[0])
+		if err != nil || i < 0 || i >= len(p) || i >= len(r) {
+			return produced
+		}
+		p[i] = maskValue(r[i], p[i], tokens[1:])
+	}
+	return produced
+}
+
+func snapshotFromValue(value any) (*instrumentation.MachineSnapshot, error) {
+	if value == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := &instrumentation.MachineSnapshot{}
+	err = json.Unmarshal(b, snapshot)
+	return snapshot, err
+}
@@ -0,0 +1,273 @@
+// Package replay verifies that a recorded run of a quantum machine is reproduced by a model.
+//
+// A recording is a sequence of events, each with the snapshot the machine produced after it (the history of a
+// debugger bot, for example). Verify replays the events on a new machine built from the model, with the
+// executors currently registered in the builtin registry (or the machine's own, see WithMachineOptions), and
+// compares every produced snapshot with the recorded one. It reports the first step that diverges with the
+// differences between both snapshots, which catches nondeterministic actions and behavior changes after
+// upgrading statepro or changing executors.
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/rendis/statepro/v3"
+	"github.com/rendis/statepro/v3/debugger/bot"
+	"github.com/rendis/statepro/v3/experimental"
+	"github.com/rendis/statepro/v3/instrumentation"
+	"github.com/rendis/statepro/v3/theoretical"
+)
+
+// Step is a recorded event and the snapshot the machine produced after handling it.
+type Step struct {
+	Event    instrumentation.Event
+	Snapshot *instrumentation.MachineSnapshot
+}
+
+// FromBotHistory converts the history of a debugger bot into steps.
+func FromBotHistory(history []*bot.EventHistory) []Step {
+	steps := make([]Step, 0, len(history))
+	for _, h := range history {
+		steps = append(steps, Step{Event: h.Event, Snapshot: h.Snapshot})
+	}
+	return steps
+}
+
+// DifferenceKind classifies a Difference.
+type DifferenceKind string
+
+const (
+	// DifferenceChanged is a value present in both snapshots with different contents.
+	DifferenceChanged DifferenceKind = "changed"
+
+	// DifferenceMissing is a value of the recorded snapshot that the produced one does not have.
+	DifferenceMissing DifferenceKind = "missing"
+
+	// DifferenceUnexpected is a value of the produced snapshot that the recorded one does not have.
+	DifferenceUnexpected DifferenceKind = "unexpected"
+)
+
+// Difference is a value that differs between the recorded and the produced snapshot.
+// Values are compared in their JSON form (numbers are float64).
+type Difference struct {
+	Kind DifferenceKind
+
+	// Path is the JSON Pointer of the value in the snapshot JSON form.
+	Path string
+
+	// Recorded is nil for DifferenceUnexpected.
+	Recorded any
+
+	// Produced is nil for DifferenceMissing.
+	Produced any
+}
+
+func (d Difference) String() string {
+	switch d.Kind {
+	case DifferenceMissing:
+		return fmt.Sprintf("%s: recorded %s, missing from the replay", d.Path, formatValue(d.Recorded))
+	case DifferenceUnexpected:
+		return fmt.Sprintf("%s: not recorded, replay produced %s", d.Path, formatValue(d.Produced))
+	default:
+		return fmt.Sprintf("%s: recorded %s, replay produced %s", d.Path, formatValue(d.Recorded), formatValue(d.Produced))
+	}
+}
+
+// Divergence is the first step whose outcome differs from the recording.
+type Divergence struct {
+	// Step is the index of the step in the recording.
+	Step  int
+	Event instrumentation.Event
+
+	// Err is the error returned by the machine when replaying the event, if any.
+	Err error
+
+	// Differences lists the values that differ, object keys in sorted order. Empty when Err is set.
+	Differences []Difference
+
+	// Changes describes the differences as the changes from the recorded to the produced snapshot
+	// (realities, superposition, metadata, accumulator, tracking), leaving out the ignored paths as Differences
+	// does. Nil when Err is set.
+	Changes *instrumentation.SnapshotDiff
+}
+
+func (d *Divergence) String() string {
+	if d.Err != nil {
+		return fmt.Sprintf("step %d (event '%s'): replay failed: %v", d.Step, d.Event.GetEventName(), d.Err)
+	}
+	lines := make([]string, 0, len(d.Differences)+1)
+	lines = append(lines, fmt.Sprintf("step %d (event '%s'): %d difference(s)", d.Step, d.Event.GetEventName(), len(d.Differences)))
+	for _, diff := range d.Differences {
+		lines = append(lines, "  "+diff.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Report is the result of a verification.
+type Report struct {
+	// Verified is the number of steps reproduced before the divergence (all of them when there is none).
+	Verified int
+
+	// Divergence is nil when every step was reproduced.
+	Divergence *Divergence
+}
+
+// OK reports whether every step was reproduced.
+func (r *Report) OK() bool {
+	return r.Divergence == nil
+}
+
+// Option configures Verify.
+type Option func(*options)
+
+type options struct {
+	initial        *instrumentation.MachineSnapshot
+	init           bool
+	machineContext any
+	machineOpts    []experimental.MachineOption
+	ignored        []string
+}
+
+// WithInitialSnapshot loads the snapshot the recording started from before replaying.
+func WithInitialSnapshot(snapshot *instrumentation.MachineSnapshot) Option {
+	return func(o *options) {
+		o.initial = snapshot
+	}
+}
+
+// WithInit initializes the machine (after loading the initial snapshot, if any) before replaying,
+// as a debugger bot created with initQuantumMachine does.
+func WithInit() Option {
+	return func(o *options) {
+		o.init = true
+	}
+}
+
+// WithMachineContext sets the machine context passed to the machine.
+func WithMachineContext(machineContext any) Option {
+	return func(o *options) {
+		o.machineContext = machineContext
+	}
+}
+
+// WithMachineOptions sets the options used to build the machine. With experimental.WithExecutors the machine
+// resolves its own executors before the builtin registry, e.g. to replay against fakes without registering them.
+func WithMachineOptions(opts ...experimental.MachineOption) Option {
+	return func(o *options) {
+		o.machineOpts = opts
+	}
+}
+
+// WithIgnoredPaths excludes values under the given JSON Pointers from the comparison
+// (e.g. "/snapshots/main/metadata/updatedAt" for a value that legitimately changes on every run).
+func WithIgnoredPaths(paths ...string) Option {
+	return func(o *options) {
+		o.ignored = append(slices.Clip(o.ignored), paths...)
+	}
+}
+
+// fingerprintPaths identify the definition rather than the behavior, so Verify does not compare them.
+var fingerprintPaths = []string{"/fingerprint", "/universeFingerprints"}
+
+// Verify replays the steps on a new machine built from the model and reports the first step whose produced
+// snapshot differs from the recorded one. Model fingerprints are not compared, so a recording can be verified
+// against a newer definition. The error is reserved for problems preparing the replay; a failing event is
+// reported as a divergence.
+func Verify(ctx context.Context, model *theoretical.QuantumMachineModel, steps []Step, opts ...Option) (*Report, error) {
+	if model == nil {
+		return nil, fmt.Errorf("model cannot be nil")
+	}
+	o := &options{ignored: fingerprintPaths}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	qm, err := statepro.NewQuantumMachine(model, o.machineOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("error building quantum machine: %w", err)
+	}
+	if o.initial != nil {
+		if err = qm.LoadSnapshot(o.initial, o.machineContext); err != nil {
+			return nil, fmt.Errorf("error loading initial snapshot: %w", err)
+		}
+	}
+	if o.init {
+		if err = qm.Init(ctx, o.machineContext); err != nil {
+			return nil, fmt.Errorf("error initializing quantum machine: %w", err)
+		}
+	}
+
+	report := &Report{}
+	for i, step := range steps {
+		if step.Event == nil || step.Snapshot == nil {
+			return nil, fmt.Errorf("step %d: event and snapshot are required", i)
+		}
+		if _, err = qm.SendEvent(ctx, step.Event); err != nil {
+			report.Divergence = &Divergence{Step: i, Event: step.Event, Err: err}
+			return report, nil
+		}
+
+		produced := qm.GetSnapshot()
+		differences, err := Compare(step.Snapshot, produced, o.ignored...)
+		if err != nil {
+			return nil, fmt.Errorf("step %d: %w", i, err)
+		}
+		if len(differences) > 0 {
+			changes, err := diffSnapshots(step.Snapshot, produced, o.ignored)
+			if err != nil {
+				return nil, fmt.Errorf("step %d: %w", i, err)
+			}
+			report.Divergence = &Divergence{
+				Step:        i,
+				Event:       step.Event,
+				Differences: differences,
+				Changes:     changes,
+			}
+			return report, nil
+		}
+		report.Verified++
+	}
+	return report, nil
+}
+
+// Compare returns the differences between the JSON forms of a recorded and a produced snapshot (object keys in
+// sorted order, array elements by index), skipping the values under the ignored JSON Pointers.
+func Compare(recorded, produced *instrumentation.MachineSnapshot, ignored ...string) ([]Difference, error) {
+	recordedValue, err := jsonValue(recorded)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding recorded snapshot: %w", err)
+	}
+	producedValue, err := jsonValue(produced)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding produced snapshot: %w", err)
+	}
+
+	var differences []Difference
+	compareValues("", recordedValue, producedValue, ignored, &differences)
+	return differences, nil
+}
+
+func jsonValue(snapshot *instrumentation.MachineSnapshot) (any, error) {
+	if snapshot == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil, err
+	}
+	var value any
+	err = json.Unmarshal(b, &value)
+	return value, err
+}
+
+func formatValue(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(b)
+}
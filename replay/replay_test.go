@@ -0,0 +1,232 @@
+package replay
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/rendis/statepro/v3"
+	"github.com/rendis/statepro/v3/builder"
+	"github.com/rendis/statepro/v3/builtin"
+	"github.com/rendis/statepro/v3/debugger/bot"
+	"github.com/rendis/statepro/v3/experimental"
+	"github.com/rendis/statepro/v3/instrumentation"
+	"github.com/rendis/statepro/v3/theoretical"
+)
+
+// countStep is added to the comment counter; tests change it to simulate a behavior change.
+var countStep = 1
+
+func init() {
+	_ = builtin.RegisterAction("action:replayCount", func(_ context.Context, args instrumentation.ActionExecutorArgs) error {
+		count, _ := args.GetUniverseMetadata()["comments"].(int)
+		args.AddToUniverseMetadata("comments", count+countStep)
+		return nil
+	})
+}
+
+// replayModel is a ticket whose comments are counted in its universe metadata.
+func replayModel() *theoretical.QuantumMachineModel {
+	return builder.New("tickets").
+		Initials(builder.Universe("ticket")).
+		Universe("ticket", func(u *builder.UniverseBuilder) {
+			u.Initial("OPEN")
+			u.Reality("OPEN", func(r *builder.RealityBuilder) {
+				r.On("comment", "OPEN").Action("action:replayCount", nil)
+				r.On("resolve", "RESOLVED")
+			})
+			u.Final("RESOLVED", nil)
+		}).
+		MustBuild()
+}
+
+// recordRun runs the events with a debugger bot and returns its history as steps.
+func recordRun(t *testing.T, events ...string) []Step {
+	t.Helper()
+	qm, err := statepro.NewQuantumMachine(replayModel())
+	if err != nil {
+		t.Fatalf("unexpected machine error: %v", err)
+	}
+	next := 0
+	provider := func(*instrumentation.MachineSnapshot) (instrumentation.Event, error) {
+		if next == len(events) {
+			return nil, nil
+		}
+		next++
+		return statepro.NewEventBuilder(events[next-1]).Build(), nil
+	}
+	b, _ := bot.NewBot(qm, provider, true)
+	if err = b.Run(context.Background(), nil); err != nil {
+		t.Fatalf("bot run failed: %v", err)
+	}
+	return FromBotHistory(b.GetHistory())
+}
+
+func TestVerify_Reproduced(t *testing.T) {
+	steps := recordRun(t, "comment", "comment", "resolve")
+	report, err := Verify(context.Background(), replayModel(), steps, WithInit())
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if !report.OK() || report.Verified != 3 {
+		t.Fatalf("expected every step to be reproduced, got %+v", report)
+	}
+}
+
+func TestVerify_BehaviorChange(t *testing.T) {
+	steps := recordRun(t, "comment", "comment", "resolve")
+
+	countStep = 2
+	defer func() { countStep = 1 }()
+
+	report, err := Verify(context.Background(), replayModel(), steps, WithInit())
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if report.OK() || report.Verified != 0 || report.Divergence.Step != 0 {
+		t.Fatalf("expected divergence at the first step, got %+v", report)
+	}
+	differences := report.Divergence.Differences
+	expected := Difference{Kind: DifferenceChanged, Path: "/snapshots/ticket/metadata/comments", Recorded: 1.0, Produced: 2.0}
+	if len(differences) != 1 || differences[0] != expected {
+		t.Fatalf("expected %v, got %v", expected, differences)
+	}
+	if changes := report.Divergence.Changes.String(); changes != "ticket: metadata 'comments' changed: 1 -> 2" {
+		t.Fatalf("unexpected changes %q", changes)
+	}
+	if out := report.Divergence.String(); !strings.Contains(out, "step 0 (event 'comment'): 1 difference(s)") ||
+		!strings.Contains(out, "/snapshots/ticket/metadata/comments: recorded 1, replay produced 2") {
+		t.Fatalf("unexpected report:\n%s", out)
+	}
+
+	report, _ = Verify(context.Background(), replayModel(), steps, WithInit(),
+		WithIgnoredPaths("/snapshots/ticket/metadata"))
+	if !report.OK() {
+		t.Fatalf("expected ignored paths to be skipped, got %s", report.Divergence)
+	}
+}
+
+func TestVerify_ModelChange(t *testing.T) {
+	steps := recordRun(t, "comment", "resolve")
+
+	// the new definition resolves tickets into a different reality
+	model := replayModel()
+	ticket := model.Universes["ticket"]
+	ticket.Realities["CLOSED"] = &theoretical.RealityModel{ID: "CLOSED", Type: theoretical.RealityTypeFinal}
+	ticket.Realities["OPEN"].On["resolve"][0].Targets = []string{"CLOSED"}
+	delete(ticket.Realities, "RESOLVED")
+
+	report, err := Verify(context.Background(), model, steps, WithInit())
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if report.OK() || report.Verified != 1 || report.Divergence.Step != 1 {
+		t.Fatalf("expected divergence at the second step, got %+v", report)
+	}
+	paths := map[string]DifferenceKind{}
+	for _, d := range report.Divergence.Differences {
+		paths[d.Path] = d.Kind
+	}
+	for path, kind := range map[string]DifferenceKind{
+		"/resume/finalizedUniverses/ticket": DifferenceChanged,
+		"/snapshots/ticket/currentReality":  DifferenceChanged,
+		"/tracking/ticket/2":                DifferenceChanged,
+	} {
+		if paths[path] != kind {
+			t.Fatalf("expected %s difference at %s, got %s", kind, path, report.Divergence)
+		}
+	}
+	if len(paths) != 3 {
+		t.Fatalf("expected fingerprints not to be compared, got %s", report.Divergence)
+	}
+}
+
+func TestVerify_IgnoredPathsLeaveChanges(t *testing.T) {
+	steps := recordRun(t, "comment", "resolve")
+
+	// the comments counter is ignored, the new target is not
+	countStep = 2
+	defer func() { countStep = 1 }()
+	model := replayModel()
+	ticket := model.Universes["ticket"]
+	ticket.Realities["CLOSED"] = &theoretical.RealityModel{ID: "CLOSED", Type: theoretical.RealityTypeFinal}
+	ticket.Realities["OPEN"].On["resolve"][0].Targets = []string{"CLOSED"}
+
+	report, err := Verify(context.Background(), model, steps, WithInit(), WithIgnoredPaths("/snapshots/ticket/metadata/comments"))
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if report.OK() || report.Divergence.Step != 1 {
+		t.Fatalf("expected divergence at the second step, got %+v", report)
+	}
+	expected := "ticket: reality 'RESOLVED' -> 'CLOSED'\nticket: tracking removed [RESOLVED]\nticket: tracking appended [CLOSED]"
+	if changes := report.Divergence.Changes.String(); changes != expected {
+		t.Fatalf("expected the ignored metadata to be left out of the changes, got %q", changes)
+	}
+}
+
+func TestVerify_MachineScopedExecutors(t *testing.T) {
+	steps := recordRun(t, "comment")
+
+	// a fake replaces the registered action for this replay only
+	fake := experimental.WithExecutors(experimental.Executors{Actions: map[string]instrumentation.ActionFn{
+		"action:replayCount": func(_ context.Context, args instrumentation.ActionExecutorArgs) error {
+			args.AddToUniverseMetadata("comments", 5)
+			return nil
+		},
+	}})
+	report, err := Verify(context.Background(), replayModel(), steps, WithInit(), WithMachineOptions(fake))
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if report.OK() || report.Divergence.Differences[0].Produced != 5.0 {
+		t.Fatalf("expected the fake to be used, got %+v", report)
+	}
+}
+
+func TestVerify_ReplayError(t *testing.T) {
+	steps := recordRun(t, "comment")
+
+	// without Init the machine cannot handle the event the same way
+	report, err := Verify(context.Background(), replayModel(), steps)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if report.OK() {
+		t.Fatal("expected a divergence replaying on an uninitialized machine")
+	}
+
+	if _, err = Verify(context.Background(), nil, steps); err == nil {
+		t.Fatal("expected error for a nil model")
+	}
+	if _, err = Verify(context.Background(), replayModel(), []Step{{}}); err == nil {
+		t.Fatal("expected error for an empty step")
+	}
+}
+
+func TestCompare(t *testing.T) {
+	recorded := &instrumentation.MachineSnapshot{}
+	recorded.AddTracking("a/b", []string{"X", "Y"})
+	recorded.AddActiveUniverse("u", "X")
+	produced := &instrumentation.MachineSnapshot{}
+	produced.AddTracking("a/b", []string{"X"})
+	produced.AddFinalizedUniverse("u", "Z")
+
+	differences, err := Compare(recorded, produced)
+	if err != nil {
+		t.Fatalf("Compare failed: %v", err)
+	}
+	expected := []string{
+		`/resume/activeUniverses: recorded {"u":"X"}, missing from the replay`,
+		`/resume/finalizedUniverses: not recorded, replay produced {"u":"Z"}`,
+		`/tracking/a~1b/1: recorded "Y", missing from the replay`,
+	}
+	if len(differences) != len(expected) {
+		t.Fatalf("expected %d differences, got %v", len(expected), differences)
+	}
+	for i, d := range differences {
+		if d.String() != expected[i] {
+			t.Fatalf("difference %d: expected %q, got %q", i, expected[i], d.String())
+		}
+	}
+}
@@ -46,7 +46,7 @@ func ValidateQuantumMachineBySchemaFromMap(source map[string]any) error {
 		return fmt.Errorf("source map cannot be nil")
 	}
 
-	return validateQuantumMachineBySchema(source)
+	return validateQuantumMachineBySchema(source, nil)
 }
 
 // ValidateQuantumMachineBySchemaFromBinary validates a JSON definition using the embedded JSON Schema.
@@ -67,17 +67,28 @@ func ValidateQuantumMachineBySchemaFromBinary(source []byte) error {
 		)
 	}
 
-	return validateQuantumMachineBySchema(payload)
+	return validateQuantumMachineBySchema(payload, nil)
 }
 
-func validateQuantumMachineBySchema(payload any) error {
+// ValidateQuantumMachineBySchemaFromYAML validates a YAML definition using the embedded JSON Schema.
+// Issues report the line and column of the offending value.
+func ValidateQuantumMachineBySchemaFromYAML(source []byte) error {
+	doc, err := parseYAMLDefinition(source)
+	if err != nil {
+		return err
+	}
+
+	return validateQuantumMachineBySchema(doc.payload, doc.annotateIssue)
+}
+
+func validateQuantumMachineBySchema(payload any, annotate issueAnnotator) error {
 	schema, err := getQuantumMachineSchema()
 	if err != nil {
 		return fmt.Errorf("error loading quantum machine schema: %w", err)
 	}
 
 	if err = schema.Validate(payload); err != nil {
-		return fmt.Errorf("json schema validation failed: %w", err)
+		return fmt.Errorf("json schema validation failed: %w", annotate.apply(schemaValidationError(err)))
 	}
 
 	return nil
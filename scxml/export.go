@@ -0,0 +1,296 @@
+package scxml
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/rendis/statepro/v3/internal/convert"
+	"github.com/rendis/statepro/v3/internal/util"
+	"github.com/rendis/statepro/v3/theoretical"
+)
+
+// Export converts the model to an SCXML document.
+// Universes become the regions of a top-level <parallel> and reality ids are prefixed with their universe
+// id ("<universe>.<reality>") to keep them unique across regions.
+func Export(model *theoretical.QuantumMachineModel) ([]byte, *Report, error) {
+	if model == nil {
+		return nil, nil, fmt.Errorf("source model cannot be nil")
+	}
+
+	report := &Report{}
+	root := newNode("scxml",
+		"xmlns", Namespace,
+		"xmlns:statepro", StateproNamespace,
+		"version", "1.0",
+		"name", model.ID,
+		stateproPrefix+"canonicalName", model.CanonicalName,
+		stateproPrefix+"version", model.Version,
+		stateproPrefix+"initials", strings.Join(model.Initials, " "),
+	)
+
+	parallel := newNode("parallel", "id", parallelID(model))
+	root.add(parallel)
+
+	if !convert.InitialsStartEveryUniverse(model) {
+		report.Add(LossInitials, "/initials",
+			"SCXML starts every region of a <parallel>; statepro only starts %v (kept in statepro:initials)", model.Initials)
+	}
+	if model.UniversalConstants != nil {
+		report.Add(LossConstants, "/universalConstants", "machine universal constants have no SCXML equivalent and are not exported")
+	}
+	if len(model.Metadata) > 0 || model.Description != nil {
+		report.Add(LossMetadata, "", "machine description and metadata are not exported")
+	}
+
+	for _, universeID := range util.SortedKeys(model.Universes) {
+		universe := model.Universes[universeID]
+		if universe == nil {
+			continue
+		}
+		region, err := exportUniverse(universeID, universe, report)
+		if err != nil {
+			return nil, nil, err
+		}
+		parallel.add(region)
+	}
+
+	b, err := xml.MarshalIndent(root, "", "  ")
+	if err != nil {
+		return nil, nil, fmt.Errorf("error marshaling scxml: %w", err)
+	}
+	return append([]byte(xml.Header), b...), report, nil
+}
+
+func exportUniverse(universeID string, universe *theoretical.UniverseModel, report *Report) (*node, error) {
+	path := util.JSONPointer("universes", universeID)
+	region := newNode("state",
+		"id", universeID,
+		stateproPrefix+"canonicalName", universe.CanonicalName,
+		stateproPrefix+"version", universe.Version,
+	)
+
+	if universe.Initial != nil {
+		region.setAttr("initial", stateID(universeID, *universe.Initial))
+	} else {
+		region.setAttr(stateproPrefix+"superposition", "true")
+		report.Add(LossSuperposition, path,
+			"universe without initial reality starts in superposition; SCXML enters its first state instead")
+	}
+	if universe.UniversalConstants != nil {
+		report.Add(LossConstants, path+"/universalConstants", "universe universal constants have no SCXML equivalent and are not exported")
+	}
+	if len(universe.Metadata) > 0 || len(universe.Tags) > 0 || universe.Description != nil {
+		report.Add(LossMetadata, path, "universe description, metadata and tags are not exported")
+	}
+
+	for _, realityID := range util.SortedKeys(universe.Realities) {
+		reality := universe.Realities[realityID]
+		if reality == nil {
+			continue
+		}
+		state, err := exportReality(universeID, reality, path+util.JSONPointer("realities", realityID), report)
+		if err != nil {
+			return nil, err
+		}
+		region.add(state)
+	}
+	return region, nil
+}
+
+func exportReality(universeID string, reality *theoretical.RealityModel, path string, report *Report) (*node, error) {
+	element := "state"
+	if theoretical.IsFinalState(reality.Type) {
+		element = "final"
+	}
+	state := newNode(element, "id", stateID(universeID, reality.ID))
+	if reality.Type == theoretical.RealityTypeUnsuccessfulFinal {
+		state.setAttr(stateproPrefix+"type", string(reality.Type))
+	}
+	if len(reality.Metadata) > 0 || reality.Description != nil {
+		report.Add(LossMetadata, path, "reality description and metadata are not exported")
+	}
+
+	for i, observer := range reality.Observers {
+		if observer == nil {
+			continue
+		}
+		observerPath := path + util.JSONPointer("observers", strconv.Itoa(i))
+		report.Add(LossObserver, observerPath,
+			"observer '%s' has no SCXML equivalent (kept as statepro:observer, ignored by SCXML processors)", observer.Src)
+		n, err := executorNode("observer", observer.Src, observer.Args, observerPath)
+		if err != nil {
+			return nil, err
+		}
+		state.add(n)
+	}
+
+	onEntry, err := exportExecutables("onentry", reality.EntryActions, reality.EntryInvokes, path)
+	if err != nil {
+		return nil, err
+	}
+	if onEntry != nil {
+		state.add(onEntry)
+	}
+	onExit, err := exportExecutables("onexit", reality.ExitActions, reality.ExitInvokes, path)
+	if err != nil {
+		return nil, err
+	}
+	if onExit != nil {
+		state.add(onExit)
+	}
+
+	for i, transition := range reality.Always {
+		t, err := exportTransition(universeID, "", transition, path+util.JSONPointer("always", strconv.Itoa(i)), report)
+		if err != nil {
+			return nil, err
+		}
+		state.add(t)
+	}
+	for _, eventName := range util.SortedKeys(reality.On) {
+		for i, transition := range reality.On[eventName] {
+			t, err := exportTransition(universeID, eventName, transition, path+util.JSONPointer("on", eventName, strconv.Itoa(i)), report)
+			if err != nil {
+				return nil, err
+			}
+			state.add(t)
+		}
+	}
+	return state, nil
+}
+
+func exportTransition(universeID, eventName string, transition *theoretical.TransitionModel, path string, report *Report) (*node, error) {
+	if transition == nil {
+		return nil, nil
+	}
+
+	var targets []string
+	superposition := len(transition.Targets) > 1
+	for _, ref := range transition.Targets {
+		targetID, isUniverse := exportTarget(universeID, ref)
+		targets = append(targets, targetID)
+		superposition = superposition || isUniverse || strings.HasPrefix(ref, "U:")
+	}
+
+	t := newNode("transition", "event", eventName, "target", strings.Join(targets, " "))
+
+	var conditions []*theoretical.ConditionModel
+	for _, condition := range append([]*theoretical.ConditionModel{transition.Condition}, transition.Conditions...) {
+		if condition != nil {
+			conditions = append(conditions, condition)
+		}
+	}
+	if len(conditions) > 0 {
+		var srcs []string
+		withArgs := false
+		for _, condition := range conditions {
+			srcs = append(srcs, condition.Src)
+			withArgs = withArgs || len(condition.Args) > 0
+		}
+		t.setAttr("cond", strings.Join(srcs, " && "))
+		if withArgs {
+			b, err := json.Marshal(conditions)
+			if err != nil {
+				return nil, fmt.Errorf("error marshaling conditions of %s: %w", path, err)
+			}
+			t.setAttr(stateproPrefix+"conditions", string(b))
+		}
+	}
+
+	if transition.IsNotification() {
+		t.setAttr(stateproPrefix+"type", string(theoretical.TransitionTypeNotify))
+		report.Add(LossNotify, path, "notify transitions have no SCXML equivalent; SCXML processors take them as regular transitions")
+	} else if superposition {
+		report.Add(LossSuperposition, path,
+			"transition to %v puts the universe in superposition; SCXML processors take it as a regular transition", transition.Targets)
+	}
+
+	if err := addExecutables(t, transition.Actions, transition.Invokes, path); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// exportTarget maps a statepro reference to an SCXML state id.
+func exportTarget(universeID, ref string) (string, bool) {
+	if !strings.HasPrefix(ref, "U:") {
+		return stateID(universeID, ref), false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(ref, "U:"), ":", 2)
+	if len(parts) == 1 {
+		return parts[0], true
+	}
+	return stateID(parts[0], parts[1]), false
+}
+
+func exportExecutables(element string, actions []*theoretical.ActionModel, invokes []*theoretical.InvokeModel, path string) (*node, error) {
+	if len(actions) == 0 && len(invokes) == 0 {
+		return nil, nil
+	}
+	n := newNode(element)
+	if err := addExecutables(n, actions, invokes, path); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+func addExecutables(parent *node, actions []*theoretical.ActionModel, invokes []*theoretical.InvokeModel, path string) error {
+	for _, action := range actions {
+		if action == nil {
+			continue
+		}
+		n, err := executorNode("action", action.Src, action.Args, path)
+		if err != nil {
+			return err
+		}
+		parent.add(n)
+	}
+	for _, invoke := range invokes {
+		if invoke == nil {
+			continue
+		}
+		n, err := executorNode("invoke", invoke.Src, invoke.Args, path)
+		if err != nil {
+			return err
+		}
+		parent.add(n)
+	}
+	return nil
+}
+
+func executorNode(kind, src string, args map[string]any, path string) (*node, error) {
+	n := newNode(stateproPrefix+kind, "src", src)
+	if len(args) > 0 {
+		b, err := json.Marshal(args)
+		if err != nil {
+			return nil, fmt.Errorf("error marshaling args of %s '%s' in %s: %w", kind, src, path, err)
+		}
+		n.setAttr("args", string(b))
+	}
+	return n, nil
+}
+
+func stateID(universeID, realityID string) string {
+	return universeID + "." + realityID
+}
+
+// parallelID returns the id of the top-level <parallel>: the machine id, suffixed when a region or a state
+// already uses it (e.g. the universe of a single-universe machine with the same id).
+func parallelID(model *theoretical.QuantumMachineModel) string {
+	taken := map[string]bool{}
+	for universeID, universe := range model.Universes {
+		taken[universeID] = true
+		if universe != nil {
+			for realityID := range universe.Realities {
+				taken[stateID(universeID, realityID)] = true
+			}
+		}
+	}
+	id := model.ID
+	for i := 2; taken[id]; i++ {
+		id = fmt.Sprintf("%s_%d", model.ID, i)
+	}
+	return id
+}
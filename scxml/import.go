@@ -0,0 +1,476 @@
+package scxml
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/rendis/statepro/v3/internal/convert"
+	"github.com/rendis/statepro/v3/internal/util"
+	"github.com/rendis/statepro/v3/theoretical"
+)
+
+// Import converts an SCXML document to a model.
+// The regions of a top-level <parallel> become universes; any other document becomes a single universe
+// named after the document. State ids prefixed with their region id ("<region>.<state>", as written by
+// Export) lose the prefix, and ids that are not valid statepro identifiers are sanitized and reported.
+//
+// The returned model is not validated; call statepro.ValidateQuantumMachineDefinition before loading it.
+func Import(data []byte) (*theoretical.QuantumMachineModel, *Report, error) {
+	root := &node{}
+	if err := xml.Unmarshal(data, root); err != nil {
+		return nil, nil, fmt.Errorf("error parsing scxml: %w", err)
+	}
+	if !root.isSCXML("scxml") {
+		return nil, nil, fmt.Errorf("root element must be <scxml>, found <%s>", root.XMLName.Local)
+	}
+
+	im := &importer{report: &Report{}, states: map[string]stateRef{}, universes: map[string]bool{}}
+	model, err := im.importMachine(root)
+	if err != nil {
+		return nil, nil, err
+	}
+	return model, im.report, nil
+}
+
+// stateRef is the statepro location of an SCXML state id.
+type stateRef struct {
+	universe string
+	// reality is empty when the SCXML id names a region.
+	reality string
+}
+
+type importer struct {
+	report *Report
+	// states maps every SCXML state id to its universe and reality.
+	states map[string]stateRef
+	// universes holds the universe ids already assigned to a region.
+	universes map[string]bool
+}
+
+// region is an SCXML state imported as a universe.
+type region struct {
+	id        string
+	element   *node
+	realities []*node
+}
+
+func (im *importer) importMachine(root *node) (*theoretical.QuantumMachineModel, error) {
+	machineID := im.identifier(convert.FirstNonEmpty(root.attr("name"), "machine"), "scxml")
+	model := &theoretical.QuantumMachineModel{
+		ID:            machineID,
+		CanonicalName: convert.FirstNonEmpty(root.stateproAttr("canonicalName"), machineID),
+		Version:       convert.FirstNonEmpty(root.stateproAttr("version"), convert.DefaultVersion),
+		Universes:     map[string]*theoretical.UniverseModel{},
+	}
+
+	im.reportUnsupportedChildren(root, "scxml")
+
+	var states []*node
+	for _, child := range root.Children {
+		if isStateElement(child) {
+			states = append(states, child)
+		}
+	}
+
+	var regions []*region
+	if len(states) == 1 && states[0].isSCXML("parallel") {
+		parallel := states[0]
+		im.reportUnsupportedChildren(parallel, parallel.attr("id"))
+		for _, child := range parallel.Children {
+			if isStateElement(child) {
+				regions = append(regions, im.newRegion(child))
+			}
+		}
+	} else if len(states) > 0 {
+		r := &region{id: im.uniqueUniverseID(machineID), element: root, realities: states}
+		regions = append(regions, r)
+		im.states[machineID] = stateRef{universe: r.id}
+	}
+
+	for _, r := range regions {
+		im.registerRealities(r)
+	}
+	for _, r := range regions {
+		universe, err := im.importUniverse(r)
+		if err != nil {
+			return nil, err
+		}
+		model.Universes[r.id] = universe
+	}
+
+	if initials := root.stateproAttr("initials"); initials != "" {
+		model.Initials = strings.Fields(initials)
+	} else {
+		for _, r := range regions {
+			model.Initials = append(model.Initials, "U:"+r.id)
+		}
+	}
+	return model, nil
+}
+
+func (im *importer) newRegion(element *node) *region {
+	scxmlID := element.attr("id")
+	r := &region{id: im.uniqueUniverseID(im.identifier(scxmlID, scxmlID)), element: element}
+	for _, child := range element.Children {
+		if isStateElement(child) {
+			r.realities = append(r.realities, child)
+		}
+	}
+	if len(r.realities) == 0 {
+		// an atomic region is a universe with a single reality
+		r.realities = []*node{element}
+	} else if scxmlID != "" {
+		im.states[scxmlID] = stateRef{universe: r.id}
+	}
+	if element.isSCXML("parallel") {
+		im.report.Add(LossHierarchy, scxmlID, "nested <parallel> imported as a regular universe")
+	}
+	return r
+}
+
+// uniqueUniverseID returns id, suffixed when another region already got it, and reserves it.
+func (im *importer) uniqueUniverseID(id string) string {
+	unique := id
+	for i := 2; im.universes[unique]; i++ {
+		unique = fmt.Sprintf("%s_%d", id, i)
+	}
+	im.universes[unique] = true
+	return unique
+}
+
+// registerRealities assigns a reality id to every state of the region, so transitions can be resolved
+// regardless of the document order.
+func (im *importer) registerRealities(r *region) {
+	taken := map[string]bool{}
+	prefix := r.element.attr("id") + "."
+	for _, state := range r.realities {
+		scxmlID := state.attr("id")
+		realityID := im.identifier(strings.TrimPrefix(scxmlID, prefix), scxmlID)
+		for base, i := realityID, 2; taken[realityID]; i++ {
+			realityID = fmt.Sprintf("%s_%d", base, i)
+		}
+		taken[realityID] = true
+		if scxmlID == "" {
+			// states without id cannot be targeted, keep the generated id on the node
+			scxmlID = "@" + r.id + "/" + realityID
+			state.Attrs = append(state.Attrs, xml.Attr{Name: xml.Name{Local: "id"}, Value: scxmlID})
+		}
+		im.states[scxmlID] = stateRef{universe: r.id, reality: realityID}
+	}
+}
+
+func (im *importer) importUniverse(r *region) (*theoretical.UniverseModel, error) {
+	universe := &theoretical.UniverseModel{
+		ID:            r.id,
+		CanonicalName: convert.FirstNonEmpty(r.element.stateproAttr("canonicalName"), r.id),
+		Version:       convert.FirstNonEmpty(r.element.stateproAttr("version"), convert.DefaultVersion),
+		Realities:     map[string]*theoretical.RealityModel{},
+	}
+
+	if r.element.stateproAttr("superposition") != "true" {
+		if initial := im.universeInitial(r); initial != "" {
+			universe.Initial = &initial
+		}
+	}
+
+	for _, state := range r.realities {
+		reality, err := im.importReality(r, state)
+		if err != nil {
+			return nil, err
+		}
+		universe.Realities[reality.ID] = reality
+	}
+	return universe, nil
+}
+
+// universeInitial resolves the initial attribute, then the <initial> child, then the first state of the region.
+func (im *importer) universeInitial(r *region) string {
+	candidates := strings.Fields(r.element.attr("initial"))
+	for _, child := range r.element.Children {
+		if child.isSCXML("initial") {
+			for _, t := range child.Children {
+				if t.isSCXML("transition") {
+					candidates = append(candidates, strings.Fields(t.attr("target"))...)
+				}
+			}
+		}
+	}
+	if len(candidates) > 1 {
+		im.report.Add(LossSuperposition, r.element.attr("id"),
+			"initial %v enters several states; only '%s' is kept", candidates, candidates[0])
+	}
+	if len(candidates) > 0 {
+		if ref, ok := im.states[candidates[0]]; ok && ref.universe == r.id && ref.reality != "" {
+			return ref.reality
+		}
+		im.report.Add(LossHierarchy, r.element.attr("id"), "initial state '%s' is not a state of the region", candidates[0])
+	}
+	return im.states[r.realities[0].attr("id")].reality
+}
+
+func (im *importer) importReality(r *region, state *node) (*theoretical.RealityModel, error) {
+	scxmlID := state.attr("id")
+	reality := &theoretical.RealityModel{
+		ID:   im.states[scxmlID].reality,
+		Type: theoretical.RealityTypeTransition,
+	}
+	if state.isSCXML("final") {
+		reality.Type = theoretical.RealityTypeFinal
+		if state.stateproAttr("type") == string(theoretical.RealityTypeUnsuccessfulFinal) {
+			reality.Type = theoretical.RealityTypeUnsuccessfulFinal
+		}
+	}
+
+	for _, child := range state.Children {
+		switch {
+		case child.isStatepro("observer"):
+			src, args, err := executor(child, scxmlID)
+			if err != nil {
+				return nil, err
+			}
+			reality.Observers = append(reality.Observers, &theoretical.ObserverModel{Src: src, Args: args})
+		case child.isSCXML("onentry"):
+			actions, invokes, err := im.importExecutables(child, scxmlID)
+			if err != nil {
+				return nil, err
+			}
+			reality.EntryActions = append(reality.EntryActions, actions...)
+			reality.EntryInvokes = append(reality.EntryInvokes, invokes...)
+		case child.isSCXML("onexit"):
+			actions, invokes, err := im.importExecutables(child, scxmlID)
+			if err != nil {
+				return nil, err
+			}
+			reality.ExitActions = append(reality.ExitActions, actions...)
+			reality.ExitInvokes = append(reality.ExitInvokes, invokes...)
+		case child.isSCXML("transition"):
+			if err := im.importTransition(r, reality, child, scxmlID); err != nil {
+				return nil, err
+			}
+		case isStateElement(child), child.isSCXML("initial"), child.isSCXML("history"):
+			im.report.Add(LossHierarchy, scxmlID, "nested <%s> is not supported, imported as an atomic reality", child.XMLName.Local)
+		case child.isSCXML("invoke"):
+			im.report.Add(LossInvoke, scxmlID, "<invoke> of external services is not supported")
+		case child.isSCXML("datamodel"):
+			im.report.Add(LossDataModel, scxmlID, "<datamodel> is not supported")
+		case child.isSCXML("donedata"):
+			im.report.Add(LossExecutableContent, scxmlID, "<donedata> is not supported")
+		}
+	}
+
+	if theoretical.IsFinalState(reality.Type) && (len(reality.On) > 0 || len(reality.Always) > 0) {
+		im.report.Add(LossHierarchy, scxmlID, "transitions of a <final> state are ignored by statepro")
+	}
+	return reality, nil
+}
+
+func (im *importer) importTransition(r *region, reality *theoretical.RealityModel, element *node, scxmlID string) error {
+	transition := &theoretical.TransitionModel{}
+
+	for _, target := range strings.Fields(element.attr("target")) {
+		ref, ok := im.states[target]
+		switch {
+		case !ok:
+			im.report.Add(LossHierarchy, scxmlID, "target '%s' is not a state of a region, ignored", target)
+		case ref.reality == "":
+			transition.Targets = append(transition.Targets, "U:"+ref.universe)
+		case ref.universe == r.id:
+			transition.Targets = append(transition.Targets, ref.reality)
+		default:
+			transition.Targets = append(transition.Targets, "U:"+ref.universe+":"+ref.reality)
+		}
+	}
+	if len(transition.Targets) == 0 {
+		im.report.Add(LossTargetlessTransition, scxmlID,
+			"transition on '%s' without target is not supported, ignored", element.attr("event"))
+		return nil
+	}
+
+	if element.stateproAttr("type") == string(theoretical.TransitionTypeNotify) {
+		notify := theoretical.TransitionTypeNotify
+		transition.Type = &notify
+	}
+
+	conditions, err := im.importConditions(element, scxmlID)
+	if err != nil {
+		return err
+	}
+	if len(conditions) == 1 {
+		transition.Condition = conditions[0]
+	} else {
+		transition.Conditions = conditions
+	}
+
+	for _, child := range element.Children {
+		switch {
+		case child.isStatepro("action"):
+			src, args, err := executor(child, scxmlID)
+			if err != nil {
+				return err
+			}
+			transition.Actions = append(transition.Actions, &theoretical.ActionModel{Src: src, Args: args})
+		case child.isStatepro("invoke"):
+			src, args, err := executor(child, scxmlID)
+			if err != nil {
+				return err
+			}
+			transition.Invokes = append(transition.Invokes, &theoretical.InvokeModel{Src: src, Args: args})
+		default:
+			im.report.Add(LossExecutableContent, scxmlID, "executable content <%s> is not supported", child.XMLName.Local)
+		}
+	}
+
+	events := strings.Fields(element.attr("event"))
+	if len(events) == 0 {
+		reality.Always = append(reality.Always, transition)
+		return nil
+	}
+
+	for i, descriptor := range events {
+		if descriptor == "*" || strings.HasSuffix(descriptor, ".*") {
+			im.report.Add(LossEvent, scxmlID, "wildcard event descriptor '%s' is not supported, ignored", descriptor)
+			continue
+		}
+		eventName := im.eventName(descriptor, scxmlID)
+		if i > 0 {
+			// every event gets its own copy so later edits of one event do not leak to the others
+			transition = copyTransition(transition)
+		}
+		if reality.On == nil {
+			reality.On = map[string][]*theoretical.TransitionModel{}
+		}
+		reality.On[eventName] = append(reality.On[eventName], transition)
+	}
+	return nil
+}
+
+// copyTransition returns a copy of the transition with its own targets, conditions, actions and invokes.
+func copyTransition(transition *theoretical.TransitionModel) *theoretical.TransitionModel {
+	copied := *transition
+	copied.Targets = slices.Clone(transition.Targets)
+	if transition.Condition != nil {
+		condition := *transition.Condition
+		copied.Condition = &condition
+	}
+	copied.Conditions = copyElements(transition.Conditions)
+	copied.Actions = copyElements(transition.Actions)
+	copied.Invokes = copyElements(transition.Invokes)
+	return &copied
+}
+
+func copyElements[T any](elements []*T) []*T {
+	if elements == nil {
+		return nil
+	}
+	copied := make([]*T, len(elements))
+	for i, element := range elements {
+		if element == nil {
+			continue
+		}
+		c := *element
+		copied[i] = &c
+	}
+	return copied
+}
+
+// importConditions prefers the statepro:conditions attribute, which keeps the condition args, and falls back
+// to the cond expression split on "&&".
+func (im *importer) importConditions(element *node, scxmlID string) ([]*theoretical.ConditionModel, error) {
+	if raw := element.stateproAttr("conditions"); raw != "" {
+		var conditions []*theoretical.ConditionModel
+		if err := json.Unmarshal([]byte(raw), &conditions); err != nil {
+			return nil, fmt.Errorf("invalid statepro:conditions in state '%s': %w", scxmlID, err)
+		}
+		if slices.Contains(conditions, nil) {
+			return nil, fmt.Errorf("invalid statepro:conditions in state '%s': null condition", scxmlID)
+		}
+		return conditions, nil
+	}
+
+	cond := strings.TrimSpace(element.attr("cond"))
+	if cond == "" {
+		return nil, nil
+	}
+	var conditions []*theoretical.ConditionModel
+	for _, src := range strings.Split(cond, "&&") {
+		src = strings.TrimSpace(src)
+		if strings.ContainsAny(src, " \t\n()!|<>=") {
+			im.report.Add(LossCondition, scxmlID,
+				"cond expression '%s' is not a condition src; kept as is and must be replaced by a registered condition", src)
+		}
+		conditions = append(conditions, &theoretical.ConditionModel{Src: src})
+	}
+	return conditions, nil
+}
+
+func (im *importer) importExecutables(element *node, scxmlID string) ([]*theoretical.ActionModel, []*theoretical.InvokeModel, error) {
+	var actions []*theoretical.ActionModel
+	var invokes []*theoretical.InvokeModel
+	for _, child := range element.Children {
+		switch {
+		case child.isStatepro("action"):
+			src, args, err := executor(child, scxmlID)
+			if err != nil {
+				return nil, nil, err
+			}
+			actions = append(actions, &theoretical.ActionModel{Src: src, Args: args})
+		case child.isStatepro("invoke"):
+			src, args, err := executor(child, scxmlID)
+			if err != nil {
+				return nil, nil, err
+			}
+			invokes = append(invokes, &theoretical.InvokeModel{Src: src, Args: args})
+		default:
+			im.report.Add(LossExecutableContent, scxmlID, "executable content <%s> is not supported", child.XMLName.Local)
+		}
+	}
+	return actions, invokes, nil
+}
+
+func (im *importer) reportUnsupportedChildren(element *node, location string) {
+	for _, child := range element.Children {
+		switch {
+		case child.isSCXML("datamodel"):
+			im.report.Add(LossDataModel, location, "<datamodel> is not supported")
+		case child.isSCXML("script"):
+			im.report.Add(LossExecutableContent, location, "<script> is not supported")
+		case child.isSCXML("onentry"), child.isSCXML("onexit"), child.isSCXML("transition"):
+			im.report.Add(LossHierarchy, location, "<%s> of a container state is not supported", child.XMLName.Local)
+		case child.isSCXML("invoke"):
+			im.report.Add(LossInvoke, location, "<invoke> of external services is not supported")
+		}
+	}
+}
+
+func executor(element *node, scxmlID string) (string, map[string]any, error) {
+	src := element.attr("src")
+	raw := element.attr("args")
+	if raw == "" {
+		return src, nil, nil
+	}
+	var args map[string]any
+	if err := json.Unmarshal([]byte(raw), &args); err != nil {
+		return "", nil, fmt.Errorf("invalid args of '%s' in state '%s': %w", src, scxmlID, err)
+	}
+	return src, args, nil
+}
+
+// identifier turns an SCXML id into a valid statepro identifier, reporting any change.
+func (im *importer) identifier(id, location string) string {
+	sanitized := util.SanitizeIdentifier(id)
+	if sanitized != id {
+		im.report.Add(LossIdentifier, location, "id '%s' renamed to '%s'", id, sanitized)
+	}
+	return sanitized
+}
+
+func (im *importer) eventName(descriptor, location string) string {
+	sanitized := util.SanitizeEventName(descriptor)
+	if sanitized != descriptor {
+		im.report.Add(LossEvent, location, "event '%s' renamed to '%s'", descriptor, sanitized)
+	}
+	return sanitized
+}
@@ -0,0 +1,119 @@
+// Package scxml converts quantum machine definitions to and from W3C SCXML.
+//
+// Universes map to the regions of a top-level <parallel>, realities to <state>/<final>, On transitions to
+// <transition event="..."> and Always transitions to eventless transitions. Actions and invokes map to named
+// executable content in the statepro namespace (<statepro:action src="..." args="..."/>) and conditions to the
+// transition cond expression (condition srcs joined with "&&").
+//
+// Features without an SCXML equivalent (observers, superposition, notify transitions, constants, ...) are
+// listed in the Report returned by both Export and Import. Statepro-specific data that SCXML tooling ignores
+// (observers, notify type, initials, versions) is kept in statepro namespaced attributes and elements so
+// that a definition survives an Export/Import round trip.
+package scxml
+
+import (
+	"encoding/xml"
+
+	"github.com/rendis/statepro/v3/internal/convert"
+)
+
+const (
+	// Namespace is the W3C SCXML namespace.
+	Namespace = "http://www.w3.org/2005/07/scxml"
+
+	// StateproNamespace is the namespace of the statepro extension attributes and elements.
+	StateproNamespace = "https://github.com/rendis/statepro"
+
+	stateproPrefix = "statepro:"
+)
+
+// LossKind identifies a construct that could not be converted faithfully.
+type LossKind = convert.LossKind
+
+const (
+	LossObserver             LossKind = "observer"
+	LossSuperposition        LossKind = "superposition"
+	LossNotify               LossKind = "notify"
+	LossInitials             LossKind = "initials"
+	LossConstants            LossKind = "universal_constants"
+	LossMetadata             LossKind = "metadata"
+	LossHierarchy            LossKind = "hierarchy"
+	LossExecutableContent    LossKind = "executable_content"
+	LossDataModel            LossKind = "datamodel"
+	LossInvoke               LossKind = "invoke"
+	LossEvent                LossKind = "event"
+	LossTargetlessTransition LossKind = "targetless_transition"
+	LossIdentifier           LossKind = "identifier"
+	LossCondition            LossKind = "condition"
+)
+
+// Loss is a construct that has no equivalent in the target format.
+type Loss = convert.Loss
+
+// Report lists what a conversion could not carry over.
+type Report = convert.Report
+
+// node is a generic XML element, used both to build and to parse documents.
+// When building, statepro elements and attributes use the literal "statepro:" prefix; when parsing,
+// encoding/xml resolves prefixes so Name.Space holds the namespace URL.
+type node struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr `xml:",any,attr"`
+	Children []*node    `xml:",any"`
+}
+
+func newNode(name string, attrs ...string) *node {
+	n := &node{XMLName: xml.Name{Local: name}}
+	for i := 0; i+1 < len(attrs); i += 2 {
+		n.setAttr(attrs[i], attrs[i+1])
+	}
+	return n
+}
+
+func (n *node) setAttr(name, value string) {
+	if value == "" {
+		return
+	}
+	n.Attrs = append(n.Attrs, xml.Attr{Name: xml.Name{Local: name}, Value: value})
+}
+
+func (n *node) add(children ...*node) *node {
+	for _, child := range children {
+		if child != nil {
+			n.Children = append(n.Children, child)
+		}
+	}
+	return n
+}
+
+// attr returns the value of an SCXML (unqualified) attribute.
+func (n *node) attr(name string) string {
+	for _, a := range n.Attrs {
+		if a.Name.Space == "" && a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// stateproAttr returns the value of a statepro namespaced attribute.
+func (n *node) stateproAttr(name string) string {
+	for _, a := range n.Attrs {
+		if a.Name.Space == StateproNamespace && a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+func (n *node) isSCXML(local string) bool {
+	return n.XMLName.Local == local && (n.XMLName.Space == Namespace || n.XMLName.Space == "")
+}
+
+func (n *node) isStatepro(local string) bool {
+	return n.XMLName.Local == local && n.XMLName.Space == StateproNamespace
+}
+
+func isStateElement(n *node) bool {
+	return n.isSCXML("state") || n.isSCXML("final") || n.isSCXML("parallel")
+}
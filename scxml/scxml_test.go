@@ -0,0 +1,217 @@
+package scxml
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rendis/statepro/v3"
+	"github.com/rendis/statepro/v3/internal/convert/converttest"
+	"github.com/rendis/statepro/v3/internal/util"
+	"github.com/rendis/statepro/v3/theoretical"
+)
+
+func TestExport(t *testing.T) {
+	out, report, err := Export(converttest.Model(t))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	doc := string(out)
+	for _, want := range []string{
+		`<scxml xmlns="http://www.w3.org/2005/07/scxml" xmlns:statepro="https://github.com/rendis/statepro" version="1.0" name="machine"`,
+		`<parallel id="machine">`,
+		`<state id="main" statepro:canonicalName="main" statepro:version="1.0.0" initial="main.A">`,
+		`<statepro:action src="action:log" args="{&#34;level&#34;:&#34;info&#34;}"></statepro:action>`,
+		`<transition target="main.B" cond="condition:skip">`,
+		`<transition event="go" target="main.DONE" cond="condition:a &amp;&amp; condition:b"`,
+		`<transition event="ping" target="side" statepro:type="notify">`,
+		`<transition event="split" target="side.S other.X">`,
+		`<final id="main.FAILED" statepro:type="unsuccessfulFinal">`,
+		`statepro:superposition="true"`,
+		`<statepro:observer src="builtin:observer:alwaysTrue">`,
+	} {
+		if !strings.Contains(doc, want) {
+			t.Fatalf("expected document to contain %q, got:\n%s", want, doc)
+		}
+	}
+
+	kinds := converttest.LossKinds(report)
+	if kinds[LossObserver] != 1 || kinds[LossNotify] != 1 || kinds[LossInitials] != 1 {
+		t.Fatalf("unexpected losses: %v", report.Losses)
+	}
+	// universe side without initial and the split fan-out
+	if kinds[LossSuperposition] != 2 {
+		t.Fatalf("expected 2 superposition losses, got %v", report.Losses)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	model := converttest.Model(t)
+	out, _, err := Export(model)
+	if err != nil {
+		t.Fatalf("unexpected export error: %v", err)
+	}
+
+	imported, report, err := Import(out)
+	if err != nil {
+		t.Fatalf("unexpected import error: %v", err)
+	}
+	if report.HasLosses() {
+		t.Fatalf("expected lossless import, got %v", report.Losses)
+	}
+
+	if err := statepro.ValidateQuantumMachineDefinition(imported); err != nil {
+		t.Fatalf("expected a valid definition, got %v", err)
+	}
+
+	expected, _ := json.Marshal(model)
+	actual, _ := json.Marshal(imported)
+	var expectedMap, actualMap map[string]any
+	_ = json.Unmarshal(expected, &expectedMap)
+	_ = json.Unmarshal(actual, &actualMap)
+	if !reflect.DeepEqual(expectedMap, actualMap) {
+		t.Fatalf("round trip mismatch:\nexpected %s\nactual   %s", expected, actual)
+	}
+}
+
+func TestImportForeignDocument(t *testing.T) {
+	doc := `<?xml version="1.0"?>
+<scxml xmlns="http://www.w3.org/2005/07/scxml" version="1.0" name="light" initial="off">
+  <datamodel><data id="count" expr="0"/></datamodel>
+  <state id="off">
+    <onentry><log expr="'off'"/></onentry>
+    <transition event="power.on switch" target="on" cond="ready"/>
+    <transition event="error.*" target="broken"/>
+  </state>
+  <state id="on">
+    <state id="dim"/>
+    <transition event="tick"/>
+    <transition event="power.off" target="off" cond="count &gt; 3"/>
+  </state>
+  <final id="broken"/>
+</scxml>`
+
+	model, report, err := Import([]byte(doc))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	universe := model.Universes["light"]
+	if universe == nil || len(universe.Realities) != 3 {
+		t.Fatalf("expected a single universe with 3 realities, got %+v", model.Universes)
+	}
+	if universe.Initial == nil || *universe.Initial != "off" {
+		t.Fatalf("expected initial 'off', got %v", universe.Initial)
+	}
+	if !reflect.DeepEqual(model.Initials, []string{"U:light"}) {
+		t.Fatalf("unexpected initials %v", model.Initials)
+	}
+
+	off := universe.Realities["off"]
+	if len(off.On["power_on"]) != 1 || len(off.On["switch"]) != 1 {
+		t.Fatalf("expected one transition per event, got %v", off.On)
+	}
+	if off.On["switch"][0].Condition == nil || off.On["switch"][0].Condition.Src != "ready" {
+		t.Fatalf("expected condition 'ready', got %+v", off.On["switch"][0])
+	}
+	if universe.Realities["broken"].Type != theoretical.RealityTypeFinal {
+		t.Fatal("expected final reality 'broken'")
+	}
+
+	kinds := converttest.LossKinds(report)
+	for _, kind := range []LossKind{LossDataModel, LossExecutableContent, LossEvent, LossHierarchy, LossTargetlessTransition, LossCondition} {
+		if kinds[kind] == 0 {
+			t.Fatalf("expected a %s loss, got %v", kind, report.Losses)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	if _, _, err := Export(nil); err == nil {
+		t.Fatal("expected error for nil model")
+	}
+	if _, _, err := Import([]byte("<machine/>")); err == nil {
+		t.Fatal("expected error for non scxml root")
+	}
+	if _, _, err := Import([]byte("<scxml")); err == nil {
+		t.Fatal("expected error for malformed document")
+	}
+}
+
+func TestExportParallelIDIsUnique(t *testing.T) {
+	model := converttest.Model(t)
+	model.ID = "main"
+	out, _, err := Export(model)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(string(out), `<parallel id="main_2">`) || strings.Count(string(out), `id="main"`) != 1 {
+		t.Fatalf("expected the parallel id not to repeat the universe id:\n%s", out)
+	}
+}
+
+func TestImportDuplicateRegionIDs(t *testing.T) {
+	doc := `<?xml version="1.0"?>
+<scxml xmlns="http://www.w3.org/2005/07/scxml" version="1.0" name="m">
+  <parallel id="p">
+    <state id="a.b"/>
+    <state id="a-b"><transition event="x y" target="a.b"><log expr="1"/></transition></state>
+    <state id="a_b"/>
+  </parallel>
+</scxml>`
+
+	model, _, err := Import([]byte(doc))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(model.Universes) != 3 || model.Universes["a_b"] == nil || model.Universes["a_b_2"] == nil {
+		t.Fatalf("expected one universe per region, got %v", util.SortedKeys(model.Universes))
+	}
+
+	// the transition of every event is a copy of its own
+	var on map[string][]*theoretical.TransitionModel
+	for _, universe := range model.Universes {
+		for _, reality := range universe.Realities {
+			if len(reality.On) > 0 {
+				on = reality.On
+			}
+		}
+	}
+	x, y := on["x"][0], on["y"][0]
+	x.Targets[0] = "changed"
+	if y.Targets[0] == "changed" {
+		t.Fatal("expected the transitions of each event not to share their targets")
+	}
+}
+
+func TestImportNullConditions(t *testing.T) {
+	doc := `<?xml version="1.0"?>
+<scxml xmlns="http://www.w3.org/2005/07/scxml" xmlns:statepro="` + StateproNamespace + `" version="1.0" name="m">
+  <state id="s"><transition event="a b" target="s" statepro:conditions='[null,{"src":"c"}]'/></state>
+</scxml>`
+
+	if _, _, err := Import([]byte(doc)); err == nil || !strings.Contains(err.Error(), "null condition") {
+		t.Fatalf("expected a null condition error, got %v", err)
+	}
+}
+
+func TestExportConditionsAndArgs(t *testing.T) {
+	model := converttest.Model(t)
+	transition := model.Universes["main"].Realities["A"].On["go"][0]
+	transition.Conditions = append([]*theoretical.ConditionModel{nil}, transition.Conditions...)
+
+	out, _, err := Export(model)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(string(out), "null") {
+		t.Fatalf("expected nil conditions to be dropped:\n%s", out)
+	}
+
+	transition.Actions[0].Args = map[string]any{"fn": func() {}}
+	if _, _, err = Export(model); err == nil {
+		t.Fatal("expected an error for args that cannot be marshaled")
+	}
+}
@@ -1,8 +1,11 @@
 package statepro
 
 import (
+	"bytes"
 	"encoding/json"
+
 	"github.com/rendis/statepro/v3/theoretical"
+	"gopkg.in/yaml.v3"
 )
 
 // ----- theoretical.QuantumMachineModel Serializers/Deserializers -----
@@ -34,6 +37,20 @@ func DeserializeQuantumMachineFromBinary(b []byte) (*theoretical.QuantumMachineM
 	return &resp, nil
 }
 
+// DeserializeQuantumMachineFromYAML decodes a YAML definition. Values get the same types as with JSON input.
+func DeserializeQuantumMachineFromYAML(b []byte) (*theoretical.QuantumMachineModel, error) {
+	doc, err := parseYAMLDefinition(b)
+	if err != nil {
+		return nil, err
+	}
+
+	jsonStr, err := json.Marshal(doc.payload)
+	if err != nil {
+		return nil, err
+	}
+	return DeserializeQuantumMachineFromBinary(jsonStr)
+}
+
 func SerializeQuantumMachineToMap(source *theoretical.QuantumMachineModel) (map[string]any, error) {
 	if source == nil {
 		return nil, nil
@@ -58,3 +75,34 @@ func SerializeQuantumMachineToBinary(source *theoretical.QuantumMachineModel) ([
 	}
 	return json.Marshal(source)
 }
+
+// SerializeQuantumMachineToYAML encodes the definition as YAML with 2-space indentation.
+// The document holds the same values, in the same order, as SerializeQuantumMachineToBinary.
+func SerializeQuantumMachineToYAML(source *theoretical.QuantumMachineModel) ([]byte, error) {
+	if source == nil {
+		return nil, nil
+	}
+
+	jsonStr, err := json.Marshal(source)
+	if err != nil {
+		return nil, err
+	}
+
+	// JSON is valid YAML: decoding it into a node keeps the field order and the null values
+	var root yaml.Node
+	if err = yaml.Unmarshal(jsonStr, &root); err != nil {
+		return nil, err
+	}
+	resetYAMLStyle(&root)
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err = encoder.Encode(&root); err != nil {
+		return nil, err
+	}
+	if err = encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -6,12 +6,12 @@ import (
 	"github.com/rendis/statepro/v3/theoretical"
 )
 
-func NewQuantumMachine(qmModel *theoretical.QuantumMachineModel) (instrumentation.QuantumMachine, error) {
+func NewQuantumMachine(qmModel *theoretical.QuantumMachineModel, opts ...experimental.MachineOption) (instrumentation.QuantumMachine, error) {
 	var universes []*experimental.ExUniverse
 	for _, model := range qmModel.Universes {
 		universes = append(universes, experimental.NewExUniverse(model))
 	}
-	return experimental.NewExQuantumMachine(qmModel, universes)
+	return experimental.NewExQuantumMachine(qmModel, universes, opts...)
 }
 
 func NewEventBuilder(eventName string) instrumentation.EventBuilder {
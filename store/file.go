@@ -0,0 +1,260 @@
+package store
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rendis/statepro/v3/codec"
+	"github.com/rendis/statepro/v3/instrumentation"
+	"github.com/rendis/statepro/v3/internal/filelock"
+)
+
+const (
+	fileExtension  = ".snap"
+	lockExtension  = ".lock"
+	fileHeaderSize = 16
+)
+
+// FileStore is a SnapshotStore keeping one file per instance under <dir>/<machine id>/<instance id>.snap
+// (ids are path-escaped). Files are replaced atomically: a save writes and syncs a temporary file and renames
+// it over the previous one, so readers never observe a partial snapshot.
+//
+// Revisions are checked under an exclusive lock on <instance id>.snap.lock, held across processes, so several
+// FileStores (in one or more processes) can share a directory. Lock files are left in place after a Delete.
+type FileStore struct {
+	dir  string
+	opts *options
+}
+
+// NewFileStore creates a store in dir, creating the directory if needed.
+func NewFileStore(dir string, opts ...Option) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating store directory: %w", err)
+	}
+	return &FileStore{dir: dir, opts: newOptions(opts)}, nil
+}
+
+func (f *FileStore) Load(ctx context.Context, key Key) (*Record, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	path, err := f.path(key)
+	if err != nil {
+		return nil, err
+	}
+	revision, updatedAt, payload, err := readRecordFile(key, path)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot, err := codec.Decode(payload)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding snapshot of '%s': %w", key, err)
+	}
+	return &Record{Key: key, Revision: revision, Snapshot: snapshot, UpdatedAt: updatedAt}, nil
+}
+
+func (f *FileStore) Save(
+	ctx context.Context,
+	key Key,
+	snapshot *instrumentation.MachineSnapshot,
+	expectedRevision uint64,
+) (uint64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	path, err := f.path(key)
+	if err != nil {
+		return 0, err
+	}
+	payload, err := codec.Encode(snapshot, f.opts.codecOpts...)
+	if err != nil {
+		return 0, err
+	}
+
+	unlock, err := lockRecordFile(key, path)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+	current, err := f.revision(key, path)
+	if err != nil {
+		return 0, err
+	}
+	if current != expectedRevision {
+		return 0, conflictError(key, expectedRevision, current)
+	}
+
+	data := make([]byte, fileHeaderSize, fileHeaderSize+len(payload))
+	binary.BigEndian.PutUint64(data[0:8], current+1)
+	binary.BigEndian.PutUint64(data[8:16], uint64(f.opts.now().UnixNano()))
+	data = append(data, payload...)
+	if err = writeFileAtomic(path, data); err != nil {
+		return 0, fmt.Errorf("error saving snapshot of '%s': %w", key, err)
+	}
+	return current + 1, nil
+}
+
+func (f *FileStore) Delete(ctx context.Context, key Key, expectedRevision uint64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	path, err := f.path(key)
+	if err != nil {
+		return err
+	}
+
+	unlock, err := lockRecordFile(key, path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	current, err := f.revision(key, path)
+	if err != nil {
+		return err
+	}
+	if current == NoRevision {
+		return notFoundError(key)
+	}
+	if current != expectedRevision {
+		return conflictError(key, expectedRevision, current)
+	}
+	if err = os.Remove(path); err != nil {
+		return fmt.Errorf("error deleting snapshot of '%s': %w", key, err)
+	}
+	return nil
+}
+
+func (f *FileStore) List(ctx context.Context, machineID string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	dir, err := f.machineDir(machineID)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error listing snapshots of machine '%s': %w", machineID, err)
+	}
+
+	ids := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, fileExtension) {
+			continue
+		}
+		id, err := url.PathUnescape(strings.TrimSuffix(name, fileExtension))
+		if err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// revision returns the stored revision of the key, NoRevision when there is no file.
+func (f *FileStore) revision(key Key, path string) (uint64, error) {
+	revision, _, _, err := readRecordFile(key, path)
+	if errors.Is(err, ErrNotFound) {
+		return NoRevision, nil
+	}
+	return revision, err
+}
+
+func (f *FileStore) machineDir(machineID string) (string, error) {
+	if machineID == "" || machineID == "." || machineID == ".." {
+		return "", fmt.Errorf("invalid machine id '%s'", machineID)
+	}
+	return filepath.Join(f.dir, url.PathEscape(machineID)), nil
+}
+
+func (f *FileStore) path(key Key) (string, error) {
+	if err := key.validate(); err != nil {
+		return "", err
+	}
+	dir, err := f.machineDir(key.MachineID)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, url.PathEscape(key.InstanceID)+fileExtension), nil
+}
+
+// lockRecordFile blocks until the calling store holds the lock of the key, across processes.
+func lockRecordFile(key Key, path string) (unlock func(), err error) {
+	if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("error locking snapshot of '%s': %w", key, err)
+	}
+	release, err := filelock.Lock(path + lockExtension)
+	if err != nil {
+		return nil, fmt.Errorf("error locking snapshot of '%s': %w", key, err)
+	}
+	return func() { _ = release() }, nil
+}
+
+func readRecordFile(key Key, path string) (revision uint64, updatedAt time.Time, payload []byte, err error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, time.Time{}, nil, notFoundError(key)
+	}
+	if err != nil {
+		return 0, time.Time{}, nil, fmt.Errorf("error reading snapshot of '%s': %w", key, err)
+	}
+	if len(data) < fileHeaderSize {
+		return 0, time.Time{}, nil, fmt.Errorf("error reading snapshot of '%s': file is truncated", key)
+	}
+	revision = binary.BigEndian.Uint64(data[0:8])
+	updatedAt = time.Unix(0, int64(binary.BigEndian.Uint64(data[8:16])))
+	return revision, updatedAt, data[fileHeaderSize:], nil
+}
+
+// writeFileAtomic replaces path with data through a synced temporary file in the same directory.
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer func() {
+		_ = os.Remove(tmpPath) // no-op after a successful rename
+	}()
+
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+
+	// persist the rename; not every platform supports syncing directories
+	if d, err := os.Open(dir); err == nil {
+		_ = d.Sync()
+		_ = d.Close()
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rendis/statepro/v3/codec"
+	"github.com/rendis/statepro/v3/instrumentation"
+)
+
+type memoryRecord struct {
+	revision  uint64
+	data      []byte
+	updatedAt time.Time
+}
+
+// MemoryStore is a SnapshotStore kept in memory. Snapshots are stored encoded, so records never share state
+// with the machines that saved or loaded them.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	records map[Key]*memoryRecord
+	opts    *options
+}
+
+// NewMemoryStore creates an empty in-memory store.
+func NewMemoryStore(opts ...Option) *MemoryStore {
+	return &MemoryStore{
+		records: map[Key]*memoryRecord{},
+		opts:    newOptions(opts),
+	}
+}
+
+func (m *MemoryStore) Load(ctx context.Context, key Key) (*Record, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	r, ok := m.records[key]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, notFoundError(key)
+	}
+
+	snapshot, err := codec.Decode(r.data)
+	if err != nil {
+		return nil, err
+	}
+	return &Record{Key: key, Revision: r.revision, Snapshot: snapshot, UpdatedAt: r.updatedAt}, nil
+}
+
+func (m *MemoryStore) Save(
+	ctx context.Context,
+	key Key,
+	snapshot *instrumentation.MachineSnapshot,
+	expectedRevision uint64,
+) (uint64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	if err := key.validate(); err != nil {
+		return 0, err
+	}
+	data, err := codec.Encode(snapshot, m.opts.codecOpts...)
+	if err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var current uint64
+	if r, ok := m.records[key]; ok {
+		current = r.revision
+	}
+	if current != expectedRevision {
+		return 0, conflictError(key, expectedRevision, current)
+	}
+	m.records[key] = &memoryRecord{revision: current + 1, data: data, updatedAt: m.opts.now()}
+	return current + 1, nil
+}
+
+func (m *MemoryStore) Delete(ctx context.Context, key Key, expectedRevision uint64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, ok := m.records[key]
+	if !ok {
+		return notFoundError(key)
+	}
+	if r.revision != expectedRevision {
+		return conflictError(key, expectedRevision, r.revision)
+	}
+	delete(m.records, key)
+	return nil
+}
+
+func (m *MemoryStore) List(ctx context.Context, machineID string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	ids := make([]string, 0)
+	for key := range m.records {
+		if key.MachineID == machineID {
+			ids = append(ids, key.InstanceID)
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
@@ -0,0 +1,134 @@
+// Package store persists machine snapshots with optimistic concurrency.
+//
+// A SnapshotStore keeps the latest snapshot of each machine instance together with a revision that increases on
+// every save. Saves state the revision they were based on and fail with ErrConflict when another writer saved in
+// between, so concurrent workers cannot silently overwrite each other's progress. SendEvent wraps the
+// load / send / save cycle. MemoryStore and FileStore are the built-in implementations; snapshots are serialized
+// with the codec package.
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rendis/statepro/v3/codec"
+	"github.com/rendis/statepro/v3/instrumentation"
+)
+
+var (
+	// ErrNotFound is returned when the store has no snapshot for a key.
+	ErrNotFound = errors.New("snapshot not found")
+
+	// ErrConflict is returned when the stored revision differs from the expected one.
+	ErrConflict = errors.New("snapshot revision conflict")
+)
+
+// NoRevision is the expected revision for a key that has not been saved yet.
+const NoRevision uint64 = 0
+
+// Key identifies a machine instance: the id of its machine definition and the id of the instance.
+type Key struct {
+	MachineID  string
+	InstanceID string
+}
+
+func (k Key) String() string {
+	return k.MachineID + "/" + k.InstanceID
+}
+
+func (k Key) validate() error {
+	if k.MachineID == "" || k.InstanceID == "" {
+		return fmt.Errorf("invalid key '%s': machine and instance ids cannot be empty", k)
+	}
+	return nil
+}
+
+// Record is a stored snapshot.
+type Record struct {
+	Key       Key
+	Revision  uint64
+	Snapshot  *instrumentation.MachineSnapshot
+	UpdatedAt time.Time
+}
+
+// SnapshotStore persists the latest snapshot of machine instances. Implementations must be safe for concurrent use.
+type SnapshotStore interface {
+	// Load returns the stored snapshot of the instance, or ErrNotFound.
+	Load(ctx context.Context, key Key) (*Record, error)
+
+	// Save stores the snapshot if the stored revision equals expectedRevision (NoRevision when the instance
+	// has not been saved yet) and returns the new revision. It returns ErrConflict otherwise.
+	Save(ctx context.Context, key Key, snapshot *instrumentation.MachineSnapshot, expectedRevision uint64) (uint64, error)
+
+	// Delete removes the stored snapshot if the stored revision equals expectedRevision.
+	// It returns ErrNotFound when there is nothing stored and ErrConflict when the revision differs.
+	Delete(ctx context.Context, key Key, expectedRevision uint64) error
+
+	// List returns the sorted instance ids stored for the machine definition.
+	List(ctx context.Context, machineID string) ([]string, error)
+}
+
+// Option configures the built-in stores.
+type Option func(*options)
+
+type options struct {
+	codecOpts []codec.Option
+	now       func() time.Time
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{now: time.Now}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithCodecOptions sets the codec options used to encode snapshots (default CBOR without compression).
+func WithCodecOptions(opts ...codec.Option) Option {
+	return func(o *options) {
+		o.codecOpts = opts
+	}
+}
+
+func conflictError(key Key, expected, actual uint64) error {
+	return fmt.Errorf("%w: '%s' is at revision %d, expected %d", ErrConflict, key, actual, expected)
+}
+
+func notFoundError(key Key) error {
+	return fmt.Errorf("%w: '%s'", ErrNotFound, key)
+}
+
+// SendEvent loads the instance snapshot into qm, sends the event and saves the resulting snapshot based on the
+// loaded revision. qm must be a machine built for the instance definition; its previous state is replaced.
+// When another writer saved the instance in the meantime the event is not persisted and ErrConflict is returned,
+// so the caller can retry with a fresh load. Side effects of the actions that ran are not undone.
+func SendEvent(
+	ctx context.Context,
+	s SnapshotStore,
+	key Key,
+	qm instrumentation.QuantumMachine,
+	machineContext any,
+	event instrumentation.Event,
+) (handled bool, revision uint64, err error) {
+	record, err := s.Load(ctx, key)
+	if err != nil {
+		return false, 0, err
+	}
+	if err = qm.LoadSnapshot(record.Snapshot, machineContext); err != nil {
+		return false, record.Revision, fmt.Errorf("error loading snapshot of '%s': %w", key, err)
+	}
+
+	handled, err = qm.SendEvent(ctx, event)
+	if err != nil {
+		return handled, record.Revision, err
+	}
+
+	revision, err = s.Save(ctx, key, qm.GetSnapshot(), record.Revision)
+	if err != nil {
+		return handled, record.Revision, err
+	}
+	return handled, revision, nil
+}
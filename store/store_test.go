@@ -0,0 +1,354 @@
+package store
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rendis/statepro/v3"
+	"github.com/rendis/statepro/v3/builder"
+	"github.com/rendis/statepro/v3/codec"
+	"github.com/rendis/statepro/v3/instrumentation"
+	"github.com/rendis/statepro/v3/internal/filelock"
+	"github.com/rendis/statepro/v3/theoretical"
+)
+
+// storeModel is a single-universe order: tick stays OPEN, close ends it.
+func storeModel() *theoretical.QuantumMachineModel {
+	return builder.New("orders").
+		Initials(builder.Universe("order")).
+		Universe("order", func(u *builder.UniverseBuilder) {
+			u.Initial("OPEN")
+			u.Reality("OPEN", func(r *builder.RealityBuilder) {
+				r.On("tick", "OPEN")
+				r.On("close", "CLOSED")
+			})
+			u.Final("CLOSED", nil)
+		}).
+		MustBuild()
+}
+
+func newMachine(t testing.TB, model *theoretical.QuantumMachineModel) instrumentation.QuantumMachine {
+	t.Helper()
+	qm, err := statepro.NewQuantumMachine(model)
+	if err != nil {
+		t.Fatalf("unexpected machine error: %v", err)
+	}
+	return qm
+}
+
+func initialSnapshot(t testing.TB, model *theoretical.QuantumMachineModel) *instrumentation.MachineSnapshot {
+	t.Helper()
+	qm := newMachine(t, model)
+	if err := qm.Init(context.Background(), nil); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	return qm.GetSnapshot()
+}
+
+func stores(t *testing.T) map[string]SnapshotStore {
+	fileStore, err := NewFileStore(t.TempDir(), WithCodecOptions(codec.WithCompression(codec.Gzip)))
+	if err != nil {
+		t.Fatalf("NewFileStore failed: %v", err)
+	}
+	return map[string]SnapshotStore{
+		"memory": NewMemoryStore(),
+		"file":   fileStore,
+	}
+}
+
+func TestSnapshotStore_Contract(t *testing.T) {
+	ctx := context.Background()
+	snapshot := initialSnapshot(t, storeModel())
+	key := Key{MachineID: "orders", InstanceID: "o-1"}
+
+	for name, s := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			if _, err := s.Load(ctx, key); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("expected ErrNotFound, got %v", err)
+			}
+
+			rev, err := s.Save(ctx, key, snapshot, NoRevision)
+			if err != nil || rev != 1 {
+				t.Fatalf("expected first save at revision 1, got %d (%v)", rev, err)
+			}
+			if _, err = s.Save(ctx, key, snapshot, NoRevision); !errors.Is(err, ErrConflict) {
+				t.Fatalf("expected ErrConflict creating an existing instance, got %v", err)
+			}
+			if rev, err = s.Save(ctx, key, snapshot, 1); err != nil || rev != 2 {
+				t.Fatalf("expected save at revision 2, got %d (%v)", rev, err)
+			}
+			if _, err = s.Save(ctx, key, snapshot, 1); !errors.Is(err, ErrConflict) {
+				t.Fatalf("expected ErrConflict for a stale revision, got %v", err)
+			}
+
+			record, err := s.Load(ctx, key)
+			if err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+			if record.Key != key || record.Revision != 2 || record.UpdatedAt.IsZero() {
+				t.Fatalf("unexpected record %+v", record)
+			}
+			if !reflect.DeepEqual(record.Snapshot.Resume, snapshot.Resume) ||
+				!reflect.DeepEqual(record.Snapshot.Tracking, snapshot.Tracking) {
+				t.Fatalf("loaded snapshot differs: %+v", record.Snapshot)
+			}
+
+			for _, other := range []Key{{"orders", "o-0"}, {"orders", "a/b"}, {"invoices", "o-9"}} {
+				if _, err = s.Save(ctx, other, snapshot, NoRevision); err != nil {
+					t.Fatalf("Save %s failed: %v", other, err)
+				}
+			}
+			ids, err := s.List(ctx, "orders")
+			if err != nil || !reflect.DeepEqual(ids, []string{"a/b", "o-0", "o-1"}) {
+				t.Fatalf("unexpected list %v (%v)", ids, err)
+			}
+			if ids, _ = s.List(ctx, "unknown"); len(ids) != 0 {
+				t.Fatalf("expected no instances, got %v", ids)
+			}
+
+			if err = s.Delete(ctx, key, 1); !errors.Is(err, ErrConflict) {
+				t.Fatalf("expected ErrConflict deleting a stale revision, got %v", err)
+			}
+			if err = s.Delete(ctx, key, 2); err != nil {
+				t.Fatalf("Delete failed: %v", err)
+			}
+			if err = s.Delete(ctx, key, 2); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("expected ErrNotFound, got %v", err)
+			}
+			if rev, err = s.Save(ctx, key, snapshot, NoRevision); err != nil || rev != 1 {
+				t.Fatalf("expected a deleted instance to start over, got %d (%v)", rev, err)
+			}
+
+			if _, err = s.Save(ctx, Key{MachineID: "orders"}, snapshot, NoRevision); err == nil {
+				t.Fatal("expected error for an empty instance id")
+			}
+		})
+	}
+}
+
+func TestMemoryStore_Isolation(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	key := Key{MachineID: "orders", InstanceID: "o-1"}
+	snapshot := initialSnapshot(t, storeModel())
+	if _, err := s.Save(ctx, key, snapshot, NoRevision); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	snapshot.Tracking["order"] = append(snapshot.Tracking["order"], "CHANGED")
+	first, _ := s.Load(ctx, key)
+	first.Snapshot.Tracking["order"] = nil
+	second, _ := s.Load(ctx, key)
+	if !reflect.DeepEqual(second.Snapshot.Tracking["order"], []string{"OPEN"}) {
+		t.Fatalf("expected stored snapshot to be isolated, got %v", second.Snapshot.Tracking)
+	}
+}
+
+func TestFileStore_Files(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	s, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileStore failed: %v", err)
+	}
+	key := Key{MachineID: "orders", InstanceID: "../o-1"}
+	snapshot := initialSnapshot(t, storeModel())
+	for rev := NoRevision; rev < 3; rev++ {
+		if _, err = s.Save(ctx, key, snapshot, rev); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+
+	entries, _ := os.ReadDir(filepath.Join(dir, "orders"))
+	if len(entries) != 2 || entries[0].Name() != "..%2Fo-1.snap" || entries[1].Name() != "..%2Fo-1.snap.lock" {
+		t.Fatalf("expected an escaped snapshot file and its lock without temporary files, got %v", entries)
+	}
+
+	reopened, _ := NewFileStore(dir)
+	record, err := reopened.Load(ctx, key)
+	if err != nil || record.Revision != 3 {
+		t.Fatalf("expected reopened store at revision 3, got %+v (%v)", record, err)
+	}
+
+	if _, err = s.Save(ctx, Key{MachineID: "..", InstanceID: "x"}, snapshot, NoRevision); err == nil {
+		t.Fatal("expected error for machine id '..'")
+	}
+	if err = os.WriteFile(filepath.Join(dir, "orders", "short.snap"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.Load(ctx, Key{MachineID: "orders", InstanceID: "short"}); err == nil || !strings.Contains(err.Error(), "truncated") {
+		t.Fatalf("expected truncated file error, got %v", err)
+	}
+}
+
+func TestFileStore_SharedDirectory(t *testing.T) {
+	// two stores on one directory stand for two processes: revisions must be checked across them
+	ctx := context.Background()
+	dir := t.TempDir()
+	var fileStores [2]*FileStore
+	for i := range fileStores {
+		s, err := NewFileStore(dir)
+		if err != nil {
+			t.Fatalf("NewFileStore failed: %v", err)
+		}
+		fileStores[i] = s
+	}
+	key := Key{MachineID: "orders", InstanceID: "o-1"}
+	snapshot := initialSnapshot(t, storeModel())
+
+	const rounds, writers = 20, 8
+	for rev := NoRevision; rev < rounds; rev++ {
+		var saved atomic.Int32
+		var wg sync.WaitGroup
+		for w := 0; w < writers; w++ {
+			wg.Add(1)
+			go func(s *FileStore) {
+				defer wg.Done()
+				_, err := s.Save(ctx, key, snapshot, rev)
+				switch {
+				case err == nil:
+					saved.Add(1)
+				case !errors.Is(err, ErrConflict):
+					t.Errorf("Save failed: %v", err)
+				}
+			}(fileStores[w%2])
+		}
+		wg.Wait()
+		if n := saved.Load(); n != 1 {
+			t.Fatalf("expected a single save at revision %d, got %d", rev, n)
+		}
+	}
+
+	record, err := fileStores[1].Load(ctx, key)
+	if err != nil || record.Revision != rounds {
+		t.Fatalf("expected revision %d, got %+v (%v)", rounds, record, err)
+	}
+}
+
+func TestFileStore_WaitsForLock(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	first, _ := NewFileStore(dir)
+	second, _ := NewFileStore(dir)
+	key := Key{MachineID: "orders", InstanceID: "o-1"}
+	if _, err := first.Save(ctx, key, initialSnapshot(t, storeModel()), NoRevision); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	path, _ := first.path(key)
+
+	// another process holds the lock while it moves the instance to revision 2
+	unlock, err := filelock.Lock(path + lockExtension)
+	if err != nil {
+		t.Fatalf("Lock failed: %v", err)
+	}
+	record, _ := second.Load(ctx, key)
+	done := make(chan error, 1)
+	go func() {
+		_, err := second.Save(ctx, key, record.Snapshot, record.Revision)
+		done <- err
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	select {
+	case err = <-done:
+		t.Fatalf("expected Save to wait for the lock, got %v", err)
+	default:
+	}
+	data, _ := os.ReadFile(path)
+	binary.BigEndian.PutUint64(data[0:8], 2)
+	if err = writeFileAtomic(path, data); err != nil {
+		t.Fatal(err)
+	}
+	_ = unlock()
+
+	if err = <-done; !errors.Is(err, ErrConflict) {
+		t.Fatalf("expected ErrConflict once the lock is released, got %v", err)
+	}
+}
+
+func TestSendEvent_Conflict(t *testing.T) {
+	ctx := context.Background()
+	model := storeModel()
+	s := NewMemoryStore()
+	key := Key{MachineID: "orders", InstanceID: "o-1"}
+	if _, err := s.Save(ctx, key, initialSnapshot(t, model), NoRevision); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	// a worker that loaded revision 1 saves after another worker advanced the instance
+	stale := newMachine(t, model)
+	record, _ := s.Load(ctx, key)
+	_ = stale.LoadSnapshot(record.Snapshot, nil)
+
+	handled, rev, err := SendEvent(ctx, s, key, newMachine(t, model), nil, statepro.NewEventBuilder("close").Build())
+	if err != nil || !handled || rev != 2 {
+		t.Fatalf("expected close to be saved at revision 2, got %v %d (%v)", handled, rev, err)
+	}
+
+	_, _ = stale.SendEvent(ctx, statepro.NewEventBuilder("tick").Build())
+	if _, err = s.Save(ctx, key, stale.GetSnapshot(), record.Revision); !errors.Is(err, ErrConflict) {
+		t.Fatalf("expected ErrConflict, got %v", err)
+	}
+	latest, _ := s.Load(ctx, key)
+	if latest.Snapshot.Resume.FinalizedUniverses["order"] != "CLOSED" {
+		t.Fatalf("expected the first worker's progress to be kept, got %+v", latest.Snapshot.Resume)
+	}
+
+	if _, _, err = SendEvent(ctx, s, Key{MachineID: "orders", InstanceID: "missing"}, newMachine(t, model), nil,
+		statepro.NewEventBuilder("tick").Build()); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestSendEvent_ConcurrentWorkers(t *testing.T) {
+	ctx := context.Background()
+	model := storeModel()
+	for name, s := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			key := Key{MachineID: "orders", InstanceID: "o-1"}
+			if _, err := s.Save(ctx, key, initialSnapshot(t, model), NoRevision); err != nil {
+				t.Fatalf("Save failed: %v", err)
+			}
+
+			const workers, events = 4, 5
+			var wg sync.WaitGroup
+			for w := 0; w < workers; w++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					qm := newMachine(t, model)
+					for i := 0; i < events; i++ {
+						for {
+							_, _, err := SendEvent(ctx, s, key, qm, nil, statepro.NewEventBuilder("tick").Build())
+							if err == nil {
+								break
+							}
+							if !errors.Is(err, ErrConflict) {
+								t.Errorf("SendEvent failed: %v", err)
+								return
+							}
+						}
+					}
+				}()
+			}
+			wg.Wait()
+
+			record, _ := s.Load(ctx, key)
+			if record.Revision != 1+workers*events {
+				t.Fatalf("expected every event to be saved once, got revision %d", record.Revision)
+			}
+			if tracking := record.Snapshot.Tracking["order"]; len(tracking) != 1+workers*events {
+				t.Fatalf("expected no lost transitions, got tracking %v", tracking)
+			}
+		})
+	}
+}
@@ -0,0 +1,292 @@
+package statepro
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/rendis/statepro/v3/internal/util"
+	"github.com/rendis/statepro/v3/theoretical"
+)
+
+// TemplateParameterType is the type of a template parameter.
+type TemplateParameterType string
+
+const (
+	TemplateParameterString  TemplateParameterType = "string"
+	TemplateParameterNumber  TemplateParameterType = "number"
+	TemplateParameterInteger TemplateParameterType = "integer"
+	TemplateParameterBoolean TemplateParameterType = "boolean"
+)
+
+// TemplateParameter declares a parameter of a MachineTemplate.
+type TemplateParameter struct {
+	Type TemplateParameterType `json:"type"`
+
+	// Default is used when Instantiate gets no value for the parameter. Parameters without default are required.
+	Default any `json:"default,omitempty"`
+
+	Description string `json:"description,omitempty"`
+}
+
+// MachineTemplate is a definition with ${name} placeholders, instantiated into concrete definitions.
+//
+// Placeholders are substituted in every string and object key of Machine: args, targets, ids, event names,
+// srcs and descriptions. A string made only of one placeholder takes the typed value of the parameter
+// (e.g. "${threshold}" becomes the number 3); otherwise the value is formatted into the string
+// ("${product}-approval"). "$${" writes a literal "${".
+type MachineTemplate struct {
+	Parameters map[string]*TemplateParameter `json:"parameters"`
+	Machine    map[string]any                `json:"machine"`
+}
+
+var templateParameterNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ParseMachineTemplate parses a JSON or YAML template and checks its parameters and placeholders.
+func ParseMachineTemplate(source []byte) (*MachineTemplate, error) {
+	doc, err := parseYAMLDefinition(source)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := json.Marshal(doc.payload)
+	if err != nil {
+		return nil, err
+	}
+	t := &MachineTemplate{}
+	if err = json.Unmarshal(b, t); err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+
+	if err = t.check(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// check verifies the parameter declarations and that every placeholder references a declared parameter.
+func (t *MachineTemplate) check() error {
+	if t.Machine == nil {
+		return fmt.Errorf("template machine cannot be nil")
+	}
+
+	for _, name := range util.SortedKeys(t.Parameters) {
+		parameter := t.Parameters[name]
+		if !templateParameterNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid template parameter name '%s'", name)
+		}
+		if parameter == nil {
+			return fmt.Errorf("template parameter '%s' cannot be nil", name)
+		}
+		switch parameter.Type {
+		case TemplateParameterString, TemplateParameterNumber, TemplateParameterInteger, TemplateParameterBoolean:
+		default:
+			return fmt.Errorf("template parameter '%s' has unsupported type '%s'", name, parameter.Type)
+		}
+		if parameter.Default != nil {
+			if _, err := parameter.coerce(parameter.Default); err != nil {
+				return fmt.Errorf("template parameter '%s' default: %w", name, err)
+			}
+		}
+	}
+
+	// substituting every parameter with a marker unique to it reports undefined and malformed placeholders
+	probe := map[string]any{}
+	for name := range t.Parameters {
+		probe[name] = "\x00" + name
+	}
+	_, err := substituteTemplateValue(t.Machine, probe, "")
+	return err
+}
+
+// Instantiate substitutes the values (parameter name to value), filling in defaults, and returns the concrete
+// definition once it passes ValidateQuantumMachineDefinitionFromMap.
+func (t *MachineTemplate) Instantiate(values map[string]any) (*theoretical.QuantumMachineModel, error) {
+	if err := t.check(); err != nil {
+		return nil, err
+	}
+
+	for name := range values {
+		if _, ok := t.Parameters[name]; !ok {
+			return nil, fmt.Errorf("unknown template parameter '%s'", name)
+		}
+	}
+
+	resolved := map[string]any{}
+	for _, name := range util.SortedKeys(t.Parameters) {
+		parameter := t.Parameters[name]
+		value, ok := values[name]
+		if !ok || value == nil {
+			if parameter.Default == nil {
+				return nil, fmt.Errorf("missing value for template parameter '%s'", name)
+			}
+			value = parameter.Default
+		}
+		coerced, err := parameter.coerce(value)
+		if err != nil {
+			return nil, fmt.Errorf("template parameter '%s': %w", name, err)
+		}
+		resolved[name] = coerced
+	}
+
+	machine, err := substituteTemplateValue(t.Machine, resolved, "")
+	if err != nil {
+		return nil, err
+	}
+
+	source := machine.(map[string]any)
+	if err = ValidateQuantumMachineDefinitionFromMap(source); err != nil {
+		return nil, err
+	}
+	return DeserializeQuantumMachineFromMap(source)
+}
+
+// coerce checks value against the parameter type and normalizes numbers to float64.
+func (p *TemplateParameter) coerce(value any) (any, error) {
+	switch p.Type {
+	case TemplateParameterString:
+		if s, ok := value.(string); ok {
+			return s, nil
+		}
+	case TemplateParameterBoolean:
+		if b, ok := value.(bool); ok {
+			return b, nil
+		}
+	case TemplateParameterNumber, TemplateParameterInteger:
+		n, ok := toFloat(value)
+		if !ok {
+			break
+		}
+		if p.Type == TemplateParameterInteger && n != math.Trunc(n) {
+			return nil, fmt.Errorf("expected integer, got %v", value)
+		}
+		return n, nil
+	}
+	return nil, fmt.Errorf("expected %s, got %T", p.Type, value)
+}
+
+func toFloat(value any) (float64, bool) {
+	switch n := value.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// substituteTemplateValue returns a copy of value with the placeholders substituted; pointer locates value in errors.
+func substituteTemplateValue(value any, values map[string]any, pointer string) (any, error) {
+	switch v := value.(type) {
+	case string:
+		return substituteTemplateString(v, values, pointer)
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for _, key := range util.SortedKeys(v) {
+			substitutedKey, err := substituteTemplateString(key, values, pointer+util.JSONPointer(key))
+			if err != nil {
+				return nil, err
+			}
+			newKey := formatTemplateValue(substitutedKey)
+			if _, exists := out[newKey]; exists {
+				return nil, fmt.Errorf("template %s: key '%s' is defined more than once after substitution", displayPointer(pointer), newKey)
+			}
+			child, err := substituteTemplateValue(v[key], values, pointer+util.JSONPointer(key))
+			if err != nil {
+				return nil, err
+			}
+			out[newKey] = child
+		}
+		return out, nil
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			child, err := substituteTemplateValue(item, values, pointer+"/"+strconv.Itoa(i))
+			if err != nil {
+				return nil, err
+			}
+			out[i] = child
+		}
+		return out, nil
+	default:
+		return value, nil
+	}
+}
+
+// substituteTemplateString substitutes the placeholders of s. A string made only of one placeholder gets the typed value.
+func substituteTemplateString(s string, values map[string]any, pointer string) (any, error) {
+	if !strings.Contains(s, "$") {
+		return s, nil
+	}
+
+	var sb strings.Builder
+	var whole any
+	placeholders := 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case strings.HasPrefix(s[i:], "$${"):
+			sb.WriteString("${")
+			i += 2
+		case strings.HasPrefix(s[i:], "${"):
+			end := strings.IndexByte(s[i:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("template %s: unterminated placeholder in '%s'", displayPointer(pointer), s)
+			}
+			name := s[i+2 : i+end]
+			value, ok := values[name]
+			if !ok {
+				return nil, fmt.Errorf("template %s: undefined parameter '%s'", displayPointer(pointer), name)
+			}
+			if i == 0 && end == len(s)-1 {
+				whole = value
+			}
+			placeholders++
+			sb.WriteString(formatTemplateValue(value))
+			i += end
+		default:
+			sb.WriteByte(s[i])
+		}
+	}
+
+	if placeholders == 1 && whole != nil {
+		return whole, nil
+	}
+	return sb.String(), nil
+}
+
+func formatTemplateValue(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func displayPointer(pointer string) string {
+	if pointer == "" {
+		return "'/'"
+	}
+	return "'" + pointer + "'"
+}
@@ -0,0 +1,140 @@
+package statepro
+
+import (
+	"strings"
+	"testing"
+)
+
+const approvalTemplate = `parameters:
+  product:
+    type: string
+  threshold:
+    type: integer
+    default: 3
+  approveEvent:
+    type: string
+    default: approve
+  notify:
+    type: boolean
+    default: false
+machine:
+  id: ${product}-approval
+  canonicalName: ${product}-approval
+  version: 1.0.0
+  initials: [U:approval]
+  universes:
+    approval:
+      id: approval
+      canonicalName: approval
+      version: 1.0.0
+      initial: PENDING
+      realities:
+        PENDING:
+          id: PENDING
+          type: transition
+          description: Waiting for ${threshold} ${product} approvals ($${not a placeholder})
+          "on":
+            ${approveEvent}:
+              - targets: [APPROVED]
+                actions:
+                  - src: action:${product}:record
+                    args:
+                      threshold: ${threshold}
+                      notify: ${notify}
+        APPROVED:
+          id: APPROVED
+          type: final
+`
+
+func TestMachineTemplateInstantiate(t *testing.T) {
+	tmpl, err := ParseMachineTemplate([]byte(approvalTemplate))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	model, err := tmpl.Instantiate(map[string]any{"product": "loans", "approveEvent": "sign", "threshold": 5})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if model.ID != "loans-approval" {
+		t.Fatalf("expected interpolated id, got %s", model.ID)
+	}
+	pending := model.Universes["approval"].Realities["PENDING"]
+	if *pending.Description != "Waiting for 5 loans approvals (${not a placeholder})" {
+		t.Fatalf("unexpected description %q", *pending.Description)
+	}
+	transitions, ok := pending.On["sign"]
+	if !ok {
+		t.Fatalf("expected substituted event name, got %v", pending.On)
+	}
+	action := transitions[0].Actions[0]
+	if action.Src != "action:loans:record" {
+		t.Fatalf("unexpected src %s", action.Src)
+	}
+	if action.Args["threshold"] != float64(5) || action.Args["notify"] != false {
+		t.Fatalf("expected typed args, got %v", action.Args)
+	}
+}
+
+func TestMachineTemplateErrors(t *testing.T) {
+	tmpl, err := ParseMachineTemplate([]byte(approvalTemplate))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cases := map[string]struct {
+		values   map[string]any
+		expected string
+	}{
+		"missing required":  {values: map[string]any{}, expected: "missing value for template parameter 'product'"},
+		"unknown parameter": {values: map[string]any{"product": "loans", "other": 1}, expected: "unknown template parameter 'other'"},
+		"wrong type":        {values: map[string]any{"product": 1}, expected: "expected string"},
+		"not an integer":    {values: map[string]any{"product": "loans", "threshold": 2.5}, expected: "expected integer"},
+		"invalid result":    {values: map[string]any{"product": "-loans"}, expected: "/id"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := tmpl.Instantiate(tc.values)
+			if err == nil || !strings.Contains(err.Error(), tc.expected) {
+				t.Fatalf("expected error containing %q, got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestParseMachineTemplateErrors(t *testing.T) {
+	cases := map[string]struct {
+		source   string
+		expected string
+	}{
+		"undefined parameter": {
+			source:   `{"parameters": {}, "machine": {"id": "${product}"}}`,
+			expected: "template '/id': undefined parameter 'product'",
+		},
+		"unterminated placeholder": {
+			source:   `{"parameters": {"a": {"type": "string"}}, "machine": {"id": "${a"}}`,
+			expected: "unterminated placeholder",
+		},
+		"unsupported type": {
+			source:   `{"parameters": {"a": {"type": "list"}}, "machine": {}}`,
+			expected: "unsupported type 'list'",
+		},
+		"default of wrong type": {
+			source:   `{"parameters": {"a": {"type": "boolean", "default": "yes"}}, "machine": {}}`,
+			expected: "template parameter 'a' default: expected boolean",
+		},
+		"missing machine": {
+			source:   `{"parameters": {}}`,
+			expected: "template machine cannot be nil",
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseMachineTemplate([]byte(tc.source))
+			if err == nil || !strings.Contains(err.Error(), tc.expected) {
+				t.Fatalf("expected error containing %q, got %v", tc.expected, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package theoretical
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// FingerprintPrefix prefixes every fingerprint with the hash algorithm it was computed with.
+const FingerprintPrefix = "sha256:"
+
+// Fingerprint returns a deterministic content hash of the machine definition, in the form "sha256:<hex>".
+// Two models with the same JSON serialization (map keys are sorted) have the same fingerprint, whatever
+// their Version, so a changed definition published under an unchanged version is still told apart.
+// An error is returned when the model cannot be serialized to JSON (e.g. metadata holding functions).
+func (qm *QuantumMachineModel) Fingerprint() (string, error) {
+	return fingerprint(qm)
+}
+
+// Fingerprint returns a deterministic content hash of the universe definition, see QuantumMachineModel.Fingerprint.
+func (u *UniverseModel) Fingerprint() (string, error) {
+	return fingerprint(u)
+}
+
+func fingerprint(model any) (string, error) {
+	b, err := json.Marshal(model)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return FingerprintPrefix + hex.EncodeToString(sum[:]), nil
+}
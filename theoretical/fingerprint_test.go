@@ -0,0 +1,58 @@
+package theoretical_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rendis/statepro/v3/theoretical"
+)
+
+func fingerprintModel() *theoretical.QuantumMachineModel {
+	initial := "A"
+	return &theoretical.QuantumMachineModel{
+		ID: "qm", CanonicalName: "qm", Version: "1.0.0", Initials: []string{"U:u1"},
+		Universes: map[string]*theoretical.UniverseModel{
+			"u1": {ID: "u1", CanonicalName: "u1", Version: "1.0.0", Initial: &initial,
+				Realities: map[string]*theoretical.RealityModel{
+					"A": {ID: "A", Type: theoretical.RealityTypeTransition},
+					"B": {ID: "B", Type: theoretical.RealityTypeFinal},
+				}},
+		},
+		Metadata: map[string]any{"b": 1, "a": []any{"x", 2.5}},
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	first, err := fingerprintModel().Fingerprint()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(first, theoretical.FingerprintPrefix) || len(first) != len(theoretical.FingerprintPrefix)+64 {
+		t.Fatalf("unexpected fingerprint format %q", first)
+	}
+	for i := 0; i < 10; i++ {
+		if again, _ := fingerprintModel().Fingerprint(); again != first {
+			t.Fatalf("expected a deterministic fingerprint, got %q and %q", first, again)
+		}
+	}
+
+	// same version, different content
+	changed := fingerprintModel()
+	changed.Universes["u1"].Realities["B"].Type = theoretical.RealityTypeUnsuccessfulFinal
+	if fp, _ := changed.Fingerprint(); fp == first {
+		t.Fatal("expected a changed definition to change the machine fingerprint")
+	}
+	before, _ := fingerprintModel().Universes["u1"].Fingerprint()
+	after, _ := changed.Universes["u1"].Fingerprint()
+	if before == after {
+		t.Fatal("expected a changed universe to change its fingerprint")
+	}
+}
+
+func TestFingerprint_Unserializable(t *testing.T) {
+	model := fingerprintModel()
+	model.Metadata["fn"] = func() {}
+	if _, err := model.Fingerprint(); err == nil {
+		t.Fatal("expected error for a model that cannot be serialized")
+	}
+}
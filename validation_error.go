@@ -0,0 +1,205 @@
+package statepro
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+
+	"github.com/rendis/statepro/v3/internal/util"
+)
+
+// ValidationSeverity is the severity of a ValidationIssue.
+type ValidationSeverity string
+
+const (
+	// ValidationSeverityError marks an issue that makes the definition invalid.
+	ValidationSeverityError ValidationSeverity = "error"
+
+	// ValidationSeverityWarning marks a suspicious construct that does not make the definition invalid.
+	ValidationSeverityWarning ValidationSeverity = "warning"
+)
+
+// ValidationIssueCode is a stable, machine readable identifier of the kind of issue found.
+type ValidationIssueCode string
+
+const (
+	IssueSchemaViolation              ValidationIssueCode = "schema_violation"
+	IssueMissingUniverses             ValidationIssueCode = "missing_universes"
+	IssueNilUniverse                  ValidationIssueCode = "nil_universe"
+	IssueUniverseIDMismatch           ValidationIssueCode = "universe_id_mismatch"
+	IssueMissingRealities             ValidationIssueCode = "missing_realities"
+	IssueNilReality                   ValidationIssueCode = "nil_reality"
+	IssueRealityIDMismatch            ValidationIssueCode = "reality_id_mismatch"
+	IssueTransitionRealityWithoutFlow ValidationIssueCode = "transition_reality_without_flow"
+	IssueUnknownInitialReality        ValidationIssueCode = "unknown_initial_reality"
+	IssueInvalidReference             ValidationIssueCode = "invalid_reference"
+	IssueInternalInitialReference     ValidationIssueCode = "internal_initial_reference"
+	IssueUnknownUniverse              ValidationIssueCode = "unknown_universe"
+	IssueUnknownReality               ValidationIssueCode = "unknown_reality"
+	IssueNilTransition                ValidationIssueCode = "nil_transition"
+	IssueMissingTargets               ValidationIssueCode = "missing_targets"
+	IssueNotifyInternalTarget         ValidationIssueCode = "notify_internal_target"
+	IssueInvalidExecutorArgs          ValidationIssueCode = "invalid_executor_args"
+	IssueUnregisteredExecutor         ValidationIssueCode = "unregistered_executor"
+	IssueExecutorKindMismatch         ValidationIssueCode = "executor_kind_mismatch"
+	IssueEmptyExecutorSrc             ValidationIssueCode = "empty_executor_src"
+)
+
+// ValidationIssue is a single problem found while validating a definition.
+type ValidationIssue struct {
+	// Code identifies the kind of issue.
+	Code ValidationIssueCode `json:"code"`
+
+	// Severity is the severity of the issue.
+	Severity ValidationSeverity `json:"severity"`
+
+	// Path is the JSON Pointer (RFC 6901) of the offending value,
+	// e.g. /universes/u1/realities/r2/on/evt/0/targets/1.
+	Path string `json:"path"`
+
+	// Message is a human readable description of the issue.
+	Message string `json:"message"`
+
+	// Line and Column locate the offending value (1-based) when the definition was validated from YAML.
+	Line   int `json:"line,omitempty"`
+	Column int `json:"column,omitempty"`
+
+	// File and FilePointer locate the offending value in the file that supplied it when the definition was
+	// loaded with LoadQuantumMachine / LoadQuantumMachineFS.
+	File        string `json:"file,omitempty"`
+	FilePointer string `json:"filePointer,omitempty"`
+}
+
+func (i ValidationIssue) String() string {
+	switch {
+	case i.File != "" && i.Line > 0:
+		return fmt.Sprintf("%s:%d:%d: %s", i.File, i.Line, i.Column, i.Message)
+	case i.File != "":
+		return fmt.Sprintf("%s#%s: %s", i.File, i.FilePointer, i.Message)
+	case i.Line > 0:
+		return fmt.Sprintf("line %d, column %d: %s", i.Line, i.Column, i.Message)
+	default:
+		return i.Message
+	}
+}
+
+// ValidationError is returned by the validation functions and lists every issue found.
+// Use errors.As to retrieve it from a wrapped validation error.
+type ValidationError struct {
+	Issues []ValidationIssue `json:"issues"`
+}
+
+func (v *ValidationError) Error() string {
+	if v == nil || len(v.Issues) == 0 {
+		return ""
+	}
+
+	messages := make([]string, 0, len(v.Issues))
+	for _, issue := range v.Issues {
+		messages = append(messages, issue.String())
+	}
+	return strings.Join(messages, "; ")
+}
+
+// HasErrors returns true if at least one issue has error severity.
+func (v *ValidationError) HasErrors() bool {
+	if v == nil {
+		return false
+	}
+	for _, issue := range v.Issues {
+		if issue.Severity == ValidationSeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// AsValidationError returns the ValidationError wrapped in err, if any.
+func AsValidationError(err error) (*ValidationError, bool) {
+	var v *ValidationError
+	if errors.As(err, &v) {
+		return v, true
+	}
+	return nil, false
+}
+
+// issueAnnotator completes issues with where the offending value comes from (YAML position, source file).
+type issueAnnotator func(issue *ValidationIssue)
+
+// apply annotates the issues of the ValidationError wrapped in err. It must run before err is wrapped with
+// fmt.Errorf, which formats the message eagerly.
+func (a issueAnnotator) apply(err error) error {
+	if validationErr, ok := AsValidationError(err); ok && a != nil {
+		for i := range validationErr.Issues {
+			a(&validationErr.Issues[i])
+		}
+	}
+	return err
+}
+
+// validationIssueCollector accumulates issues while walking a definition.
+type validationIssueCollector struct {
+	issues []ValidationIssue
+}
+
+func (c *validationIssueCollector) add(code ValidationIssueCode, path string, format string, args ...any) {
+	c.addWithSeverity(ValidationSeverityError, code, path, format, args...)
+}
+
+func (c *validationIssueCollector) addWithSeverity(severity ValidationSeverity, code ValidationIssueCode, path string, format string, args ...any) {
+	c.issues = append(c.issues, ValidationIssue{
+		Code:     code,
+		Severity: severity,
+		Path:     path,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (c *validationIssueCollector) hasErrors() bool {
+	return len(c.issues) > 0
+}
+
+// err returns the collected issues as a *ValidationError, or nil when there are none.
+func (c *validationIssueCollector) err() error {
+	if !c.hasErrors() {
+		return nil
+	}
+	return &ValidationError{Issues: c.issues}
+}
+
+var schemaMessagePrinter = message.NewPrinter(language.English)
+
+// schemaValidationError flattens a JSON Schema validation error into one issue per failing leaf.
+func schemaValidationError(err error) error {
+	var schemaErr *jsonschema.ValidationError
+	if !errors.As(err, &schemaErr) {
+		return err
+	}
+
+	collector := &validationIssueCollector{}
+	collectSchemaIssues(collector, schemaErr)
+	if !collector.hasErrors() {
+		collector.add(IssueSchemaViolation, "", "%s", schemaErr.Error())
+	}
+	return collector.err()
+}
+
+func collectSchemaIssues(collector *validationIssueCollector, schemaErr *jsonschema.ValidationError) {
+	if len(schemaErr.Causes) > 0 {
+		for _, cause := range schemaErr.Causes {
+			collectSchemaIssues(collector, cause)
+		}
+		return
+	}
+
+	path := util.JSONPointer(schemaErr.InstanceLocation...)
+	location := path
+	if location == "" {
+		location = "/"
+	}
+	collector.add(IssueSchemaViolation, path, "at '%s': %s", location, schemaErr.ErrorKind.LocalizedString(schemaMessagePrinter))
+}
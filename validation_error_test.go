@@ -0,0 +1,142 @@
+package statepro
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateQuantumMachineDefinitionFromBinary_StructuredSemanticIssues(t *testing.T) {
+	payload := `{
+		"id":"machine",
+		"canonicalName":"machine",
+		"version":"1.0.0",
+		"initials":["U:u1", "U:ghost"],
+		"universes":{
+			"u1":{
+				"id":"u1",
+				"canonicalName":"u1",
+				"version":"1.0.0",
+				"initial":"r1",
+				"realities":{
+					"r1":{"id":"r1","type":"transition","always":[{"targets":["r2"]}]},
+					"r2":{"id":"r2","type":"transition","on":{"evt":[{"targets":["r1","U:u1:missing"]}]}}
+				}
+			}
+		}
+	}`
+
+	err := ValidateQuantumMachineDefinitionFromBinary([]byte(payload))
+	if err == nil {
+		t.Fatal("expected semantic validation error")
+	}
+
+	if !strings.HasPrefix(err.Error(), "semantic validation failed: ") {
+		t.Fatalf("unexpected error prefix: %s", err)
+	}
+
+	vErr, ok := AsValidationError(err)
+	if !ok {
+		t.Fatalf("expected *ValidationError, got %T", err)
+	}
+
+	want := map[string]ValidationIssueCode{
+		"/initials/1": IssueUnknownUniverse,
+		"/universes/u1/realities/r2/on/evt/0/targets/1": IssueUnknownReality,
+	}
+	if len(vErr.Issues) != len(want) {
+		t.Fatalf("expected %d issues, got %+v", len(want), vErr.Issues)
+	}
+	for _, issue := range vErr.Issues {
+		code, ok := want[issue.Path]
+		if !ok {
+			t.Fatalf("unexpected issue path %q (%+v)", issue.Path, issue)
+		}
+		if issue.Code != code {
+			t.Fatalf("issue at %q: expected code %q, got %q", issue.Path, code, issue.Code)
+		}
+		if issue.Severity != ValidationSeverityError {
+			t.Fatalf("issue at %q: expected error severity, got %q", issue.Path, issue.Severity)
+		}
+	}
+	if !vErr.HasErrors() {
+		t.Fatal("expected HasErrors to be true")
+	}
+}
+
+func TestValidateQuantumMachineBySchemaFromBinary_StructuredIssues(t *testing.T) {
+	payload := `{
+		"id":"machine",
+		"canonicalName":"machine",
+		"version":"1.0.0",
+		"initials":["U:u1"],
+		"universes":{
+			"u1":{
+				"id":"u1",
+				"version":"1.0.0",
+				"realities":{"r1":{"id":"r1","type":"final"}}
+			}
+		}
+	}`
+
+	err := ValidateQuantumMachineBySchemaFromBinary([]byte(payload))
+	if err == nil {
+		t.Fatal("expected schema validation error")
+	}
+	if !strings.Contains(err.Error(), "json schema validation failed") {
+		t.Fatalf("unexpected error message: %s", err)
+	}
+
+	var vErr *ValidationError
+	if !errors.As(err, &vErr) {
+		t.Fatalf("expected *ValidationError, got %T", err)
+	}
+
+	found := false
+	for _, issue := range vErr.Issues {
+		if issue.Code != IssueSchemaViolation {
+			t.Fatalf("unexpected code %q", issue.Code)
+		}
+		if issue.Path == "/universes/u1" && strings.Contains(issue.Message, "canonicalName") {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected missing canonicalName issue at /universes/u1, got %+v", vErr.Issues)
+	}
+}
+
+func TestValidationError_JSON(t *testing.T) {
+	vErr := &ValidationError{Issues: []ValidationIssue{{
+		Code:     IssueMissingTargets,
+		Severity: ValidationSeverityError,
+		Path:     "/universes/u1/realities/r1/always/0/targets",
+		Message:  "must define at least one target",
+	}}}
+
+	b, err := json.Marshal(vErr)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := `{"issues":[{"code":"missing_targets","severity":"error","path":"/universes/u1/realities/r1/always/0/targets","message":"must define at least one target"}]}`
+	if string(b) != expected {
+		t.Fatalf("unexpected JSON:\n got: %s\nwant: %s", b, expected)
+	}
+}
+
+func TestValidationError_EmptyAndWarnings(t *testing.T) {
+	var nilErr *ValidationError
+	if nilErr.Error() != "" || nilErr.HasErrors() {
+		t.Fatal("nil ValidationError must be empty")
+	}
+
+	warnOnly := &ValidationError{Issues: []ValidationIssue{{Severity: ValidationSeverityWarning, Message: "w"}}}
+	if warnOnly.HasErrors() {
+		t.Fatal("warnings must not count as errors")
+	}
+
+	if _, ok := AsValidationError(errors.New("plain")); ok {
+		t.Fatal("plain errors must not be reported as ValidationError")
+	}
+}
@@ -0,0 +1,225 @@
+package xstate
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rendis/statepro/v3/internal/convert"
+	"github.com/rendis/statepro/v3/internal/util"
+	"github.com/rendis/statepro/v3/theoretical"
+)
+
+// Export converts the model to an XState v5 machine config: a parallel root state with one region per
+// universe. Targets in another universe are written as "#<machine>.<universe>[.<reality>]".
+func Export(model *theoretical.QuantumMachineModel) ([]byte, *Report, error) {
+	if model == nil {
+		return nil, nil, fmt.Errorf("source model cannot be nil")
+	}
+
+	report := &Report{}
+	root := &stateNode{
+		ID:          model.ID,
+		Type:        "parallel",
+		States:      map[string]*stateNode{},
+		Description: deref(model.Description),
+		Meta: withStateproMeta(model.Metadata, &stateproMeta{
+			CanonicalName: model.CanonicalName,
+			Version:       model.Version,
+			Initials:      model.Initials,
+		}),
+	}
+
+	if !convert.InitialsStartEveryUniverse(model) {
+		report.Add(LossInitials, "/initials",
+			"XState starts every region of a parallel state; statepro only starts %v (kept in meta.statepro.initials)", model.Initials)
+	}
+	if model.UniversalConstants != nil {
+		report.Add(LossConstants, "/universalConstants", "machine universal constants have no XState equivalent and are not exported")
+	}
+
+	for universeID, universe := range model.Universes {
+		if universe == nil {
+			continue
+		}
+		root.States[universeID] = exportUniverse(model.ID, universeID, universe, report)
+	}
+
+	sortLosses(report)
+	b, err := json.MarshalIndent(root, "", "  ")
+	if err != nil {
+		return nil, nil, fmt.Errorf("error marshaling xstate config: %w", err)
+	}
+	return b, report, nil
+}
+
+func exportUniverse(machineID, universeID string, universe *theoretical.UniverseModel, report *Report) *stateNode {
+	path := util.JSONPointer("universes", universeID)
+	sp := &stateproMeta{CanonicalName: universe.CanonicalName, Version: universe.Version}
+	node := &stateNode{States: map[string]*stateNode{}, Description: deref(universe.Description), Tags: universe.Tags}
+
+	if universe.Initial != nil {
+		node.Initial = *universe.Initial
+	} else {
+		sp.Superposition = true
+		report.Add(LossSuperposition, path,
+			"universe without initial reality starts in superposition; XState requires an initial state (kept in meta.statepro.superposition)")
+	}
+	if universe.UniversalConstants != nil {
+		report.Add(LossConstants, path+"/universalConstants", "universe universal constants have no XState equivalent and are not exported")
+	}
+	node.Meta = withStateproMeta(universe.Metadata, sp)
+
+	for realityID, reality := range universe.Realities {
+		if reality == nil {
+			continue
+		}
+		node.States[realityID] = exportReality(machineID, universeID, reality, path+util.JSONPointer("realities", realityID), report)
+	}
+	return node
+}
+
+func exportReality(machineID, universeID string, reality *theoretical.RealityModel, path string, report *Report) *stateNode {
+	sp := &stateproMeta{EntryInvokes: reality.EntryInvokes, ExitInvokes: reality.ExitInvokes, Observers: reality.Observers}
+	node := &stateNode{
+		Entry:       exportActions(reality.EntryActions),
+		Exit:        exportActions(reality.ExitActions),
+		Description: deref(reality.Description),
+	}
+	if theoretical.IsFinalState(reality.Type) {
+		node.Type = "final"
+	}
+	if reality.Type == theoretical.RealityTypeUnsuccessfulFinal {
+		sp.Type = string(reality.Type)
+	}
+	if len(reality.Observers) > 0 {
+		report.Add(LossObserver, path+"/observers", "observers have no XState equivalent (kept in meta.statepro.observers)")
+	}
+	if len(reality.EntryInvokes) > 0 || len(reality.ExitInvokes) > 0 {
+		report.Add(LossInvoke, path, "entry and exit invokes are not XState actions (kept in meta.statepro)")
+	}
+	node.Meta = withStateproMeta(reality.Metadata, sp)
+
+	var always []*transitionConfig
+	for i, transition := range reality.Always {
+		if transition != nil {
+			always = append(always, exportTransition(machineID, universeID, transition, path+util.JSONPointer("always", strconv.Itoa(i)), report))
+		}
+	}
+	if len(always) > 0 {
+		node.Always = always
+	}
+
+	for eventName, transitions := range reality.On {
+		var configs []*transitionConfig
+		for i, transition := range transitions {
+			if transition != nil {
+				configs = append(configs, exportTransition(machineID, universeID, transition, path+util.JSONPointer("on", eventName, strconv.Itoa(i)), report))
+			}
+		}
+		if node.On == nil {
+			node.On = map[string]any{}
+		}
+		node.On[eventName] = configs
+	}
+	return node
+}
+
+func exportTransition(machineID, universeID string, transition *theoretical.TransitionModel, path string, report *Report) *transitionConfig {
+	sp := &stateproMeta{Invokes: transition.Invokes}
+	config := &transitionConfig{
+		Actions:     exportActions(transition.Actions),
+		Description: deref(transition.Description),
+	}
+
+	var targets []string
+	superposition := len(transition.Targets) > 1
+	for _, ref := range transition.Targets {
+		targets = append(targets, exportTarget(machineID, ref))
+		parts := strings.SplitN(strings.TrimPrefix(ref, "U:"), ":", 2)
+		superposition = superposition || (strings.HasPrefix(ref, "U:") && len(parts) == 1)
+	}
+	if len(targets) == 1 {
+		config.Target = targets[0]
+	} else {
+		config.Target = targets
+	}
+
+	var conditions []*theoretical.ConditionModel
+	if transition.Condition != nil {
+		conditions = append(conditions, transition.Condition)
+	}
+	for _, condition := range transition.Conditions {
+		if condition != nil {
+			conditions = append(conditions, condition)
+		}
+	}
+	switch len(conditions) {
+	case 0:
+	case 1:
+		config.Guard = exportExecutor(conditions[0].Src, conditions[0].Args)
+	default:
+		// XState guards combining several conditions are functions, not JSON
+		config.Guard = exportExecutor(conditions[0].Src, conditions[0].Args)
+		sp.Conditions = conditions
+		report.Add(LossGuard, path, "XState takes a single guard; all %d conditions are kept in meta.statepro.conditions", len(conditions))
+	}
+
+	if transition.IsNotification() {
+		sp.Type = string(theoretical.TransitionTypeNotify)
+		report.Add(LossNotify, path, "notify transitions have no XState equivalent (kept in meta.statepro.type)")
+	} else if superposition {
+		report.Add(LossSuperposition, path,
+			"transition to %v puts the universe in superposition; XState takes it as a regular transition", transition.Targets)
+	}
+	if len(transition.Invokes) > 0 {
+		report.Add(LossInvoke, path+"/invokes", "transition invokes are not XState actions (kept in meta.statepro.invokes)")
+	}
+
+	config.Meta = withStateproMeta(transition.Metadata, sp)
+	return config
+}
+
+// exportTarget maps a statepro reference to an XState target: siblings by key, other universes by id.
+func exportTarget(machineID, ref string) string {
+	if !strings.HasPrefix(ref, "U:") {
+		return ref
+	}
+	return "#" + machineID + "." + strings.ReplaceAll(strings.TrimPrefix(ref, "U:"), ":", ".")
+}
+
+func exportActions(actions []*theoretical.ActionModel) any {
+	var out []any
+	for _, action := range actions {
+		if action != nil {
+			out = append(out, exportExecutor(action.Src, action.Args))
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+// exportExecutor writes a reference by type, as an object only when it has params.
+func exportExecutor(src string, args map[string]any) any {
+	if len(args) == 0 {
+		return src
+	}
+	return &executorConfig{Type: src, Params: args}
+}
+
+func sortLosses(report *Report) {
+	sort.SliceStable(report.Losses, func(i, j int) bool {
+		return report.Losses[i].Location < report.Losses[j].Location
+	})
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,481 @@
+package xstate
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/rendis/statepro/v3/internal/convert"
+	"github.com/rendis/statepro/v3/internal/util"
+	"github.com/rendis/statepro/v3/theoretical"
+)
+
+// defaultMachineID is the id XState gives to machines without one.
+const defaultMachineID = "(machine)"
+
+// Import converts an XState machine config (JSON) to a model.
+// Child states are read in key order, so a compound state without "initial" starts in its first state
+// by key. Non-final states without transitions are imported as final realities, since statepro requires a
+// way out of every transition reality; both cases are reported. Keys that sanitize to an id already taken in
+// the same scope are suffixed ("_2", "_3", ...) and reported too.
+//
+// The returned model is not validated; call statepro.ValidateQuantumMachineDefinition before loading it.
+func Import(data []byte) (*theoretical.QuantumMachineModel, *Report, error) {
+	root := &stateNode{}
+	if err := json.Unmarshal(data, root); err != nil {
+		return nil, nil, fmt.Errorf("error parsing xstate config: %w", err)
+	}
+	if len(root.States) == 0 {
+		return nil, nil, fmt.Errorf("xstate config must define states")
+	}
+
+	im := &importer{report: &Report{}, ids: map[string]stateRef{}}
+	model, err := im.importMachine(root)
+	if err != nil {
+		return nil, nil, err
+	}
+	sortLosses(im.report)
+	return model, im.report, nil
+}
+
+// stateRef is the statepro location of an XState state node.
+type stateRef struct {
+	universe string
+	// reality is empty when the node is a universe.
+	reality string
+}
+
+type importer struct {
+	report *Report
+	// ids maps the XState ids of every state node (default "<machine>.<path>" ids and explicit ids).
+	ids map[string]stateRef
+}
+
+// region is an XState state node imported as a universe.
+type region struct {
+	id   string
+	path string
+	node *stateNode
+	// realities are the child nodes by key; an atomic region is its own single reality.
+	realities map[string]*stateNode
+	// parentPath is the default id of the parent of the realities, used to resolve sibling targets.
+	parentPath string
+}
+
+func (im *importer) importMachine(root *stateNode) (*theoretical.QuantumMachineModel, error) {
+	xstateID := root.ID
+	if xstateID == "" {
+		xstateID = defaultMachineID
+	}
+
+	metadata, sp, err := splitMeta(root.Meta)
+	if err != nil {
+		return nil, err
+	}
+
+	machineID := im.identifier(strings.Trim(xstateID, "()"), xstateID)
+	model := &theoretical.QuantumMachineModel{
+		ID:            machineID,
+		CanonicalName: convert.FirstNonEmpty(sp.CanonicalName, machineID),
+		Version:       convert.FirstNonEmpty(sp.Version, convert.DefaultVersion),
+		Universes:     map[string]*theoretical.UniverseModel{},
+		Description:   optional(root.Description),
+		Metadata:      metadata,
+	}
+	im.reportUnsupported(root, xstateID)
+
+	var regions []*region
+	if root.Type == "parallel" {
+		universes := map[string]bool{}
+		for _, key := range util.SortedKeys(root.States) {
+			child := root.States[key]
+			if child == nil {
+				continue
+			}
+			path := xstateID + "." + key
+			r := &region{id: im.uniqueID(universes, im.identifier(key, path), path), path: path, node: child, realities: child.States, parentPath: path}
+			if len(child.States) == 0 {
+				// an atomic region is a universe with a single reality
+				r.realities = map[string]*stateNode{key: child}
+				r.parentPath = xstateID
+			}
+			regions = append(regions, r)
+		}
+	} else {
+		regions = append(regions, &region{id: machineID, path: xstateID, node: root, realities: root.States, parentPath: xstateID})
+	}
+
+	for _, r := range regions {
+		im.registerRegion(r)
+	}
+	for _, r := range regions {
+		universe, err := im.importUniverse(r, r.node == root)
+		if err != nil {
+			return nil, err
+		}
+		model.Universes[r.id] = universe
+	}
+
+	if len(sp.Initials) > 0 {
+		model.Initials = sp.Initials
+	} else {
+		for _, r := range regions {
+			model.Initials = append(model.Initials, "U:"+r.id)
+		}
+	}
+	return model, nil
+}
+
+// registerRegion registers the ids of the region and its realities, so targets can be resolved in any order.
+func (im *importer) registerRegion(r *region) {
+	if r.parentPath == r.path {
+		im.register(r.node, r.path, stateRef{universe: r.id})
+	}
+	taken := map[string]bool{}
+	for _, key := range util.SortedKeys(r.realities) {
+		if node := r.realities[key]; node != nil {
+			path := r.parentPath + "." + key
+			im.register(node, path, stateRef{universe: r.id, reality: im.uniqueID(taken, im.identifier(key, path), path)})
+		}
+	}
+}
+
+// uniqueID returns id, suffixed when another key of the same scope sanitized to it, and reserves it in taken.
+func (im *importer) uniqueID(taken map[string]bool, id, path string) string {
+	unique := id
+	for i := 2; taken[unique]; i++ {
+		unique = fmt.Sprintf("%s_%d", id, i)
+	}
+	if unique != id {
+		im.report.Add(LossIdentifier, path, "id '%s' is already taken, renamed to '%s'", id, unique)
+	}
+	taken[unique] = true
+	return unique
+}
+
+func (im *importer) register(node *stateNode, path string, ref stateRef) {
+	im.ids[path] = ref
+	if node.ID != "" {
+		im.ids[node.ID] = ref
+	}
+}
+
+func (im *importer) importUniverse(r *region, isRoot bool) (*theoretical.UniverseModel, error) {
+	universe := &theoretical.UniverseModel{
+		ID:        r.id,
+		Realities: map[string]*theoretical.RealityModel{},
+	}
+	if !isRoot {
+		metadata, sp, err := splitMeta(r.node.Meta)
+		if err != nil {
+			return nil, fmt.Errorf("state '%s': %w", r.path, err)
+		}
+		universe.CanonicalName = sp.CanonicalName
+		universe.Version = sp.Version
+		universe.Description = optional(r.node.Description)
+		universe.Metadata = metadata
+		universe.Tags = r.node.Tags
+		if sp.Superposition {
+			universe.Initial = nil
+		} else {
+			universe.Initial = im.universeInitial(r)
+		}
+		im.reportUnsupported(r.node, r.path)
+	} else {
+		universe.Initial = im.universeInitial(r)
+	}
+	universe.CanonicalName = convert.FirstNonEmpty(universe.CanonicalName, r.id)
+	universe.Version = convert.FirstNonEmpty(universe.Version, convert.DefaultVersion)
+
+	for _, key := range util.SortedKeys(r.realities) {
+		node := r.realities[key]
+		if node == nil {
+			continue
+		}
+		reality, err := im.importReality(r, r.parentPath+"."+key, node)
+		if err != nil {
+			return nil, err
+		}
+		universe.Realities[reality.ID] = reality
+	}
+	return universe, nil
+}
+
+func (im *importer) universeInitial(r *region) *string {
+	keys := util.SortedKeys(r.realities)
+	initial, _ := r.node.Initial.(string)
+	if len(r.node.States) == 0 {
+		initial = keys[0]
+	}
+	if initial == "" {
+		initial = keys[0]
+		im.report.Add(LossHierarchy, r.path, "state without initial, starting in '%s'", initial)
+	}
+	ref, ok := im.ids[r.parentPath+"."+initial]
+	if !ok {
+		im.report.Add(LossTarget, r.path, "initial '%s' is not a child state, starting in '%s'", initial, keys[0])
+		ref = im.ids[r.parentPath+"."+keys[0]]
+	}
+	return &ref.reality
+}
+
+func (im *importer) importReality(r *region, path string, node *stateNode) (*theoretical.RealityModel, error) {
+	metadata, sp, err := splitMeta(node.Meta)
+	if err != nil {
+		return nil, fmt.Errorf("state '%s': %w", path, err)
+	}
+
+	reality := &theoretical.RealityModel{
+		ID:           im.ids[path].reality,
+		Type:         theoretical.RealityTypeTransition,
+		Description:  optional(node.Description),
+		Metadata:     metadata,
+		Observers:    sp.Observers,
+		EntryInvokes: sp.EntryInvokes,
+		ExitInvokes:  sp.ExitInvokes,
+		EntryActions: im.importActions(node.Entry, path),
+		ExitActions:  im.importActions(node.Exit, path),
+	}
+	switch {
+	case node.Type == "final" && sp.Type == string(theoretical.RealityTypeUnsuccessfulFinal):
+		reality.Type = theoretical.RealityTypeUnsuccessfulFinal
+	case node.Type == "final":
+		reality.Type = theoretical.RealityTypeFinal
+	case node.Type == "history":
+		im.report.Add(LossHistory, path, "history states are not supported, imported as a regular reality")
+	}
+
+	if len(node.States) > 0 && node != r.node {
+		im.report.Add(LossHierarchy, path, "nested states %v are not supported, imported as an atomic reality", util.SortedKeys(node.States))
+	}
+	if len(node.Tags) > 0 {
+		im.report.Add(LossTags, path, "state tags are only kept on universes")
+	}
+	if node != r.node {
+		im.reportUnsupported(node, path)
+	}
+
+	for _, config := range im.transitionConfigs(node.Always, path) {
+		if transition := im.importTransition(r, config, path); transition != nil {
+			reality.Always = append(reality.Always, transition)
+		}
+	}
+
+	for _, descriptor := range util.SortedKeys(node.On) {
+		if descriptor == "*" || strings.HasSuffix(descriptor, ".*") {
+			im.report.Add(LossEvent, path, "wildcard event descriptor '%s' is not supported, ignored", descriptor)
+			continue
+		}
+		eventName := im.eventName(descriptor, path)
+		for _, config := range im.transitionConfigs(node.On[descriptor], path) {
+			if transition := im.importTransition(r, config, path); transition != nil {
+				if reality.On == nil {
+					reality.On = map[string][]*theoretical.TransitionModel{}
+				}
+				reality.On[eventName] = append(reality.On[eventName], transition)
+			}
+		}
+	}
+
+	if reality.Type == theoretical.RealityTypeTransition && len(reality.On) == 0 && len(reality.Always) == 0 {
+		reality.Type = theoretical.RealityTypeFinal
+		im.report.Add(LossDeadEnd, path, "state without transitions imported as a final reality")
+	}
+	return reality, nil
+}
+
+// transitionConfigs normalizes the target string, transition object and array forms.
+func (im *importer) transitionConfigs(value any, path string) []*transitionConfig {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case string:
+		return []*transitionConfig{{Target: v}}
+	case []any:
+		var configs []*transitionConfig
+		for _, item := range v {
+			configs = append(configs, im.transitionConfigs(item, path)...)
+		}
+		return configs
+	case map[string]any:
+		config := &transitionConfig{}
+		if err := decode(v, config); err != nil {
+			im.report.Add(LossTarget, path, "invalid transition config: %v", err)
+			return nil
+		}
+		for _, key := range []string{"reenter", "internal", "in"} {
+			if _, ok := v[key]; ok {
+				im.report.Add(LossTarget, path, "transition option '%s' is not supported", key)
+			}
+		}
+		return []*transitionConfig{config}
+	default:
+		im.report.Add(LossTarget, path, "unsupported transition config %v", v)
+		return nil
+	}
+}
+
+func (im *importer) importTransition(r *region, config *transitionConfig, path string) *theoretical.TransitionModel {
+	metadata, sp, err := splitMeta(config.Meta)
+	if err != nil {
+		im.report.Add(LossTarget, path, "%v", err)
+	}
+
+	transition := &theoretical.TransitionModel{
+		Actions:     im.importActions(config.Actions, path),
+		Invokes:     sp.Invokes,
+		Description: optional(config.Description),
+		Metadata:    metadata,
+	}
+
+	var targets []string
+	switch t := config.Target.(type) {
+	case string:
+		targets = []string{t}
+	case []any:
+		for _, item := range t {
+			if s, ok := item.(string); ok {
+				targets = append(targets, s)
+			}
+		}
+	}
+	for _, target := range targets {
+		if ref, ok := im.resolveTarget(r, target, path); ok {
+			transition.Targets = append(transition.Targets, ref)
+		}
+	}
+	if len(transition.Targets) == 0 {
+		if len(targets) == 0 {
+			im.report.Add(LossTargetlessTransition, path, "transition without target is not supported, ignored")
+		}
+		return nil
+	}
+
+	if sp.Type == string(theoretical.TransitionTypeNotify) {
+		notify := theoretical.TransitionTypeNotify
+		transition.Type = &notify
+	}
+
+	conditions := sp.Conditions
+	if len(conditions) == 0 {
+		guard := config.Guard
+		if guard == nil {
+			guard = config.Cond
+		}
+		if guard != nil {
+			if src, args, ok := im.executor(guard, path, LossGuard); ok {
+				conditions = append(conditions, &theoretical.ConditionModel{Src: src, Args: args})
+			}
+		}
+	}
+	if len(conditions) == 1 {
+		transition.Condition = conditions[0]
+	} else {
+		transition.Conditions = conditions
+	}
+	return transition
+}
+
+// resolveTarget resolves sibling ("key") and id ("#id", "#id.child") targets to a statepro reference.
+func (im *importer) resolveTarget(r *region, target, path string) (string, bool) {
+	var ref stateRef
+	var ok bool
+	switch {
+	case strings.HasPrefix(target, "#"):
+		ref, ok = im.ids[strings.TrimPrefix(target, "#")]
+	case strings.HasPrefix(target, "."):
+		im.report.Add(LossHierarchy, path, "child target '%s' is not supported, ignored", target)
+		return "", false
+	default:
+		ref, ok = im.ids[r.parentPath+"."+target]
+	}
+	if !ok {
+		im.report.Add(LossTarget, path, "target '%s' is not a universe or reality, ignored", target)
+		return "", false
+	}
+
+	switch {
+	case ref.reality == "":
+		return "U:" + ref.universe, true
+	case ref.universe == r.id:
+		return ref.reality, true
+	default:
+		return "U:" + ref.universe + ":" + ref.reality, true
+	}
+}
+
+func (im *importer) importActions(value any, path string) []*theoretical.ActionModel {
+	var items []any
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []any:
+		items = v
+	default:
+		items = []any{v}
+	}
+
+	var actions []*theoretical.ActionModel
+	for _, item := range items {
+		if src, args, ok := im.executor(item, path, LossAction); ok {
+			actions = append(actions, &theoretical.ActionModel{Src: src, Args: args})
+		}
+	}
+	return actions
+}
+
+// executor reads an action or guard given by type, either as a string or as {type, params}.
+func (im *importer) executor(value any, path string, kind LossKind) (string, map[string]any, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, nil, true
+	case map[string]any:
+		config := &executorConfig{}
+		if err := decode(v, config); err == nil && config.Type != "" {
+			if _, nested := v["guards"]; !nested {
+				return config.Type, config.Params, true
+			}
+		}
+	}
+	im.report.Add(kind, path, "%s %v is not a reference by type, ignored", kind, value)
+	return "", nil, false
+}
+
+func (im *importer) reportUnsupported(node *stateNode, path string) {
+	if node.After != nil {
+		im.report.Add(LossDelayedTransition, path, "delayed 'after' transitions are not supported")
+	}
+	if node.Invoke != nil {
+		im.report.Add(LossInvoke, path, "invoked actors are not supported")
+	}
+	if node.OnDone != nil {
+		im.report.Add(LossDoneEvent, path, "'onDone' transitions are not supported")
+	}
+	if node.Context != nil {
+		im.report.Add(LossContext, path, "machine context is not supported")
+	}
+}
+
+// identifier turns an XState key into a valid statepro identifier, reporting any change.
+func (im *importer) identifier(key, path string) string {
+	sanitized := util.SanitizeIdentifier(key)
+	if sanitized != key {
+		im.report.Add(LossIdentifier, path, "key '%s' renamed to '%s'", key, sanitized)
+	}
+	return sanitized
+}
+
+func (im *importer) eventName(descriptor, path string) string {
+	sanitized := util.SanitizeEventName(descriptor)
+	if sanitized != descriptor {
+		im.report.Add(LossEvent, path, "event '%s' renamed to '%s'", descriptor, sanitized)
+	}
+	return sanitized
+}
+
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
@@ -0,0 +1,159 @@
+// Package xstate converts quantum machine definitions to and from XState v5 machine configs (JSON).
+//
+// A root "parallel" state maps to the machine: its child states are universes and their child states are
+// realities. Any other root is imported as a single universe. "on" and "always" transitions, "entry"/"exit"
+// actions, guards and final states map one to one; actions and guards are referenced by their type, with
+// params carried as executor args.
+//
+// Constructs without a statepro equivalent (nested states, delayed transitions, invoked actors, context,
+// wildcard events, ...) are listed in the Report. Statepro-only data (observers, invokes, superposition,
+// notify transitions, initials, versions) is kept under meta.statepro so that a definition survives an
+// Export/Import round trip.
+package xstate
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/rendis/statepro/v3/internal/convert"
+	"github.com/rendis/statepro/v3/theoretical"
+)
+
+// LossKind identifies a construct that could not be converted faithfully.
+type LossKind = convert.LossKind
+
+const (
+	LossHierarchy            LossKind = "hierarchy"
+	LossDelayedTransition    LossKind = "delayed_transition"
+	LossInvoke               LossKind = "invoke"
+	LossContext              LossKind = "context"
+	LossHistory              LossKind = "history"
+	LossDoneEvent            LossKind = "done_event"
+	LossEvent                LossKind = "event"
+	LossTargetlessTransition LossKind = "targetless_transition"
+	LossTarget               LossKind = "target"
+	LossGuard                LossKind = "guard"
+	LossAction               LossKind = "action"
+	LossIdentifier           LossKind = "identifier"
+	LossDeadEnd              LossKind = "dead_end"
+	LossObserver             LossKind = "observer"
+	LossSuperposition        LossKind = "superposition"
+	LossNotify               LossKind = "notify"
+	LossInitials             LossKind = "initials"
+	LossConstants            LossKind = "universal_constants"
+	LossTags                 LossKind = "tags"
+)
+
+// Loss is a construct that has no equivalent in the target format.
+type Loss = convert.Loss
+
+// Report lists what a conversion could not carry over.
+type Report = convert.Report
+
+// stateNode is an XState state node config.
+type stateNode struct {
+	ID          string                `json:"id,omitempty"`
+	Type        string                `json:"type,omitempty"`
+	Initial     any                   `json:"initial,omitempty"`
+	States      map[string]*stateNode `json:"states,omitempty"`
+	On          map[string]any        `json:"on,omitempty"`
+	Always      any                   `json:"always,omitempty"`
+	Entry       any                   `json:"entry,omitempty"`
+	Exit        any                   `json:"exit,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Meta        map[string]any        `json:"meta,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+
+	// constructs without statepro equivalent, only read to report them
+	After   any `json:"after,omitempty"`
+	Invoke  any `json:"invoke,omitempty"`
+	OnDone  any `json:"onDone,omitempty"`
+	Context any `json:"context,omitempty"`
+	History any `json:"history,omitempty"`
+}
+
+// transitionConfig is an XState transition config.
+type transitionConfig struct {
+	Target      any            `json:"target,omitempty"`
+	Guard       any            `json:"guard,omitempty"`
+	Actions     any            `json:"actions,omitempty"`
+	Description string         `json:"description,omitempty"`
+	Meta        map[string]any `json:"meta,omitempty"`
+
+	// Cond is the XState v4 name of Guard.
+	Cond any `json:"cond,omitempty"`
+}
+
+// executorConfig is the object form of an XState action or guard reference.
+type executorConfig struct {
+	Type   string         `json:"type"`
+	Params map[string]any `json:"params,omitempty"`
+}
+
+// stateproMeta is the statepro-only data kept under meta.statepro.
+type stateproMeta struct {
+	CanonicalName string                        `json:"canonicalName,omitempty"`
+	Version       string                        `json:"version,omitempty"`
+	Initials      []string                      `json:"initials,omitempty"`
+	Superposition bool                          `json:"superposition,omitempty"`
+	Type          string                        `json:"type,omitempty"`
+	Observers     []*theoretical.ObserverModel  `json:"observers,omitempty"`
+	EntryInvokes  []*theoretical.InvokeModel    `json:"entryInvokes,omitempty"`
+	ExitInvokes   []*theoretical.InvokeModel    `json:"exitInvokes,omitempty"`
+	Invokes       []*theoretical.InvokeModel    `json:"invokes,omitempty"`
+	Conditions    []*theoretical.ConditionModel `json:"conditions,omitempty"`
+}
+
+const stateproMetaKey = "statepro"
+
+func (m *stateproMeta) isEmpty() bool {
+	b, _ := json.Marshal(m)
+	return string(b) == "{}"
+}
+
+// withStateproMeta returns metadata plus the statepro meta, or nil when both are empty.
+func withStateproMeta(metadata map[string]any, sp *stateproMeta) map[string]any {
+	if len(metadata) == 0 && sp.isEmpty() {
+		return nil
+	}
+	meta := map[string]any{}
+	for k, v := range metadata {
+		meta[k] = v
+	}
+	if !sp.isEmpty() {
+		meta[stateproMetaKey] = sp
+	}
+	return meta
+}
+
+// splitMeta separates the statepro meta from the user metadata.
+func splitMeta(meta map[string]any) (map[string]any, *stateproMeta, error) {
+	sp := &stateproMeta{}
+	if len(meta) == 0 {
+		return nil, sp, nil
+	}
+	metadata := map[string]any{}
+	for k, v := range meta {
+		if k != stateproMetaKey {
+			metadata[k] = v
+		}
+	}
+	if raw, ok := meta[stateproMetaKey]; ok {
+		if err := decode(raw, sp); err != nil {
+			return nil, nil, fmt.Errorf("invalid meta.statepro: %w", err)
+		}
+	}
+	if len(metadata) == 0 {
+		metadata = nil
+	}
+	return metadata, sp, nil
+}
+
+// decode converts a generic JSON value into out via a JSON round-trip.
+func decode(v any, out any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, out)
+}
@@ -0,0 +1,260 @@
+package xstate
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rendis/statepro/v3"
+	"github.com/rendis/statepro/v3/internal/convert/converttest"
+	"github.com/rendis/statepro/v3/internal/util"
+	"github.com/rendis/statepro/v3/theoretical"
+)
+
+// xstateModel returns the shared converter machine plus the description, tags and metadata XState keeps.
+func xstateModel(t *testing.T) *theoretical.QuantumMachineModel {
+	t.Helper()
+	model := converttest.Model(t)
+	description := "order flow"
+	model.Description = &description
+	model.Universes["main"].Tags = []string{"core"}
+	model.Universes["main"].Realities["B"].Metadata = map[string]any{"owner": "team"}
+	return model
+}
+
+func TestExport(t *testing.T) {
+	out, report, err := Export(xstateModel(t))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	config := map[string]any{}
+	if err := json.Unmarshal(out, &config); err != nil {
+		t.Fatalf("expected a JSON config: %v", err)
+	}
+	if config["type"] != "parallel" || config["id"] != "machine" {
+		t.Fatalf("expected a parallel root, got %v", config)
+	}
+
+	doc := string(out)
+	for _, want := range []string{
+		`"initial": "A"`,
+		`"target": "#machine.side"`,
+		`"#machine.side.S",`,
+		`"guard": "condition:skip"`,
+		`"type": "action:log"`,
+		`"superposition": true`,
+		`"type": "unsuccessfulFinal"`,
+		`"owner": "team"`,
+	} {
+		if !strings.Contains(doc, want) {
+			t.Fatalf("expected config to contain %q, got:\n%s", want, doc)
+		}
+	}
+
+	kinds := converttest.LossKinds(report)
+	for _, kind := range []LossKind{LossObserver, LossNotify, LossInitials, LossGuard, LossInvoke, LossSuperposition} {
+		if kinds[kind] == 0 {
+			t.Fatalf("expected a %s loss, got %v", kind, report.Losses)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	model := xstateModel(t)
+	out, _, err := Export(model)
+	if err != nil {
+		t.Fatalf("unexpected export error: %v", err)
+	}
+
+	imported, report, err := Import(out)
+	if err != nil {
+		t.Fatalf("unexpected import error: %v", err)
+	}
+	if report.HasLosses() {
+		t.Fatalf("expected lossless import, got %v", report.Losses)
+	}
+	if err := statepro.ValidateQuantumMachineDefinition(imported); err != nil {
+		t.Fatalf("expected a valid definition, got %v", err)
+	}
+
+	expected, _ := json.Marshal(model)
+	actual, _ := json.Marshal(imported)
+	var expectedMap, actualMap map[string]any
+	_ = json.Unmarshal(expected, &expectedMap)
+	_ = json.Unmarshal(actual, &actualMap)
+	if !reflect.DeepEqual(expectedMap, actualMap) {
+		t.Fatalf("round trip mismatch:\nexpected %s\nactual   %s", expected, actual)
+	}
+}
+
+func TestImportXStateConfig(t *testing.T) {
+	config := `{
+		"id": "checkout",
+		"type": "parallel",
+		"context": {"items": 0},
+		"states": {
+			"payment": {
+				"initial": "idle",
+				"states": {
+					"idle": {
+						"on": {
+							"PAY": {"target": "processing", "guard": {"type": "hasItems", "params": {"min": 1}}, "actions": ["track", {"type": "notify", "params": {"channel": "email"}}]},
+							"*": "idle"
+						}
+					},
+					"processing": {
+						"entry": "charge",
+						"invoke": {"src": "paymentService"},
+						"after": {"5000": "failed"},
+						"on": {
+							"payment.ok": {"target": ["paid", "#checkout.shipping.ready"]},
+							"RETRY": {"actions": "log"}
+						}
+					},
+					"paid": {"type": "final"},
+					"failed": {"type": "final"}
+				}
+			},
+			"shipping": {
+				"initial": "waiting",
+				"states": {
+					"waiting": {"always": [{"target": "ready", "cond": "addressKnown"}]},
+					"ready": {"states": {"a": {}}, "on": {"SHIP": ".a"}},
+					"sent": {}
+				}
+			}
+		}
+	}`
+
+	model, report, err := Import([]byte(config))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := statepro.ValidateQuantumMachineDefinition(model); err != nil {
+		t.Fatalf("expected a valid definition, got %v", err)
+	}
+
+	if !reflect.DeepEqual(model.Initials, []string{"U:payment", "U:shipping"}) {
+		t.Fatalf("unexpected initials %v", model.Initials)
+	}
+
+	idle := model.Universes["payment"].Realities["idle"]
+	pay := idle.On["PAY"][0]
+	if pay.Targets[0] != "processing" || pay.Condition == nil || pay.Condition.Src != "hasItems" || pay.Condition.Args["min"] != float64(1) {
+		t.Fatalf("unexpected PAY transition %+v", pay)
+	}
+	if len(pay.Actions) != 2 || pay.Actions[1].Src != "notify" || pay.Actions[1].Args["channel"] != "email" {
+		t.Fatalf("unexpected PAY actions %+v", pay.Actions)
+	}
+
+	processing := model.Universes["payment"].Realities["processing"]
+	if !reflect.DeepEqual(processing.On["payment_ok"][0].Targets, []string{"paid", "U:shipping:ready"}) {
+		t.Fatalf("unexpected targets %v", processing.On["payment_ok"][0].Targets)
+	}
+	if processing.EntryActions[0].Src != "charge" {
+		t.Fatalf("unexpected entry actions %+v", processing.EntryActions)
+	}
+
+	waiting := model.Universes["shipping"].Realities["waiting"]
+	if waiting.Always[0].Condition == nil || waiting.Always[0].Condition.Src != "addressKnown" {
+		t.Fatalf("expected v4 cond to be imported as condition, got %+v", waiting.Always[0])
+	}
+	if model.Universes["shipping"].Realities["sent"].Type != theoretical.RealityTypeFinal {
+		t.Fatal("expected state without transitions to be imported as final")
+	}
+
+	kinds := converttest.LossKinds(report)
+	for _, kind := range []LossKind{LossContext, LossInvoke, LossDelayedTransition, LossEvent, LossTargetlessTransition, LossHierarchy, LossDeadEnd} {
+		if kinds[kind] == 0 {
+			t.Fatalf("expected a %s loss, got %v", kind, report.Losses)
+		}
+	}
+}
+
+func TestImportSingleUniverse(t *testing.T) {
+	config := `{"initial": "off", "states": {"off": {"on": {"TOGGLE": "on"}}, "on": {"on": {"TOGGLE": "off"}}}}`
+
+	model, _, err := Import([]byte(config))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	universe := model.Universes["machine"]
+	if model.ID != "machine" || universe == nil || *universe.Initial != "off" {
+		t.Fatalf("expected a single universe 'machine' starting in 'off', got %+v", model)
+	}
+	if err := statepro.ValidateQuantumMachineDefinition(model); err != nil {
+		t.Fatalf("expected a valid definition, got %v", err)
+	}
+}
+
+func TestImportDuplicateIdentifiers(t *testing.T) {
+	config := `{
+		"type": "parallel",
+		"states": {
+			"left side": {"initial": "a b", "states": {"a b": {"on": {"GO": "a/b"}}, "a/b": {"on": {"GO": "a_b"}}, "a_b": {"type": "final"}}},
+			"left_side": {"initial": "x", "states": {"x": {"type": "final"}}}
+		}
+	}`
+
+	model, report, err := Import([]byte(config))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := statepro.ValidateQuantumMachineDefinition(model); err != nil {
+		t.Fatalf("expected a valid definition, got %v", err)
+	}
+	if len(model.Universes) != 2 || model.Universes["left_side"] == nil || model.Universes["left_side_2"] == nil {
+		t.Fatalf("expected both regions to be kept, got %v", util.SortedKeys(model.Universes))
+	}
+
+	// every state is kept and transitions follow the renamed ids
+	realities := model.Universes["left_side"].Realities
+	if !reflect.DeepEqual(util.SortedKeys(realities), []string{"a_b", "a_b_2", "a_b_3"}) {
+		t.Fatalf("expected the colliding states to be kept, got %v", util.SortedKeys(realities))
+	}
+	if realities["a_b"].On["GO"][0].Targets[0] != "a_b_2" || realities["a_b_2"].On["GO"][0].Targets[0] != "a_b_3" ||
+		realities["a_b_3"].Type != theoretical.RealityTypeFinal {
+		t.Fatalf("unexpected realities %+v", realities)
+	}
+
+	var collisions int
+	for _, loss := range report.Losses {
+		if loss.Kind == LossIdentifier && strings.Contains(loss.Message, "already taken") {
+			collisions++
+		}
+	}
+	if collisions != 3 {
+		t.Fatalf("expected 3 reported collisions, got %v", report.Losses)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	if _, _, err := Export(nil); err == nil {
+		t.Fatal("expected error for nil model")
+	}
+	if _, _, err := Import([]byte(`{"id":"empty"}`)); err == nil {
+		t.Fatal("expected error for config without states")
+	}
+	if _, _, err := Import([]byte(`{`)); err == nil {
+		t.Fatal("expected error for malformed config")
+	}
+}
+
+func TestExportNilConditions(t *testing.T) {
+	model := xstateModel(t)
+	transition := model.Universes["main"].Realities["A"].On["go"][0]
+	transition.Conditions = []*theoretical.ConditionModel{nil, {Src: "condition:a"}}
+
+	out, report, err := Export(model)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(string(out), `"guard": "condition:a"`) {
+		t.Fatalf("expected the non-nil condition to be the guard:\n%s", out)
+	}
+	if converttest.LossKinds(report)[LossGuard] != 0 {
+		t.Fatalf("expected a single guard without losses, got %v", report.Losses)
+	}
+}
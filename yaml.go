@@ -0,0 +1,123 @@
+package statepro
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/rendis/statepro/v3/internal/util"
+)
+
+// yamlPosition is the line and column (1-based) of a value in a YAML document.
+type yamlPosition struct {
+	line   int
+	column int
+}
+
+// yamlDocument is a parsed YAML definition: its JSON compatible payload and the position of every value,
+// keyed by JSON Pointer.
+type yamlDocument struct {
+	payload   any
+	positions map[string]yamlPosition
+}
+
+func parseYAMLDefinition(source []byte) (*yamlDocument, error) {
+	if len(source) == 0 {
+		return nil, fmt.Errorf("source payload cannot be empty")
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(source, &root); err != nil {
+		return nil, fmt.Errorf("invalid yaml payload: %w", err)
+	}
+	if len(root.Content) == 0 {
+		return nil, fmt.Errorf("source payload cannot be empty")
+	}
+
+	var raw any
+	if err := root.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("invalid yaml payload: %w", err)
+	}
+
+	// a JSON round-trip gives the same value types as JSON input (float64 numbers, map[string]any objects)
+	b, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("yaml payload is not representable as json: %w", err)
+	}
+	var payload any
+	if err = json.Unmarshal(b, &payload); err != nil {
+		return nil, fmt.Errorf("yaml payload is not representable as json: %w", err)
+	}
+
+	doc := &yamlDocument{payload: payload, positions: map[string]yamlPosition{}}
+	doc.index(root.Content[0], "")
+	return doc, nil
+}
+
+func (d *yamlDocument) index(node *yaml.Node, pointer string) {
+	if node.Kind == yaml.AliasNode && node.Alias != nil {
+		d.positions[pointer] = yamlPosition{line: node.Line, column: node.Column}
+		d.index(node.Alias, pointer)
+		return
+	}
+	if _, ok := d.positions[pointer]; !ok {
+		d.positions[pointer] = yamlPosition{line: node.Line, column: node.Column}
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			d.index(node.Content[i+1], pointer+util.JSONPointer(node.Content[i].Value))
+		}
+	case yaml.SequenceNode:
+		for i, child := range node.Content {
+			d.index(child, fmt.Sprintf("%s/%d", pointer, i))
+		}
+	}
+}
+
+// position returns the position of the value at pointer, or of its closest existing ancestor
+// (e.g. the object missing a required property).
+func (d *yamlDocument) position(pointer string) yamlPosition {
+	for {
+		if pos, ok := d.positions[pointer]; ok {
+			return pos
+		}
+		if pointer == "" {
+			return yamlPosition{}
+		}
+		cut := len(pointer) - 1
+		for cut > 0 && pointer[cut] != '/' {
+			cut--
+		}
+		pointer = pointer[:cut]
+	}
+}
+
+// annotateIssue sets the YAML position of the value of the issue.
+func (d *yamlDocument) annotateIssue(issue *ValidationIssue) {
+	pos := d.position(issue.Path)
+	issue.Line, issue.Column = pos.line, pos.column
+}
+
+// resetYAMLStyle switches a node decoded from JSON (flow style, quoted strings) to block style.
+// Strings read as booleans by YAML 1.1 parsers ("on", "yes", ...) stay quoted.
+func resetYAMLStyle(node *yaml.Node) {
+	node.Style = 0
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!str" && isYAML11Bool(node.Value) {
+		node.Style = yaml.DoubleQuotedStyle
+	}
+	for _, child := range node.Content {
+		resetYAMLStyle(child)
+	}
+}
+
+func isYAML11Bool(s string) bool {
+	switch strings.ToLower(s) {
+	case "y", "yes", "n", "no", "on", "off":
+		return true
+	}
+	return false
+}
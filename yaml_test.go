@@ -0,0 +1,147 @@
+package statepro
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const yamlMachine = `# order machine, edited by ops
+id: order
+canonicalName: order
+version: 1.0.0
+initials:
+  - U:main
+universes:
+  main:
+    id: main
+    canonicalName: main
+    version: 1.0.0
+    initial: CREATED
+    realities:
+      CREATED:
+        id: CREATED
+        type: transition
+        on:
+          pay:
+            - targets: [PAID]
+              actions:
+                - src: action:charge
+                  args:
+                    retries: 3 # numbers decode as with JSON
+      PAID:
+        id: PAID
+        type: final
+`
+
+func TestYAMLRoundTrip(t *testing.T) {
+	model, err := DeserializeQuantumMachineFromYAML([]byte(yamlMachine))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err = ValidateQuantumMachineDefinition(model); err != nil {
+		t.Fatalf("expected valid definition, got %v", err)
+	}
+
+	args := model.Universes["main"].Realities["CREATED"].On["pay"][0].Actions[0].Args
+	if args["retries"] != float64(3) {
+		t.Fatalf("expected JSON number types, got %T", args["retries"])
+	}
+
+	out, err := SerializeQuantumMachineToYAML(model)
+	if err != nil {
+		t.Fatalf("unexpected serialize error: %v", err)
+	}
+	if !strings.HasPrefix(string(out), "id: order\ncanonicalName: order\n") || !strings.Contains(string(out), `"on":`) {
+		t.Fatalf("expected model field order and quoted 'on' key, got:\n%s", out)
+	}
+
+	again, err := DeserializeQuantumMachineFromYAML(out)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(model, again) {
+		t.Fatalf("round trip mismatch:\n%s", out)
+	}
+}
+
+func TestSerializeQuantumMachineToYAML_MatchesJSON(t *testing.T) {
+	b, err := os.ReadFile("example/cli/state_machine.json")
+	if err != nil {
+		t.Fatalf("error reading fixture: %v", err)
+	}
+	model, err := DeserializeQuantumMachineFromBinary(b)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	out, err := SerializeQuantumMachineToYAML(model)
+	if err != nil {
+		t.Fatalf("unexpected serialize error: %v", err)
+	}
+	if err = ValidateQuantumMachineDefinitionFromYAML(out); err != nil {
+		t.Fatalf("expected serialized fixture to validate, got %v", err)
+	}
+
+	fromYAML, err := DeserializeQuantumMachineFromYAML(out)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected, _ := json.Marshal(model)
+	actual, _ := json.Marshal(fromYAML)
+	if string(expected) != string(actual) {
+		t.Fatal("expected YAML and JSON serializations to hold the same definition")
+	}
+}
+
+func TestValidateQuantumMachineDefinitionFromYAML_Positions(t *testing.T) {
+	source := strings.Replace(yamlMachine, "targets: [PAID]", "targets: [PAID, MISSING]", 1)
+
+	err := ValidateQuantumMachineDefinitionFromYAML([]byte(source))
+	validationErr, ok := AsValidationError(err)
+	if !ok {
+		t.Fatalf("expected ValidationError, got %v", err)
+	}
+
+	issue := validationErr.Issues[0]
+	if issue.Code != IssueUnknownReality || issue.Line != 19 || issue.Column != 31 {
+		t.Fatalf("expected unknown reality at line 19, column 31, got %+v", issue)
+	}
+	if !strings.Contains(err.Error(), "line 19, column 31: ") {
+		t.Fatalf("expected position in error message, got %v", err)
+	}
+}
+
+func TestValidateQuantumMachineBySchemaFromYAML_Positions(t *testing.T) {
+	// a missing required property is reported on the object that lacks it
+	source := strings.Replace(yamlMachine, "        type: final\n", "", 1)
+
+	err := ValidateQuantumMachineBySchemaFromYAML([]byte(source))
+	validationErr, ok := AsValidationError(err)
+	if !ok {
+		t.Fatalf("expected ValidationError, got %v", err)
+	}
+	for _, issue := range validationErr.Issues {
+		if issue.Path == "/universes/main/realities/PAID" && issue.Line == 25 && issue.Column == 9 {
+			if !strings.Contains(err.Error(), "line 25, column 9: ") {
+				t.Fatalf("expected position in error message, got %v", err)
+			}
+			return
+		}
+	}
+	t.Fatalf("expected an issue on PAID at line 25, column 9, got %+v", validationErr.Issues)
+}
+
+func TestYAMLInvalidPayload(t *testing.T) {
+	if _, err := DeserializeQuantumMachineFromYAML([]byte("id: [unclosed")); err == nil {
+		t.Fatal("expected yaml syntax error")
+	}
+	if err := ValidateQuantumMachineDefinitionFromYAML(nil); err == nil {
+		t.Fatal("expected error for empty payload")
+	}
+	if err := ValidateQuantumMachineBySchemaFromYAML([]byte("- a\n- b\n")); err == nil {
+		t.Fatal("expected schema error for non mapping payload")
+	}
+}